@@ -1,6 +1,6 @@
 module github.com/openfaas/faas/gateway
 
-go 1.20
+go 1.21
 
 require (
 	github.com/docker/distribution v2.8.3+incompatible
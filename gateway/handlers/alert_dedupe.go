@@ -0,0 +1,63 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// alertDeduplicator remembers the alerts claimed within a window, so that
+// the same notification from several Alertmanager replicas is applied once
+type alertDeduplicator struct {
+	window time.Duration
+
+	lock    sync.Mutex
+	claimed map[string]time.Time
+}
+
+func newAlertDeduplicator(window time.Duration) *alertDeduplicator {
+	return &alertDeduplicator{
+		window:  window,
+		claimed: make(map[string]time.Time),
+	}
+}
+
+// claim is true when key was not claimed within the window, and marks it
+// as claimed at now. The check and the mark are made under one lock, so
+// that only one of several concurrent notifications of an alert applies
+// it. Every key is claimed when deduplication is disabled.
+func (d *alertDeduplicator) claim(key string, now time.Time) bool {
+	if d == nil || d.window <= 0 || len(key) == 0 {
+		return true
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for k, at := range d.claimed {
+		if now.Sub(at) >= d.window {
+			delete(d.claimed, k)
+		}
+	}
+
+	if _, ok := d.claimed[key]; ok {
+		return false
+	}
+	d.claimed[key] = now
+	return true
+}
+
+// release forgets a claim on key, so that the alert is applied again when
+// Alertmanager retries it
+func (d *alertDeduplicator) release(key string) {
+	if d == nil || d.window <= 0 || len(key) == 0 {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.claimed, key)
+}
@@ -5,19 +5,101 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/openfaas/faas/gateway/pkg/middleware"
 	"github.com/openfaas/faas/gateway/requests"
 	"github.com/openfaas/faas/gateway/scaling"
 )
 
-// MakeAlertHandler handles alerts from Prometheus Alertmanager
-func MakeAlertHandler(service scaling.ServiceQuery, defaultNamespace string) http.HandlerFunc {
+// Annotations read from each alert
+const (
+	// ScaleActionAnnotation sets the action taken for a firing alert, one
+	// of the ScaleAction constants, the default is ScaleActionUp
+	ScaleActionAnnotation = "scale_action"
+
+	// ScaleReplicasAnnotation sets the replicas for ScaleActionSet
+	ScaleReplicasAnnotation = "scale_replicas"
+)
+
+// Scaling actions for ScaleActionAnnotation
+const (
+	// ScaleActionUp adds one step of the function's scaling factor
+	ScaleActionUp = "up"
+
+	// ScaleActionDown removes one step of the function's scaling factor
+	ScaleActionDown = "down"
+
+	// ScaleActionMin scales to the function's min replicas, which is
+	// always the action for a resolved alert
+	ScaleActionMin = "min"
+
+	// ScaleActionMax scales to the function's max replicas
+	ScaleActionMax = "max"
+
+	// ScaleActionSet scales to the replicas in ScaleReplicasAnnotation
+	ScaleActionSet = "set"
+
+	// ScaleActionNone records the alert without scaling the function
+	ScaleActionNone = "none"
+)
+
+// Results for each alert in an AlertResponse
+const (
+	AlertScaled    = "scaled"
+	AlertUnchanged = "unchanged"
+	AlertHeld      = "held"
+	AlertDuplicate = "duplicate"
+	AlertIgnored   = "ignored"
+	AlertInvalid   = "invalid"
+	AlertNotFound  = "not_found"
+	AlertError     = "error"
+)
+
+// AlertResult is the outcome of a single alert, alerts for the same
+// function share the decision made for the function
+type AlertResult struct {
+	Fingerprint string `json:"fingerprint,omitempty"`
+	AlertName   string `json:"alertname,omitempty"`
+	Function    string `json:"function,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Status      string `json:"status"`
+	Action      string `json:"action,omitempty"`
+
+	// Result is one of the Alert result constants i.e. AlertScaled
+	Result string `json:"result"`
+
+	CurrentReplicas uint64 `json:"currentReplicas,omitempty"`
+	TargetReplicas  uint64 `json:"targetReplicas,omitempty"`
+
+	// Reason explains why a decision was held back
+	Reason string `json:"reason,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// AlertResponse is returned by the alert handler
+type AlertResponse struct {
+	Results []AlertResult `json:"results"`
+}
+
+// MakeAlertHandler handles alerts from Prometheus Alertmanager, the replicas
+// recommended by each alert are applied through the stabilizer, so that the
+// ScalingPolicy of each function is respected.
+//
+// A notification for an alert already handled within dedupeWindow, with the
+// same fingerprint, status and start time, is not applied again, so that
+// Alertmanager replicas in a cluster do not scale a function once each.
+func MakeAlertHandler(stabilizer *scaling.Stabilizer, dedupeWindow time.Duration, defaultNamespace string) http.HandlerFunc {
+	deduplicator := newAlertDeduplicator(dedupeWindow)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		if r.Body == nil {
@@ -32,7 +114,7 @@ func MakeAlertHandler(service scaling.ServiceQuery, defaultNamespace string) htt
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("Unable to read alert."))
 
-			log.Println(err)
+			alertsLogger.ErrorContext(r.Context(), "unable to read alert", "error", err)
 			return
 		}
 
@@ -40,69 +122,287 @@ func MakeAlertHandler(service scaling.ServiceQuery, defaultNamespace string) htt
 		if err := json.Unmarshal(body, &req); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("Unable to parse alert, bad format."))
-			log.Println(err)
+			alertsLogger.WarnContext(r.Context(), "unable to parse alert", "error", err)
 			return
 		}
 
-		errors := handleAlerts(req, service, defaultNamespace)
-		if len(errors) > 0 {
-			log.Println(errors)
-			var errorOutput string
-			for d, err := range errors {
-				errorOutput += fmt.Sprintf("[%d] %s\n", d, err)
+		results := handleAlerts(req, stabilizer, deduplicator, defaultNamespace, time.Now())
+
+		// Alertmanager retries the notification when any alert failed
+		statusCode := http.StatusOK
+		for _, result := range results {
+			if result.Result == AlertError {
+				statusCode = http.StatusInternalServerError
 			}
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(errorOutput))
-			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		out, _ := json.Marshal(AlertResponse{Results: results})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		w.Write(out)
 	}
 }
 
-func handleAlerts(req requests.PrometheusAlert, service scaling.ServiceQuery, defaultNamespace string) []error {
-	var errors []error
-	for _, alert := range req.Alerts {
-		if err := scaleService(alert, service, defaultNamespace); err != nil {
-			log.Println(err)
-			errors = append(errors, err)
+// functionAlert is the combined status of the alerts for one function
+type functionAlert struct {
+	name      string
+	namespace string
+	status    string
+
+	// alerts are the indexes of the function's alerts in the results
+	alerts []int
+
+	// actions are the actions of the alerts which take part in the decision
+	actions []alertAction
+}
+
+type alertAction struct {
+	status   string
+	action   string
+	replicas uint64
+}
+
+// alertKey identifies a notification of an alert for deduplication, alerts
+// without a fingerprint are never duplicates
+func alertKey(alert requests.PrometheusInnerAlert) string {
+	if len(alert.Fingerprint) == 0 {
+		return ""
+	}
+	return alert.Fingerprint + "|" + alert.Status + "|" + alert.StartsAt.UTC().Format(time.RFC3339Nano)
+}
+
+// alertFunction returns the function an alert is for, the namespace label
+// takes precedence over a namespace in the function_name label
+func alertFunction(alert requests.PrometheusInnerAlert, defaultNamespace string) (string, string) {
+	name, namespace := middleware.GetNamespace(defaultNamespace, alert.Labels.FunctionName)
+	if len(alert.Labels.Namespace) > 0 {
+		namespace = alert.Labels.Namespace
+	}
+	return name, namespace
+}
+
+// parseAction reads the action for an alert from its annotations
+func parseAction(alert requests.PrometheusInnerAlert) (alertAction, error) {
+	action := alertAction{status: alert.Status, action: ScaleActionMin}
+	if alert.Status != "firing" {
+		return action, nil
+	}
+
+	action.action = ScaleActionUp
+	if v := alert.Annotations[ScaleActionAnnotation]; len(v) > 0 {
+		action.action = v
+	}
+
+	switch action.action {
+	case ScaleActionUp, ScaleActionDown, ScaleActionMin, ScaleActionMax, ScaleActionNone:
+	case ScaleActionSet:
+		replicas, err := strconv.ParseUint(alert.Annotations[ScaleReplicasAnnotation], 10, 64)
+		if err != nil {
+			return action, fmt.Errorf("invalid value for %s: %q", ScaleReplicasAnnotation, alert.Annotations[ScaleReplicasAnnotation])
 		}
+		action.replicas = replicas
+	default:
+		return action, fmt.Errorf("unknown %s: %q", ScaleActionAnnotation, action.action)
 	}
 
-	return errors
+	return action, nil
 }
 
-func scaleService(alert requests.PrometheusInnerAlert, service scaling.ServiceQuery, defaultNamespace string) error {
-	var err error
+// groupAlerts combines the alerts in a batch so that each function is
+// scaled once, a function is firing if any of its alerts is firing. The
+// result of each alert which does not take part in a decision is set.
+func groupAlerts(alerts []requests.PrometheusInnerAlert, results []AlertResult, deduplicator *alertDeduplicator, defaultNamespace string, now time.Time) []*functionAlert {
+	grouped := []*functionAlert{}
+	index := map[string]*functionAlert{}
+	batch := map[string]bool{}
+
+	for i, alert := range alerts {
+		serviceName, namespace := alertFunction(alert, defaultNamespace)
+		results[i] = AlertResult{
+			Fingerprint: alert.Fingerprint,
+			AlertName:   alert.Labels.AlertName,
+			Function:    serviceName,
+			Namespace:   namespace,
+			Status:      alert.Status,
+		}
 
-	serviceName, namespace := middleware.GetNamespace(defaultNamespace, alert.Labels.FunctionName)
+		if len(serviceName) == 0 {
+			results[i].Result = AlertIgnored
+			results[i].Error = "no function_name label"
+			continue
+		}
 
-	if len(serviceName) > 0 {
-		queryResponse, getErr := service.GetReplicas(serviceName, namespace)
-		if getErr == nil {
-			status := alert.Status
+		dedupeKey := alertKey(alert)
+		if len(dedupeKey) > 0 {
+			if batch[dedupeKey] || !deduplicator.claim(dedupeKey, now) {
+				results[i].Result = AlertDuplicate
+				continue
+			}
+			batch[dedupeKey] = true
+		}
 
-			newReplicas := CalculateReplicas(status, queryResponse.Replicas, uint64(queryResponse.MaxReplicas), queryResponse.MinReplicas, queryResponse.ScalingFactor)
+		action, err := parseAction(alert)
+		results[i].Action = action.action
+		if err != nil {
+			deduplicator.release(dedupeKey)
+			results[i].Result = AlertInvalid
+			results[i].Error = err.Error()
+			continue
+		}
 
-			log.Printf("[Scale] function=%s %d => %d.\n", serviceName, queryResponse.Replicas, newReplicas)
-			if newReplicas == queryResponse.Replicas {
-				return nil
+		if action.action == ScaleActionNone {
+			deduplicator.release(dedupeKey)
+			results[i].Result = AlertIgnored
+			continue
+		}
+
+		key := serviceName + "." + namespace
+		group, ok := index[key]
+		if !ok {
+			group = &functionAlert{name: serviceName, namespace: namespace, status: alert.Status}
+			index[key] = group
+			grouped = append(grouped, group)
+		}
+
+		if alert.Status == "firing" {
+			group.status = alert.Status
+		}
+		group.alerts = append(group.alerts, i)
+		group.actions = append(group.actions, action)
+	}
+
+	return grouped
+}
+
+func handleAlerts(req requests.PrometheusAlert, stabilizer *scaling.Stabilizer, deduplicator *alertDeduplicator, defaultNamespace string, now time.Time) []AlertResult {
+	results := make([]AlertResult, len(req.Alerts))
+
+	for _, group := range groupAlerts(req.Alerts, results, deduplicator, defaultNamespace, now) {
+		result := scaleService(*group, stabilizer, now)
+
+		for _, i := range group.alerts {
+			results[i].Result = result.Result
+			results[i].CurrentReplicas = result.CurrentReplicas
+			results[i].TargetReplicas = result.TargetReplicas
+			results[i].Reason = result.Reason
+			results[i].Error = result.Error
+
+			// A failed alert is applied again when Alertmanager retries it
+			if result.Result == AlertError {
+				deduplicator.release(alertKey(req.Alerts[i]))
 			}
+		}
+	}
+
+	return results
+}
 
-			updateErr := service.SetReplicas(serviceName, namespace, newReplicas)
-			if updateErr != nil {
-				err = updateErr
+// recommend returns the replicas for an action, bounded by the function's
+// min and max replicas
+func (a alertAction) recommend(queryResponse scaling.ServiceQueryResponse) uint64 {
+	switch a.action {
+	case ScaleActionUp:
+		return CalculateReplicas(a.status, queryResponse.Replicas, uint64(queryResponse.MaxReplicas), queryResponse.MinReplicas, queryResponse.ScalingFactor)
+	case ScaleActionDown:
+		step := uint64(math.Ceil(float64(queryResponse.MaxReplicas) / 100 * float64(queryResponse.ScalingFactor)))
+		if queryResponse.Replicas < queryResponse.MinReplicas+step {
+			return queryResponse.MinReplicas
+		}
+		return queryResponse.Replicas - step
+	case ScaleActionMax:
+		return queryResponse.MaxReplicas
+	case ScaleActionSet:
+		if a.replicas < queryResponse.MinReplicas {
+			return queryResponse.MinReplicas
+		}
+		if a.replicas > queryResponse.MaxReplicas {
+			return queryResponse.MaxReplicas
+		}
+		return a.replicas
+	default:
+		return queryResponse.MinReplicas
+	}
+}
+
+// scaleService applies the highest recommendation of the function's firing
+// alerts, or its min replicas once every alert is resolved
+func scaleService(alert functionAlert, stabilizer *scaling.Stabilizer, now time.Time) AlertResult {
+	actions := []string{}
+	for _, action := range alert.actions {
+		if action.status == alert.status {
+			actions = append(actions, action.action)
+		}
+	}
+
+	inputs := map[string]string{
+		"status": alert.status,
+		"action": strings.Join(actions, ","),
+	}
+
+	decision, err := stabilizer.Scale(alert.name, alert.namespace, inputs, func(queryResponse scaling.ServiceQueryResponse) uint64 {
+		var replicas uint64
+		first := true
+		for _, action := range alert.actions {
+			if action.status != alert.status {
+				continue
+			}
+			if recommended := action.recommend(queryResponse); first || recommended > replicas {
+				replicas = recommended
 			}
+			first = false
+		}
+		return replicas
+	}, now)
+
+	result := AlertResult{
+		CurrentReplicas: decision.CurrentReplicas,
+		TargetReplicas:  decision.TargetReplicas,
+		Reason:          decision.Reason,
+	}
+
+	if !decision.Queried {
+		result.Error = err.Error()
+		if errors.Is(err, scaling.ErrFunctionNotFound) {
+			alertsLogger.Warn("function not found", "function", alert.name, "namespace", alert.namespace)
+			result.Result = AlertNotFound
+			return result
 		}
+
+		alertsLogger.Error("unable to query replicas", "function", alert.name, "namespace", alert.namespace, "error", err)
+		result.Result = AlertError
+		return result
 	}
-	return err
+
+	switch {
+	case err != nil:
+		alertsLogger.Error("unable to scale",
+			"function", alert.name, "namespace", alert.namespace,
+			"replicas", decision.CurrentReplicas, "target_replicas", decision.TargetReplicas, "error", err)
+		result.Result = AlertError
+		result.Error = err.Error()
+	case decision.Pending:
+		alertsLogger.Info("scaling held back",
+			"function", alert.name, "namespace", alert.namespace,
+			"replicas", decision.CurrentReplicas, "target_replicas", decision.TargetReplicas,
+			"recommended_replicas", decision.RecommendedReplicas, "reason", decision.Reason)
+		result.Result = AlertHeld
+	case decision.TargetReplicas == decision.CurrentReplicas:
+		result.Result = AlertUnchanged
+	default:
+		alertsLogger.Info("scaled",
+			"function", alert.name, "namespace", alert.namespace,
+			"replicas", decision.CurrentReplicas, "target_replicas", decision.TargetReplicas)
+		result.Result = AlertScaled
+	}
+
+	return result
 }
 
 // CalculateReplicas decides what replica count to set depending on current/desired amount
 func CalculateReplicas(status string, currentReplicas uint64, maxReplicas uint64, minReplicas uint64, scalingFactor uint64) uint64 {
 	var newReplicas uint64
 
-	maxReplicas = uint64(math.Min(float64(maxReplicas), float64(scaling.DefaultMaxReplicas)))
 	step := uint64(math.Ceil(float64(maxReplicas) / 100 * float64(scalingFactor)))
 
 	if status == "firing" && step > 0 {
@@ -4,8 +4,17 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/openfaas/faas/gateway/requests"
 	"github.com/openfaas/faas/gateway/scaling"
 )
 
@@ -47,8 +56,18 @@ func TestMaxScale(t *testing.T) {
 	minReplicas := uint64(1)
 	scalingFactor := uint64(100)
 	got := CalculateReplicas("firing", scaling.DefaultMinReplicas, scaling.DefaultMaxReplicas*2, minReplicas, scalingFactor)
-	if got != scaling.DefaultMaxReplicas {
-		t.Fatalf("want ceiling: %d, but got: %d", scaling.DefaultMaxReplicas, got)
+	if got != scaling.DefaultMaxReplicas*2 {
+		t.Fatalf("want ceiling: %d, but got: %d", scaling.DefaultMaxReplicas*2, got)
+	}
+}
+
+func TestMaxScale_AboveDefaultMaxReplicas(t *testing.T) {
+	minReplicas := uint64(1)
+	scalingFactor := uint64(10)
+	got := CalculateReplicas("firing", 10, 50, minReplicas, scalingFactor)
+	want := uint64(15)
+	if got != want {
+		t.Fatalf("want: %d, but got: %d", want, got)
 	}
 }
 
@@ -128,3 +147,263 @@ func TestScaledUpWithSmallParam(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// countingQuery records each call to SetReplicas
+type countingQuery struct {
+	response scaling.ServiceQueryResponse
+	setCalls []uint64
+	getErr   error
+}
+
+func (c *countingQuery) GetReplicas(service, namespace string) (scaling.ServiceQueryResponse, error) {
+	if service != "echo" {
+		return scaling.ServiceQueryResponse{}, fmt.Errorf("%w: %s", scaling.ErrFunctionNotFound, service)
+	}
+	return c.response, c.getErr
+}
+
+func (c *countingQuery) SetReplicas(service, namespace string, count uint64) error {
+	c.setCalls = append(c.setCalls, count)
+	c.response.Replicas = count
+	return nil
+}
+
+func Test_handleAlerts_BatchScalesEachFunctionOnce(t *testing.T) {
+	query := &countingQuery{response: scaling.ServiceQueryResponse{
+		Replicas: 1, MinReplicas: 1, MaxReplicas: 10, ScalingFactor: 20,
+	}}
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{ServiceQuery: query})
+
+	req := requests.PrometheusAlert{
+		Alerts: []requests.PrometheusInnerAlert{
+			{Status: "firing", Labels: requests.PrometheusInnerAlertLabel{FunctionName: "echo"}},
+			{Status: "resolved", Labels: requests.PrometheusInnerAlertLabel{FunctionName: "echo.openfaas-fn"}},
+			{Status: "firing", Labels: requests.PrometheusInnerAlertLabel{FunctionName: "echo"}},
+		},
+	}
+
+	for _, result := range handleAlerts(req, stabilizer, nil, "openfaas-fn", time.Now()) {
+		if result.Result != AlertScaled {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+
+	if len(query.setCalls) != 1 || query.setCalls[0] != 3 {
+		t.Fatalf("setCalls, want: [3], got: %v", query.setCalls)
+	}
+}
+
+func Test_handleAlerts_AnnotationActions(t *testing.T) {
+	cases := []struct {
+		annotations map[string]string
+		want        uint64
+	}{
+		{annotations: nil, want: 4},
+		{annotations: map[string]string{ScaleActionAnnotation: ScaleActionDown}, want: 1},
+		{annotations: map[string]string{ScaleActionAnnotation: ScaleActionMax}, want: 10},
+		{annotations: map[string]string{ScaleActionAnnotation: ScaleActionSet, ScaleReplicasAnnotation: "7"}, want: 7},
+		{annotations: map[string]string{ScaleActionAnnotation: ScaleActionSet, ScaleReplicasAnnotation: "70"}, want: 10},
+	}
+
+	for _, c := range cases {
+		query := &countingQuery{response: scaling.ServiceQueryResponse{
+			Replicas: 2, MinReplicas: 1, MaxReplicas: 10, ScalingFactor: 20,
+		}}
+		stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{ServiceQuery: query})
+
+		req := requests.PrometheusAlert{
+			Alerts: []requests.PrometheusInnerAlert{
+				{Status: "firing", Labels: requests.PrometheusInnerAlertLabel{FunctionName: "echo"}, Annotations: c.annotations},
+			},
+		}
+
+		results := handleAlerts(req, stabilizer, nil, "openfaas-fn", time.Now())
+		if results[0].TargetReplicas != c.want {
+			t.Fatalf("%v target, want: %d, got: %+v", c.annotations, c.want, results[0])
+		}
+	}
+}
+
+func Test_handleAlerts_InvalidAndNoneActions(t *testing.T) {
+	query := &countingQuery{response: scaling.ServiceQueryResponse{Replicas: 2, MinReplicas: 1, MaxReplicas: 10, ScalingFactor: 20}}
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{ServiceQuery: query})
+
+	req := requests.PrometheusAlert{
+		Alerts: []requests.PrometheusInnerAlert{
+			{Status: "firing", Labels: requests.PrometheusInnerAlertLabel{FunctionName: "echo"}, Annotations: map[string]string{ScaleActionAnnotation: ScaleActionNone}},
+			{Status: "firing", Labels: requests.PrometheusInnerAlertLabel{FunctionName: "echo"}, Annotations: map[string]string{ScaleActionAnnotation: "sideways"}},
+			{Status: "firing", Labels: requests.PrometheusInnerAlertLabel{AlertName: "APIHighInvocationRate"}},
+		},
+	}
+
+	results := handleAlerts(req, stabilizer, nil, "openfaas-fn", time.Now())
+
+	want := []string{AlertIgnored, AlertInvalid, AlertIgnored}
+	for i, result := range results {
+		if result.Result != want[i] {
+			t.Fatalf("alert %d result, want: %s, got: %s", i, want[i], result.Result)
+		}
+	}
+	if len(query.setCalls) != 0 {
+		t.Fatalf("want no calls to SetReplicas, got: %v", query.setCalls)
+	}
+}
+
+func Test_handleAlerts_NamespaceLabel(t *testing.T) {
+	query := &countingQuery{response: scaling.ServiceQueryResponse{Replicas: 1, MinReplicas: 1, MaxReplicas: 10, ScalingFactor: 20}}
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{ServiceQuery: query})
+
+	req := requests.PrometheusAlert{
+		Alerts: []requests.PrometheusInnerAlert{
+			{Status: "firing", Labels: requests.PrometheusInnerAlertLabel{FunctionName: "echo.openfaas-fn", Namespace: "staging"}},
+		},
+	}
+
+	results := handleAlerts(req, stabilizer, nil, "openfaas-fn", time.Now())
+	if results[0].Function != "echo" || results[0].Namespace != "staging" {
+		t.Fatalf("want echo in staging, got: %s in %s", results[0].Function, results[0].Namespace)
+	}
+}
+
+func Test_handleAlerts_DeduplicatesByFingerprint(t *testing.T) {
+	query := &countingQuery{response: scaling.ServiceQueryResponse{Replicas: 1, MinReplicas: 1, MaxReplicas: 10, ScalingFactor: 20}}
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{ServiceQuery: query})
+	deduplicator := newAlertDeduplicator(time.Second * 5)
+
+	startsAt := time.Date(2023, 3, 15, 15, 0, 0, 0, time.UTC)
+	alert := requests.PrometheusInnerAlert{
+		Status:      "firing",
+		Labels:      requests.PrometheusInnerAlertLabel{FunctionName: "echo"},
+		StartsAt:    startsAt,
+		Fingerprint: "6b2f4f2d2e6b9a1c",
+	}
+	req := requests.PrometheusAlert{Alerts: []requests.PrometheusInnerAlert{alert, alert}}
+
+	now := time.Now()
+	results := handleAlerts(req, stabilizer, deduplicator, "openfaas-fn", now)
+	if results[0].Result != AlertScaled || results[1].Result != AlertDuplicate {
+		t.Fatalf("want scaled then duplicate, got: %s, %s", results[0].Result, results[1].Result)
+	}
+
+	// The same notification from another Alertmanager replica
+	results = handleAlerts(requests.PrometheusAlert{Alerts: []requests.PrometheusInnerAlert{alert}}, stabilizer, deduplicator, "openfaas-fn", now.Add(time.Second))
+	if results[0].Result != AlertDuplicate {
+		t.Fatalf("want duplicate within the window, got: %s", results[0].Result)
+	}
+
+	// A repeat notification after the window scales again
+	results = handleAlerts(requests.PrometheusAlert{Alerts: []requests.PrometheusInnerAlert{alert}}, stabilizer, deduplicator, "openfaas-fn", now.Add(time.Minute))
+	if results[0].Result != AlertScaled {
+		t.Fatalf("want scaled after the window, got: %s", results[0].Result)
+	}
+
+	if len(query.setCalls) != 2 {
+		t.Fatalf("setCalls, want: 2, got: %v", query.setCalls)
+	}
+}
+
+// slowQuery takes a while to answer, so that concurrent notifications of
+// the same alert overlap
+type slowQuery struct {
+	lock     sync.Mutex
+	response scaling.ServiceQueryResponse
+	setCalls []uint64
+}
+
+func (q *slowQuery) GetReplicas(service, namespace string) (scaling.ServiceQueryResponse, error) {
+	time.Sleep(time.Millisecond * 20)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.response, nil
+}
+
+func (q *slowQuery) SetReplicas(service, namespace string, count uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.setCalls = append(q.setCalls, count)
+	q.response.Replicas = count
+	return nil
+}
+
+func Test_handleAlerts_ConcurrentDuplicatesScaleOnce(t *testing.T) {
+	query := &slowQuery{response: scaling.ServiceQueryResponse{Replicas: 1, MinReplicas: 1, MaxReplicas: 10, ScalingFactor: 20}}
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{ServiceQuery: query})
+	deduplicator := newAlertDeduplicator(time.Minute)
+
+	alert := requests.PrometheusInnerAlert{
+		Status:      "firing",
+		Labels:      requests.PrometheusInnerAlertLabel{FunctionName: "echo"},
+		StartsAt:    time.Date(2023, 3, 15, 15, 0, 0, 0, time.UTC),
+		Fingerprint: "6b2f4f2d2e6b9a1c",
+	}
+	req := requests.PrometheusAlert{Alerts: []requests.PrometheusInnerAlert{alert}}
+
+	now := time.Now()
+	results := make([]string, 5)
+	wg := sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = handleAlerts(req, stabilizer, deduplicator, "openfaas-fn", now)[0].Result
+		}(i)
+	}
+	wg.Wait()
+
+	scaled := 0
+	for _, result := range results {
+		if result == AlertScaled {
+			scaled++
+		} else if result != AlertDuplicate {
+			t.Fatalf("want scaled or duplicate, got: %s", result)
+		}
+	}
+	if scaled != 1 || len(query.setCalls) != 1 {
+		t.Fatalf("want the alert applied once, scaled: %d, setCalls: %v", scaled, query.setCalls)
+	}
+}
+
+func Test_MakeAlertHandler_ReportsEachAlert(t *testing.T) {
+	query := &countingQuery{response: scaling.ServiceQueryResponse{Replicas: 1, MinReplicas: 1, MaxReplicas: 10, ScalingFactor: 20}}
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{ServiceQuery: query})
+	handler := MakeAlertHandler(stabilizer, 0, "openfaas-fn")
+
+	body := `{"version":"4","status":"firing","alerts":[
+		{"status":"firing","labels":{"alertname":"APIHighInvocationRate","function_name":"echo"},"fingerprint":"a"},
+		{"status":"firing","labels":{"alertname":"APIHighInvocationRate","function_name":"deleted"},"fingerprint":"b"}]}`
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/system/alert", strings.NewReader(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code, want: %d, got: %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response AlertResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("unable to unmarshal: %s", err)
+	}
+	if len(response.Results) != 2 || response.Results[0].Result != AlertScaled || response.Results[1].Result != AlertNotFound {
+		t.Fatalf("want scaled and not found, got: %+v", response.Results)
+	}
+}
+
+func Test_MakeAlertHandler_QueryErrorFailsNotification(t *testing.T) {
+	query := &countingQuery{getErr: errors.New("provider unavailable")}
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{ServiceQuery: query})
+	handler := MakeAlertHandler(stabilizer, time.Minute, "openfaas-fn")
+
+	body := `{"status":"firing","alerts":[{"status":"firing","labels":{"function_name":"echo"},"fingerprint":"a"}]}`
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/system/alert", strings.NewReader(body)))
+
+		// A failed alert is not deduplicated when Alertmanager retries it
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("attempt %d status code, want: %d, got: %d", i, http.StatusInternalServerError, rr.Code)
+		}
+	}
+}
@@ -5,10 +5,12 @@ package handlers
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/docker/distribution/uuid"
+	"github.com/openfaas/faas/gateway/pkg/logging"
 	"github.com/openfaas/faas/gateway/version"
 )
 
@@ -23,6 +25,8 @@ func MakeCallIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			callID := uuid.Generate().String()
 			r.Header.Add("X-Call-Id", callID)
 			w.Header().Add("X-Call-Id", callID)
+
+			r = r.WithContext(logging.With(r.Context(), slog.String("call_id", callID)))
 		}
 
 		r.Header.Add("X-Start-Time", fmt.Sprintf("%d", start.UTC().UnixNano()))
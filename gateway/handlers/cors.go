@@ -3,7 +3,17 @@
 
 package handlers
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/scaling"
+	"github.com/openfaas/faas/gateway/types"
+)
 
 // CORSHandler set custom CORS instructions for the store.
 type CORSHandler struct {
@@ -27,3 +37,189 @@ func DecorateWithCORS(upstream http.Handler, allowedHost string) http.Handler {
 		AllowedHost: allowedHost,
 	}
 }
+
+const (
+	// CORSAllowOriginsAnnotation is a comma-separated list of origins, or "*"
+	CORSAllowOriginsAnnotation = "com.openfaas.cors.allow-origins"
+
+	// CORSAllowMethodsAnnotation is a comma-separated list of HTTP methods
+	CORSAllowMethodsAnnotation = "com.openfaas.cors.allow-methods"
+
+	// CORSAllowHeadersAnnotation is a comma-separated list of request headers
+	CORSAllowHeadersAnnotation = "com.openfaas.cors.allow-headers"
+
+	// CORSExposeHeadersAnnotation is a comma-separated list of response headers
+	CORSExposeHeadersAnnotation = "com.openfaas.cors.expose-headers"
+
+	// CORSAllowCredentialsAnnotation set to "true" to allow credentials
+	CORSAllowCredentialsAnnotation = "com.openfaas.cors.allow-credentials"
+
+	// CORSMaxAgeAnnotation is a duration or number of seconds to cache a preflight
+	CORSMaxAgeAnnotation = "com.openfaas.cors.max-age"
+)
+
+// defaultCORSMethods are allowed when a function does not set CORSAllowMethodsAnnotation
+var defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodHead}
+
+// ParseCORSPolicy builds a CORS policy from a function's annotations, nil is
+// returned when the function has not opted into CORS.
+func ParseCORSPolicy(annotations map[string]string) (*types.CORSPolicy, error) {
+	origins := types.ParseCSV(annotations[CORSAllowOriginsAnnotation])
+	if len(origins) == 0 {
+		return nil, nil
+	}
+
+	policy := types.CORSPolicy{
+		AllowedOrigins: origins,
+		AllowedMethods: defaultCORSMethods,
+		AllowedHeaders: types.ParseCSV(annotations[CORSAllowHeadersAnnotation]),
+		ExposedHeaders: types.ParseCSV(annotations[CORSExposeHeadersAnnotation]),
+	}
+
+	if methods := types.ParseCSV(annotations[CORSAllowMethodsAnnotation]); len(methods) > 0 {
+		policy.AllowedMethods = methods
+	}
+
+	if v, ok := annotations[CORSAllowCredentialsAnnotation]; ok {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", CORSAllowCredentialsAnnotation, v)
+		}
+		policy.AllowCredentials = allow
+	}
+
+	if policy.AllowCredentials && policy.AllowsAnyOrigin() {
+		return nil, fmt.Errorf("invalid value for %s: credentials cannot be allowed for any origin", CORSAllowCredentialsAnnotation)
+	}
+
+	if v, ok := annotations[CORSMaxAgeAnnotation]; ok && len(v) > 0 {
+		maxAge, err := parseSecondsOrDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", CORSMaxAgeAnnotation, v)
+		}
+		policy.MaxAge = maxAge
+	}
+
+	return &policy, nil
+}
+
+func parseSecondsOrDuration(val string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(val)
+}
+
+// isPreflight is true for an OPTIONS request sent by a browser before the
+// actual cross-origin request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		len(r.Header.Get("Origin")) > 0 &&
+		len(r.Header.Get("Access-Control-Request-Method")) > 0
+}
+
+// writeCORS adds the CORS headers from the policy to the response and answers
+// preflight requests, true is returned when the request has been handled and
+// must not be passed upstream.
+func writeCORS(w http.ResponseWriter, r *http.Request, policy types.CORSPolicy) bool {
+	w.Header().Add("Vary", "Origin")
+
+	allowOrigin, allowed := policy.AllowOrigin(r.Header.Get("Origin"))
+
+	if !isPreflight(r) {
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			if policy.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if len(policy.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+		}
+		return false
+	}
+
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	// A disallowed preflight is still answered by the gateway, but without
+	// any Access-Control-* headers the browser will block the request.
+	if allowed && policy.AllowMethod(r.Header.Get("Access-Control-Request-Method")) {
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+
+		if len(policy.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+		} else if requested := r.Header.Get("Access-Control-Request-Headers"); len(requested) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", requested)
+		}
+
+		if policy.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if policy.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// MakeFunctionCORSHandler applies the CORS policy set in a function's
+// annotations. Preflight requests are answered by the gateway and are never
+// passed to the function. Functions without a policy are passed through
+// untouched.
+func MakeFunctionCORSHandler(next http.HandlerFunc, functionQuery scaling.FunctionQuery, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Origin")) == 0 {
+			next(w, r)
+			return
+		}
+
+		functionName, namespace := middleware.GetNamespace(defaultNamespace, middleware.GetServiceName(r.URL.String()))
+
+		annotations, err := functionQuery.GetAnnotations(functionName, namespace)
+		if err != nil {
+			// Let the proxy report on missing functions
+			next(w, r)
+			return
+		}
+
+		policy, err := ParseCORSPolicy(annotations)
+		if err != nil {
+			proxyLogger.WarnContext(r.Context(), "invalid CORS policy", "error", err)
+			next(w, r)
+			return
+		}
+
+		if policy == nil {
+			next(w, r)
+			return
+		}
+
+		if handled := writeCORS(w, r, *policy); handled {
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// DecorateWithCORSPolicy applies a CORS policy to any request with a path
+// starting with pathPrefix. It must wrap the router, so that preflight
+// requests are answered before method matching takes place.
+func DecorateWithCORSPolicy(upstream http.Handler, policy types.CORSPolicy, pathPrefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, pathPrefix) || len(r.Header.Get("Origin")) == 0 {
+			upstream.ServeHTTP(w, r)
+			return
+		}
+
+		if handled := writeCORS(w, r, policy); handled {
+			return
+		}
+
+		upstream.ServeHTTP(w, r)
+	})
+}
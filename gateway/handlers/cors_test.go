@@ -7,6 +7,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/scaling"
+	"github.com/openfaas/faas/gateway/types"
 )
 
 type customHandler struct {
@@ -35,3 +39,201 @@ func Test_HeadersAdded(t *testing.T) {
 	}
 
 }
+
+type fakeFunctionQuery struct {
+	annotations map[string]string
+	err         error
+}
+
+func (f fakeFunctionQuery) Get(name string, namespace string) (scaling.ServiceQueryResponse, error) {
+	return scaling.ServiceQueryResponse{Annotations: &f.annotations}, f.err
+}
+
+func (f fakeFunctionQuery) GetAnnotations(name string, namespace string) (map[string]string, error) {
+	return f.annotations, f.err
+}
+
+func Test_ParseCORSPolicy_NoOrigins(t *testing.T) {
+	policy, err := ParseCORSPolicy(map[string]string{CORSMaxAgeAnnotation: "60"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy != nil {
+		t.Fatalf("want nil policy when no origins are set, got: %v", policy)
+	}
+}
+
+func Test_ParseCORSPolicy_AllFields(t *testing.T) {
+	policy, err := ParseCORSPolicy(map[string]string{
+		CORSAllowOriginsAnnotation:     "https://a.example.com, https://b.example.com",
+		CORSAllowMethodsAnnotation:     "GET,POST",
+		CORSAllowHeadersAnnotation:     "Content-Type",
+		CORSAllowCredentialsAnnotation: "true",
+		CORSMaxAgeAnnotation:           "10m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(policy.AllowedOrigins) != 2 {
+		t.Errorf("AllowedOrigins want: 2, got: %d", len(policy.AllowedOrigins))
+	}
+	if len(policy.AllowedMethods) != 2 {
+		t.Errorf("AllowedMethods want: 2, got: %d", len(policy.AllowedMethods))
+	}
+	if !policy.AllowCredentials {
+		t.Errorf("AllowCredentials want: true")
+	}
+	if policy.MaxAge != time.Minute*10 {
+		t.Errorf("MaxAge want: %s, got: %s", time.Minute*10, policy.MaxAge)
+	}
+}
+
+func Test_ParseCORSPolicy_BadCredentials(t *testing.T) {
+	_, err := ParseCORSPolicy(map[string]string{
+		CORSAllowOriginsAnnotation:     "*",
+		CORSAllowCredentialsAnnotation: "maybe",
+	})
+	if err == nil {
+		t.Fatal("want error for invalid credentials value")
+	}
+}
+
+func Test_MakeFunctionCORSHandler_PreflightNotForwarded(t *testing.T) {
+	query := fakeFunctionQuery{annotations: map[string]string{
+		CORSAllowOriginsAnnotation: "https://app.example.com",
+		CORSMaxAgeAnnotation:       "600",
+	}}
+
+	visited := false
+	handler := MakeFunctionCORSHandler(func(w http.ResponseWriter, r *http.Request) {
+		visited = true
+	}, query, "openfaas-fn")
+
+	req := httptest.NewRequest(http.MethodOptions, "/function/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if visited {
+		t.Fatalf("preflight should not be forwarded to the function")
+	}
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("status want: %d, got: %d", http.StatusNoContent, rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin want: %s, got: %s", "https://app.example.com", got)
+	}
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age want: %s, got: %s", "600", got)
+	}
+}
+
+func Test_MakeFunctionCORSHandler_DisallowedOrigin(t *testing.T) {
+	query := fakeFunctionQuery{annotations: map[string]string{
+		CORSAllowOriginsAnnotation: "https://app.example.com",
+	}}
+
+	handler := MakeFunctionCORSHandler(func(w http.ResponseWriter, r *http.Request) {}, query, "openfaas-fn")
+
+	req := httptest.NewRequest(http.MethodOptions, "/function/echo", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin want empty, got: %s", got)
+	}
+}
+
+func Test_MakeFunctionCORSHandler_NoPolicyPassesThrough(t *testing.T) {
+	query := fakeFunctionQuery{annotations: map[string]string{}}
+
+	visited := false
+	handler := MakeFunctionCORSHandler(func(w http.ResponseWriter, r *http.Request) {
+		visited = true
+	}, query, "openfaas-fn")
+
+	req := httptest.NewRequest(http.MethodOptions, "/function/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if !visited {
+		t.Fatalf("want request passed to the function when it has no CORS policy")
+	}
+}
+
+func Test_ParseCORSPolicy_CredentialsWithAnyOrigin(t *testing.T) {
+	_, err := ParseCORSPolicy(map[string]string{
+		CORSAllowOriginsAnnotation:     "*",
+		CORSAllowCredentialsAnnotation: "true",
+	})
+	if err == nil {
+		t.Fatalf("want an error for credentials with any origin")
+	}
+}
+
+func Test_MakeFunctionCORSHandler_CredentialsWithAnyOriginRejected(t *testing.T) {
+	query := fakeFunctionQuery{annotations: map[string]string{
+		CORSAllowOriginsAnnotation:     "*",
+		CORSAllowCredentialsAnnotation: "true",
+	}}
+
+	handler := MakeFunctionCORSHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, query, "openfaas-fn")
+
+	req := httptest.NewRequest(http.MethodGet, "/function/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin want: none, got: %s", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials want: none, got: %s", got)
+	}
+}
+
+func Test_DecorateWithCORSPolicy_OnlyMatchesPrefix(t *testing.T) {
+	policy := types.CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodGet},
+	}
+
+	visited := 0
+	decorated := DecorateWithCORSPolicy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visited++
+	}), policy, "/system/")
+
+	req := httptest.NewRequest(http.MethodOptions, "/system/functions", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rr := httptest.NewRecorder()
+	decorated.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("status want: %d, got: %d", http.StatusNoContent, rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin want: *, got: %s", got)
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/function/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	decorated.ServeHTTP(httptest.NewRecorder(), req)
+
+	if visited != 1 {
+		t.Errorf("want only the non-matching request passed upstream, visited: %d", visited)
+	}
+}
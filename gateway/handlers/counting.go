@@ -0,0 +1,66 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// countingReader counts the bytes read from a request body, the count is
+// atomic because the transport may still be reading the body when the
+// response has been received
+type countingReader struct {
+	io.ReadCloser
+	bytes atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.bytes.Add(int64(n))
+	return n, err
+}
+
+// Bytes returns the number of bytes read so far
+func (c *countingReader) Bytes() int64 {
+	return c.bytes.Load()
+}
+
+// countBody replaces the body of r with a countingReader, a request without
+// a body is left as it is so that it is still forwarded without one
+func countBody(r *http.Request) *countingReader {
+	c := &countingReader{}
+	if r.Body != nil && r.Body != http.NoBody {
+		c.ReadCloser = r.Body
+		r.Body = c
+	}
+	return c
+}
+
+// countingWriter counts the bytes of a response body
+type countingWriter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.bytes += int64(n)
+	return n, err
+}
+
+func (c *countingWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// CloseNotify is required by handlers which stream their response
+func (c *countingWriter) CloseNotify() <-chan bool {
+	if notifier, ok := c.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
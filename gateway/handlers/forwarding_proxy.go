@@ -7,12 +7,12 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 	"github.com/openfaas/faas/gateway/types"
 )
 
@@ -33,25 +33,64 @@ func MakeForwardingProxyHandler(proxy *types.HTTPClientReverseProxy,
 		originalURL := r.URL.String()
 		requestURL := urlPathTransformer.Transform(r)
 
+		notification := types.HTTPNotification{
+			Method:      r.Method,
+			URL:         requestURL,
+			OriginalURL: originalURL,
+			StatusCode:  http.StatusProcessing,
+			Event:       "started",
+			CallID:      r.Header.Get("X-Call-Id"),
+			TraceID:     sampledTraceID(r.Context()),
+		}
+
 		for _, notifier := range notifiers {
-			notifier.Notify(r.Method, requestURL, originalURL, http.StatusProcessing, "started", time.Second*0)
+			notifier.Notify(notification)
+		}
+
+		ctx, span := tracing.Start(r.Context(), "proxy", tracing.KindClient)
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.url", requestURL)
+		if serviceName := middleware.GetServiceName(originalURL); len(serviceName) > 0 {
+			span.SetAttribute("faas.function", serviceName)
 		}
 
 		start := time.Now()
 
-		statusCode, err := forwardRequest(w, r, proxy.Client, baseURL, requestURL, proxy.Timeout, writeRequestURI, serviceAuthInjector)
+		requestBody := countBody(r)
+		writer := &countingWriter{ResponseWriter: w}
+
+		statusCode, err := forwardRequest(writer, r.WithContext(ctx), proxy.Client, baseURL, requestURL, proxy.Timeout, writeRequestURI, serviceAuthInjector)
 
 		seconds := time.Since(start)
 		if err != nil {
-			log.Printf("error with upstream request to: %s, %s\n", requestURL, err.Error())
+			proxyLogger.ErrorContext(r.Context(), "upstream request failed", "url", requestURL, "error", err)
 		}
 
+		span.SetStatusCode(statusCode)
+		span.SetError(err)
+		span.End()
+
+		notification.StatusCode = statusCode
+		notification.Event = "completed"
+		notification.Duration = seconds
+		notification.RequestBytes = requestBody.Bytes()
+		notification.ResponseBytes = writer.bytes
+
 		for _, notifier := range notifiers {
-			notifier.Notify(r.Method, requestURL, originalURL, statusCode, "completed", seconds)
+			notifier.Notify(notification)
 		}
 	}
 }
 
+// sampledTraceID returns the trace ID of a sampled trace, so that exemplars
+// only link to traces which were exported
+func sampledTraceID(ctx context.Context) string {
+	if c := tracing.SpanContextFromContext(ctx); c.IsValid() && c.Sampled {
+		return c.TraceID.String()
+	}
+	return ""
+}
+
 func buildUpstreamRequest(r *http.Request, baseURL string, requestURL string) *http.Request {
 	url := baseURL + requestURL
 
@@ -96,9 +135,10 @@ func forwardRequest(w http.ResponseWriter,
 	if serviceAuthInjector != nil {
 		serviceAuthInjector.Inject(upstreamReq)
 	}
+	tracing.Inject(r.Context(), upstreamReq.Header)
 
 	if writeRequestURI {
-		log.Printf("forwardRequest: %s %s\n", upstreamReq.Host, upstreamReq.URL.String())
+		proxyLogger.InfoContext(r.Context(), "forwarding request", "host", upstreamReq.Host, "url", upstreamReq.URL.String())
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), timeout)
@@ -8,10 +8,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/types"
 )
 
 func Test_buildUpstreamRequest_Body_Method_Query(t *testing.T) {
@@ -364,3 +368,47 @@ func Test_deleteHeaders(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func Test_MakeForwardingProxyHandler_NotifiesBodySizes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(bytes.Repeat(body, 3))
+	}))
+	defer upstream.Close()
+
+	upstreamURL, _ := url.Parse(upstream.URL)
+	proxy := types.NewHTTPClientReverseProxy(upstreamURL, time.Second, 1, 1)
+	notifier := &testNotifier{}
+
+	handler := MakeForwardingProxyHandler(proxy, []HTTPNotifier{notifier},
+		middleware.SingleHostBaseURLResolver{BaseURL: upstream.URL}, middleware.TransparentURLPathTransformer{}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/function/echo", strings.NewReader("hello"))
+	req.Header.Set("X-Call-Id", "4b1e2b4c")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if len(notifier.Notifications) != 2 {
+		t.Fatalf("notifications, want: 2, got: %d", len(notifier.Notifications))
+	}
+
+	completed := notifier.Notifications[1]
+	if completed.Event != "completed" || completed.StatusCode != http.StatusOK || completed.CallID != "4b1e2b4c" {
+		t.Fatalf("unexpected notification: %+v", completed)
+	}
+	if completed.RequestBytes != 5 || completed.ResponseBytes != 15 {
+		t.Fatalf("want 5 bytes in and 15 out, got: %d, %d", completed.RequestBytes, completed.ResponseBytes)
+	}
+	if rec.Body.String() != "hellohellohello" {
+		t.Fatalf("unexpected body: %q", rec.Body.String())
+	}
+}
+
+func Test_countBody_LeavesEmptyBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/function/echo", nil)
+
+	counter := countBody(req)
+	if req.Body != http.NoBody || counter.Bytes() != 0 {
+		t.Fatalf("want a request without a body to be left as it is")
+	}
+}
@@ -6,7 +6,6 @@ package handlers
 import (
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
 	"net/http/httptest"
 
@@ -29,7 +28,7 @@ func MakeInfoHandler(h http.Handler) http.HandlerFunc {
 		upstreamBody, _ := io.ReadAll(upstreamCall.Body)
 		err := json.Unmarshal(upstreamBody, &provider)
 		if err != nil {
-			log.Printf("Error unmarshalling provider json from body %s. Error %s\n", upstreamBody, err.Error())
+			logger.ErrorContext(r.Context(), "unable to unmarshal provider info", "body", string(upstreamBody), "error", err)
 		}
 
 		gatewayInfo := &types.GatewayInfo{
@@ -44,7 +43,7 @@ func MakeInfoHandler(h http.Handler) http.HandlerFunc {
 
 		jsonOut, marshalErr := json.Marshal(gatewayInfo)
 		if marshalErr != nil {
-			log.Printf("Error during unmarshal of gateway info request %s\n", marshalErr.Error())
+			logger.ErrorContext(r.Context(), "unable to marshal gateway info", "error", marshalErr)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -0,0 +1,59 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/openfaas/faas/gateway/pkg/logging"
+)
+
+// maxLogLevelBody bounds the body of a request to change the log level
+const maxLogLevelBody = 1024
+
+// LogLevel is the level of the gateway's logs
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// MakeLogLevelHandler returns the level of the gateway's logs on GET, and
+// changes it for every component on PUT with a body such as
+// {"level": "debug"}
+func MakeLogLevelHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxLogLevelBody))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var req LogLevel
+			if err := json.Unmarshal(body, &req); err != nil {
+				http.Error(w, "invalid body, want: {\"level\": \"debug\"}", http.StatusBadRequest)
+				return
+			}
+
+			level, err := logging.ParseLevel(req.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if level != logging.Level() {
+				logger.Info("log level changed", "from", logging.Level().String(), "to", level.String())
+				logging.SetLevel(level)
+			}
+		}
+
+		out, _ := json.Marshal(LogLevel{Level: strings.ToLower(logging.Level().String())})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
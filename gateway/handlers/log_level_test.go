@@ -0,0 +1,53 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/logging"
+)
+
+func Test_MakeLogLevelHandler_ChangesLevel(t *testing.T) {
+	defer logging.SetLevel(logging.Level())
+	logging.SetLevel(slog.LevelInfo)
+
+	handler := MakeLogLevelHandler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/system/log-level", strings.NewReader(`{"level": "debug"}`)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code, want: %d, got: %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := logging.Level(); got != slog.LevelDebug {
+		t.Errorf("level, want: %s, got: %s", slog.LevelDebug, got)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/system/log-level", nil))
+
+	if got := strings.TrimSpace(rr.Body.String()); got != `{"level":"debug"}` {
+		t.Errorf("body, want: %s, got: %s", `{"level":"debug"}`, got)
+	}
+}
+
+func Test_MakeLogLevelHandler_RejectsUnknownLevel(t *testing.T) {
+	defer logging.SetLevel(logging.Level())
+	logging.SetLevel(slog.LevelInfo)
+
+	rr := httptest.NewRecorder()
+	MakeLogLevelHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/system/log-level", strings.NewReader(`{"level": "verbose"}`)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status code, want: %d, got: %d", http.StatusBadRequest, rr.Code)
+	}
+	if got := logging.Level(); got != slog.LevelInfo {
+		t.Errorf("want the level to be unchanged, got: %s", got)
+	}
+}
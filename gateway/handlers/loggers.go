@@ -0,0 +1,17 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"github.com/openfaas/faas/gateway/pkg/logging"
+)
+
+// Loggers of the components served by the handlers
+var (
+	logger        = logging.For(logging.ComponentGateway)
+	proxyLogger   = logging.For(logging.ComponentProxy)
+	scalingLogger = logging.For(logging.ComponentScaling)
+	alertsLogger  = logging.For(logging.ComponentAlerts)
+	queueLogger   = logging.For(logging.ComponentQueue)
+)
@@ -4,12 +4,13 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
 	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 )
 
 const crlf = "\r\n"
@@ -25,7 +26,10 @@ func NewLogHandlerFunc(logProvider url.URL, timeout time.Duration) http.HandlerF
 	upstreamLogProviderBase := strings.TrimSuffix(logProvider.String(), "/")
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancelQuery := context.WithTimeout(r.Context(), timeout)
+		ctx, span := tracing.Start(r.Context(), "logs", tracing.KindClient)
+		defer span.End()
+
+		ctx, cancelQuery := context.WithTimeout(ctx, timeout)
 		defer cancelQuery()
 
 		if r.Body != nil {
@@ -39,33 +43,36 @@ func NewLogHandlerFunc(logProvider url.URL, timeout time.Duration) http.HandlerF
 
 		cn, ok := w.(http.CloseNotifier)
 		if !ok {
-			log.Println("LogHandler: response is not a CloseNotifier, required for streaming response")
+			proxyLogger.ErrorContext(r.Context(), "log response is not a CloseNotifier, required for streaming response")
 			http.NotFound(w, r)
 			return
 		}
 
 		wf, ok := w.(writerFlusher)
 		if !ok {
-			log.Println("LogHandler: response is not a Flusher, required for streaming response")
+			proxyLogger.ErrorContext(r.Context(), "log response is not a Flusher, required for streaming response")
 			http.NotFound(w, r)
 			return
 		}
 
 		if writeRequestURI {
-			log.Printf("LogProxy: proxying request to %s %s\n", logRequest.Host, logRequest.URL.String())
+			proxyLogger.InfoContext(r.Context(), "proxying log request", "host", logRequest.Host, "url", logRequest.URL.String())
 		}
 
 		ctx, cancel := context.WithCancel(ctx)
 		logRequest = logRequest.WithContext(ctx)
 		defer cancel()
+		tracing.Inject(ctx, logRequest.Header)
 
 		logResp, err := http.DefaultTransport.RoundTrip(logRequest)
 		if err != nil {
-			log.Printf("LogProxy: forwarding request failed: %s\n", err.Error())
+			proxyLogger.ErrorContext(ctx, "log request failed", "error", err)
+			span.SetError(err)
 			http.Error(w, "log request failed", http.StatusInternalServerError)
 			return
 		}
 		defer logResp.Body.Close()
+		span.SetStatusCode(logResp.StatusCode)
 
 		switch logResp.StatusCode {
 		case http.StatusNotFound, http.StatusNotImplemented:
@@ -77,11 +84,11 @@ func NewLogHandlerFunc(logProvider url.URL, timeout time.Duration) http.HandlerF
 			select {
 			case err := <-copyNotify(&unbufferedWriter{wf}, logResp.Body):
 				if err != nil {
-					log.Printf("LogProxy: error while copy: %s", err.Error())
+					proxyLogger.ErrorContext(ctx, "unable to copy logs", "error", err)
 					return
 				}
 			case <-cn.CloseNotify():
-				log.Printf("LogProxy: client connection closed")
+				proxyLogger.DebugContext(ctx, "log client connection closed")
 				return
 			}
 		default:
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/openfaas/faas-provider/httputil"
+	"github.com/openfaas/faas/gateway/types"
 )
 
 // MakeNotifierWrapper wraps a http.HandlerFunc in an interceptor to pass to HTTPNotifier
@@ -16,11 +17,24 @@ func MakeNotifierWrapper(next http.HandlerFunc, notifiers []HTTPNotifier) http.H
 		then := time.Now()
 		url := r.URL.String()
 
+		requestBody := countBody(r)
 		writer := httputil.NewHttpWriteInterceptor(w)
-		next(writer, r)
+		responseBody := &countingWriter{ResponseWriter: writer}
+		next(responseBody, r)
 
 		for _, notifier := range notifiers {
-			notifier.Notify(r.Method, url, url, writer.Status(), "completed", time.Since(then))
+			notifier.Notify(types.HTTPNotification{
+				Method:        r.Method,
+				URL:           url,
+				OriginalURL:   url,
+				StatusCode:    writer.Status(),
+				Event:         "completed",
+				Duration:      time.Since(then),
+				RequestBytes:  requestBody.Bytes(),
+				ResponseBytes: responseBody.bytes,
+				CallID:        r.Header.Get("X-Call-Id"),
+				TraceID:       sampledTraceID(r.Context()),
+			})
 		}
 	}
 }
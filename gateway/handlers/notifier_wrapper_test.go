@@ -5,13 +5,17 @@ package handlers
 
 import (
 	"bytes"
-	"fmt"
-	"log"
+	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
-	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/logging"
+	"github.com/openfaas/faas/gateway/types"
 )
 
 func Test_MakeNotifierWrapper_ReceivesHttpStatusInNotifier(t *testing.T) {
@@ -85,11 +89,31 @@ func Test_MakeNotifierWrapper_ReceivesDefaultHttpStatusWhenNotSet(t *testing.T)
 
 type testNotifier struct {
 	StatusReceived int
+	Notifications  []types.HTTPNotification
 }
 
 // Notify about service metrics
-func (tf *testNotifier) Notify(method string, URL string, originalURL string, statusCode int, event string, duration time.Duration) {
-	tf.StatusReceived = statusCode
+func (tf *testNotifier) Notify(n types.HTTPNotification) {
+	tf.StatusReceived = n.StatusCode
+	tf.Notifications = append(tf.Notifications, n)
+}
+
+func Test_MakeNotifierWrapper_CountsBodies(t *testing.T) {
+	notifier := &testNotifier{}
+
+	handler := MakeNotifierWrapper(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("queued"))
+	}, []HTTPNotifier{notifier})
+
+	req := httptest.NewRequest(http.MethodPost, "/async-function/echo", strings.NewReader("hello world"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	n := notifier.Notifications[0]
+	if n.RequestBytes != 11 || n.ResponseBytes != 6 {
+		t.Fatalf("want 11 bytes in and 6 out, got: %d, %d", n.RequestBytes, n.ResponseBytes)
+	}
 }
 
 func TestLoggingMiddleware(t *testing.T) {
@@ -184,9 +208,8 @@ func TestLoggingMiddleware(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			var b bytes.Buffer
-			log.SetOutput(&b)
-			log.SetFlags(0)
-			log.SetPrefix("")
+			logging.Configure(&b, logging.FormatJSON, slog.LevelInfo)
+			defer logging.Configure(os.Stderr, logging.FormatText, slog.LevelInfo)
 
 			handler := MakeNotifierWrapper(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(tc.status)
@@ -201,11 +224,22 @@ func TestLoggingMiddleware(t *testing.T) {
 				t.Fatalf("unexpected status code, expected %d, got %d", tc.status, rec.Code)
 			}
 
-			logs := b.String()
+			var record struct {
+				Msg       string `json:"msg"`
+				Component string `json:"component"`
+				Method    string `json:"method"`
+				URL       string `json:"url"`
+				Status    int    `json:"status"`
+			}
+			if err := json.Unmarshal(b.Bytes(), &record); err != nil {
+				t.Fatalf("unable to decode log: %s, got: %q", err, b.String())
+			}
 
-			prefix := fmt.Sprintf("Forwarded [%s] to %s - [%d] -", tc.method, tc.path, tc.status)
-			if !strings.HasPrefix(logs, prefix) {
-				t.Fatalf("expected log to start with: %q\ngot: %q", prefix, logs)
+			if record.Msg != "forwarded" || record.Component != logging.ComponentProxy {
+				t.Fatalf("want a forwarded record of the proxy, got: %q", b.String())
+			}
+			if record.Method != tc.method || record.URL != tc.path || record.Status != tc.status {
+				t.Fatalf("want %s %s %d, got: %q", tc.method, tc.path, tc.status, b.String())
 			}
 		})
 	}
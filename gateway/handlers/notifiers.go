@@ -2,19 +2,20 @@ package handlers
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
-	"time"
+	"unicode/utf8"
 
 	"github.com/openfaas/faas/gateway/metrics"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/scaling"
+	"github.com/openfaas/faas/gateway/types"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // HTTPNotifier notify about HTTP request/response
 type HTTPNotifier interface {
-	Notify(method string, URL string, originalURL string, statusCode int, event string, duration time.Duration)
+	Notify(notification types.HTTPNotification)
 }
 
 func urlToLabel(path string) string {
@@ -27,47 +28,134 @@ func urlToLabel(path string) string {
 	return path
 }
 
+// FunctionLister reports whether a function is deployed, so that the
+// invocations of unknown functions are recorded under a single label
+type FunctionLister interface {
+	FunctionExists(serviceName string) bool
+}
+
+// functionLabel returns the function_name to record for serviceName
+func functionLabel(functions FunctionLister, serviceName string) string {
+	if functions != nil && !functions.FunctionExists(serviceName) {
+		return metrics.UnknownFunctionName
+	}
+	return serviceName
+}
+
+// maxExemplarCallID bounds the length of a caller's X-Call-Id recorded in
+// an exemplar, the labels of an exemplar are limited to 128 runes
+const maxExemplarCallID = 64
+
 // PrometheusFunctionNotifier records metrics to Prometheus
 type PrometheusFunctionNotifier struct {
 	Metrics *metrics.MetricOptions
 	//FunctionNamespace default namespace of the function
 	FunctionNamespace string
+	// FunctionQuery looks up each function's buckets annotation, the
+	// default buckets are used when nil
+	FunctionQuery scaling.FunctionQuery
+	// Functions folds invocations of functions which are not deployed into
+	// metrics.UnknownFunctionName, every function is recorded when nil
+	Functions FunctionLister
 }
 
 // Notify records metrics in Prometheus
-func (p PrometheusFunctionNotifier) Notify(method string, URL string, originalURL string, statusCode int, event string, duration time.Duration) {
-	serviceName := middleware.GetServiceName(originalURL)
+func (p PrometheusFunctionNotifier) Notify(n types.HTTPNotification) {
+	serviceName := middleware.GetServiceName(n.OriginalURL)
 	if len(p.FunctionNamespace) > 0 {
 		if !strings.Contains(serviceName, ".") {
 			serviceName = fmt.Sprintf("%s.%s", serviceName, p.FunctionNamespace)
 		}
 	}
 
-	code := strconv.Itoa(statusCode)
+	serviceName = functionLabel(p.Functions, serviceName)
+
+	code := strconv.Itoa(n.StatusCode)
 	labels := prometheus.Labels{"function_name": serviceName, "code": code}
 
-	if event == "completed" {
-		seconds := duration.Seconds()
+	if n.Event == "completed" {
+		name, namespace := middleware.GetNamespace(p.FunctionNamespace, serviceName)
+		if serviceName == metrics.UnknownFunctionName {
+			// The namespace of an unknown function is not bounded either
+			name, namespace = "", ""
+		}
+
+		annotation := ""
+		if p.FunctionQuery != nil && len(name) > 0 {
+			if annotations, err := p.FunctionQuery.GetAnnotations(name, namespace); err == nil {
+				annotation = annotations[metrics.BucketsAnnotation]
+			}
+		}
+
 		p.Metrics.GatewayFunctionsHistogram.
-			With(labels).
-			Observe(seconds)
+			Observe(serviceName, namespace, code, annotation, n.Duration.Seconds(), exemplarLabels(n))
 
 		p.Metrics.GatewayFunctionInvocation.
 			With(labels).
 			Inc()
-	} else if event == "started" {
+	} else if n.Event == "started" {
 		p.Metrics.GatewayFunctionInvocationStarted.WithLabelValues(serviceName).Inc()
 	}
 
 }
 
+// exemplarLabels links an observation to its trace and call ID
+func exemplarLabels(n types.HTTPNotification) prometheus.Labels {
+	labels := prometheus.Labels{}
+	if len(n.TraceID) > 0 {
+		labels["trace_id"] = n.TraceID
+	}
+	if len(n.CallID) > 0 && len(n.CallID) <= maxExemplarCallID && utf8.ValidString(n.CallID) {
+		labels["call_id"] = n.CallID
+	}
+	return labels
+}
+
+// PrometheusPayloadNotifier records the size of the request and response
+// bodies of each function invocation
+type PrometheusPayloadNotifier struct {
+	Metrics *metrics.MetricOptions
+	//FunctionNamespace default namespace of the function
+	FunctionNamespace string
+	// Functions folds invocations of functions which are not deployed into
+	// metrics.UnknownFunctionName, every function is recorded when nil
+	Functions FunctionLister
+}
+
+// Notify records the sizes of a "completed" invocation
+func (p PrometheusPayloadNotifier) Notify(n types.HTTPNotification) {
+	if n.Event != "completed" {
+		return
+	}
+
+	serviceName := middleware.GetServiceName(n.OriginalURL)
+	if len(serviceName) == 0 {
+		return
+	}
+	if len(p.FunctionNamespace) > 0 && !strings.Contains(serviceName, ".") {
+		serviceName = fmt.Sprintf("%s.%s", serviceName, p.FunctionNamespace)
+	}
+	serviceName = functionLabel(p.Functions, serviceName)
+
+	code := strconv.Itoa(n.StatusCode)
+	p.Metrics.FunctionRequestBytes.WithLabelValues(serviceName, code).Observe(float64(n.RequestBytes))
+	p.Metrics.FunctionResponseBytes.WithLabelValues(serviceName, code).Observe(float64(n.ResponseBytes))
+}
+
 // LoggingNotifier notifies a log about a request
 type LoggingNotifier struct {
 }
 
 // Notify the LoggingNotifier about a request
-func (LoggingNotifier) Notify(method string, URL string, originalURL string, statusCode int, event string, duration time.Duration) {
-	if event == "completed" {
-		log.Printf("Forwarded [%s] to %s - [%d] - %.4fs", method, originalURL, statusCode, duration.Seconds())
+func (LoggingNotifier) Notify(n types.HTTPNotification) {
+	if n.Event == "completed" {
+		args := []any{"method", n.Method, "url", n.OriginalURL, "status", n.StatusCode, "duration_seconds", n.Duration.Seconds()}
+		if len(n.CallID) > 0 {
+			args = append(args, "call_id", n.CallID)
+		}
+		if len(n.TraceID) > 0 {
+			args = append(args, "trace_id", n.TraceID)
+		}
+		proxyLogger.Info("forwarded", args...)
 	}
 }
@@ -1,6 +1,14 @@
 package handlers
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
 
 func Test_urlToLabel_normalizeTrailing(t *testing.T) {
 	have := "/system/functions/"
@@ -21,3 +29,128 @@ func Test_urlToLabel_retainRoot(t *testing.T) {
 		t.Errorf("want %s, got %s", want, got)
 	}
 }
+
+func Test_PrometheusFunctionNotifier_BucketsAndExemplar(t *testing.T) {
+	metricsOptions := metrics.BuildMetricsOptions()
+	notifier := PrometheusFunctionNotifier{
+		Metrics:           &metricsOptions,
+		FunctionNamespace: "openfaas-fn",
+		FunctionQuery:     fakeFunctionQuery{annotations: map[string]string{metrics.BucketsAnnotation: "0.1,1"}},
+	}
+
+	notifier.Notify(types.HTTPNotification{
+		Method:      "POST",
+		URL:         "/",
+		OriginalURL: "/function/echo",
+		StatusCode:  200,
+		Event:       "completed",
+		Duration:    time.Millisecond * 50,
+		CallID:      "4b1e2b4c",
+		TraceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+	})
+
+	ch := make(chan prometheus.Metric, 1)
+	metricsOptions.GatewayFunctionsHistogram.Collect(ch)
+
+	m := &dto.Metric{}
+	(<-ch).Write(m)
+
+	labels := map[string]string{}
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if labels["function_name"] != "echo.openfaas-fn" || labels["namespace"] != "openfaas-fn" || labels["code"] != "200" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+
+	buckets := m.GetHistogram().GetBucket()
+	if len(buckets) != 2 {
+		t.Fatalf("want the annotation's buckets, got: %v", buckets)
+	}
+
+	exemplar := map[string]string{}
+	for _, l := range buckets[0].GetExemplar().GetLabel() {
+		exemplar[l.GetName()] = l.GetValue()
+	}
+	if exemplar["call_id"] != "4b1e2b4c" || exemplar["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("unexpected exemplar: %v", exemplar)
+	}
+}
+
+func Test_PrometheusPayloadNotifier(t *testing.T) {
+	metricsOptions := metrics.BuildMetricsOptions()
+	notifier := PrometheusPayloadNotifier{Metrics: &metricsOptions, FunctionNamespace: "openfaas-fn"}
+
+	notifier.Notify(types.HTTPNotification{OriginalURL: "/function/echo", StatusCode: 200, Event: "started"})
+	notifier.Notify(types.HTTPNotification{OriginalURL: "/system/functions", StatusCode: 200, Event: "completed", RequestBytes: 10})
+	notifier.Notify(types.HTTPNotification{OriginalURL: "/function/echo", StatusCode: 200, Event: "completed", RequestBytes: 100, ResponseBytes: 5000})
+
+	m := &dto.Metric{}
+	metricsOptions.FunctionRequestBytes.WithLabelValues("echo.openfaas-fn", "200").(prometheus.Metric).Write(m)
+	if m.GetHistogram().GetSampleCount() != 1 || m.GetHistogram().GetSampleSum() != 100 {
+		t.Fatalf("want one request of 100 bytes, got: %v", m.GetHistogram())
+	}
+
+	m = &dto.Metric{}
+	metricsOptions.FunctionResponseBytes.WithLabelValues("echo.openfaas-fn", "200").(prometheus.Metric).Write(m)
+	if m.GetHistogram().GetSampleSum() != 5000 {
+		t.Fatalf("want a response of 5000 bytes, got: %v", m.GetHistogram())
+	}
+
+	ch := make(chan prometheus.Metric, 4)
+	metricsOptions.FunctionRequestBytes.Collect(ch)
+	if len(ch) != 1 {
+		t.Fatalf("want only the function to be recorded, got: %d series", len(ch))
+	}
+}
+
+type fakeFunctionLister map[string]bool
+
+func (f fakeFunctionLister) FunctionExists(serviceName string) bool {
+	return f[serviceName]
+}
+
+func Test_PrometheusNotifiers_FoldUnknownFunctions(t *testing.T) {
+	metricsOptions := metrics.BuildMetricsOptions()
+	functions := fakeFunctionLister{"echo.openfaas-fn": true}
+	notifiers := []HTTPNotifier{
+		PrometheusFunctionNotifier{Metrics: &metricsOptions, FunctionNamespace: "openfaas-fn", Functions: functions},
+		PrometheusPayloadNotifier{Metrics: &metricsOptions, FunctionNamespace: "openfaas-fn", Functions: functions},
+	}
+
+	for _, path := range []string{"/function/echo", "/function/missing", "/function/other.dev"} {
+		for _, notifier := range notifiers {
+			notifier.Notify(types.HTTPNotification{OriginalURL: path, StatusCode: 404, Event: "started"})
+			notifier.Notify(types.HTTPNotification{OriginalURL: path, StatusCode: 404, Event: "completed"})
+		}
+	}
+
+	m := &dto.Metric{}
+	metricsOptions.GatewayFunctionInvocation.WithLabelValues(metrics.UnknownFunctionName, "404").Write(m)
+	if m.GetCounter().GetValue() != 2 {
+		t.Fatalf("want both unknown functions to be folded, got: %v", m.GetCounter().GetValue())
+	}
+
+	for name, collector := range map[string]prometheus.Collector{
+		"invocation_total":   metricsOptions.GatewayFunctionInvocation,
+		"invocation_started": metricsOptions.GatewayFunctionInvocationStarted,
+		"functions_seconds":  metricsOptions.GatewayFunctionsHistogram,
+		"request_bytes":      metricsOptions.FunctionRequestBytes,
+	} {
+		ch := make(chan prometheus.Metric, 10)
+		collector.Collect(ch)
+		close(ch)
+		if len(ch) != 2 {
+			t.Fatalf("%s: want a series for echo and _unknown, got: %d", name, len(ch))
+		}
+		for metric := range ch {
+			m := &dto.Metric{}
+			metric.Write(m)
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "namespace" && l.GetValue() != "openfaas-fn" && l.GetValue() != "" {
+					t.Fatalf("%s: want no namespace for _unknown, got: %s", name, l.GetValue())
+				}
+			}
+		}
+	}
+}
@@ -4,31 +4,87 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	ftypes "github.com/openfaas/faas-provider/types"
 	"github.com/openfaas/faas/gateway/metrics"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 
 	"github.com/openfaas/faas/gateway/scaling"
 )
 
-// MakeQueuedProxy accepts work onto a queue
-func MakeQueuedProxy(metrics metrics.MetricOptions, queuer ftypes.RequestQueuer, pathTransformer middleware.URLPathTransformer, defaultNS string, functionQuery scaling.FunctionQuery) http.HandlerFunc {
+// queueAnnotation names the queue which a function's asynchronous requests
+// are published to, instead of the default queue
+const queueAnnotation = "com.openfaas.queue"
+
+// Reasons recorded when an asynchronous request is rejected
+const (
+	asyncRejectedTooLarge    = "too_large"
+	asyncRejectedBadCallback = "bad_callback"
+)
+
+// QueuedProxyConfig configures MakeQueuedProxy
+type QueuedProxyConfig struct {
+	// DefaultQueue is recorded as the queue of functions without a
+	// com.openfaas.queue annotation
+	DefaultQueue string
+
+	// MaxBodyBytes is the largest body accepted, larger requests receive a
+	// 413. 0 means no limit.
+	MaxBodyBytes int64
+
+	// Functions folds requests for functions which are not deployed into
+	// metrics.UnknownFunctionName, every function is recorded when nil
+	Functions FunctionLister
+}
+
+// MakeQueuedProxy accepts work onto a queue, the queue named by the
+// function's com.openfaas.queue annotation or else the default queue.
+// Requests accepted onto a queue are counted as started invocations.
+func MakeQueuedProxy(metrics metrics.MetricOptions, queuer ftypes.RequestQueuer, pathTransformer middleware.URLPathTransformer, defaultNS string, functionQuery scaling.FunctionQuery, config QueuedProxyConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		name := vars["name"]
+
+		serviceName := name
+		if len(defaultNS) > 0 && !strings.Contains(serviceName, ".") {
+			serviceName = fmt.Sprintf("%s.%s", serviceName, defaultNS)
+		}
+		label := functionLabel(config.Functions, serviceName)
+
+		queueName := getQueueName(name, defaultNS, functionQuery)
+		queueLabel := queueName
+		if len(queueLabel) == 0 {
+			queueLabel = config.DefaultQueue
+		}
+
 		var body []byte
 		if r.Body != nil {
 			defer r.Body.Close()
 
+			reader := r.Body
+			if config.MaxBodyBytes > 0 {
+				reader = http.MaxBytesReader(w, r.Body, config.MaxBodyBytes)
+			}
+
 			var err error
-			body, err = io.ReadAll(r.Body)
+			body, err = io.ReadAll(reader)
 			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					metrics.AsyncRejected.WithLabelValues(label, queueLabel, asyncRejectedTooLarge).Inc()
+					http.Error(w, fmt.Sprintf("request body is larger than %d bytes", config.MaxBodyBytes), http.StatusRequestEntityTooLarge)
+					return
+				}
+
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
@@ -36,12 +92,18 @@ func MakeQueuedProxy(metrics metrics.MetricOptions, queuer ftypes.RequestQueuer,
 
 		callbackURL, err := getCallbackURLHeader(r.Header)
 		if err != nil {
+			metrics.AsyncRejected.WithLabelValues(label, queueLabel, asyncRejectedBadCallback).Inc()
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		vars := mux.Vars(r)
-		name := vars["name"]
+		ctx, span := tracing.Start(r.Context(), "enqueue", tracing.KindProducer)
+		span.SetAttribute("faas.function", name)
+		defer span.End()
+
+		// The trace continues from the queue-worker's invocation
+		header := r.Header.Clone()
+		tracing.Inject(ctx, header)
 
 		req := &ftypes.QueueRequest{
 			Function:    name,
@@ -49,22 +111,52 @@ func MakeQueuedProxy(metrics metrics.MetricOptions, queuer ftypes.RequestQueuer,
 			Method:      r.Method,
 			QueryString: r.URL.RawQuery,
 			Path:        pathTransformer.Transform(r),
-			Header:      r.Header,
+			Header:      header,
 			Host:        r.Host,
 			CallbackURL: callbackURL,
+			QueueName:   queueName,
 		}
 
+		start := time.Now()
 		if err = queuer.Queue(req); err != nil {
-			log.Printf("Error queuing request: %v", err)
+			metrics.AsyncPublishFailures.WithLabelValues(label, queueLabel).Inc()
+
+			span.SetError(err)
+			queueLogger.ErrorContext(ctx, "unable to queue request", "queue", queueLabel, "error", err)
 			http.Error(w, fmt.Sprintf("Error queuing request: %s", err.Error()),
 				http.StatusInternalServerError)
 			return
 		}
 
+		metrics.AsyncEnqueueSeconds.WithLabelValues(label, queueLabel).Observe(time.Since(start).Seconds())
+		metrics.AsyncRequestBytes.WithLabelValues(label, queueLabel).Observe(float64(len(body)))
+		metrics.AsyncEnqueued.WithLabelValues(label, queueLabel).Inc()
+		metrics.GatewayFunctionInvocationStarted.WithLabelValues(label).Inc()
+
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
 
+// getQueueName returns the queue named by a function's annotation, or an
+// empty string for the default queue, including when the function can not
+// be queried
+func getQueueName(name, defaultNS string, functionQuery scaling.FunctionQuery) string {
+	if functionQuery == nil {
+		return ""
+	}
+
+	fn, ns := getNameParts(name)
+	if len(ns) == 0 {
+		ns = defaultNS
+	}
+
+	annotations, err := functionQuery.GetAnnotations(fn, ns)
+	if err != nil {
+		return ""
+	}
+	return annotations[queueAnnotation]
+}
+
 func getCallbackURLHeader(header http.Header) (*url.URL, error) {
 	value := header.Get("X-Callback-Url")
 	var callbackURL *url.URL
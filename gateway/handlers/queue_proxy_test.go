@@ -4,8 +4,19 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/gorilla/mux"
+	ftypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func Test_getNameParts(t *testing.T) {
@@ -72,3 +83,144 @@ func Test_getCallbackURLHeader_ParseFails(t *testing.T) {
 		t.Fatal("wanted a parsing error.")
 	}
 }
+
+type recordingQueuer struct {
+	requests []*ftypes.QueueRequest
+	err      error
+}
+
+func (q *recordingQueuer) Queue(req *ftypes.QueueRequest) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.requests = append(q.requests, req)
+	return nil
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	c.Write(m)
+	return m.GetCounter().GetValue()
+}
+
+func enqueue(handler http.HandlerFunc, name, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/async-function/"+name, strings.NewReader(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func Test_MakeQueuedProxy_InjectsTraceparent(t *testing.T) {
+	queuer := &recordingQueuer{}
+	handler := MakeQueuedProxy(metrics.BuildMetricsOptions(), queuer, middleware.TransparentURLPathTransformer{}, "openfaas-fn", nil, QueuedProxyConfig{})
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodPost, "/async-function/echo", nil)
+	req.Header.Set(tracing.TraceparentHeader, traceparent)
+	req = req.WithContext(tracing.Extract(req.Context(), req.Header))
+	req = mux.SetURLVars(req, map[string]string{"name": "echo"})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status code, want: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+	if len(queuer.requests) != 1 {
+		t.Fatalf("queued requests, want: 1, got: %d", len(queuer.requests))
+	}
+	if got := queuer.requests[0].Header.Get(tracing.TraceparentHeader); got != traceparent {
+		t.Fatalf("traceparent, want: %s, got: %s", traceparent, got)
+	}
+}
+
+func Test_MakeQueuedProxy_RecordsEnqueued(t *testing.T) {
+	metricsOptions := metrics.BuildMetricsOptions()
+	queuer := &recordingQueuer{}
+	functionQuery := fakeFunctionQuery{annotations: map[string]string{queueAnnotation: "slow-queue"}}
+	handler := MakeQueuedProxy(metricsOptions, queuer, middleware.TransparentURLPathTransformer{}, "openfaas-fn", functionQuery, QueuedProxyConfig{DefaultQueue: "faas-request"})
+
+	rr := enqueue(handler, "echo", "hello", nil)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status code, want: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+	if got := queuer.requests[0].QueueName; got != "slow-queue" {
+		t.Errorf("queue name, want: %s, got: %s", "slow-queue", got)
+	}
+	if got := counterValue(metricsOptions.AsyncEnqueued.WithLabelValues("echo.openfaas-fn", "slow-queue")); got != 1 {
+		t.Errorf("enqueued, want: 1, got: %v", got)
+	}
+	if got := counterValue(metricsOptions.GatewayFunctionInvocationStarted.WithLabelValues("echo.openfaas-fn")); got != 1 {
+		t.Errorf("invocations started, want: 1, got: %v", got)
+	}
+
+	m := &dto.Metric{}
+	metricsOptions.AsyncRequestBytes.WithLabelValues("echo.openfaas-fn", "slow-queue").(prometheus.Metric).Write(m)
+	if m.GetHistogram().GetSampleCount() != 1 || m.GetHistogram().GetSampleSum() != 5 {
+		t.Errorf("request bytes, want one body of 5 bytes, got: %d bodies of %v bytes", m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum())
+	}
+}
+
+func Test_MakeQueuedProxy_DefaultQueue(t *testing.T) {
+	metricsOptions := metrics.BuildMetricsOptions()
+	queuer := &recordingQueuer{}
+	handler := MakeQueuedProxy(metricsOptions, queuer, middleware.TransparentURLPathTransformer{}, "openfaas-fn", fakeFunctionQuery{}, QueuedProxyConfig{DefaultQueue: "faas-request"})
+
+	enqueue(handler, "echo.dev", "", nil)
+
+	if got := queuer.requests[0].QueueName; got != "" {
+		t.Errorf("want the default queue, got: %s", got)
+	}
+	if got := counterValue(metricsOptions.AsyncEnqueued.WithLabelValues("echo.dev", "faas-request")); got != 1 {
+		t.Errorf("enqueued, want: 1, got: %v", got)
+	}
+}
+
+func Test_MakeQueuedProxy_RecordsRejected(t *testing.T) {
+	metricsOptions := metrics.BuildMetricsOptions()
+	queuer := &recordingQueuer{}
+	handler := MakeQueuedProxy(metricsOptions, queuer, middleware.TransparentURLPathTransformer{}, "openfaas-fn", nil, QueuedProxyConfig{DefaultQueue: "faas-request", MaxBodyBytes: 4})
+
+	if rr := enqueue(handler, "echo", "hello", nil); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status code, want: %d, got: %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+	if rr := enqueue(handler, "echo", "hi", http.Header{"X-Callback-Url": []string{"ht tp://foo.com"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("status code, want: %d, got: %d", http.StatusBadRequest, rr.Code)
+	}
+
+	if len(queuer.requests) != 0 {
+		t.Fatalf("want no requests to be queued, got: %d", len(queuer.requests))
+	}
+	for _, reason := range []string{asyncRejectedTooLarge, asyncRejectedBadCallback} {
+		if got := counterValue(metricsOptions.AsyncRejected.WithLabelValues("echo.openfaas-fn", "faas-request", reason)); got != 1 {
+			t.Errorf("rejected %s, want: 1, got: %v", reason, got)
+		}
+	}
+	if got := counterValue(metricsOptions.GatewayFunctionInvocationStarted.WithLabelValues("echo.openfaas-fn")); got != 0 {
+		t.Errorf("want rejected requests not to be started, got: %v", got)
+	}
+}
+
+func Test_MakeQueuedProxy_RecordsPublishFailure(t *testing.T) {
+	metricsOptions := metrics.BuildMetricsOptions()
+	queuer := &recordingQueuer{err: fmt.Errorf("nats: connection closed")}
+	functions := fakeFunctionLister{"echo.openfaas-fn": true}
+	handler := MakeQueuedProxy(metricsOptions, queuer, middleware.TransparentURLPathTransformer{}, "openfaas-fn", nil, QueuedProxyConfig{DefaultQueue: "faas-request", Functions: functions})
+
+	if rr := enqueue(handler, "missing", "", nil); rr.Code != http.StatusInternalServerError {
+		t.Errorf("status code, want: %d, got: %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	if got := counterValue(metricsOptions.AsyncPublishFailures.WithLabelValues(metrics.UnknownFunctionName, "faas-request")); got != 1 {
+		t.Errorf("publish failures of unknown functions, want: 1, got: %v", got)
+	}
+	if got := counterValue(metricsOptions.AsyncEnqueued.WithLabelValues(metrics.UnknownFunctionName, "faas-request")); got != 0 {
+		t.Errorf("want a failed publish not to be enqueued, got: %v", got)
+	}
+}
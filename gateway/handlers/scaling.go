@@ -5,29 +5,43 @@ package handlers
 
 import (
 	"fmt"
-	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/openfaas/faas/gateway/pkg/middleware"
 	"github.com/openfaas/faas/gateway/scaling"
 )
 
+// ColdStartHeader is set to "triggered" on the response of a request which
+// scaled its function up from zero, or to "waited" when the request waited
+// for a scale up which was already in progress
+const ColdStartHeader = "X-Cold-Start"
+
 // MakeScalingHandler creates handler which can scale a function from
 // zero to N replica(s). After scaling the next http.HandlerFunc will
-// be called. If the function is not ready after the configured
-// amount of attempts / queries then next will not be invoked and a status
-// will be returned to the client.
+// be called. If the function is not ready before its cold start deadline
+// then next will not be invoked and a 504 will be returned to the client,
+// or a 503 if too many requests are already being held. Both include a
+// Retry-After header. Requests which triggered or waited for a scale up are
+// told so with the ColdStartHeader.
 func MakeScalingHandler(next http.HandlerFunc, scaler scaling.FunctionScaler, config scaling.ScalingConfig, defaultNamespace string) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		functionName, namespace := middleware.GetNamespace(defaultNamespace, middleware.GetServiceName(r.URL.String()))
 
-		res := scaler.Scale(functionName, namespace)
+		res := scaler.ScaleContext(r.Context(), functionName, namespace)
+
+		if res.Triggered {
+			w.Header().Set(ColdStartHeader, "triggered")
+		} else if res.Waited {
+			w.Header().Set(ColdStartHeader, "waited")
+		}
 
 		if !res.Found {
 			errStr := fmt.Sprintf("error finding function %s.%s: %s", functionName, namespace, res.Error.Error())
-			log.Printf("Scaling: %s\n", errStr)
+			scalingLogger.WarnContext(r.Context(), "function not found", "error", res.Error)
 
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte(errStr))
@@ -36,7 +50,7 @@ func MakeScalingHandler(next http.HandlerFunc, scaler scaling.FunctionScaler, co
 
 		if res.Error != nil {
 			errStr := fmt.Sprintf("error finding function %s.%s: %s", functionName, namespace, res.Error.Error())
-			log.Printf("Scaling: %s\n", errStr)
+			scalingLogger.ErrorContext(r.Context(), "unable to scale from zero", "error", res.Error)
 
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(errStr))
@@ -48,7 +62,23 @@ func MakeScalingHandler(next http.HandlerFunc, scaler scaling.FunctionScaler, co
 			return
 		}
 
-		log.Printf("[Scale] function=%s.%s 0=>N timed-out after %.4fs\n",
-			functionName, namespace, res.Duration.Seconds())
+		retryAfter := int(math.Ceil(config.RetryAfter.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+		if res.Rejected {
+			scalingLogger.WarnContext(r.Context(), "too many requests held while scaling from zero, rejected")
+
+			http.Error(w, fmt.Sprintf("function %s.%s is scaling up from zero, too many requests are waiting", functionName, namespace),
+				http.StatusServiceUnavailable)
+			return
+		}
+
+		scalingLogger.WarnContext(r.Context(), "timed out scaling from zero", "duration_seconds", res.Duration.Seconds())
+
+		http.Error(w, fmt.Sprintf("function %s.%s did not become ready after %.2fs", functionName, namespace, res.Duration.Seconds()),
+			http.StatusGatewayTimeout)
 	}
 }
@@ -0,0 +1,41 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// MakeScalingEventsHandler lists the scaling decisions recorded in history,
+// from oldest to newest. The function query parameter accepts a name with an
+// optional namespace i.e. "echo.openfaas-fn", without it every function's
+// decisions are listed.
+func MakeScalingEventsHandler(history *scaling.ScalingHistory, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		name, namespace := "", ""
+		if function := query.Get("function"); len(function) > 0 {
+			name, namespace = middleware.GetNamespace(defaultNamespace, function)
+			if ns := query.Get("namespace"); len(ns) > 0 {
+				namespace = ns
+			}
+		}
+
+		out, err := json.Marshal(history.Events(name, namespace))
+		if err != nil {
+			scalingLogger.ErrorContext(r.Context(), "unable to marshal scaling events", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
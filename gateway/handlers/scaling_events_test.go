@@ -0,0 +1,51 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+func Test_MakeScalingEventsHandler_FiltersByFunction(t *testing.T) {
+	history, _ := scaling.NewScalingHistory(10, "")
+	history.Record(scaling.ScalingEvent{Function: "echo", Namespace: "openfaas-fn", Trigger: scaling.TriggerAlert, OldReplicas: 1, NewReplicas: 2})
+	history.Record(scaling.ScalingEvent{Function: "figlet", Namespace: "openfaas-fn", Trigger: scaling.TriggerManual, NewReplicas: 3})
+	history.Record(scaling.ScalingEvent{Function: "echo", Namespace: "staging", Trigger: scaling.TriggerColdStart, NewReplicas: 1})
+
+	handler := MakeScalingEventsHandler(history, "openfaas-fn")
+
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{query: "", want: 3},
+		{query: "?function=echo", want: 1},
+		{query: "?function=echo.staging", want: 1},
+		{query: "?function=echo&namespace=staging", want: 1},
+		{query: "?function=nodeinfo", want: 0},
+	}
+
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/system/scaling/events"+c.query, nil)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q status code, want: %d, got: %d", c.query, http.StatusOK, rr.Code)
+		}
+
+		events := []scaling.ScalingEvent{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &events); err != nil {
+			t.Fatalf("%q unable to unmarshal: %s", c.query, err)
+		}
+		if len(events) != c.want {
+			t.Fatalf("%q events, want: %d, got: %d", c.query, c.want, len(events))
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+type zeroReplicaQuery struct {
+}
+
+func (zeroReplicaQuery) GetReplicas(service, namespace string) (scaling.ServiceQueryResponse, error) {
+	return scaling.ServiceQueryResponse{MinReplicas: 1, Replicas: 1}, nil
+}
+
+func (zeroReplicaQuery) SetReplicas(service, namespace string, count uint64) error {
+	return nil
+}
+
+func Test_MakeScalingHandler_TimeoutWritesRetryAfter(t *testing.T) {
+	config := scaling.ScalingConfig{
+		MaxPollCount:         uint(1000),
+		SetScaleRetries:      uint(1),
+		FunctionPollInterval: time.Millisecond * 5,
+		CacheExpiry:          time.Millisecond * 1,
+		ServiceQuery:         zeroReplicaQuery{},
+		ColdStartTimeout:     time.Millisecond * 30,
+		RetryAfter:           time.Second * 5,
+	}
+	scaler := scaling.NewFunctionScaler(config, scaling.NewFunctionCache(config.CacheExpiry))
+
+	visited := false
+	handler := MakeScalingHandler(func(w http.ResponseWriter, r *http.Request) {
+		visited = true
+	}, scaler, config, "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/function/echo", nil))
+
+	if visited {
+		t.Fatalf("want the function not to be invoked after a timeout")
+	}
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("status want: %d, got: %d", http.StatusGatewayTimeout, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After want: %s, got: %s", "5", got)
+	}
+}
+
+func Test_MakeScalingHandler_TimeoutWritesColdStartWaited(t *testing.T) {
+	config := scaling.ScalingConfig{
+		MaxPollCount:         uint(1000),
+		SetScaleRetries:      uint(1),
+		FunctionPollInterval: time.Millisecond * 5,
+		CacheExpiry:          time.Millisecond * 1,
+		ServiceQuery:         zeroReplicaQuery{},
+		ColdStartTimeout:     time.Millisecond * 30,
+	}
+	scaler := scaling.NewFunctionScaler(config, scaling.NewFunctionCache(config.CacheExpiry))
+
+	handler := MakeScalingHandler(func(w http.ResponseWriter, r *http.Request) {}, scaler, config, "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/function/echo", nil))
+
+	if got := rr.Header().Get(ColdStartHeader); got != "waited" {
+		t.Errorf("%s want: %s, got: %q", ColdStartHeader, "waited", got)
+	}
+}
+
+type scaleFromZeroQuery struct {
+	lock     sync.Mutex
+	replicas uint64
+}
+
+func (q *scaleFromZeroQuery) GetReplicas(service, namespace string) (scaling.ServiceQueryResponse, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return scaling.ServiceQueryResponse{MinReplicas: 1, Replicas: q.replicas, AvailableReplicas: q.replicas}, nil
+}
+
+func (q *scaleFromZeroQuery) SetReplicas(service, namespace string, count uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.replicas = count
+	return nil
+}
+
+func Test_MakeScalingHandler_ScaleUpWritesColdStartTriggered(t *testing.T) {
+	config := scaling.ScalingConfig{
+		MaxPollCount:         uint(1000),
+		SetScaleRetries:      uint(1),
+		FunctionPollInterval: time.Millisecond * 5,
+		CacheExpiry:          time.Millisecond * 1,
+		ServiceQuery:         &scaleFromZeroQuery{},
+		ColdStartTimeout:     time.Second,
+	}
+	scaler := scaling.NewFunctionScaler(config, scaling.NewFunctionCache(config.CacheExpiry))
+
+	visited := false
+	handler := MakeScalingHandler(func(w http.ResponseWriter, r *http.Request) {
+		visited = true
+	}, scaler, config, "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/function/echo", nil))
+
+	if !visited {
+		t.Fatalf("want the function to be invoked after scaling up")
+	}
+	if got := rr.Header().Get(ColdStartHeader); got != "triggered" {
+		t.Errorf("%s want: %s, got: %q", ColdStartHeader, "triggered", got)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/function/echo", nil))
+
+	if got := rr.Header().Get(ColdStartHeader); got != "" {
+		t.Errorf("want no %s once the function is ready, got: %q", ColdStartHeader, got)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	providerTypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// MakeScheduleStatusHandler adds the active schedule profile of a function
+// to its status as the scaling.ScheduleActiveAnnotation and
+// scaling.ScheduleMinReplicasAnnotation annotations.
+func MakeScheduleStatusHandler(next http.HandlerFunc, resolver *scaling.ScheduleResolver, defaultNamespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseRecorder := httptest.NewRecorder()
+		next.ServeHTTP(responseRecorder, r)
+		upstreamCall := responseRecorder.Result()
+
+		defer upstreamCall.Body.Close()
+
+		upstreamBody, _ := io.ReadAll(upstreamCall.Body)
+
+		if upstreamCall.StatusCode == http.StatusOK {
+			if body, ok := addScheduleAnnotations(upstreamBody, resolver, r.URL.Query().Get("namespace"), defaultNamespace); ok {
+				upstreamBody = body
+				upstreamCall.Header.Del("Content-Length")
+			}
+		}
+
+		copyHeaders(w.Header(), &upstreamCall.Header)
+		w.WriteHeader(upstreamCall.StatusCode)
+		w.Write(upstreamBody)
+	}
+}
+
+func addScheduleAnnotations(body []byte, resolver *scaling.ScheduleResolver, namespace, defaultNamespace string) ([]byte, bool) {
+	var function providerTypes.FunctionStatus
+	if err := json.Unmarshal(body, &function); err != nil {
+		scalingLogger.Error("unable to unmarshal function status", "error", err)
+		return body, false
+	}
+
+	if len(function.Namespace) > 0 {
+		namespace = function.Namespace
+	}
+	if len(namespace) == 0 {
+		namespace = defaultNamespace
+	}
+
+	spec := scaling.ScheduleSpec{}
+	for _, values := range []*map[string]string{function.Annotations, function.Labels} {
+		if values == nil {
+			continue
+		}
+		if v := (*values)[scaling.ScheduleLabel]; len(v) > 0 {
+			spec.Schedule = v
+		}
+		if v := (*values)[scaling.ScheduleTimezoneLabel]; len(v) > 0 {
+			spec.Timezone = v
+		}
+	}
+
+	profile, found, err := resolver.Resolve(function.Name, namespace, spec, time.Now())
+	if err != nil {
+		scalingLogger.Warn("invalid schedule", "function", function.Name, "namespace", namespace, "error", err)
+		return body, false
+	}
+	if !found {
+		return body, false
+	}
+
+	annotations := map[string]string{}
+	if function.Annotations != nil {
+		annotations = *function.Annotations
+	}
+	annotations[scaling.ScheduleActiveAnnotation] = profile.Name
+	annotations[scaling.ScheduleMinReplicasAnnotation] = strconv.FormatUint(profile.MinReplicas, 10)
+
+	// Only the annotations are replaced, so that fields which the provider
+	// returns and FunctionStatus does not know about are passed through
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		scalingLogger.Error("unable to unmarshal function status", "error", err)
+		return body, false
+	}
+
+	rawAnnotations, err := json.Marshal(annotations)
+	if err != nil {
+		scalingLogger.Error("unable to marshal annotations", "error", err)
+		return body, false
+	}
+	fields["annotations"] = rawAnnotations
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		scalingLogger.Error("unable to marshal function status", "error", err)
+		return body, false
+	}
+
+	return out, true
+}
@@ -0,0 +1,85 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	providerTypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+func Test_MakeScheduleStatusHandler_AddsActiveProfile(t *testing.T) {
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"echo","namespace":"openfaas-fn","annotations":{"com.openfaas.scale.schedule":"always: * * * * * = 2"}}`))
+	}
+
+	handler := MakeScheduleStatusHandler(upstream, scaling.NewScheduleResolver(nil, "UTC"), "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/system/function/echo", nil)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code, want: %d, got: %d", http.StatusOK, rr.Code)
+	}
+
+	var status providerTypes.FunctionStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unable to unmarshal: %s", err)
+	}
+
+	annotations := *status.Annotations
+	if annotations[scaling.ScheduleActiveAnnotation] != "always" {
+		t.Fatalf("%s, want: always, got: %q", scaling.ScheduleActiveAnnotation, annotations[scaling.ScheduleActiveAnnotation])
+	}
+	if annotations[scaling.ScheduleMinReplicasAnnotation] != "2" {
+		t.Fatalf("%s, want: 2, got: %q", scaling.ScheduleMinReplicasAnnotation, annotations[scaling.ScheduleMinReplicasAnnotation])
+	}
+}
+
+func Test_MakeScheduleStatusHandler_KeepsUnknownFields(t *testing.T) {
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"echo","namespace":"openfaas-fn","providerField":{"tier":"gold"},"annotations":{"com.openfaas.scale.schedule":"always: * * * * * = 2"}}`))
+	}
+
+	handler := MakeScheduleStatusHandler(upstream, scaling.NewScheduleResolver(nil, "UTC"), "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/system/function/echo", nil)
+	handler.ServeHTTP(rr, req)
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rr.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("unable to unmarshal: %s", err)
+	}
+
+	if got := string(fields["providerField"]); got != `{"tier":"gold"}` {
+		t.Fatalf("providerField, want: %s, got: %s", `{"tier":"gold"}`, got)
+	}
+	if _, ok := fields["replicas"]; ok {
+		t.Fatalf("want no replicas field to be added, got: %s", fields["replicas"])
+	}
+}
+
+func Test_MakeScheduleStatusHandler_PassesThroughErrors(t *testing.T) {
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+
+	handler := MakeScheduleStatusHandler(upstream, scaling.NewScheduleResolver(nil, "UTC"), "openfaas-fn")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/system/function/echo", nil)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status code, want: %d, got: %d", http.StatusNotFound, rr.Code)
+	}
+}
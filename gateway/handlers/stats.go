@@ -0,0 +1,85 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openfaas/faas/gateway/stats"
+)
+
+const (
+	defaultStatsLimit = 10
+	maxStatsLimit     = 100
+)
+
+// StatsResponse lists the top functions over a window
+type StatsResponse struct {
+	Window string `json:"window"`
+
+	ByRate    []stats.FunctionStats `json:"byRate"`
+	ByErrors  []stats.FunctionStats `json:"byErrors"`
+	ByLatency []stats.FunctionStats `json:"byLatency"`
+}
+
+// MakeStatsHandler lists the functions with the highest request rate, the
+// most errors and the highest p99 latency. The window query parameter is
+// one of 1m, 5m, 1h or 24h, with a default of 5m, and limit bounds the
+// functions in each list.
+func MakeStatsHandler(store *stats.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		window := time.Minute * 5
+		if value := query.Get("window"); len(value) > 0 {
+			parsed, err := parseStatsWindow(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			window = parsed
+		}
+
+		limit := defaultStatsLimit
+		if value := query.Get("limit"); len(value) > 0 {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxStatsLimit {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxStatsLimit), http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		res := StatsResponse{Window: stats.FormatWindow(window)}
+		res.ByRate, res.ByErrors, res.ByLatency = store.Top(window, limit)
+
+		out, err := json.Marshal(res)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "unable to marshal stats", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
+
+// parseStatsWindow accepts one of stats.Windows
+func parseStatsWindow(value string) (time.Duration, error) {
+	window, err := time.ParseDuration(value)
+	if err == nil {
+		for _, w := range stats.Windows {
+			if w == window {
+				return window, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid window: %q, must be one of 1m, 5m, 1h or 24h", value)
+}
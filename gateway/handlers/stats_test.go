@@ -0,0 +1,50 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/stats"
+	"github.com/openfaas/faas/gateway/types"
+)
+
+func Test_MakeStatsHandler(t *testing.T) {
+	store := stats.NewStore("openfaas-fn", 0)
+	for _, url := range []string{"/function/echo", "/function/echo", "/function/figlet"} {
+		store.Notify(types.HTTPNotification{OriginalURL: url, StatusCode: 200, Event: "completed", Duration: time.Millisecond})
+	}
+	store.Notify(types.HTTPNotification{OriginalURL: "/function/figlet", StatusCode: 500, Event: "completed", Duration: time.Second})
+
+	handler := MakeStatsHandler(store)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/system/stats?window=1h&limit=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code, want: %d, got: %d", http.StatusOK, rr.Code)
+	}
+
+	res := StatsResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Window != "1h" || len(res.ByRate) != 1 || len(res.ByErrors) != 1 || len(res.ByLatency) != 1 {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+	if res.ByErrors[0].Name != "figlet" || res.ByLatency[0].Name != "figlet" {
+		t.Fatalf("want figlet to have the most errors and highest latency, got: %s", rr.Body.String())
+	}
+
+	for _, query := range []string{"?window=10m", "?window=soon", "?limit=0", "?limit=1000"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/system/stats"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s status code, want: %d, got: %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
@@ -5,17 +5,24 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	// Time zones are embedded for scheduled scaling, the image has no tzdata
+	_ "time/tzdata"
+
 	"github.com/gorilla/mux"
 	"github.com/openfaas/faas-provider/auth"
 	"github.com/openfaas/faas/gateway/handlers"
 	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/logging"
 	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 	"github.com/openfaas/faas/gateway/plugin"
 	"github.com/openfaas/faas/gateway/scaling"
+	"github.com/openfaas/faas/gateway/stats"
 	"github.com/openfaas/faas/gateway/types"
 	"github.com/openfaas/faas/gateway/version"
 	natsHandler "github.com/openfaas/nats-queue-worker/handler"
@@ -24,27 +31,66 @@ import (
 // NameExpression for a function / service
 const NameExpression = "-a-zA-Z_0-9."
 
+var logger = logging.For(logging.ComponentGateway)
+
+// readPeerToken reads the token shared by the gateway replicas, which must
+// not be empty
+func readPeerToken(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if len(token) == 0 {
+		return "", fmt.Errorf("%s is empty", name)
+	}
+	return token, nil
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		os.Exit(simulate(os.Args[2:], os.Stdout))
+	}
 
 	osEnv := types.OsEnv{}
 	readConfig := types.ReadConfig{}
 	config, configErr := readConfig.Read(osEnv)
 
 	if configErr != nil {
-		log.Fatalln(configErr)
+		fatal("invalid configuration", "error", configErr)
 	}
 	if !config.UseExternalProvider() {
-		log.Fatalln("You must provide an external provider via 'functions_provider_url' env-var.")
+		fatal("You must provide an external provider via 'functions_provider_url' env-var.")
 	}
 
-	fmt.Printf("OpenFaaS Gateway - Community Edition (CE)\n"+
-		"\nVersion: %s Commit: %s\nTimeouts: read=%s\twrite=%s\tupstream=%s\nFunction provider: %s\n\n",
-		version.BuildVersion(),
-		version.GitCommitSHA,
-		config.ReadTimeout,
-		config.WriteTimeout,
-		config.UpstreamTimeout,
-		config.FunctionsProviderURL)
+	if err := logging.Configure(os.Stderr, config.LogFormat, config.LogLevel); err != nil {
+		fatal("unable to configure logging", "error", err)
+	}
+
+	if config.LogFormat == logging.FormatText {
+		fmt.Printf("OpenFaaS Gateway - Community Edition (CE)\n"+
+			"\nVersion: %s Commit: %s\nTimeouts: read=%s\twrite=%s\tupstream=%s\nFunction provider: %s\n\n",
+			version.BuildVersion(),
+			version.GitCommitSHA,
+			config.ReadTimeout,
+			config.WriteTimeout,
+			config.UpstreamTimeout,
+			config.FunctionsProviderURL)
+	} else {
+		logger.Info("OpenFaaS Gateway - Community Edition (CE)",
+			"version", version.BuildVersion(),
+			"commit", version.GitCommitSHA,
+			"read_timeout", config.ReadTimeout.String(),
+			"write_timeout", config.WriteTimeout.String(),
+			"upstream_timeout", config.UpstreamTimeout.String(),
+			"functions_provider_url", config.FunctionsProviderURL.String())
+	}
 
 	// credentials is used for service-to-service auth
 	var credentials *auth.BasicAuthCredentials
@@ -57,7 +103,7 @@ func main() {
 		credentials, readErr = reader.Read()
 
 		if readErr != nil {
-			log.Panicf(readErr.Error())
+			fatal("unable to read basic auth credentials", "error", readErr)
 		}
 	}
 
@@ -65,8 +111,32 @@ func main() {
 
 	servicePollInterval := time.Second * 5
 
-	metricsOptions := metrics.BuildMetricsOptions()
+	// Expired entries are removed from the function caches on this interval
+	functionCacheSweepInterval := time.Second * 30
+	functionCacheExpiry := time.Millisecond * 250 // freshness of replica values before going stale
+
+	if len(config.TracingEndpoint) > 0 {
+		logger.Info("tracing enabled", "endpoint", config.TracingEndpoint, "sample_ratio", config.TracingSampleRatio)
+
+		tracer := tracing.NewTracer(tracing.Config{
+			SampleRatio: config.TracingSampleRatio,
+			Interval:    time.Second * 5,
+		}, tracing.NewOTLPExporter(config.TracingEndpoint, config.TracingServiceName, time.Second*10))
+		tracer.Start()
+		tracing.SetTracer(tracer)
+	}
+
+	metricsOptions := metrics.BuildMetricsOptionsWithConfig(metrics.MetricsConfig{
+		FunctionsHistogram: metrics.FunctionHistogramConfig{
+			Buckets:            config.FunctionHistogramBuckets,
+			Native:             config.FunctionHistogramNative,
+			NativeBucketFactor: config.FunctionHistogramNativeFactor,
+			MaxFunctions:       config.FunctionHistogramMaxFunctions,
+		},
+	})
 	exporter := metrics.NewExporter(metricsOptions, credentials, config.Namespace)
+	exporter.IncludeUsage = config.MetricsIncludeUsage
+	exporter.StaleSeriesGrace = config.MetricsStaleSeriesGrace
 	exporter.StartServiceWatcher(*config.FunctionsProviderURL, metricsOptions, "func", servicePollInterval)
 	metrics.RegisterExporter(exporter)
 
@@ -75,17 +145,6 @@ func main() {
 		config.MaxIdleConns,
 		config.MaxIdleConnsPerHost)
 
-	loggingNotifier := handlers.LoggingNotifier{}
-
-	prometheusNotifier := handlers.PrometheusFunctionNotifier{
-		Metrics:           &metricsOptions,
-		FunctionNamespace: config.Namespace,
-	}
-
-	functionNotifiers := []handlers.HTTPNotifier{loggingNotifier, prometheusNotifier}
-	forwardingNotifiers := []handlers.HTTPNotifier{loggingNotifier}
-	quietNotifier := []handlers.HTTPNotifier{}
-
 	urlResolver := middleware.SingleHostBaseURLResolver{BaseURL: config.FunctionsProviderURL.String()}
 	var functionURLResolver middleware.BaseURLResolver
 	var functionURLTransformer middleware.URLPathTransformer
@@ -102,20 +161,120 @@ func main() {
 	}
 
 	// externalServiceQuery is used to query metadata from the provider about a function
+	// The ceiling is applied on top of each function's own min and max labels
 	externalServiceQuery := plugin.NewExternalServiceQuery(*config.FunctionsProviderURL, serviceAuthInjector)
 
+	// The active schedule profile raises each function's min replicas
+	var scheduleResolver *scaling.ScheduleResolver
+	if config.ScaleSchedule {
+		schedulePolicy := scaling.SchedulePolicy{}
+		if len(config.ScaleSchedulePolicy) > 0 {
+			var err error
+			if schedulePolicy, err = scaling.LoadSchedulePolicy(config.ScaleSchedulePolicy); err != nil {
+				fatal("unable to load scale_schedule_policy", "error", err)
+			}
+		}
+
+		scheduleResolver = scaling.NewScheduleResolver(schedulePolicy, config.ScaleScheduleTimezone)
+		externalServiceQuery = scaling.NewScheduleServiceQuery(externalServiceQuery, scheduleResolver)
+	}
+
+	externalServiceQuery = scaling.NewCeilingServiceQuery(externalServiceQuery, config.MaxReplicas)
+
+	// This cache can be used to query a function's annotations, including the
+	// buckets of gateway_functions_seconds for the prometheusNotifier
+	functionAnnotationCache := scaling.NewFunctionCacheWithConfig(scaling.FunctionCacheConfig{
+		Name:           "query",
+		Expiry:         functionCacheExpiry,
+		NotFoundExpiry: config.FunctionCacheNotFoundTTL,
+		MaxEntries:     config.FunctionCacheMaxEntries,
+		Metrics:        &metricsOptions,
+	})
+	functionAnnotationCache.Start(functionCacheSweepInterval)
+	cachedFunctionQuery := scaling.NewCachedFunctionQuery(functionAnnotationCache, externalServiceQuery)
+
+	loggingNotifier := handlers.LoggingNotifier{}
+
+	prometheusNotifier := handlers.PrometheusFunctionNotifier{
+		Metrics:           &metricsOptions,
+		FunctionNamespace: config.Namespace,
+		FunctionQuery:     cachedFunctionQuery,
+		Functions:         exporter,
+	}
+
+	payloadNotifier := handlers.PrometheusPayloadNotifier{
+		Metrics:           &metricsOptions,
+		FunctionNamespace: config.Namespace,
+		Functions:         exporter,
+	}
+
+	// invocationTracker records the load of each function for the built-in
+	// autoscaler and the idler, it is pruned even when neither is enabled
+	invocationTracker := scaling.NewInvocationTracker(config.Namespace)
+	invocationTracker.Functions = exporter
+	trackerIdleWindow := scaling.AutoscalerConfig{
+		Interval:        config.AutoscalerInterval,
+		ScaleDownWindow: config.AutoscalerScaleDownWindow,
+	}.IdleWindow()
+	invocationTracker.Start(config.AutoscalerInterval, trackerIdleWindow)
+
+	functionNotifiers := []handlers.HTTPNotifier{loggingNotifier, prometheusNotifier, payloadNotifier, invocationTracker}
+
+	// invocationStats keeps rolling statistics for /system/stats, and for the
+	// list of functions when there is no Prometheus
+	var invocationStats *stats.Store
+	if config.InvocationStats {
+		invocationStats = stats.NewStore(config.Namespace, config.InvocationStatsMaxFunctions)
+		invocationStats.Functions = exporter
+		functionNotifiers = append(functionNotifiers, invocationStats)
+	}
+	forwardingNotifiers := []handlers.HTTPNotifier{loggingNotifier}
+	quietNotifier := []handlers.HTTPNotifier{}
+
+	// Every decision to scale a function is recorded for /system/scaling/events
+	scalingHistory, err := scaling.NewScalingHistory(config.ScalingHistorySize, config.ScalingHistoryFile)
+	if err != nil {
+		fatal("unable to load scaling_history_file", "error", err)
+	}
+
+	// The prewarmer is added to the notifiers before the proxy is built, so that
+	// it observes every invocation
+	var prewarmer *scaling.Prewarmer
+	if config.Prewarm {
+		prewarmer = scaling.NewPrewarmer(scaling.PrewarmerConfig{
+			Interval:     config.PrewarmInterval,
+			Lead:         config.PrewarmLead,
+			Threshold:    config.PrewarmThreshold,
+			MinWeeks:     config.PrewarmMinWeeks,
+			ServiceQuery: externalServiceQuery,
+			Functions:    exporter,
+			StateFile:    config.PrewarmStateFile,
+			Metrics:      &metricsOptions,
+			History:      scalingHistory,
+		}, config.Namespace, invocationTracker)
+
+		if len(config.PrewarmStateFile) > 0 {
+			if err := prewarmer.Load(); err != nil {
+				logger.Warn("unable to load prewarm_state_file", "error", err)
+			}
+		}
+
+		functionNotifiers = append(functionNotifiers, prewarmer)
+	}
+
 	scalingConfig := scaling.ScalingConfig{
 		MaxPollCount:         uint(1000),
 		SetScaleRetries:      uint(20),
 		FunctionPollInterval: time.Millisecond * 100,
-		CacheExpiry:          time.Millisecond * 250, // freshness of replica values before going stale
+		CacheExpiry:          functionCacheExpiry,
 		ServiceQuery:         externalServiceQuery,
+		ColdStartTimeout:     config.ColdStartTimeout,
+		MaxHeldRequests:      config.ColdStartMaxHeld,
+		RetryAfter:           time.Second * 5,
+		Metrics:              &metricsOptions,
+		History:              scalingHistory,
 	}
 
-	// This cache can be used to query a function's annotations.
-	functionAnnotationCache := scaling.NewFunctionCache(scalingConfig.CacheExpiry)
-	cachedFunctionQuery := scaling.NewCachedFunctionQuery(functionAnnotationCache, externalServiceQuery)
-
 	faasHandlers.Proxy = handlers.MakeCallIDMiddleware(
 		handlers.MakeForwardingProxyHandler(reverseProxy, functionNotifiers, functionURLResolver, functionURLTransformer, nil),
 	)
@@ -132,24 +291,139 @@ func main() {
 	faasHandlers.NamespaceListerHandler = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
 	faasHandlers.NamespaceMutatorHandler = handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector)
 
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{
+		ServiceQuery: externalServiceQuery,
+		Interval:     time.Second * 10,
+		DefaultPolicy: scaling.ScalingPolicy{
+			ScaleUpWindow:     config.AlertScaleUpWindow,
+			ScaleDownWindow:   config.AlertScaleDownWindow,
+			ScaleUpCooldown:   config.AlertScaleUpCooldown,
+			ScaleDownCooldown: config.AlertScaleDownCooldown,
+			MaxStepUp:         config.AlertMaxStepUp,
+			MaxStepDown:       config.AlertMaxStepDown,
+		},
+		History: scalingHistory,
+	})
+	stabilizer.Start()
+
 	faasHandlers.Alert = handlers.MakeNotifierWrapper(
-		handlers.MakeAlertHandler(externalServiceQuery, config.Namespace),
+		handlers.MakeAlertHandler(stabilizer, config.AlertDedupeWindow, config.Namespace),
 		quietNotifier,
 	)
 
+	if config.Autoscaler {
+		logger.Info("autoscaler enabled",
+			"interval", config.AutoscalerInterval.String(), "scale_down_window", config.AutoscalerScaleDownWindow.String())
+
+		autoscaler := scaling.NewAutoscaler(scaling.AutoscalerConfig{
+			Interval:        config.AutoscalerInterval,
+			ScaleDownWindow: config.AutoscalerScaleDownWindow,
+			ServiceQuery:    externalServiceQuery,
+			History:         scalingHistory,
+		}, invocationTracker)
+		autoscaler.Start()
+	}
+
+	if config.ScaleSchedule {
+		logger.Info("scheduled scaling enabled",
+			"interval", config.ScaleScheduleInterval.String(), "time_zone", config.ScaleScheduleTimezone)
+
+		faasHandlers.FunctionStatus = handlers.MakeScheduleStatusHandler(faasHandlers.FunctionStatus, scheduleResolver, config.Namespace)
+
+		scheduler := scaling.NewScheduler(scaling.SchedulerConfig{
+			Interval:     config.ScaleScheduleInterval,
+			ServiceQuery: externalServiceQuery,
+			Resolver:     scheduleResolver,
+			Lister:       exporter,
+			History:      scalingHistory,
+		})
+		scheduler.Start()
+	}
+
+	if prewarmer != nil {
+		logger.Info("pre-warming enabled",
+			"interval", config.PrewarmInterval.String(), "lead", config.PrewarmLead.String(), "threshold", config.PrewarmThreshold)
+
+		prewarmer.Start()
+	}
+
+	if config.ScaleToZero {
+		logger.Info("scale to zero enabled",
+			"interval", config.ScaleToZeroInterval.String(), "idle_duration", config.ScaleToZeroIdleDuration.String())
+
+		idler := scaling.NewIdler(scaling.IdlerConfig{
+			Interval:            config.ScaleToZeroInterval,
+			DefaultIdleDuration: config.ScaleToZeroIdleDuration,
+			ServiceQuery:        externalServiceQuery,
+			Lister:              exporter,
+			Metrics:             &metricsOptions,
+			History:             scalingHistory,
+		}, invocationTracker)
+		idler.Start()
+	}
+
 	faasHandlers.LogProxyHandler = handlers.NewLogHandlerFunc(*config.LogsProviderURL, config.WriteTimeout)
 
 	functionProxy := faasHandlers.Proxy
 
 	if config.ScaleFromZero {
-		scalingFunctionCache := scaling.NewFunctionCache(scalingConfig.CacheExpiry)
+		scalerCache := scaling.NewFunctionCacheWithConfig(scaling.FunctionCacheConfig{
+			Name:           "scaler",
+			Expiry:         scalingConfig.CacheExpiry,
+			NotFoundExpiry: config.FunctionCacheNotFoundTTL,
+			MaxEntries:     config.FunctionCacheMaxEntries,
+			Metrics:        &metricsOptions,
+		})
+		scalerCache.Start(functionCacheSweepInterval)
+
+		var scalingFunctionCache scaling.FunctionCacher = scalerCache
+
+		// Replicas share the cache and the scale ups in progress through
+		// their peers, so that a function is scaled by one replica
+		if len(config.PeerDiscovery) > 0 {
+			var peers scaling.PeerLister = scaling.StaticPeers(config.Peers)
+			if config.PeerDiscovery == types.PeerDiscoveryDNS {
+				dnsPeers, err := scaling.NewDNSPeers(config.Peers[0], time.Second*10)
+				if err != nil {
+					fatal("invalid value for peers", "error", err)
+				}
+				peers = dnsPeers
+			}
+
+			peerToken, err := readPeerToken(config.PeerTokenFile)
+			if err != nil {
+				fatal("unable to read peer_token_file", "error", err)
+			}
+
+			logger.Info("peer discovery enabled", "discovery", config.PeerDiscovery, "address", config.PeerAddress)
+
+			peer := scaling.NewPeer(scaling.PeerConfig{
+				Address:        config.PeerAddress,
+				Peers:          peers,
+				Token:          peerToken,
+				AuthInjector:   serviceAuthInjector,
+				GossipInterval: config.PeerGossipInterval,
+				LeaseTimeout:   time.Second * 10,
+				WaitTimeout:    time.Second * 5,
+			}, scalerCache)
+			peer.Start()
+
+			scalingFunctionCache = peer
+			scalingConfig.FlightGroup = peer
+			faasHandlers.Peer = peer.ServeHTTP
+		}
+
 		scaler := scaling.NewFunctionScaler(scalingConfig, scalingFunctionCache)
 		functionProxy = handlers.MakeScalingHandler(functionProxy, scaler, scalingConfig, config.Namespace)
 	}
 
+	// CORS is applied before scaling, so that preflight requests are answered
+	// by the gateway without waking up or invoking the function.
+	functionProxy = handlers.MakeFunctionCORSHandler(functionProxy, cachedFunctionQuery, config.Namespace)
+
 	if config.UseNATS() {
-		log.Println("Async enabled: Using NATS Streaming")
-		log.Println("Deprecation Notice: NATS Streaming is no longer maintained and won't receive updates from June 2023")
+		logger.Info("async enabled: using NATS Streaming")
+		logger.Warn("deprecation notice: NATS Streaming is no longer maintained and won't receive updates from June 2023")
 
 		maxReconnect := 60
 		interval := time.Second * 2
@@ -158,18 +432,53 @@ func main() {
 
 		natsQueue, queueErr := natsHandler.CreateNATSQueue(*config.NATSAddress, *config.NATSPort, *config.NATSClusterName, *config.NATSChannel, defaultNATSConfig)
 		if queueErr != nil {
-			log.Fatalln(queueErr)
+			fatal("unable to connect to NATS Streaming", "error", queueErr)
+		}
+
+		// Enqueued requests keep their function from being scaled to zero,
+		// but are not invocations for the autoscaler. Their payloads are
+		// recorded by the queued proxy in gateway_async_request_bytes.
+		queueNotifiers := []handlers.HTTPNotifier{loggingNotifier, scaling.TouchNotifier{Tracker: invocationTracker}}
+		if prewarmer != nil {
+			queueNotifiers = append(queueNotifiers, scaling.PrewarmEnqueueNotifier{Prewarmer: prewarmer})
+		}
+
+		queuedProxyConfig := handlers.QueuedProxyConfig{
+			DefaultQueue: *config.NATSChannel,
+			MaxBodyBytes: config.AsyncMaxBodyBytes,
+			Functions:    exporter,
 		}
 
 		faasHandlers.QueuedProxy = handlers.MakeNotifierWrapper(
-			handlers.MakeCallIDMiddleware(handlers.MakeQueuedProxy(metricsOptions, natsQueue, trimURLTransformer, config.Namespace, cachedFunctionQuery)),
-			forwardingNotifiers,
+			handlers.MakeCallIDMiddleware(handlers.MakeQueuedProxy(metricsOptions, natsQueue, trimURLTransformer, config.Namespace, cachedFunctionQuery, queuedProxyConfig)),
+			queueNotifiers,
 		)
 	}
 
-	prometheusQuery := metrics.NewPrometheusQuery(config.PrometheusHost, config.PrometheusPort, &http.Client{})
-	faasHandlers.ListFunctions = metrics.AddMetricsHandler(faasHandlers.ListFunctions, prometheusQuery)
-	faasHandlers.ScaleFunction = scaling.MakeHorizontalScalingHandler(handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector))
+	// The list of functions is returned without metrics when Prometheus does not respond
+	var functionMetrics metrics.FunctionMetricsQuery = invocationStats
+	if config.MetricsQueryBackend != types.MetricsQueryBackendMemory {
+		prometheusQuery, err := metrics.NewPrometheusQueryWithConfig(metrics.PrometheusQueryConfig{
+			URL:                config.PrometheusURL,
+			Timeout:            config.PrometheusTimeout,
+			BearerTokenFile:    config.PrometheusBearerTokenFile,
+			Username:           config.PrometheusUsername,
+			PasswordFile:       config.PrometheusPasswordFile,
+			CAFile:             config.PrometheusCAFile,
+			InsecureSkipVerify: config.PrometheusTLSInsecure,
+		})
+		if err != nil {
+			fatal("unable to configure the Prometheus client", "error", err)
+		}
+		functionMetrics = metrics.NewPrometheusFunctionMetrics(prometheusQuery)
+	}
+	faasHandlers.ListFunctions = metrics.AddMetricsHandler(faasHandlers.ListFunctions, functionMetrics, config.Namespace)
+	faasHandlers.ScaleFunction = scaling.MakeHorizontalScalingHandler(handlers.MakeForwardingProxyHandler(reverseProxy, forwardingNotifiers, urlResolver, nilURLTransformer, serviceAuthInjector), cachedFunctionQuery, scalingHistory, config.Namespace)
+	faasHandlers.ScalingEvents = handlers.MakeScalingEventsHandler(scalingHistory, config.Namespace)
+	if invocationStats != nil {
+		faasHandlers.Stats = handlers.MakeStatsHandler(invocationStats)
+	}
+	faasHandlers.LogLevel = handlers.MakeLogLevelHandler()
 
 	if credentials != nil {
 		faasHandlers.Alert =
@@ -196,6 +505,18 @@ func main() {
 			auth.DecorateWithBasicAuth(faasHandlers.NamespaceListerHandler, credentials)
 		faasHandlers.NamespaceMutatorHandler =
 			auth.DecorateWithBasicAuth(faasHandlers.NamespaceMutatorHandler, credentials)
+		faasHandlers.ScalingEvents =
+			auth.DecorateWithBasicAuth(faasHandlers.ScalingEvents, credentials)
+		faasHandlers.LogLevel =
+			auth.DecorateWithBasicAuth(faasHandlers.LogLevel, credentials)
+		if faasHandlers.Peer != nil {
+			faasHandlers.Peer =
+				auth.DecorateWithBasicAuth(faasHandlers.Peer, credentials)
+		}
+		if faasHandlers.Stats != nil {
+			faasHandlers.Stats =
+				auth.DecorateWithBasicAuth(faasHandlers.Stats, credentials)
+		}
 	}
 
 	r := mux.NewRouter()
@@ -214,6 +535,16 @@ func main() {
 	r.HandleFunc("/system/functions", faasHandlers.DeleteFunction).Methods(http.MethodDelete)
 	r.HandleFunc("/system/functions", faasHandlers.UpdateFunction).Methods(http.MethodPut)
 	r.HandleFunc("/system/scale-function/{name:["+NameExpression+"]+}", faasHandlers.ScaleFunction).Methods(http.MethodPost)
+	r.HandleFunc("/system/scaling/events", faasHandlers.ScalingEvents).Methods(http.MethodGet)
+	r.HandleFunc("/system/log-level", faasHandlers.LogLevel).Methods(http.MethodGet, http.MethodPut)
+
+	if faasHandlers.Stats != nil {
+		r.HandleFunc("/system/stats", faasHandlers.Stats).Methods(http.MethodGet)
+	}
+
+	if faasHandlers.Peer != nil {
+		r.PathPrefix("/system/peer/").Handler(faasHandlers.Peer).Methods(http.MethodPost)
+	}
 
 	r.HandleFunc("/system/secrets", faasHandlers.SecretHandler).Methods(http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
 	r.HandleFunc("/system/logs", faasHandlers.LogProxyHandler).Methods(http.MethodGet)
@@ -252,6 +583,15 @@ func main() {
 
 	r.Handle("/", http.RedirectHandler("/ui/", http.StatusMovedPermanently)).Methods(http.MethodGet)
 
+	var handler http.Handler = r
+	if config.SystemCORS != nil {
+		handler = handlers.DecorateWithCORSPolicy(r, *config.SystemCORS, "/system/")
+	}
+	handler = logging.Handler(handler, config.Namespace)
+	if len(config.TracingEndpoint) > 0 {
+		handler = tracing.Handler(handler)
+	}
+
 	tcpPort := 8080
 
 	s := &http.Server{
@@ -259,10 +599,10 @@ func main() {
 		ReadTimeout:    config.ReadTimeout,
 		WriteTimeout:   config.WriteTimeout,
 		MaxHeaderBytes: http.DefaultMaxHeaderBytes, // 1MB - can be overridden by setting Server.MaxHeaderBytes.
-		Handler:        r,
+		Handler:        handler,
 	}
 
-	log.Fatal(s.ListenAndServe())
+	fatal("server stopped", "error", s.ListenAndServe())
 }
 
 // runMetricsServer Listen on a separate HTTP port for Prometheus metrics to keep this accessible from
@@ -285,5 +625,5 @@ func runMetricsServer() {
 		Handler:        router,
 	}
 
-	log.Fatal(s.ListenAndServe())
+	fatal("server stopped", "error", s.ListenAndServe())
 }
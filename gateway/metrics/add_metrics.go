@@ -4,26 +4,67 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
-	"strconv"
+	"time"
 
 	types "github.com/openfaas/faas-provider/types"
 )
 
-// AddMetricsHandler wraps a http.HandlerFunc with Prometheus metrics
-func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQueryFetcher) http.HandlerFunc {
+// MetricsStatusHeader is set to "unavailable" on the list of functions when
+// Prometheus could not be queried, and the metrics are left empty
+const MetricsStatusHeader = "X-Metrics-Status"
+
+const (
+	minMetricsWindow = time.Minute
+	maxMetricsWindow = time.Hour * 24
+)
+
+// FunctionMetrics are added to each function when the list of functions is
+// requested with a window, i.e. ?window=5m
+type FunctionMetrics struct {
+	// Window is the range the rates and latencies are calculated over
+	Window string `json:"window"`
+
+	// RequestRate is the invocations per second
+	RequestRate float64 `json:"requestRate"`
+
+	// ErrorRate is the fraction of invocations which returned a 5xx status
+	ErrorRate float64 `json:"errorRate"`
+
+	// Latency quantiles in seconds, omitted when there were no invocations
+	LatencyP50 *float64 `json:"latencyP50Seconds,omitempty"`
+	LatencyP95 *float64 `json:"latencyP95Seconds,omitempty"`
+	LatencyP99 *float64 `json:"latencyP99Seconds,omitempty"`
+}
+
+// functionStatusWithMetrics is a function in the list of functions
+type functionStatusWithMetrics struct {
+	types.FunctionStatus
+
+	Metrics *FunctionMetrics `json:"metrics,omitempty"`
+}
+
+// AddMetricsHandler wraps a http.HandlerFunc with the metrics of each
+// function from functionMetrics, defaultNamespace is the gateway's
+// function_namespace, which is recorded for functions listed without a
+// namespace
+func AddMetricsHandler(handler http.HandlerFunc, functionMetrics FunctionMetricsQuery, defaultNamespace string) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
+		window, err := parseMetricsWindow(r.URL.Query().Get("window"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		recorder := httptest.NewRecorder()
 		handler.ServeHTTP(recorder, r)
 		upstreamCall := recorder.Result()
 
 		if upstreamCall.Body == nil {
-			log.Println("Upstream call had empty body.")
+			logger.ErrorContext(r.Context(), "list functions responded with an empty body")
 			return
 		}
 
@@ -31,18 +72,17 @@ func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQuery
 		upstreamBody, _ := io.ReadAll(upstreamCall.Body)
 
 		if recorder.Code != http.StatusOK {
-			log.Printf("List functions responded with code %d, body: %s",
-				recorder.Code,
-				string(upstreamBody))
+			logger.ErrorContext(r.Context(), "list functions responded with an error",
+				"status", recorder.Code, "body", string(upstreamBody))
 			http.Error(w, string(upstreamBody), recorder.Code)
 			return
 		}
 
 		var functions []types.FunctionStatus
 
-		err := json.Unmarshal(upstreamBody, &functions)
+		err = json.Unmarshal(upstreamBody, &functions)
 		if err != nil {
-			log.Printf("Metrics upstream error: %s, value: %s", err, string(upstreamBody))
+			logger.ErrorContext(r.Context(), "unable to parse list of functions", "body", string(upstreamBody), "error", err)
 
 			http.Error(w, "Unable to parse list of functions from provider", http.StatusInternalServerError)
 			return
@@ -53,23 +93,33 @@ func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQuery
 			functions[i].InvocationCount = 0
 		}
 
-		if len(functions) > 0 {
+		// Functions listed without a namespace are in the namespace that
+		// was requested, or in the default namespace
+		namespace := r.URL.Query().Get("namespace")
+		if len(namespace) == 0 {
+			namespace = defaultNamespace
+		}
 
-			ns := functions[0].Namespace
-			q := fmt.Sprintf(`sum(gateway_function_invocation_total{function_name=~".*.%s"}) by (function_name)`, ns)
-			// Restrict query results to only function names matching namespace suffix.
+		res := make([]functionStatusWithMetrics, len(functions))
+		for i := range functions {
+			res[i].FunctionStatus = functions[i]
+		}
 
-			results, err := prometheusQuery.Fetch(url.QueryEscape(q))
-			if err != nil {
-				// log the error but continue, the mixIn will correctly handle the empty results.
-				log.Printf("Error querying Prometheus: %s\n", err.Error())
+		if len(functions) > 0 {
+			if err := addMetrics(res, functionMetrics, namespace, window); err != nil {
+				// The functions are still listed, without their metrics
+				logger.WarnContext(r.Context(), "unable to query metrics, listing functions without them", "error", err)
+				w.Header().Set(MetricsStatusHeader, "unavailable")
+				for i := range res {
+					res[i].InvocationCount = 0
+					res[i].Metrics = nil
+				}
 			}
-			mixIn(&functions, results)
 		}
 
-		bytesOut, err := json.Marshal(functions)
+		bytesOut, err := json.Marshal(res)
 		if err != nil {
-			log.Printf("Error serializing functions: %s", err)
+			logger.ErrorContext(r.Context(), "unable to marshal functions", "error", err)
 			http.Error(w, "Error writing response after adding metrics", http.StatusInternalServerError)
 			return
 		}
@@ -80,27 +130,64 @@ func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQuery
 	}
 }
 
-func mixIn(functions *[]types.FunctionStatus, metrics *VectorQueryResponse) {
+// parseMetricsWindow parses the window query parameter, 0 is returned when
+// it is not set
+func parseMetricsWindow(value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
 
-	if functions == nil {
-		return
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window: %q", value)
+	}
+	if window < minMetricsWindow || window > maxMetricsWindow {
+		return 0, fmt.Errorf("window must be between %s and %s", minMetricsWindow, maxMetricsWindow)
 	}
+	return window.Truncate(time.Second), nil
+}
 
-	for i, function := range *functions {
-		for _, v := range metrics.Data.Result {
-
-			if v.Metric.FunctionName == fmt.Sprintf("%s.%s", function.Name, function.Namespace) {
-				metricValue := v.Value[1]
-				switch value := metricValue.(type) {
-				case string:
-					f, err := strconv.ParseFloat(value, 64)
-					if err != nil {
-						log.Printf("add_metrics: unable to convert value %q for metric: %s", value, err)
-						continue
-					}
-					(*functions)[i].InvocationCount += f
-				}
-			}
-		}
+// addMetrics queries the invocation count of each function, and the rates
+// and latencies over window when it is set
+func addMetrics(functions []functionStatusWithMetrics, functionMetrics FunctionMetricsQuery, namespace string, window time.Duration) error {
+	labels := make([]string, len(functions))
+	for i, function := range functions {
+		labels[i] = functionLabel(function.Name, function.Namespace, namespace)
+	}
+
+	counts, err := functionMetrics.InvocationCounts(labels)
+	if err != nil {
+		return err
+	}
+	for i := range functions {
+		functions[i].InvocationCount = counts[labels[i]]
+	}
+
+	if window == 0 {
+		return nil
+	}
+
+	windowMetrics, err := functionMetrics.WindowMetrics(labels, window)
+	if err != nil {
+		return err
+	}
+
+	for i := range functions {
+		m := windowMetrics[labels[i]]
+		m.Window = fmt.Sprintf("%ds", int(window.Seconds()))
+		functions[i].Metrics = &m
+	}
+
+	return nil
+}
+
+// functionLabel returns the function_name recorded for a function
+func functionLabel(name, namespace, defaultNamespace string) string {
+	if len(namespace) == 0 {
+		namespace = defaultNamespace
+	}
+	if len(namespace) == 0 {
+		return name
 	}
+	return fmt.Sprintf("%s.%s", name, namespace)
 }
@@ -2,9 +2,11 @@ package metrics
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -29,7 +31,7 @@ func Test_PrometheusMetrics_MixedInto_Services(t *testing.T) {
 	functionsHandler := makeFunctionsHandler()
 	fakeQuery := makeFakePrometheusQueryFetcher()
 
-	handler := AddMetricsHandler(functionsHandler, fakeQuery)
+	handler := AddMetricsHandler(functionsHandler, NewPrometheusFunctionMetrics(fakeQuery), "")
 
 	rr := httptest.NewRecorder()
 	request, _ := http.NewRequest(http.MethodGet, "/system/functions", nil)
@@ -56,15 +58,164 @@ func Test_PrometheusMetrics_MixedInto_Services(t *testing.T) {
 	}
 }
 
+// queryFetcher returns the first response whose key is in the query
+type queryFetcher struct {
+	responses [][2]string
+	queries   []string
+	err       error
+}
+
+func (q *queryFetcher) Fetch(query string) (*VectorQueryResponse, error) {
+	unescaped, _ := url.QueryUnescape(query)
+	q.queries = append(q.queries, unescaped)
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	queryRes := VectorQueryResponse{}
+	for _, response := range q.responses {
+		if strings.Contains(unescaped, response[0]) {
+			err := json.Unmarshal([]byte(response[1]), &queryRes)
+			return &queryRes, err
+		}
+	}
+	return &queryRes, nil
+}
+
+func vector(values map[string]string) string {
+	results := []string{}
+	for name, value := range values {
+		results = append(results, `{"metric":{"function_name":"`+name+`"},"value":[1509267827.752,"`+value+`"]}`)
+	}
+	return `{"status":"success","data":{"resultType":"vector","result":[` + strings.Join(results, ",") + `]}}`
+}
+
+func makeListHandler(functions []types.FunctionStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytesOut, _ := json.Marshal(functions)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bytesOut)
+	}
+}
+
+type listedFunction struct {
+	Name            string           `json:"name"`
+	Namespace       string           `json:"namespace"`
+	InvocationCount float64          `json:"invocationCount"`
+	Metrics         *FunctionMetrics `json:"metrics"`
+}
+
+func Test_AddMetricsHandler_MixedAndEmptyNamespaces(t *testing.T) {
+	fetcher := &queryFetcher{responses: [][2]string{
+		{"sum(gateway_function_invocation_total", vector(map[string]string{
+			"echo.openfaas-fn": "5",
+			"echo.dev":         "7",
+			"figlet.dev":       "3",
+		})},
+	}}
+	list := makeListHandler([]types.FunctionStatus{
+		{Name: "echo", Namespace: "openfaas-fn"},
+		{Name: "echo", Namespace: "dev"},
+		{Name: "figlet"},
+	})
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, "/system/functions?namespace=dev", nil)
+	AddMetricsHandler(list, NewPrometheusFunctionMetrics(fetcher), "openfaas-fn").ServeHTTP(rr, request)
+
+	results := []listedFunction{}
+	json.Unmarshal(rr.Body.Bytes(), &results)
+	if len(results) != 3 || results[0].InvocationCount != 5 || results[1].InvocationCount != 7 || results[2].InvocationCount != 3 {
+		t.Fatalf("unexpected invocation counts: %+v", results)
+	}
+	if results[0].Metrics != nil {
+		t.Fatalf("want no metrics without a window, got: %+v", results[0].Metrics)
+	}
+
+	want := "function_name=~`[^.]+\\.dev|[^.]+\\.openfaas-fn`"
+	if len(fetcher.queries) != 1 || !strings.Contains(fetcher.queries[0], want) {
+		t.Fatalf("want selector %s, got: %v", want, fetcher.queries)
+	}
+}
+
+func Test_AddMetricsHandler_Window(t *testing.T) {
+	fetcher := &queryFetcher{responses: [][2]string{
+		{`code=~"5.."`, vector(map[string]string{"echo": "0.5"})},
+		{"histogram_quantile(0.5,", vector(map[string]string{"echo": "0.1"})},
+		{"histogram_quantile(0.95,", vector(map[string]string{"echo": "0.4"})},
+		{"histogram_quantile(0.99,", vector(map[string]string{"echo": "NaN"})},
+		{"sum(rate(gateway_function_invocation", vector(map[string]string{"echo": "2"})},
+		{"sum(gateway_function_invocation_total", vector(map[string]string{"echo": "100"})},
+	}}
+	list := makeListHandler([]types.FunctionStatus{{Name: "echo"}})
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, "/system/functions?window=5m", nil)
+	AddMetricsHandler(list, NewPrometheusFunctionMetrics(fetcher), "").ServeHTTP(rr, request)
+
+	results := []listedFunction{}
+	json.Unmarshal(rr.Body.Bytes(), &results)
+	if len(results) != 1 || results[0].Metrics == nil {
+		t.Fatalf("want metrics, got: %s", rr.Body.String())
+	}
+
+	m := results[0].Metrics
+	if m.Window != "300s" || m.RequestRate != 2 || m.ErrorRate != 0.25 {
+		t.Fatalf("unexpected metrics: %+v", m)
+	}
+	if m.LatencyP50 == nil || *m.LatencyP50 != 0.1 || m.LatencyP95 == nil || *m.LatencyP95 != 0.4 || m.LatencyP99 != nil {
+		t.Fatalf("unexpected latencies: %+v", m)
+	}
+	if !strings.Contains(fetcher.queries[1], "function_name=~`echo`") || !strings.Contains(fetcher.queries[1], "[300s]") {
+		t.Fatalf("unexpected query: %s", fetcher.queries[1])
+	}
+}
+
+func Test_AddMetricsHandler_InvalidWindow(t *testing.T) {
+	for _, window := range []string{"soon", "10s", "48h"} {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/system/functions?window="+window, nil)
+		AddMetricsHandler(makeFunctionsHandler(), NewPrometheusFunctionMetrics(&queryFetcher{}), "").ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("window %s, want: %d, got: %d", window, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func Test_AddMetricsHandler_PrometheusUnavailable(t *testing.T) {
+	fetcher := &queryFetcher{err: errors.New("connection refused")}
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, "/system/functions?window=5m", nil)
+	AddMetricsHandler(makeFunctionsHandler(), NewPrometheusFunctionMetrics(fetcher), "").ServeHTTP(rr, request)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want the functions to be listed, got: %d", rr.Code)
+	}
+	if got := rr.Header().Get(MetricsStatusHeader); got != "unavailable" {
+		t.Fatalf("%s, want: unavailable, got: %q", MetricsStatusHeader, got)
+	}
+	if len(fetcher.queries) != 1 {
+		t.Fatalf("want the remaining queries to be skipped, got: %d", len(fetcher.queries))
+	}
+
+	results := []listedFunction{}
+	json.Unmarshal(rr.Body.Bytes(), &results)
+	if len(results) != 1 || results[0].Name != "func_echoit" || results[0].Metrics != nil {
+		t.Fatalf("unexpected functions: %s", rr.Body.String())
+	}
+}
+
 func Test_MetricHandler_ForwardsErrors(t *testing.T) {
 	functionsHandler := func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusConflict)
 		w.Write([]byte("test error case"))
 	}
-	// explicitly set the query fetcher to nil because it should
+	// explicitly set the metrics query to nil because it should
 	// not be called when a non-200 response is returned from the
 	// functions handler, if it is called then the test will panic
-	handler := AddMetricsHandler(functionsHandler, nil)
+	handler := AddMetricsHandler(functionsHandler, nil, "")
 
 	rr := httptest.NewRecorder()
 	request, _ := http.NewRequest(http.MethodGet, "/system/functions", nil)
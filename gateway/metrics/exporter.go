@@ -12,10 +12,9 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sync"
 	"time"
 
-	"log"
-
 	"github.com/openfaas/faas-provider/auth"
 	types "github.com/openfaas/faas-provider/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,8 +24,25 @@ import (
 type Exporter struct {
 	metricOptions     MetricOptions
 	services          []types.FunctionStatus
+	servicesLock      sync.RWMutex
 	credentials       *auth.BasicAuthCredentials
 	FunctionNamespace string
+
+	// IncludeUsage requests the CPU and memory usage of each function from
+	// the provider
+	IncludeUsage bool
+
+	// StaleSeriesGrace is how long a function can be missing from the
+	// provider's listing before its series are deleted, 0 keeps them
+	StaleSeriesGrace time.Duration
+
+	// known holds the functions of the last complete listing, it is nil
+	// until the first one
+	known map[string]bool
+
+	// missing holds when each recorded function was first found missing,
+	// it is only used by the service watcher
+	missing map[string]time.Time
 }
 
 // NewExporter creates a new exporter for the OpenFaaS gateway metrics
@@ -36,6 +52,7 @@ func NewExporter(options MetricOptions, credentials *auth.BasicAuthCredentials,
 		services:          []types.FunctionStatus{},
 		credentials:       credentials,
 		FunctionNamespace: namespace,
+		missing:           make(map[string]time.Time),
 	}
 }
 
@@ -46,6 +63,29 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	e.metricOptions.GatewayFunctionsHistogram.Describe(ch)
 	e.metricOptions.ServiceReplicasGauge.Describe(ch)
 	e.metricOptions.GatewayFunctionInvocationStarted.Describe(ch)
+	e.metricOptions.GatewayFunctionScaleToZero.Describe(ch)
+	e.metricOptions.ColdStartHeldRequests.Describe(ch)
+	e.metricOptions.ColdStartHoldSeconds.Describe(ch)
+	e.metricOptions.ColdStartDuration.Describe(ch)
+	e.metricOptions.ColdStarts.Describe(ch)
+	e.metricOptions.AsyncEnqueued.Describe(ch)
+	e.metricOptions.AsyncRejected.Describe(ch)
+	e.metricOptions.AsyncPublishFailures.Describe(ch)
+	e.metricOptions.AsyncEnqueueSeconds.Describe(ch)
+	e.metricOptions.AsyncRequestBytes.Describe(ch)
+	e.metricOptions.PrewarmForecast.Describe(ch)
+	e.metricOptions.PrewarmForecastError.Describe(ch)
+	e.metricOptions.PrewarmDecisions.Describe(ch)
+	e.metricOptions.FunctionCacheRequests.Describe(ch)
+	e.metricOptions.FunctionCacheEvictions.Describe(ch)
+	e.metricOptions.FunctionCacheEntries.Describe(ch)
+	e.metricOptions.FunctionRequestBytes.Describe(ch)
+	e.metricOptions.FunctionResponseBytes.Describe(ch)
+	e.metricOptions.ServiceDesiredReplicasGauge.Describe(ch)
+	e.metricOptions.ServiceAvailableReplicasGauge.Describe(ch)
+	e.metricOptions.ServiceCPUGauge.Describe(ch)
+	e.metricOptions.ServiceMemoryGauge.Describe(ch)
+	e.metricOptions.ServiceAgeGauge.Describe(ch)
 }
 
 // Collect collects data to be consumed by prometheus
@@ -55,9 +95,35 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 	e.metricOptions.GatewayFunctionInvocationStarted.Collect(ch)
 
+	e.metricOptions.GatewayFunctionScaleToZero.Collect(ch)
+	e.metricOptions.ColdStartHeldRequests.Collect(ch)
+	e.metricOptions.ColdStartHoldSeconds.Collect(ch)
+	e.metricOptions.ColdStartDuration.Collect(ch)
+	e.metricOptions.ColdStarts.Collect(ch)
+	e.metricOptions.AsyncEnqueued.Collect(ch)
+	e.metricOptions.AsyncRejected.Collect(ch)
+	e.metricOptions.AsyncPublishFailures.Collect(ch)
+	e.metricOptions.AsyncEnqueueSeconds.Collect(ch)
+	e.metricOptions.AsyncRequestBytes.Collect(ch)
+	e.metricOptions.PrewarmForecast.Collect(ch)
+	e.metricOptions.PrewarmForecastError.Collect(ch)
+	e.metricOptions.PrewarmDecisions.Collect(ch)
+	e.metricOptions.FunctionCacheRequests.Collect(ch)
+	e.metricOptions.FunctionCacheEvictions.Collect(ch)
+	e.metricOptions.FunctionCacheEntries.Collect(ch)
+	e.metricOptions.FunctionRequestBytes.Collect(ch)
+	e.metricOptions.FunctionResponseBytes.Collect(ch)
+
 	e.metricOptions.ServiceReplicasGauge.Reset()
+	e.metricOptions.ServiceDesiredReplicasGauge.Reset()
+	e.metricOptions.ServiceAvailableReplicasGauge.Reset()
+	e.metricOptions.ServiceCPUGauge.Reset()
+	e.metricOptions.ServiceMemoryGauge.Reset()
+	e.metricOptions.ServiceAgeGauge.Reset()
+
+	now := time.Now()
 
-	for _, service := range e.services {
+	for _, service := range e.Services() {
 		var serviceName string
 		if len(service.Namespace) > 0 {
 			serviceName = fmt.Sprintf("%s.%s", service.Name, service.Namespace)
@@ -69,9 +135,37 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		e.metricOptions.ServiceReplicasGauge.
 			WithLabelValues(serviceName).
 			Set(float64(service.Replicas))
+
+		e.metricOptions.ServiceDesiredReplicasGauge.
+			WithLabelValues(serviceName, service.Namespace).
+			Set(float64(service.Replicas))
+		e.metricOptions.ServiceAvailableReplicasGauge.
+			WithLabelValues(serviceName, service.Namespace).
+			Set(float64(service.AvailableReplicas))
+
+		// Usage is only reported by some providers, and when requested
+		if service.Usage != nil {
+			e.metricOptions.ServiceCPUGauge.
+				WithLabelValues(serviceName, service.Namespace).
+				Set(service.Usage.CPU)
+			e.metricOptions.ServiceMemoryGauge.
+				WithLabelValues(serviceName, service.Namespace).
+				Set(service.Usage.TotalMemoryBytes)
+		}
+
+		if !service.CreatedAt.IsZero() {
+			e.metricOptions.ServiceAgeGauge.
+				WithLabelValues(serviceName, service.Namespace).
+				Set(now.Sub(service.CreatedAt).Seconds())
+		}
 	}
 
 	e.metricOptions.ServiceReplicasGauge.Collect(ch)
+	e.metricOptions.ServiceDesiredReplicasGauge.Collect(ch)
+	e.metricOptions.ServiceAvailableReplicasGauge.Collect(ch)
+	e.metricOptions.ServiceCPUGauge.Collect(ch)
+	e.metricOptions.ServiceMemoryGauge.Collect(ch)
+	e.metricOptions.ServiceAgeGauge.Collect(ch)
 }
 
 // StartServiceWatcher starts a ticker and collects service replica counts to expose to prometheus
@@ -84,9 +178,13 @@ func (e *Exporter) StartServiceWatcher(endpointURL url.URL, metricsOptions Metri
 			select {
 			case <-ticker.C:
 
+				// Only a complete listing is used to find stale series
+				complete := true
+
 				namespaces, err := e.getNamespaces(endpointURL)
 				if err != nil {
-					log.Println(err)
+					logger.Error("unable to list namespaces", "error", err)
+					complete = false
 				}
 
 				services := []types.FunctionStatus{}
@@ -95,22 +193,29 @@ func (e *Exporter) StartServiceWatcher(endpointURL url.URL, metricsOptions Metri
 				if len(namespaces) == 0 {
 					services, err = e.getFunctions(endpointURL, e.FunctionNamespace)
 					if err != nil {
-						log.Println(err)
+						logger.Error("unable to list functions", "namespace", e.FunctionNamespace, "error", err)
 						continue
 					}
-					e.services = services
 				} else {
 					for _, namespace := range namespaces {
 						nsServices, err := e.getFunctions(endpointURL, namespace)
 						if err != nil {
-							log.Println(err)
+							logger.Error("unable to list functions", "namespace", namespace, "error", err)
+							complete = false
 							continue
 						}
 						services = append(services, nsServices...)
 					}
 				}
 
+				e.servicesLock.Lock()
 				e.services = services
+				e.servicesLock.Unlock()
+
+				if complete {
+					e.setKnown(services)
+					e.reconcile(time.Now())
+				}
 
 				break
 			case <-quit:
@@ -120,6 +225,14 @@ func (e *Exporter) StartServiceWatcher(endpointURL url.URL, metricsOptions Metri
 	}()
 }
 
+// Services returns the functions found by the service watcher
+func (e *Exporter) Services() []types.FunctionStatus {
+	e.servicesLock.RLock()
+	defer e.servicesLock.RUnlock()
+
+	return e.services
+}
+
 func (e *Exporter) getHTTPClient(timeout time.Duration) http.Client {
 
 	return http.Client{
@@ -142,11 +255,14 @@ func (e *Exporter) getFunctions(endpointURL url.URL, namespace string) ([]types.
 	proxyClient := e.getHTTPClient(timeout)
 
 	endpointURL.Path = path.Join(endpointURL.Path, "/system/functions")
+	q := endpointURL.Query()
 	if len(namespace) > 0 {
-		q := endpointURL.Query()
 		q.Set("namespace", namespace)
-		endpointURL.RawQuery = q.Encode()
 	}
+	if e.IncludeUsage {
+		q.Set("usage", "true")
+	}
+	endpointURL.RawQuery = q.Encode()
 
 	get, _ := http.NewRequest(http.MethodGet, endpointURL.String(), nil)
 	if e.credentials != nil {
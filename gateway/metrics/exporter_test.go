@@ -1,7 +1,12 @@
 package metrics
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	types "github.com/openfaas/faas-provider/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -48,7 +53,7 @@ func Test_Describe_DescribesThePrometheusMetrics(t *testing.T) {
 	}
 
 	d = <-ch
-	expectedGatewayFunctionsHistogramDesc := `Desc{fqName: "gateway_functions_seconds", help: "Function time taken", constLabels: {}, variableLabels: {function_name,code}}`
+	expectedGatewayFunctionsHistogramDesc := `Desc{fqName: "gateway_functions_seconds", help: "Function time taken", constLabels: {}, variableLabels: {function_name,namespace,code}}`
 	actualGatewayFunctionsHistogramDesc := d.String()
 	if expectedGatewayFunctionsHistogramDesc != actualGatewayFunctionsHistogramDesc {
 		t.Errorf("Want\n%s\ngot\n%s", expectedGatewayFunctionsHistogramDesc, actualGatewayFunctionsHistogramDesc)
@@ -90,3 +95,150 @@ func Test_Collect_CollectsTheNumberOfReplicasOfAService(t *testing.T) {
 	ch = nil
 
 }
+
+func Test_Collect_ReplicaUsageAndAgeGauges(t *testing.T) {
+	metricsOptions := BuildMetricsOptions()
+	exporter := NewExporter(metricsOptions, nil, "openfaas-fn")
+
+	exporter.services = []types.FunctionStatus{
+		{
+			Name:              "echo",
+			Namespace:         "openfaas-fn",
+			Replicas:          3,
+			AvailableReplicas: 2,
+			CreatedAt:         time.Now().Add(-time.Hour),
+			Usage:             &types.FunctionUsage{CPU: 250, TotalMemoryBytes: 1024 * 1024},
+		},
+		{
+			Name:      "figlet",
+			Namespace: "dev",
+			Replicas:  1,
+		},
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	exporter.Collect(ch)
+	close(ch)
+
+	values := map[string]metricResult{}
+	count := map[string]int{}
+	for metric := range ch {
+		desc := metric.Desc().String()
+		if !strings.Contains(desc, `"gateway_service_`) {
+			continue
+		}
+		name := strings.Split(strings.Split(desc, `fqName: "`)[1], `"`)[0]
+		result := readGauge(metric)
+		count[name]++
+		if result.labels["function_name"] == "echo.openfaas-fn" {
+			values[name] = result
+		}
+	}
+
+	if values["gateway_service_desired_replicas"].value != 3 || values["gateway_service_available_replicas"].value != 2 {
+		t.Fatalf("want 3 desired and 2 available replicas, got: %v", values)
+	}
+	if got := values["gateway_service_available_replicas"].labels["namespace"]; got != "openfaas-fn" {
+		t.Fatalf("namespace, want: openfaas-fn, got: %s", got)
+	}
+	if values["gateway_service_cpu_usage_millicores"].value != 250 || values["gateway_service_memory_usage_bytes"].value != 1024*1024 {
+		t.Fatalf("unexpected usage: %v", values)
+	}
+	if age := values["gateway_service_age_seconds"].value; age < 3600 || age > 3660 {
+		t.Fatalf("age, want about 3600s, got: %f", age)
+	}
+
+	// figlet has no usage or creation time
+	if count["gateway_service_available_replicas"] != 2 || count["gateway_service_cpu_usage_millicores"] != 1 || count["gateway_service_age_seconds"] != 1 {
+		t.Fatalf("unexpected series: %v", count)
+	}
+}
+
+func Test_getFunctions_IncludeUsage(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`[{"name":"echo","usage":{"cpu":10,"totalMemoryBytes":2048}}]`))
+	}))
+	defer server.Close()
+
+	endpoint, _ := url.Parse(server.URL)
+	exporter := NewExporter(BuildMetricsOptions(), nil, "openfaas-fn")
+	exporter.IncludeUsage = true
+
+	services, err := exporter.getFunctions(*endpoint, "openfaas-fn")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if query.Get("usage") != "true" || query.Get("namespace") != "openfaas-fn" {
+		t.Fatalf("unexpected query: %v", query)
+	}
+	if services[0].Usage == nil || services[0].Usage.TotalMemoryBytes != 2048 {
+		t.Fatalf("want usage to be decoded, got: %+v", services[0])
+	}
+}
+
+func Test_reconcile_DeletesSeriesAfterGrace(t *testing.T) {
+	metricsOptions := BuildMetricsOptions()
+	exporter := NewExporter(metricsOptions, nil, "openfaas-fn")
+	exporter.StaleSeriesGrace = time.Minute
+
+	if !exporter.FunctionExists("anything.openfaas-fn") {
+		t.Fatalf("want every function to exist before the first listing")
+	}
+
+	for _, name := range []string{"echo.openfaas-fn", "old.openfaas-fn", UnknownFunctionName} {
+		metricsOptions.GatewayFunctionInvocation.WithLabelValues(name, "200").Inc()
+		metricsOptions.GatewayFunctionInvocationStarted.WithLabelValues(name).Inc()
+		metricsOptions.GatewayFunctionsHistogram.Observe(name, "openfaas-fn", "200", "", 0.1, nil)
+	}
+
+	// echo is listed without a namespace, as by faasd
+	exporter.setKnown([]types.FunctionStatus{{Name: "echo"}})
+	if !exporter.FunctionExists("echo.openfaas-fn") || exporter.FunctionExists("old.openfaas-fn") {
+		t.Fatalf("want only echo to exist")
+	}
+
+	start := time.Now()
+	exporter.reconcile(start)
+	exporter.reconcile(start.Add(time.Second * 30))
+	if got := functionNames(metricsOptions.GatewayFunctionInvocation); len(got) != 3 {
+		t.Fatalf("want the series to be kept during the grace period, got: %v", got)
+	}
+
+	exporter.reconcile(start.Add(time.Minute))
+	for _, c := range metricsOptions.functionCollectors() {
+		for _, name := range functionNames(c) {
+			if name == "old.openfaas-fn" {
+				t.Fatalf("want the series of old to be deleted")
+			}
+		}
+	}
+	if got := functionNames(metricsOptions.GatewayFunctionsHistogram); len(got) != 2 {
+		t.Fatalf("want echo and _unknown to be kept, got: %v", got)
+	}
+	if len(exporter.missing) != 0 {
+		t.Fatalf("want no functions to be missing, got: %v", exporter.missing)
+	}
+}
+
+func Test_reconcile_FunctionListedAgainIsKept(t *testing.T) {
+	metricsOptions := BuildMetricsOptions()
+	exporter := NewExporter(metricsOptions, nil, "openfaas-fn")
+	exporter.StaleSeriesGrace = time.Minute
+
+	metricsOptions.GatewayFunctionInvocation.WithLabelValues("echo.dev", "200").Inc()
+
+	start := time.Now()
+	exporter.setKnown([]types.FunctionStatus{})
+	exporter.reconcile(start)
+
+	exporter.setKnown([]types.FunctionStatus{{Name: "echo", Namespace: "dev"}})
+	exporter.reconcile(start.Add(time.Second * 30))
+
+	exporter.setKnown([]types.FunctionStatus{})
+	exporter.reconcile(start.Add(time.Minute))
+	if got := functionNames(metricsOptions.GatewayFunctionInvocation); len(got) != 1 {
+		t.Fatalf("want the grace period to restart, got: %v", got)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BucketsAnnotation sets a function's own buckets for gateway_functions_seconds
+// as a comma-separated list of upper bounds in seconds, i.e. "0.005,0.01,0.05"
+const BucketsAnnotation = "com.openfaas.metrics.buckets"
+
+// OverflowFunctionName is recorded as the function_name of functions
+// observed after the histogram reached MaxFunctions
+const OverflowFunctionName = "_overflow"
+
+// maxBucketLayouts bounds the distinct bucket annotations, further layouts
+// are recorded with the default buckets
+const maxBucketLayouts = 16
+
+// FunctionHistogramConfig configures gateway_functions_seconds
+type FunctionHistogramConfig struct {
+	// Buckets are the default upper bounds in seconds, the Prometheus default
+	// buckets are used when empty
+	Buckets []float64
+
+	// Native records a native histogram alongside the classic buckets
+	Native bool
+
+	// NativeBucketFactor is the growth factor between native buckets
+	NativeBucketFactor float64
+
+	// MaxFunctions bounds the number of functions with their own series,
+	// 0 is unlimited
+	MaxFunctions int
+}
+
+// FunctionHistogram records gateway_functions_seconds, with one set of
+// buckets per distinct BucketsAnnotation
+type FunctionHistogram struct {
+	config FunctionHistogramConfig
+
+	lock sync.Mutex
+
+	defaultVec *prometheus.HistogramVec
+
+	// vecs are keyed by the bucket annotation, an invalid annotation maps
+	// to defaultVec
+	vecs map[string]*prometheus.HistogramVec
+
+	// layouts holds the annotation each function was last observed with
+	layouts map[string]string
+}
+
+// NewFunctionHistogram creates gateway_functions_seconds
+func NewFunctionHistogram(config FunctionHistogramConfig) *FunctionHistogram {
+	if config.Native && config.NativeBucketFactor <= 1 {
+		config.NativeBucketFactor = 1.1
+	}
+
+	h := &FunctionHistogram{
+		config:  config,
+		vecs:    make(map[string]*prometheus.HistogramVec),
+		layouts: make(map[string]string),
+	}
+	h.defaultVec = h.newVec(config.Buckets)
+	h.vecs[""] = h.defaultVec
+
+	return h
+}
+
+func (h *FunctionHistogram) newVec(buckets []float64) *prometheus.HistogramVec {
+	// The classic buckets are only kept with a native histogram when they
+	// are set explicitly
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	opts := prometheus.HistogramOpts{
+		Name:    "gateway_functions_seconds",
+		Help:    "Function time taken",
+		Buckets: buckets,
+	}
+	if h.config.Native {
+		opts.NativeHistogramBucketFactor = h.config.NativeBucketFactor
+		opts.NativeHistogramMaxBucketNumber = 160
+		opts.NativeHistogramMinResetDuration = time.Hour
+	}
+
+	return prometheus.NewHistogramVec(opts, []string{"function_name", "namespace", "code"})
+}
+
+// Observe records the duration of an invocation, annotation is the
+// function's BucketsAnnotation and exemplar may be nil
+func (h *FunctionHistogram) Observe(function, namespace, code, annotation string, seconds float64, exemplar prometheus.Labels) {
+	h.lock.Lock()
+
+	if _, ok := h.layouts[function]; !ok && h.config.MaxFunctions > 0 && len(h.layouts) >= h.config.MaxFunctions {
+		function = OverflowFunctionName
+		annotation = ""
+	}
+
+	vec := h.vec(annotation)
+	if previous, ok := h.layouts[function]; ok && previous != annotation {
+		// Keep each function's series in a single vec, so that it is
+		// collected once
+		if old := h.vecs[previous]; old != nil && old != vec {
+			old.DeletePartialMatch(prometheus.Labels{"function_name": function})
+		}
+	}
+	h.layouts[function] = annotation
+
+	observer := vec.WithLabelValues(function, namespace, code)
+	h.lock.Unlock()
+
+	if e, ok := observer.(prometheus.ExemplarObserver); ok && len(exemplar) > 0 {
+		e.ObserveWithExemplar(seconds, exemplar)
+		return
+	}
+	observer.Observe(seconds)
+}
+
+// Delete removes every series of a function, which frees its place when
+// MaxFunctions is set
+func (h *FunctionHistogram) Delete(function string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if annotation, ok := h.layouts[function]; ok {
+		if vec := h.vecs[annotation]; vec != nil {
+			vec.DeletePartialMatch(prometheus.Labels{"function_name": function})
+		}
+		delete(h.layouts, function)
+	}
+}
+
+// vec returns the HistogramVec for an annotation, creating it on first use
+func (h *FunctionHistogram) vec(annotation string) *prometheus.HistogramVec {
+	if vec, ok := h.vecs[annotation]; ok {
+		return vec
+	}
+	if len(h.vecs) > maxBucketLayouts {
+		return h.defaultVec
+	}
+
+	buckets, err := ParseBuckets(annotation)
+	if err != nil {
+		logger.Warn("invalid buckets annotation", "annotation", BucketsAnnotation, "value", annotation, "error", err)
+		h.vecs[annotation] = h.defaultVec
+		return h.defaultVec
+	}
+
+	vec := h.newVec(buckets)
+	h.vecs[annotation] = vec
+	return vec
+}
+
+// Describe describes gateway_functions_seconds, every vec shares the same
+// descriptor
+func (h *FunctionHistogram) Describe(ch chan<- *prometheus.Desc) {
+	h.defaultVec.Describe(ch)
+}
+
+// Collect collects the series of every bucket layout
+func (h *FunctionHistogram) Collect(ch chan<- prometheus.Metric) {
+	h.lock.Lock()
+	vecs := make([]*prometheus.HistogramVec, 0, len(h.vecs))
+	seen := map[*prometheus.HistogramVec]bool{}
+	for _, vec := range h.vecs {
+		if !seen[vec] {
+			seen[vec] = true
+			vecs = append(vecs, vec)
+		}
+	}
+	h.lock.Unlock()
+
+	for _, vec := range vecs {
+		vec.Collect(ch)
+	}
+}
+
+// ParseBuckets parses a comma-separated list of increasing upper bounds in
+// seconds
+func ParseBuckets(value string) ([]float64, error) {
+	buckets := []float64{}
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		bound, err := strconv.ParseFloat(part, 64)
+		if err != nil || bound <= 0 || math.IsInf(bound, 0) || math.IsNaN(bound) {
+			return nil, fmt.Errorf("invalid bucket: %q", part)
+		}
+		buckets = append(buckets, bound)
+	}
+
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("no buckets given")
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		return nil, fmt.Errorf("buckets must be in increasing order")
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] == buckets[i-1] {
+			return nil, fmt.Errorf("duplicate bucket: %v", buckets[i])
+		}
+	}
+
+	return buckets, nil
+}
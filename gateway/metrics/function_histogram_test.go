@@ -0,0 +1,166 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// collectHistograms returns the series of h by function_name
+func collectHistograms(h *FunctionHistogram) map[string][]*dto.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		h.Collect(ch)
+		close(ch)
+	}()
+
+	res := map[string][]*dto.Metric{}
+	for metric := range ch {
+		m := &dto.Metric{}
+		metric.Write(m)
+		name := labels2Map(m.GetLabel())["function_name"]
+		res[name] = append(res[name], m)
+	}
+	return res
+}
+
+func Test_FunctionHistogram_DefaultBuckets(t *testing.T) {
+	h := NewFunctionHistogram(FunctionHistogramConfig{Buckets: []float64{0.01, 0.1, 1}})
+
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "", 0.05, nil)
+
+	series := collectHistograms(h)["echo.openfaas-fn"]
+	if len(series) != 1 {
+		t.Fatalf("series, want: 1, got: %d", len(series))
+	}
+
+	labels := labels2Map(series[0].GetLabel())
+	if labels["namespace"] != "openfaas-fn" || labels["code"] != "200" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+	if got := len(series[0].GetHistogram().GetBucket()); got != 3 {
+		t.Fatalf("buckets, want: 3, got: %d", got)
+	}
+}
+
+func Test_FunctionHistogram_AnnotationBuckets(t *testing.T) {
+	h := NewFunctionHistogram(FunctionHistogramConfig{})
+
+	h.Observe("resize.openfaas-fn", "openfaas-fn", "200", "30, 60, 300", 45, nil)
+
+	series := collectHistograms(h)["resize.openfaas-fn"]
+	buckets := series[0].GetHistogram().GetBucket()
+	if len(buckets) != 3 || buckets[0].GetUpperBound() != 30 || buckets[2].GetUpperBound() != 300 {
+		t.Fatalf("want the annotation's buckets, got: %v", buckets)
+	}
+	if buckets[0].GetCumulativeCount() != 0 || buckets[1].GetCumulativeCount() != 1 {
+		t.Fatalf("unexpected counts: %v", buckets)
+	}
+}
+
+func Test_FunctionHistogram_InvalidAnnotationUsesDefault(t *testing.T) {
+	h := NewFunctionHistogram(FunctionHistogramConfig{Buckets: []float64{1, 2}})
+
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "5,1", 1, nil)
+
+	series := collectHistograms(h)["echo.openfaas-fn"]
+	if got := len(series[0].GetHistogram().GetBucket()); got != 2 {
+		t.Fatalf("want the default buckets, got: %d", got)
+	}
+}
+
+func Test_FunctionHistogram_LayoutChangeMovesSeries(t *testing.T) {
+	h := NewFunctionHistogram(FunctionHistogramConfig{})
+
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "", 1, nil)
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "0.1,1", 1, nil)
+
+	series := collectHistograms(h)["echo.openfaas-fn"]
+	if len(series) != 1 {
+		t.Fatalf("want the function to be collected once, got: %d", len(series))
+	}
+	if got := len(series[0].GetHistogram().GetBucket()); got != 2 {
+		t.Fatalf("want the new buckets, got: %d", got)
+	}
+}
+
+func Test_FunctionHistogram_MaxFunctions(t *testing.T) {
+	h := NewFunctionHistogram(FunctionHistogramConfig{MaxFunctions: 1})
+
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "", 1, nil)
+	h.Observe("figlet.openfaas-fn", "openfaas-fn", "200", "", 1, nil)
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "", 1, nil)
+
+	series := collectHistograms(h)
+	if len(series) != 2 || len(series["echo.openfaas-fn"]) != 1 || len(series[OverflowFunctionName]) != 1 {
+		t.Fatalf("want echo and %s, got: %v", OverflowFunctionName, series)
+	}
+	if got := series["echo.openfaas-fn"][0].GetHistogram().GetSampleCount(); got != 2 {
+		t.Fatalf("echo observations, want: 2, got: %d", got)
+	}
+}
+
+func Test_FunctionHistogram_Exemplar(t *testing.T) {
+	h := NewFunctionHistogram(FunctionHistogramConfig{Buckets: []float64{1}})
+
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "", 0.5, prometheus.Labels{"call_id": "4b1e2b4c"})
+
+	series := collectHistograms(h)["echo.openfaas-fn"]
+	exemplar := series[0].GetHistogram().GetBucket()[0].GetExemplar()
+	if exemplar == nil {
+		t.Fatalf("want an exemplar")
+	}
+	if got := labels2Map(exemplar.GetLabel())["call_id"]; got != "4b1e2b4c" {
+		t.Fatalf("call_id, want: 4b1e2b4c, got: %s", got)
+	}
+}
+
+func Test_FunctionHistogram_Native(t *testing.T) {
+	h := NewFunctionHistogram(FunctionHistogramConfig{Native: true})
+
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "", 0.5, nil)
+
+	histogram := collectHistograms(h)["echo.openfaas-fn"][0].GetHistogram()
+	if histogram.Schema == nil || len(histogram.GetPositiveSpan()) == 0 {
+		t.Fatalf("want a native histogram, got: %v", histogram)
+	}
+	if len(histogram.GetBucket()) == 0 {
+		t.Fatalf("want the classic buckets to be kept")
+	}
+}
+
+func Test_ParseBuckets(t *testing.T) {
+	buckets, err := ParseBuckets("0.005, 0.05,0.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(buckets) != 3 || buckets[0] != 0.005 || buckets[2] != 0.5 {
+		t.Fatalf("unexpected buckets: %v", buckets)
+	}
+
+	for _, value := range []string{"", "0.5,0.1", "1,1", "-1,1", "1,Inf", "fast"} {
+		if _, err := ParseBuckets(value); err == nil {
+			t.Errorf("want an error for: %q", value)
+		}
+	}
+}
+
+func Test_FunctionHistogram_DeleteFreesMaxFunctions(t *testing.T) {
+	h := NewFunctionHistogram(FunctionHistogramConfig{MaxFunctions: 1})
+
+	h.Observe("echo.openfaas-fn", "openfaas-fn", "200", "0.1,1", 0.05, nil)
+	h.Delete("echo.openfaas-fn")
+	h.Observe("figlet.openfaas-fn", "openfaas-fn", "200", "", 0.05, nil)
+
+	series := collectHistograms(h)
+	if len(series["echo.openfaas-fn"]) != 0 || len(series[OverflowFunctionName]) != 0 {
+		t.Fatalf("want only figlet to be recorded, got: %v", series)
+	}
+	if len(series["figlet.openfaas-fn"]) != 1 {
+		t.Fatalf("want figlet to have its own series, got: %v", series)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FunctionMetricsQuery returns the metrics which are added to the list of
+// functions. functions holds the function_name of each function, i.e.
+// echo.openfaas-fn, and the results are keyed by function_name.
+type FunctionMetricsQuery interface {
+	// InvocationCounts returns the invocations of each function
+	InvocationCounts(functions []string) (map[string]float64, error)
+
+	// WindowMetrics returns the rates and latencies of each function over
+	// window, functions without invocations can be left out
+	WindowMetrics(functions []string, window time.Duration) (map[string]FunctionMetrics, error)
+}
+
+// PrometheusFunctionMetrics answers a FunctionMetricsQuery from the
+// gateway's metrics in Prometheus
+type PrometheusFunctionMetrics struct {
+	Query PrometheusQueryFetcher
+}
+
+// NewPrometheusFunctionMetrics creates a FunctionMetricsQuery which queries
+// Prometheus
+func NewPrometheusFunctionMetrics(query PrometheusQueryFetcher) *PrometheusFunctionMetrics {
+	return &PrometheusFunctionMetrics{Query: query}
+}
+
+// InvocationCounts sums gateway_function_invocation_total for each function
+func (p *PrometheusFunctionMetrics) InvocationCounts(functions []string) (map[string]float64, error) {
+	return p.fetchValues(fmt.Sprintf(`sum(gateway_function_invocation_total{%s}) by (function_name)`,
+		functionSelector(functions)))
+}
+
+// WindowMetrics queries the request and error rates from
+// gateway_function_invocation_total and the latencies from
+// gateway_functions_seconds
+func (p *PrometheusFunctionMetrics) WindowMetrics(functions []string, window time.Duration) (map[string]FunctionMetrics, error) {
+	selector := functionSelector(functions)
+	rangeSelector := fmt.Sprintf("%ds", int(window.Seconds()))
+
+	requests, err := p.fetchValues(
+		fmt.Sprintf(`sum(rate(gateway_function_invocation_total{%s}[%s])) by (function_name)`, selector, rangeSelector))
+	if err != nil {
+		return nil, err
+	}
+	errorRates, err := p.fetchValues(
+		fmt.Sprintf(`sum(rate(gateway_function_invocation_total{%s,code=~"5.."}[%s])) by (function_name)`, selector, rangeSelector))
+	if err != nil {
+		return nil, err
+	}
+
+	quantiles := []float64{0.5, 0.95, 0.99}
+	latencies := make([]map[string]float64, len(quantiles))
+	for i, quantile := range quantiles {
+		latencies[i], err = p.fetchValues(
+			fmt.Sprintf(`histogram_quantile(%g, sum(rate(gateway_functions_seconds_bucket{%s}[%s])) by (function_name, le))`, quantile, selector, rangeSelector))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := make(map[string]FunctionMetrics, len(functions))
+	for _, label := range functions {
+		m := FunctionMetrics{RequestRate: requests[label]}
+		if m.RequestRate > 0 {
+			m.ErrorRate = errorRates[label] / m.RequestRate
+		}
+		if v, ok := latencies[0][label]; ok {
+			m.LatencyP50 = &v
+		}
+		if v, ok := latencies[1][label]; ok {
+			m.LatencyP95 = &v
+		}
+		if v, ok := latencies[2][label]; ok {
+			m.LatencyP99 = &v
+		}
+		res[label] = m
+	}
+	return res, nil
+}
+
+// functionSelector restricts a query to the namespaces of the functions,
+// and to the names of functions which have no namespace at all
+func functionSelector(functions []string) string {
+	seen := map[string]bool{}
+	patterns := []string{}
+
+	for _, label := range functions {
+		name, namespace, _ := strings.Cut(label, ".")
+
+		pattern := `[^.]+\.` + regexp.QuoteMeta(namespace)
+		if len(namespace) == 0 {
+			pattern = regexp.QuoteMeta(name)
+		}
+
+		if !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+
+	// A raw string is used so that the regular expression is not escaped
+	// again for PromQL
+	return fmt.Sprintf("function_name=~`%s`", strings.Join(patterns, "|"))
+}
+
+// fetchValues runs a query and returns its values by function_name, values
+// which are not a number are left out
+func (p *PrometheusFunctionMetrics) fetchValues(query string) (map[string]float64, error) {
+	results, err := p.Query.Fetch(url.QueryEscape(query))
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]float64{}
+	if results == nil {
+		return values, nil
+	}
+
+	for _, v := range results.Data.Result {
+		if len(v.Value) < 2 {
+			continue
+		}
+
+		metricValue := v.Value[1]
+		switch value := metricValue.(type) {
+		case string:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				logger.Warn("unable to convert metric value", "value", value, "error", err)
+				continue
+			}
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				continue
+			}
+			values[v.Metric.FunctionName] += f
+		}
+	}
+	return values, nil
+}
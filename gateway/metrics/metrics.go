@@ -14,10 +14,39 @@ import (
 // MetricOptions to be used by web handlers
 type MetricOptions struct {
 	GatewayFunctionInvocation        *prometheus.CounterVec
-	GatewayFunctionsHistogram        *prometheus.HistogramVec
+	GatewayFunctionsHistogram        *FunctionHistogram
 	GatewayFunctionInvocationStarted *prometheus.CounterVec
+	GatewayFunctionScaleToZero       *prometheus.CounterVec
+
+	FunctionRequestBytes  *prometheus.HistogramVec
+	FunctionResponseBytes *prometheus.HistogramVec
+
+	ColdStartHeldRequests *prometheus.GaugeVec
+	ColdStartHoldSeconds  *prometheus.HistogramVec
+	ColdStartDuration     *prometheus.HistogramVec
+	ColdStarts            *prometheus.CounterVec
+
+	AsyncEnqueued        *prometheus.CounterVec
+	AsyncRejected        *prometheus.CounterVec
+	AsyncPublishFailures *prometheus.CounterVec
+	AsyncEnqueueSeconds  *prometheus.HistogramVec
+	AsyncRequestBytes    *prometheus.HistogramVec
+
+	PrewarmForecast      *prometheus.GaugeVec
+	PrewarmForecastError *prometheus.GaugeVec
+	PrewarmDecisions     *prometheus.CounterVec
+
+	FunctionCacheRequests  *prometheus.CounterVec
+	FunctionCacheEvictions *prometheus.CounterVec
+	FunctionCacheEntries   *prometheus.GaugeVec
 
 	ServiceReplicasGauge *prometheus.GaugeVec
+
+	ServiceDesiredReplicasGauge   *prometheus.GaugeVec
+	ServiceAvailableReplicasGauge *prometheus.GaugeVec
+	ServiceCPUGauge               *prometheus.GaugeVec
+	ServiceMemoryGauge            *prometheus.GaugeVec
+	ServiceAgeGauge               *prometheus.GaugeVec
 }
 
 // ServiceMetricOptions provides RED metrics
@@ -36,17 +65,29 @@ func RegisterExporter(exporter *Exporter) {
 	})
 }
 
-// PrometheusHandler Bootstraps prometheus for metrics collection
+// PrometheusHandler Bootstraps prometheus for metrics collection, OpenMetrics
+// is negotiated so that exemplars are exposed
 func PrometheusHandler() http.Handler {
-	return promhttp.Handler()
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}))
+}
+
+// MetricsConfig configures the metrics built by BuildMetricsOptionsWithConfig
+type MetricsConfig struct {
+	FunctionsHistogram FunctionHistogramConfig
 }
 
 // BuildMetricsOptions builds metrics for tracking functions in the API gateway
+// with the default configuration
 func BuildMetricsOptions() MetricOptions {
-	gatewayFunctionsHistogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name: "gateway_functions_seconds",
-		Help: "Function time taken",
-	}, []string{"function_name", "code"})
+	return BuildMetricsOptionsWithConfig(MetricsConfig{})
+}
+
+// BuildMetricsOptionsWithConfig builds metrics for tracking functions in the API gateway
+func BuildMetricsOptionsWithConfig(config MetricsConfig) MetricOptions {
+	gatewayFunctionsHistogram := NewFunctionHistogram(config.FunctionsHistogram)
 
 	gatewayFunctionInvocation := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -67,21 +108,282 @@ func BuildMetricsOptions() MetricOptions {
 		[]string{"function_name"},
 	)
 
+	serviceDesiredReplicas := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "service",
+			Name:      "desired_replicas",
+			Help:      "Replicas of a function requested of the provider.",
+		},
+		[]string{"function_name", "namespace"},
+	)
+
+	serviceAvailableReplicas := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "service",
+			Name:      "available_replicas",
+			Help:      "Replicas of a function ready to receive invocations.",
+		},
+		[]string{"function_name", "namespace"},
+	)
+
+	serviceCPU := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "service",
+			Name:      "cpu_usage_millicores",
+			Help:      "CPU used by all of a function's replicas, when the provider reports usage.",
+		},
+		[]string{"function_name", "namespace"},
+	)
+
+	serviceMemory := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "service",
+			Name:      "memory_usage_bytes",
+			Help:      "Memory used by all of a function's replicas, when the provider reports usage.",
+		},
+		[]string{"function_name", "namespace"},
+	)
+
+	serviceAge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "service",
+			Name:      "age_seconds",
+			Help:      "Time since a function was created.",
+		},
+		[]string{"function_name", "namespace"},
+	)
+
 	gatewayFunctionInvocationStarted := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "function",
 			Name:      "invocation_started",
-			Help:      "The total number of function HTTP requests started.",
+			Help:      "The total number of function HTTP requests started, including asynchronous requests accepted onto a queue.",
+		},
+		[]string{"function_name"},
+	)
+
+	gatewayFunctionScaleToZero := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "function",
+			Name:      "scale_to_zero_total",
+			Help:      "The total number of idle functions scaled to zero replicas.",
+		},
+		[]string{"function_name", "result"},
+	)
+
+	// 64B to 64MB
+	payloadBuckets := prometheus.ExponentialBuckets(64, 4, 11)
+
+	functionRequestBytes := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "function",
+			Name:      "request_bytes",
+			Help:      "Size of the request bodies sent to a function, including asynchronous requests.",
+			Buckets:   payloadBuckets,
+		},
+		[]string{"function_name", "code"},
+	)
+
+	functionResponseBytes := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "function",
+			Name:      "response_bytes",
+			Help:      "Size of the response bodies returned by a function.",
+			Buckets:   payloadBuckets,
+		},
+		[]string{"function_name", "code"},
+	)
+
+	coldStartHeldRequests := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "cold_start",
+			Name:      "held_requests",
+			Help:      "Requests currently held while a function scales up from zero.",
 		},
 		[]string{"function_name"},
 	)
 
+	coldStartHoldSeconds := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "cold_start",
+			Name:      "hold_seconds",
+			Help:      "Time requests were held while a function scaled up from zero.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		},
+		[]string{"function_name", "outcome"},
+	)
+
+	coldStartDuration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "cold_start",
+			Name:      "duration_seconds",
+			Help:      "Time from a request scaling a function up from zero until a replica was ready.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		},
+		[]string{"function_name"},
+	)
+
+	coldStarts := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Name:      "cold_starts_total",
+			Help:      "Scale ups from zero requested by the gateway by outcome: ready, timeout or failed.",
+		},
+		[]string{"function_name", "outcome"},
+	)
+
+	asyncEnqueued := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "async",
+			Name:      "enqueued_total",
+			Help:      "Asynchronous requests published to a queue.",
+		},
+		[]string{"function_name", "queue"},
+	)
+
+	asyncRejected := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "async",
+			Name:      "rejected_total",
+			Help:      "Asynchronous requests rejected before they were published by reason: too_large or bad_callback.",
+		},
+		[]string{"function_name", "queue", "reason"},
+	)
+
+	asyncPublishFailures := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "async",
+			Name:      "publish_failures_total",
+			Help:      "Asynchronous requests which could not be published to a queue.",
+		},
+		[]string{"function_name", "queue"},
+	)
+
+	asyncEnqueueSeconds := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "async",
+			Name:      "enqueue_duration_seconds",
+			Help:      "Time taken to publish an asynchronous request to a queue.",
+			Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+		},
+		[]string{"function_name", "queue"},
+	)
+
+	asyncRequestBytes := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "async",
+			Name:      "request_bytes",
+			Help:      "Size of the body of asynchronous requests published to a queue.",
+			Buckets:   payloadBuckets,
+		},
+		[]string{"function_name", "queue"},
+	)
+
+	prewarmForecast := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "prewarm",
+			Name:      "forecast",
+			Help:      "Invocations forecast for a function in the hour starting after the pre-warm lead time.",
+		},
+		[]string{"function_name"},
+	)
+
+	prewarmForecastError := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "prewarm",
+			Name:      "forecast_absolute_error",
+			Help:      "Absolute difference between the forecast and actual invocations of a function in the last complete hour.",
+		},
+		[]string{"function_name"},
+	)
+
+	prewarmDecisions := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "prewarm",
+			Name:      "decisions_total",
+			Help:      "Pre-warm decisions, and whether the forecast traffic arrived.",
+		},
+		[]string{"function_name", "outcome"},
+	)
+
+	functionCacheRequests := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "function_cache",
+			Name:      "requests_total",
+			Help:      "Lookups in a function cache by result: hit, miss or not_found.",
+		},
+		[]string{"cache", "result"},
+	)
+
+	functionCacheEvictions := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "function_cache",
+			Name:      "evictions_total",
+			Help:      "Entries removed from a function cache by reason: size or expired.",
+		},
+		[]string{"cache", "reason"},
+	)
+
+	functionCacheEntries := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "function_cache",
+			Name:      "entries",
+			Help:      "Entries in a function cache, including functions cached as not found.",
+		},
+		[]string{"cache"},
+	)
+
 	metricsOptions := MetricOptions{
 		GatewayFunctionsHistogram:        gatewayFunctionsHistogram,
 		GatewayFunctionInvocation:        gatewayFunctionInvocation,
 		ServiceReplicasGauge:             serviceReplicas,
+		ServiceDesiredReplicasGauge:      serviceDesiredReplicas,
+		ServiceAvailableReplicasGauge:    serviceAvailableReplicas,
+		ServiceCPUGauge:                  serviceCPU,
+		ServiceMemoryGauge:               serviceMemory,
+		ServiceAgeGauge:                  serviceAge,
 		GatewayFunctionInvocationStarted: gatewayFunctionInvocationStarted,
+		GatewayFunctionScaleToZero:       gatewayFunctionScaleToZero,
+		FunctionRequestBytes:             functionRequestBytes,
+		FunctionResponseBytes:            functionResponseBytes,
+		ColdStartHeldRequests:            coldStartHeldRequests,
+		ColdStartHoldSeconds:             coldStartHoldSeconds,
+		ColdStartDuration:                coldStartDuration,
+		ColdStarts:                       coldStarts,
+		AsyncEnqueued:                    asyncEnqueued,
+		AsyncRejected:                    asyncRejected,
+		AsyncPublishFailures:             asyncPublishFailures,
+		AsyncEnqueueSeconds:              asyncEnqueueSeconds,
+		AsyncRequestBytes:                asyncRequestBytes,
+		PrewarmForecast:                  prewarmForecast,
+		PrewarmForecastError:             prewarmForecastError,
+		PrewarmDecisions:                 prewarmDecisions,
+		FunctionCacheRequests:            functionCacheRequests,
+		FunctionCacheEvictions:           functionCacheEvictions,
+		FunctionCacheEntries:             functionCacheEntries,
 	}
 
 	return metricsOptions
@@ -1,10 +1,19 @@
 package metrics
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // PrometheusQuery represents parameters for querying Prometheus
@@ -12,12 +21,46 @@ type PrometheusQuery struct {
 	Port   int
 	Host   string
 	Client *http.Client
+
+	// baseURL includes the scheme and any path prefix, when nil
+	// http://Host:Port is used
+	baseURL *url.URL
+
+	bearerTokenFile string
+	username        string
+	passwordFile    string
 }
 
 type PrometheusQueryFetcher interface {
 	Fetch(query string) (*VectorQueryResponse, error)
 }
 
+// PrometheusQueryConfig configures a PrometheusQuery
+type PrometheusQueryConfig struct {
+	// URL of Prometheus including any path prefix, i.e.
+	// https://monitoring.example.com/prometheus
+	URL string
+
+	// Timeout bounds each query, 0 means no timeout
+	Timeout time.Duration
+
+	// BearerTokenFile is read for every query, so that a rotated token is
+	// picked up
+	BearerTokenFile string
+
+	// Username and PasswordFile set basic auth, the file is read for
+	// every query
+	Username     string
+	PasswordFile string
+
+	// CAFile holds the PEM certificates which Prometheus' certificate is
+	// verified with, instead of the system's
+	CAFile string
+
+	// InsecureSkipVerify disables the verification of Prometheus' certificate
+	InsecureSkipVerify bool
+}
+
 // NewPrometheusQuery create a NewPrometheusQuery
 func NewPrometheusQuery(host string, port int, client *http.Client) PrometheusQuery {
 	return PrometheusQuery{
@@ -27,50 +70,231 @@ func NewPrometheusQuery(host string, port int, client *http.Client) PrometheusQu
 	}
 }
 
-// Fetch queries aggregated stats
+// NewPrometheusQueryWithConfig creates a PrometheusQuery for a URL, with
+// its own TLS settings, credentials and timeout
+func NewPrometheusQueryWithConfig(config PrometheusQueryConfig) (PrometheusQuery, error) {
+	baseURL, err := url.Parse(config.URL)
+	if err != nil {
+		return PrometheusQuery{}, fmt.Errorf("invalid Prometheus URL: %s", err)
+	}
+	if (baseURL.Scheme != "http" && baseURL.Scheme != "https") || len(baseURL.Host) == 0 {
+		return PrometheusQuery{}, fmt.Errorf("invalid Prometheus URL: %q, must be http or https with a host", config.URL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(config.CAFile) > 0 || config.InsecureSkipVerify {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: config.InsecureSkipVerify,
+		}
+
+		if len(config.CAFile) > 0 {
+			pem, err := os.ReadFile(config.CAFile)
+			if err != nil {
+				return PrometheusQuery{}, fmt.Errorf("unable to read Prometheus CA file: %s", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return PrometheusQuery{}, fmt.Errorf("no certificates found in Prometheus CA file: %s", config.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return PrometheusQuery{
+		Host: baseURL.Hostname(),
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+		},
+		baseURL:         baseURL,
+		bearerTokenFile: config.BearerTokenFile,
+		username:        config.Username,
+		passwordFile:    config.PasswordFile,
+	}, nil
+}
+
+// Fetch runs an instant query which has been escaped with url.QueryEscape,
+// the result must be a vector
 func (q PrometheusQuery) Fetch(query string) (*VectorQueryResponse, error) {
+	unescaped, err := url.QueryUnescape(query)
+	if err != nil {
+		return nil, err
+	}
 
-	req, reqErr := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s:%d/api/v1/query?query=%s", q.Host, q.Port, query), nil)
-	if reqErr != nil {
-		return nil, reqErr
+	result, err := q.Query(context.Background(), unescaped, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if result.ResultType != ResultTypeVector {
+		return nil, &PrometheusDecodeError{Err: fmt.Errorf("want a %s result, got: %s", ResultTypeVector, result.ResultType)}
 	}
 
-	res, getErr := q.Client.Do(req)
-	if getErr != nil {
-		return nil, getErr
+	var values VectorQueryResponse
+	for _, sample := range result.Vector {
+		values.Data.Result = append(values.Data.Result, VectorQueryResult{
+			Metric: VectorQueryMetric{
+				Code:         sample.Metric["code"],
+				FunctionName: sample.Metric["function_name"],
+				Labels:       sample.Metric,
+			},
+			Value: []interface{}{
+				float64(sample.Value.Timestamp.UnixNano()) / float64(time.Second),
+				strconv.FormatFloat(sample.Value.Value, 'f', -1, 64),
+			},
+		})
 	}
 
-	if res.Body != nil {
-		defer res.Body.Close()
+	return &values, nil
+}
+
+// Query runs an instant query at a time, or at the current time when at is
+// zero
+func (q PrometheusQuery) Query(ctx context.Context, query string, at time.Time) (*QueryResult, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	if !at.IsZero() {
+		params.Set("time", formatTime(at))
 	}
 
-	bytesOut, readErr := io.ReadAll(res.Body)
-	if readErr != nil {
-		return nil, readErr
+	return q.do(ctx, "/api/v1/query", params)
+}
+
+// QueryRange runs a query over a range of time, the result is a matrix
+func (q PrometheusQuery) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*QueryResult, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("step must be positive")
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end must not be before start")
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Unexpected status code from Prometheus want: %d, got: %d, body: %s", http.StatusOK, res.StatusCode, string(bytesOut))
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatTime(start))
+	params.Set("end", formatTime(end))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	return q.do(ctx, "/api/v1/query_range", params)
+}
+
+// endpoint joins the path of an API to the base URL
+func (q PrometheusQuery) endpoint(apiPath string) string {
+	base := q.baseURL
+	if base == nil {
+		base = &url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", q.Host, q.Port)}
 	}
 
-	var values VectorQueryResponse
+	u := *base
+	u.Path = path.Join("/", base.Path, apiPath)
+	u.RawQuery = ""
+	return u.String()
+}
+
+// do posts the parameters of a query as a form, so that long queries are
+// not limited by the length of the URL
+func (q PrometheusQuery) do(ctx context.Context, apiPath string, params url.Values) (*QueryResult, error) {
+	endpoint := q.endpoint(apiPath)
 
-	unmarshalErr := json.Unmarshal(bytesOut, &values)
-	if unmarshalErr != nil {
-		return nil, fmt.Errorf("Error unmarshaling result: %s, '%s'", unmarshalErr, string(bytesOut))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
 
-	return &values, nil
+	if err := q.authorize(req); err != nil {
+		return nil, err
+	}
+
+	client := q.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, &PrometheusRequestError{URL: endpoint, Err: err}
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, &PrometheusRequestError{URL: endpoint, Err: err}
+	}
+
+	return decodeQueryResponse(res.StatusCode, body)
 }
 
-type VectorQueryResponse struct {
-	Data struct {
-		Result []struct {
-			Metric struct {
-				Code         string `json:"code"`
-				FunctionName string `json:"function_name"`
+// authorize sets the bearer token or basic auth of a request
+func (q PrometheusQuery) authorize(req *http.Request) error {
+	if len(q.bearerTokenFile) > 0 {
+		token, err := readSecretFile(q.bearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("unable to read Prometheus bearer token: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	if len(q.username) > 0 {
+		password := ""
+		if len(q.passwordFile) > 0 {
+			var err error
+			if password, err = readSecretFile(q.passwordFile); err != nil {
+				return fmt.Errorf("unable to read Prometheus password: %s", err)
 			}
-			Value []interface{} `json:"value"`
 		}
+		req.SetBasicAuth(q.username, password)
 	}
+	return nil
+}
+
+func readSecretFile(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', -1, 64)
+}
+
+type VectorQueryResponse struct {
+	Data struct {
+		Result []VectorQueryResult
+	}
+}
+
+// VectorQueryResult is a single sample of a vector, Value holds the
+// timestamp and the value as a string
+type VectorQueryResult struct {
+	Metric VectorQueryMetric
+	Value  []interface{} `json:"value"`
+}
+
+// VectorQueryMetric holds the labels of a sample
+type VectorQueryMetric struct {
+	Code         string `json:"code"`
+	FunctionName string `json:"function_name"`
+
+	// Labels holds every label of the sample, including code and
+	// function_name
+	Labels map[string]string `json:"-"`
+}
+
+// UnmarshalJSON decodes every label of a sample
+func (m *VectorQueryMetric) UnmarshalJSON(data []byte) error {
+	labels := map[string]string{}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return err
+	}
+
+	m.Code = labels["code"]
+	m.FunctionName = labels["function_name"]
+	m.Labels = labels
+	return nil
 }
@@ -0,0 +1,255 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakePrometheus records the last request and answers with body
+func fakePrometheus(t *testing.T, tlsServer bool, status int, body string) (*httptest.Server, *http.Request) {
+	t.Helper()
+
+	last := &http.Request{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*last = *r.Clone(context.Background())
+		last.Form = r.Form
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+
+	var server *httptest.Server
+	if tlsServer {
+		server = httptest.NewTLSServer(handler)
+	} else {
+		server = httptest.NewServer(handler)
+	}
+	t.Cleanup(server.Close)
+	return server, last
+}
+
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func Test_PrometheusQuery_HTTPSPathPrefixAndBearerToken(t *testing.T) {
+	server, last := fakePrometheus(t, true, http.StatusOK,
+		`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"function_name":"echo.openfaas-fn","code":"200","instance":"gateway:8082"},"value":[1700000000.5,"12"]}]}}`)
+
+	caFile := writeFile(t, "ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+	tokenFile := writeFile(t, "token", "s3cr3t\n")
+
+	q, err := NewPrometheusQueryWithConfig(PrometheusQueryConfig{
+		URL:             server.URL + "/prometheus/",
+		Timeout:         time.Second,
+		BearerTokenFile: tokenFile,
+		CAFile:          caFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Unix(1700000000, 0)
+	res, err := q.Query(context.Background(), `sum(gateway_function_invocation_total) by (function_name)`, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last.URL.Path != "/prometheus/api/v1/query" {
+		t.Fatalf("path, want: /prometheus/api/v1/query, got: %s", last.URL.Path)
+	}
+	if got := last.Header.Get("Authorization"); got != "Bearer s3cr3t" {
+		t.Fatalf("Authorization, want: Bearer s3cr3t, got: %q", got)
+	}
+	if last.Form.Get("query") != `sum(gateway_function_invocation_total) by (function_name)` || last.Form.Get("time") != "1700000000" {
+		t.Fatalf("unexpected form: %v", last.Form)
+	}
+
+	if res.ResultType != ResultTypeVector || len(res.Vector) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	sample := res.Vector[0]
+	if sample.Metric["instance"] != "gateway:8082" || sample.Value.Value != 12 {
+		t.Fatalf("unexpected sample: %+v", sample)
+	}
+	if want := time.Unix(1700000000, 500000000).UTC(); !sample.Value.Timestamp.Equal(want) {
+		t.Fatalf("timestamp, want: %s, got: %s", want, sample.Value.Timestamp)
+	}
+}
+
+func Test_PrometheusQuery_UntrustedCertificate(t *testing.T) {
+	server, _ := fakePrometheus(t, true, http.StatusOK, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+
+	q, _ := NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL})
+	_, err := q.Query(context.Background(), "up", time.Time{})
+
+	var requestErr *PrometheusRequestError
+	if !errors.As(err, &requestErr) {
+		t.Fatalf("want a PrometheusRequestError, got: %v", err)
+	}
+
+	q, _ = NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL, InsecureSkipVerify: true})
+	if _, err := q.Query(context.Background(), "up", time.Time{}); err != nil {
+		t.Fatalf("want the certificate to be skipped, got: %s", err)
+	}
+}
+
+func Test_PrometheusQuery_QueryRangeWithBasicAuth(t *testing.T) {
+	server, last := fakePrometheus(t, false, http.StatusOK,
+		`{"status":"success","warnings":["partial"],"data":{"resultType":"matrix","result":[{"metric":{"function_name":"echo.openfaas-fn"},"values":[[1700000000,"1"],[1700000060,"NaN"],[1700000120,"+Inf"]]}]}}`)
+	passwordFile := writeFile(t, "password", "pa55")
+
+	q, _ := NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL, Username: "admin", PasswordFile: passwordFile})
+
+	start := time.Unix(1700000000, 0)
+	res, err := q.QueryRange(context.Background(), "rate(gateway_function_invocation_total[1m])", start, start.Add(time.Minute*2), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user, password, ok := last.BasicAuth(); !ok || user != "admin" || password != "pa55" {
+		t.Fatalf("unexpected basic auth: %s, %s, %v", user, password, ok)
+	}
+	if last.URL.Path != "/api/v1/query_range" || last.Form.Get("start") != "1700000000" || last.Form.Get("end") != "1700000120" || last.Form.Get("step") != "60" {
+		t.Fatalf("unexpected request: %s %v", last.URL.Path, last.Form)
+	}
+
+	if res.ResultType != ResultTypeMatrix || len(res.Matrix) != 1 || len(res.Warnings) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	values := res.Matrix[0].Values
+	if len(values) != 3 || values[0].Value != 1 || !math.IsNaN(values[1].Value) || !math.IsInf(values[2].Value, 1) {
+		t.Fatalf("unexpected values: %+v", values)
+	}
+
+	if _, err := q.QueryRange(context.Background(), "up", start, start, 0); err == nil {
+		t.Fatalf("want an error for a step of 0")
+	}
+}
+
+func Test_PrometheusQuery_ScalarAndString(t *testing.T) {
+	server, _ := fakePrometheus(t, false, http.StatusOK, `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"3.5"]}}`)
+	q, _ := NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL})
+
+	res, err := q.Query(context.Background(), "scalar(3.5)", time.Time{})
+	if err != nil || res.Scalar == nil || res.Scalar.Value != 3.5 {
+		t.Fatalf("unexpected scalar: %+v, %v", res, err)
+	}
+
+	server, _ = fakePrometheus(t, false, http.StatusOK, `{"status":"success","data":{"resultType":"string","result":[1700000000,"openfaas"]}}`)
+	q, _ = NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL})
+
+	res, err = q.Query(context.Background(), `"openfaas"`, time.Time{})
+	if err != nil || res.String == nil || res.String.Value != "openfaas" {
+		t.Fatalf("unexpected string: %+v, %v", res, err)
+	}
+}
+
+func Test_PrometheusQuery_TypedErrors(t *testing.T) {
+	t.Run("API error", func(t *testing.T) {
+		server, _ := fakePrometheus(t, false, http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"parse error at char 4"}`)
+		q, _ := NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL})
+
+		_, err := q.Query(context.Background(), "sum(", time.Time{})
+		var apiErr *PrometheusAPIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Type != "bad_data" || apiErr.Message != "parse error at char 4" {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("proxy error", func(t *testing.T) {
+		server, _ := fakePrometheus(t, false, http.StatusBadGateway, `<html>bad gateway</html>`)
+		q, _ := NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL})
+
+		_, err := q.Query(context.Background(), "up", time.Time{})
+		var apiErr *PrometheusAPIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Type != "" {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("decode error", func(t *testing.T) {
+		server, _ := fakePrometheus(t, false, http.StatusOK, `{"status":"success","data":{"resultType":"vector","result":{}}}`)
+		q, _ := NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL})
+
+		_, err := q.Query(context.Background(), "up", time.Time{})
+		var decodeErr *PrometheusDecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Millisecond * 200)
+		}))
+		defer server.Close()
+		q, _ := NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL, Timeout: time.Millisecond * 20})
+
+		_, err := q.Query(context.Background(), "up", time.Time{})
+		var requestErr *PrometheusRequestError
+		if !errors.As(err, &requestErr) || !requestErr.Timeout() {
+			t.Fatalf("want a timeout, got: %#v", err)
+		}
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		if _, err := NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: "prometheus:9090"}); err == nil {
+			t.Fatalf("want an error for a URL without a scheme")
+		}
+	})
+}
+
+func Test_PrometheusQuery_Fetch(t *testing.T) {
+	server, last := fakePrometheus(t, false, http.StatusOK,
+		`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"function_name":"echo.openfaas-fn","code":"200","namespace":"openfaas-fn"},"value":[1700000000,"7"]}]}}`)
+
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+	q := NewPrometheusQuery(u.Hostname(), port, &http.Client{})
+
+	res, err := q.Fetch(url.QueryEscape(`sum(gateway_function_invocation_total{function_name=~".+"}) by (function_name)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := last.Form.Get("query"); got != `sum(gateway_function_invocation_total{function_name=~".+"}) by (function_name)` {
+		t.Fatalf("want the query to be unescaped, got: %s", got)
+	}
+
+	result := res.Data.Result[0]
+	if result.Metric.FunctionName != "echo.openfaas-fn" || result.Metric.Code != "200" || result.Metric.Labels["namespace"] != "openfaas-fn" {
+		t.Fatalf("unexpected labels: %+v", result.Metric)
+	}
+	if result.Value[1] != "7" {
+		t.Fatalf("value, want: 7, got: %v", result.Value[1])
+	}
+
+	server, _ = fakePrometheus(t, false, http.StatusOK, `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"1"]}}`)
+	q, _ = NewPrometheusQueryWithConfig(PrometheusQueryConfig{URL: server.URL})
+	var decodeErr *PrometheusDecodeError
+	if _, err := q.Fetch("scalar(1)"); !errors.As(err, &decodeErr) {
+		t.Fatalf("want a decode error for a scalar, got: %v", err)
+	}
+}
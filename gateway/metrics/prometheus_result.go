@@ -0,0 +1,240 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Types of the result of a query
+const (
+	ResultTypeVector = "vector"
+	ResultTypeMatrix = "matrix"
+	ResultTypeScalar = "scalar"
+	ResultTypeString = "string"
+)
+
+// maxErrorBody bounds the part of a response body kept in an error
+const maxErrorBody = 512
+
+// QueryResult is the decoded result of a query, only the field for its
+// ResultType is set
+type QueryResult struct {
+	ResultType string
+
+	Vector []Sample
+	Matrix []Series
+	Scalar *SamplePair
+	String *StringPair
+
+	// Warnings are returned by Prometheus alongside a result, such as when
+	// a query is partial
+	Warnings []string
+}
+
+// Sample is a single value of a series in a vector
+type Sample struct {
+	Metric map[string]string `json:"metric"`
+	Value  SamplePair        `json:"value"`
+}
+
+// Series is the values of a series over a range in a matrix
+type Series struct {
+	Metric map[string]string `json:"metric"`
+	Values []SamplePair      `json:"values"`
+}
+
+// SamplePair is a value at a time, the value may be NaN or infinite
+type SamplePair struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// UnmarshalJSON decodes a [timestamp, "value"] pair
+func (p *SamplePair) UnmarshalJSON(data []byte) error {
+	var value string
+	timestamp, err := decodePair(data, &value)
+	if err != nil {
+		return err
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sample value: %q", value)
+	}
+
+	p.Timestamp = timestamp
+	p.Value = f
+	return nil
+}
+
+// StringPair is a string at a time
+type StringPair struct {
+	Timestamp time.Time
+	Value     string
+}
+
+// UnmarshalJSON decodes a [timestamp, "value"] pair
+func (p *StringPair) UnmarshalJSON(data []byte) error {
+	timestamp, err := decodePair(data, &p.Value)
+	if err != nil {
+		return err
+	}
+	p.Timestamp = timestamp
+	return nil
+}
+
+func decodePair(data []byte, value *string) (time.Time, error) {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return time.Time{}, err
+	}
+	if len(pair) != 2 {
+		return time.Time{}, fmt.Errorf("want a [timestamp, value] pair, got: %s", string(data))
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(pair[0], &seconds); err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %s", string(pair[0]))
+	}
+	if err := json.Unmarshal(pair[1], value); err != nil {
+		return time.Time{}, fmt.Errorf("invalid value: %s", string(pair[1]))
+	}
+
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(math.Round(fraction*1e9))).UTC(), nil
+}
+
+// PrometheusAPIError is returned when Prometheus answers with an error, such
+// as a query which cannot be parsed or an unexpected status code
+type PrometheusAPIError struct {
+	StatusCode int
+
+	// Type is Prometheus' errorType, i.e. "bad_data" or "timeout", it is
+	// empty when the response was not from the Prometheus API
+	Type    string
+	Message string
+}
+
+func (e *PrometheusAPIError) Error() string {
+	if len(e.Type) > 0 {
+		return fmt.Sprintf("prometheus: %s: %s", e.Type, e.Message)
+	}
+	return fmt.Sprintf("prometheus: unexpected status code: %d, body: %s", e.StatusCode, e.Message)
+}
+
+// PrometheusRequestError is returned when Prometheus could not be reached,
+// or did not answer within the timeout
+type PrometheusRequestError struct {
+	URL string
+	Err error
+}
+
+func (e *PrometheusRequestError) Error() string {
+	return fmt.Sprintf("prometheus: request to %s failed: %s", e.URL, e.Err)
+}
+
+func (e *PrometheusRequestError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the request timed out
+func (e *PrometheusRequestError) Timeout() bool {
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(e.Err, &netErr) && netErr.Timeout()
+}
+
+// PrometheusDecodeError is returned when a response could not be decoded
+type PrometheusDecodeError struct {
+	Body string
+	Err  error
+}
+
+func (e *PrometheusDecodeError) Error() string {
+	if len(e.Body) > 0 {
+		return fmt.Sprintf("prometheus: unable to decode response: %s, body: %s", e.Err, e.Body)
+	}
+	return fmt.Sprintf("prometheus: unable to decode response: %s", e.Err)
+}
+
+func (e *PrometheusDecodeError) Unwrap() error {
+	return e.Err
+}
+
+type apiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+	Warnings  []string        `json:"warnings"`
+}
+
+type queryData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// decodeQueryResponse decodes the response of the query APIs
+func decodeQueryResponse(statusCode int, body []byte) (*QueryResult, error) {
+	var res apiResponse
+	if err := json.Unmarshal(body, &res); err != nil || len(res.Status) == 0 {
+		if statusCode != http.StatusOK {
+			return nil, &PrometheusAPIError{StatusCode: statusCode, Message: truncateBody(body)}
+		}
+		if err == nil {
+			err = fmt.Errorf("no status")
+		}
+		return nil, &PrometheusDecodeError{Body: truncateBody(body), Err: err}
+	}
+
+	if res.Status != "success" {
+		return nil, &PrometheusAPIError{StatusCode: statusCode, Type: res.ErrorType, Message: res.Error}
+	}
+
+	var data queryData
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		return nil, &PrometheusDecodeError{Body: truncateBody(body), Err: err}
+	}
+
+	result := &QueryResult{ResultType: data.ResultType, Warnings: res.Warnings}
+
+	var err error
+	switch data.ResultType {
+	case ResultTypeVector:
+		err = json.Unmarshal(data.Result, &result.Vector)
+	case ResultTypeMatrix:
+		err = json.Unmarshal(data.Result, &result.Matrix)
+	case ResultTypeScalar:
+		result.Scalar = &SamplePair{}
+		err = json.Unmarshal(data.Result, result.Scalar)
+	case ResultTypeString:
+		result.String = &StringPair{}
+		err = json.Unmarshal(data.Result, result.String)
+	default:
+		err = fmt.Errorf("unknown result type: %q", data.ResultType)
+	}
+	if err != nil {
+		return nil, &PrometheusDecodeError{Body: truncateBody(body), Err: err}
+	}
+
+	return result, nil
+}
+
+func truncateBody(body []byte) string {
+	if len(body) > maxErrorBody {
+		return string(body[:maxErrorBody]) + "..."
+	}
+	return string(body)
+}
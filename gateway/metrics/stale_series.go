@@ -0,0 +1,194 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	types "github.com/openfaas/faas-provider/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// UnknownFunctionName is recorded as the function_name of invocations of
+// functions which the provider does not list
+const UnknownFunctionName = "_unknown"
+
+// FunctionExists reports whether serviceName, in the form name.namespace
+// when the function has a namespace, was listed by the provider. Every
+// function exists until the service watcher has listed them all once.
+func (e *Exporter) FunctionExists(serviceName string) bool {
+	e.servicesLock.RLock()
+	defer e.servicesLock.RUnlock()
+
+	if e.known == nil {
+		return true
+	}
+	return e.known[serviceName]
+}
+
+// setKnown records the functions of a complete listing, with and without
+// the default namespace when the provider does not return one
+func (e *Exporter) setKnown(services []types.FunctionStatus) {
+	known := make(map[string]bool, len(services))
+	for _, service := range services {
+		if len(service.Namespace) > 0 {
+			known[fmt.Sprintf("%s.%s", service.Name, service.Namespace)] = true
+			continue
+		}
+
+		known[service.Name] = true
+		if len(e.FunctionNamespace) > 0 {
+			known[fmt.Sprintf("%s.%s", service.Name, e.FunctionNamespace)] = true
+		}
+	}
+
+	e.servicesLock.Lock()
+	e.known = known
+	e.servicesLock.Unlock()
+}
+
+// reconcile deletes the per-function series of functions which have not
+// been listed by the provider for StaleSeriesGrace
+func (e *Exporter) reconcile(now time.Time) {
+	if e.StaleSeriesGrace <= 0 {
+		return
+	}
+
+	recorded := map[string]bool{}
+	for _, c := range e.metricOptions.functionCollectors() {
+		for _, name := range functionNames(c) {
+			recorded[name] = true
+		}
+	}
+
+	for name := range e.missing {
+		if !recorded[name] || e.FunctionExists(name) {
+			delete(e.missing, name)
+		}
+	}
+
+	for name := range recorded {
+		if name == UnknownFunctionName || name == OverflowFunctionName || e.FunctionExists(name) {
+			continue
+		}
+
+		since, ok := e.missing[name]
+		if !ok {
+			e.missing[name] = now
+			continue
+		}
+
+		if now.Sub(since) >= e.StaleSeriesGrace {
+			e.metricOptions.deleteFunction(name)
+			delete(e.missing, name)
+			logger.Info("removed the series of a function which is no longer listed",
+				"function", name, "missing_seconds", now.Sub(since).Round(time.Second).Seconds())
+		}
+	}
+}
+
+// functionCollectors returns the metrics labelled by function_name which
+// are recorded for invocations, the gauges set from the provider's listing
+// are reset on every scrape instead
+func (m *MetricOptions) functionCollectors() []prometheus.Collector {
+	collectors := []prometheus.Collector{}
+	if m.GatewayFunctionsHistogram != nil {
+		collectors = append(collectors, m.GatewayFunctionsHistogram)
+	}
+	for _, vec := range m.functionVecs() {
+		collectors = append(collectors, vec)
+	}
+	return collectors
+}
+
+func (m *MetricOptions) functionVecs() []*prometheus.MetricVec {
+	vecs := []*prometheus.MetricVec{}
+	if m.GatewayFunctionInvocation != nil {
+		vecs = append(vecs, m.GatewayFunctionInvocation.MetricVec)
+	}
+	if m.GatewayFunctionInvocationStarted != nil {
+		vecs = append(vecs, m.GatewayFunctionInvocationStarted.MetricVec)
+	}
+	if m.GatewayFunctionScaleToZero != nil {
+		vecs = append(vecs, m.GatewayFunctionScaleToZero.MetricVec)
+	}
+	if m.FunctionRequestBytes != nil {
+		vecs = append(vecs, m.FunctionRequestBytes.MetricVec)
+	}
+	if m.FunctionResponseBytes != nil {
+		vecs = append(vecs, m.FunctionResponseBytes.MetricVec)
+	}
+	if m.ColdStartHoldSeconds != nil {
+		vecs = append(vecs, m.ColdStartHoldSeconds.MetricVec)
+	}
+	if m.ColdStartDuration != nil {
+		vecs = append(vecs, m.ColdStartDuration.MetricVec)
+	}
+	if m.ColdStarts != nil {
+		vecs = append(vecs, m.ColdStarts.MetricVec)
+	}
+	if m.AsyncEnqueued != nil {
+		vecs = append(vecs, m.AsyncEnqueued.MetricVec)
+	}
+	if m.AsyncRejected != nil {
+		vecs = append(vecs, m.AsyncRejected.MetricVec)
+	}
+	if m.AsyncPublishFailures != nil {
+		vecs = append(vecs, m.AsyncPublishFailures.MetricVec)
+	}
+	if m.AsyncEnqueueSeconds != nil {
+		vecs = append(vecs, m.AsyncEnqueueSeconds.MetricVec)
+	}
+	if m.AsyncRequestBytes != nil {
+		vecs = append(vecs, m.AsyncRequestBytes.MetricVec)
+	}
+	if m.PrewarmForecast != nil {
+		vecs = append(vecs, m.PrewarmForecast.MetricVec)
+	}
+	if m.PrewarmForecastError != nil {
+		vecs = append(vecs, m.PrewarmForecastError.MetricVec)
+	}
+	if m.PrewarmDecisions != nil {
+		vecs = append(vecs, m.PrewarmDecisions.MetricVec)
+	}
+	return vecs
+}
+
+// deleteFunction deletes every series of a function
+func (m *MetricOptions) deleteFunction(name string) {
+	labels := prometheus.Labels{"function_name": name}
+	for _, vec := range m.functionVecs() {
+		vec.DeletePartialMatch(labels)
+	}
+	if m.GatewayFunctionsHistogram != nil {
+		m.GatewayFunctionsHistogram.Delete(name)
+	}
+}
+
+// functionNames returns the distinct function_name labels collected from c
+func functionNames(c prometheus.Collector) []string {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	seen := map[string]bool{}
+	names := []string{}
+	for metric := range ch {
+		m := &dto.Metric{}
+		if err := metric.Write(m); err != nil {
+			continue
+		}
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "function_name" && !seen[label.GetValue()] {
+				seen[label.GetValue()] = true
+				names = append(names, label.GetValue())
+			}
+		}
+	}
+	return names
+}
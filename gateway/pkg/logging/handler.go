@@ -0,0 +1,32 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+)
+
+// Handler adds the X-Call-Id of each request, and the function and
+// namespace of requests to /function/ and /async-function/, to the records
+// logged with its context
+func Handler(next http.Handler, defaultNamespace string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attrs := []slog.Attr{}
+		if callID := r.Header.Get("X-Call-Id"); len(callID) > 0 {
+			attrs = append(attrs, slog.String("call_id", callID))
+		}
+		if serviceName := middleware.GetServiceName(r.URL.Path); len(serviceName) > 0 {
+			name, namespace := middleware.GetNamespace(defaultNamespace, serviceName)
+			attrs = append(attrs, slog.String("function", name), slog.String("namespace", namespace))
+		}
+
+		if len(attrs) > 0 {
+			r = r.WithContext(With(r.Context(), attrs...))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
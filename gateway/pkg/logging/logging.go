@@ -0,0 +1,179 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+// Package logging writes the gateway's logs as structured records in text
+// or JSON, with a logger for each component and the fields of the request
+// being served added from its context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+// Formats of the log output
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Components of the gateway, recorded as the component field
+const (
+	ComponentGateway  = "gateway"
+	ComponentProxy    = "proxy"
+	ComponentScaling  = "scaling"
+	ComponentAlerts   = "alerts"
+	ComponentQueue    = "queue"
+	ComponentExporter = "exporter"
+	ComponentPlugin   = "plugin"
+)
+
+var (
+	// level is shared by every logger, so that it can be changed at runtime
+	level = new(slog.LevelVar)
+
+	// output is the handler which formats and writes records
+	output atomic.Pointer[slog.Handler]
+)
+
+func init() {
+	setOutput(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// Configure writes the logs of every component to w in format, at lvl and
+// above. Messages from the standard library's log package are written as
+// records of the gateway component.
+func Configure(w io.Writer, format string, lvl slog.Level) error {
+	options := &slog.HandlerOptions{Level: level}
+
+	switch format {
+	case FormatText, "":
+		setOutput(slog.NewTextHandler(w, options))
+	case FormatJSON:
+		setOutput(slog.NewJSONHandler(w, options))
+	default:
+		return fmt.Errorf("unknown log format: %q, want %s or %s", format, FormatText, FormatJSON)
+	}
+
+	level.Set(lvl)
+	slog.SetDefault(For(ComponentGateway))
+	return nil
+}
+
+func setOutput(h slog.Handler) {
+	output.Store(&h)
+}
+
+// For returns the logger of a component, it follows any later change to
+// the configuration
+func For(component string) *slog.Logger {
+	return slog.New(&handler{}).With(slog.String("component", component))
+}
+
+// Level returns the current level
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the level of every logger
+func SetLevel(lvl slog.Level) {
+	level.Set(lvl)
+}
+
+// ParseLevel parses debug, info, warn or error, in any case
+func ParseLevel(value string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return lvl, fmt.Errorf("unknown log level: %q, want debug, info, warn or error", value)
+	}
+	return lvl, nil
+}
+
+type fieldsKey struct{}
+
+// With returns a context whose log records include attrs, along with the
+// attrs of ctx
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+
+	merged := make([]slog.Attr, 0, len(fields)+len(attrs))
+	merged = append(merged, fields...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// handler adds the fields of the context and its trace to each record, and
+// hands it to the configured output
+type handler struct {
+	// with replays the attrs and groups of the logger onto the output
+	with func(slog.Handler) slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return lvl >= level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+		if c := tracing.SpanContextFromContext(ctx); c.IsValid() {
+			fields = append(fields[:len(fields):len(fields)], slog.String("trace_id", c.TraceID.String()))
+		}
+		addMissing(&r, fields)
+	}
+
+	out := *output.Load()
+	if h.with != nil {
+		out = h.with(out)
+	}
+	return out.Handle(ctx, r)
+}
+
+// addMissing adds the fields which the record does not already have, so
+// that a function logged explicitly is not repeated from the context
+func addMissing(r *slog.Record, fields []slog.Attr) {
+	if len(fields) == 0 {
+		return
+	}
+
+	present := map[string]bool{}
+	r.Attrs(func(a slog.Attr) bool {
+		present[a.Key] = true
+		return true
+	})
+
+	for _, field := range fields {
+		if !present[field.Key] {
+			r.AddAttrs(field)
+		}
+	}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.then(func(out slog.Handler) slog.Handler {
+		return out.WithAttrs(attrs)
+	})
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.then(func(out slog.Handler) slog.Handler {
+		return out.WithGroup(name)
+	})
+}
+
+func (h *handler) then(next func(slog.Handler) slog.Handler) slog.Handler {
+	with := h.with
+	if with == nil {
+		return &handler{with: next}
+	}
+	return &handler{with: func(out slog.Handler) slog.Handler {
+		return next(with(out))
+	}}
+}
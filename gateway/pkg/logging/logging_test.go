@@ -0,0 +1,181 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/pkg/tracing"
+)
+
+func useBuffer(t *testing.T, format string, lvl slog.Level) *bytes.Buffer {
+	t.Helper()
+
+	var b bytes.Buffer
+	if err := Configure(&b, format, lvl); err != nil {
+		t.Fatalf("unable to configure: %s", err)
+	}
+	t.Cleanup(func() {
+		Configure(os.Stderr, FormatText, slog.LevelInfo)
+	})
+	return &b
+}
+
+func records(t *testing.T, b *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	out := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		record := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("unable to decode record: %s, got: %q", err, line)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func Test_For_LogsComponentAsJSON(t *testing.T) {
+	b := useBuffer(t, FormatJSON, slog.LevelInfo)
+
+	For(ComponentScaling).Info("scaling", "replicas", 2)
+
+	got := records(t, b)
+	if len(got) != 1 {
+		t.Fatalf("want 1 record, got: %d", len(got))
+	}
+	if got[0]["component"] != ComponentScaling || got[0]["msg"] != "scaling" || got[0]["replicas"] != float64(2) {
+		t.Fatalf("unexpected record: %v", got[0])
+	}
+}
+
+func Test_For_FollowsLaterConfiguration(t *testing.T) {
+	logger := For(ComponentAlerts).With("receiver", "scale-up")
+
+	b := useBuffer(t, FormatJSON, slog.LevelInfo)
+	logger.Info("alert")
+
+	got := records(t, b)
+	if len(got) != 1 || got[0]["component"] != ComponentAlerts || got[0]["receiver"] != "scale-up" {
+		t.Fatalf("want the record in the buffer with its attrs, got: %v", got)
+	}
+}
+
+func Test_SetLevel_FiltersRecords(t *testing.T) {
+	b := useBuffer(t, FormatJSON, slog.LevelInfo)
+	logger := For(ComponentProxy)
+
+	logger.Debug("hidden")
+	SetLevel(slog.LevelDebug)
+	logger.Debug("shown")
+	SetLevel(slog.LevelError)
+	logger.Warn("hidden")
+
+	got := records(t, b)
+	if len(got) != 1 || got[0]["msg"] != "shown" {
+		t.Fatalf("want only the debug record logged at debug, got: %v", got)
+	}
+	if Level() != slog.LevelError {
+		t.Fatalf("want level %s, got: %s", slog.LevelError, Level())
+	}
+}
+
+func Test_Configure_UnknownFormat(t *testing.T) {
+	if err := Configure(os.Stderr, "xml", slog.LevelInfo); err == nil {
+		t.Fatalf("want an error for an unknown format")
+	}
+}
+
+func Test_ParseLevel(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{value: "debug", want: slog.LevelDebug},
+		{value: "INFO", want: slog.LevelInfo},
+		{value: " warn ", want: slog.LevelWarn},
+		{value: "error", want: slog.LevelError},
+		{value: "verbose", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := ParseLevel(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("want an error")
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Fatalf("want %s, got: %s, error: %v", tc.want, got, err)
+			}
+		})
+	}
+}
+
+func Test_With_AddsContextFields(t *testing.T) {
+	b := useBuffer(t, FormatJSON, slog.LevelInfo)
+
+	ctx := With(context.Background(), slog.String("call_id", "abc"), slog.String("function", "echo"))
+	ctx = tracing.ContextWithSpanContext(ctx, tracing.SpanContext{
+		TraceID: [16]byte{1},
+		SpanID:  [8]byte{1},
+	})
+
+	// function is logged explicitly, so it is not repeated from the context
+	For(ComponentQueue).InfoContext(ctx, "queued", "function", "figlet")
+
+	got := records(t, b)
+	if len(got) != 1 {
+		t.Fatalf("want 1 record, got: %d", len(got))
+	}
+	if got[0]["call_id"] != "abc" || got[0]["function"] != "figlet" {
+		t.Fatalf("unexpected record: %v", got[0])
+	}
+	if got[0]["trace_id"] != "01000000000000000000000000000000" {
+		t.Fatalf("want the trace_id of the context, got: %v", got[0]["trace_id"])
+	}
+	if strings.Count(b.String(), `"function"`) != 1 {
+		t.Fatalf("want function once, got: %s", b.String())
+	}
+}
+
+func Test_Handler_AddsRequestFields(t *testing.T) {
+	b := useBuffer(t, FormatJSON, slog.LevelInfo)
+
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		For(ComponentProxy).InfoContext(r.Context(), "proxied")
+	}), "openfaas-fn")
+
+	req := httptest.NewRequest(http.MethodPost, "/function/echo.staging/path", nil)
+	req.Header.Set("X-Call-Id", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/async-function/figlet", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := records(t, b)
+	if len(got) != 2 {
+		t.Fatalf("want 2 records, got: %d", len(got))
+	}
+	if got[0]["call_id"] != "abc" || got[0]["function"] != "echo" || got[0]["namespace"] != "staging" {
+		t.Fatalf("unexpected record: %v", got[0])
+	}
+	if _, ok := got[1]["call_id"]; ok || got[1]["function"] != "figlet" || got[1]["namespace"] != "openfaas-fn" {
+		t.Fatalf("unexpected record: %v", got[1])
+	}
+}
@@ -153,3 +153,12 @@ func TestFunctionAsHostBaseURLResolver_WithoutSuffix(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGetServiceName_Async(t *testing.T) {
+	want := "figlet.openfaas-fn"
+	got := GetServiceName("/async-function/figlet.openfaas-fn/path")
+
+	if got != want {
+		t.Fatalf("want: %s, got: %s", want, got)
+	}
+}
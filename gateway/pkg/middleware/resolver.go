@@ -137,10 +137,12 @@ func (f FunctionPrefixTrimmingURLPathTransformer) Transform(r *http.Request) str
 	return ret
 }
 
+// GetServiceName returns the function name from a /function/ or /async-function/ path
 func GetServiceName(urlValue string) string {
 	var serviceName string
 	forward := "/function/"
-	if strings.HasPrefix(urlValue, forward) {
+	asyncForward := "/async-function/"
+	if strings.HasPrefix(urlValue, forward) || strings.HasPrefix(urlValue, asyncForward) {
 		// With a path like `/function/xyz/rest/of/path?q=a`, the service
 		// name we wish to locate is just the `xyz` portion.  With a positive
 		// match on the regex below, it will return a three-element slice.
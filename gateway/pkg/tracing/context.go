@@ -0,0 +1,142 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// W3C Trace Context headers
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext is the part of a span which is propagated to other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+
+	// State is the vendor specific tracestate, passed on unchanged
+	State string
+}
+
+// IsValid is true when both the trace and span IDs are set
+func (c SpanContext) IsValid() bool {
+	return c.TraceID != TraceID{} && c.SpanID != SpanID{}
+}
+
+// Traceparent formats the context as a version 00 traceparent header
+func (c SpanContext) Traceparent() string {
+	flags := 0
+	if c.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", c.TraceID, c.SpanID, flags)
+}
+
+// ParseTraceparent reads a traceparent header. Later versions are read
+// as version 00, as required by the W3C specification.
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %q", value)
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent version: %q", value)
+	}
+
+	var c SpanContext
+	if err := decodeHex(parts[1], c.TraceID[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace-id: %q", value)
+	}
+	if err := decodeHex(parts[2], c.SpanID[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid parent-id: %q", value)
+	}
+
+	var flags [1]byte
+	if err := decodeHex(parts[3], flags[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace-flags: %q", value)
+	}
+	c.Sampled = flags[0]&1 == 1
+
+	if !c.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent, zero id: %q", value)
+	}
+	return c, nil
+}
+
+// decodeHex decodes lower case hex of exactly the length of dst
+func decodeHex(value string, dst []byte) error {
+	if len(value) != len(dst)*2 || strings.ToLower(value) != value {
+		return fmt.Errorf("invalid length or case")
+	}
+	_, err := hex.Decode(dst, []byte(value))
+	return err
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context holding the span context as the
+// parent of spans started from it
+func ContextWithSpanContext(ctx context.Context, c SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, c)
+}
+
+// SpanContextFromContext returns the current span context, which is not
+// valid when there is none
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	c, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return c
+}
+
+// Extract returns a context with the span context of the traceparent and
+// tracestate headers, or ctx when there is no valid traceparent
+func Extract(ctx context.Context, header http.Header) context.Context {
+	c, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	c.State = header.Get(TracestateHeader)
+
+	return ContextWithSpanContext(ctx, c)
+}
+
+// Inject sets the traceparent and tracestate headers from the current span
+// context of ctx, an incoming context is passed on even when tracing is
+// disabled
+func Inject(ctx context.Context, header http.Header) {
+	c := SpanContextFromContext(ctx)
+	if !c.IsValid() {
+		return
+	}
+
+	header.Set(TraceparentHeader, c.Traceparent())
+	if len(c.State) > 0 {
+		header.Set(TracestateHeader, c.State)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const otlpTracesPath = "/v1/traces"
+
+// OTLPExporter sends spans to an OpenTelemetry collector with the JSON
+// encoding of OTLP over HTTP
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter creates an exporter for a collector's OTLP/HTTP endpoint
+// i.e. "http://127.0.0.1:4318", /v1/traces is added when it is not set
+func NewOTLPExporter(endpoint, serviceName string, timeout time.Duration) *OTLPExporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, otlpTracesPath) {
+		url += otlpTracesPath
+	}
+
+	return &OTLPExporter{
+		url:         url,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: timeout},
+	}
+}
+
+// Export sends the spans in a single request
+func (e *OTLPExporter) Export(spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	res, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("unexpected status code from %s: %d, body: %s", e.url, res.StatusCode, string(message))
+	}
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	// Code is 0 for unset and 2 for an error
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *OTLPExporter) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+
+	for _, s := range spans {
+		s.lock.Lock()
+		span := otlpSpan{
+			TraceID:           s.Context.TraceID.String(),
+			SpanID:            s.Context.SpanID.String(),
+			TraceState:        s.Context.State,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+		}
+		if s.Parent != (SpanID{}) {
+			span.ParentSpanID = s.Parent.String()
+		}
+		for _, a := range s.Attributes {
+			span.Attributes = append(span.Attributes, attribute(a.Key, a.Value))
+		}
+		if len(s.Error) > 0 {
+			span.Status = otlpStatus{Code: 2, Message: s.Error}
+		}
+		s.lock.Unlock()
+
+		out = append(out, span)
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{attribute("service.name", e.serviceName)},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/openfaas/faas/gateway"},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func attribute(key string, value interface{}) otlpAttribute {
+	a := otlpAttribute{Key: key}
+
+	switch v := value.(type) {
+	case bool:
+		a.Value.BoolValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		a.Value.IntValue = &s
+	case float64:
+		a.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		a.Value.StringValue = &s
+	}
+
+	return a
+}
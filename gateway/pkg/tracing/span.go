@@ -0,0 +1,110 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package tracing
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Attribute of a span, Value is a string, bool, int64 or float64
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a timed operation within a trace. A nil *Span records nothing,
+// so that callers do not need to check whether tracing is enabled.
+type Span struct {
+	tracer *Tracer
+
+	Name       string
+	Kind       SpanKind
+	Context    SpanContext
+	Parent     SpanID
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes []Attribute
+
+	// Error is set when the operation failed
+	Error string
+
+	lock  sync.Mutex
+	ended bool
+}
+
+// SetAttribute records an attribute, ints are stored as int64
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	switch v := value.(type) {
+	case int:
+		value = int64(v)
+	case uint64:
+		value = int64(v)
+	case time.Duration:
+		value = v.Seconds()
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, a := range s.Attributes {
+		if a.Key == key {
+			s.Attributes[i].Value = value
+			return
+		}
+	}
+	s.Attributes = append(s.Attributes, Attribute{Key: key, Value: value})
+}
+
+// SetError marks the span as failed when err is not nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.Error = err.Error()
+}
+
+// SetStatusCode records the HTTP status code, a 5xx status marks the span
+// as failed
+func (s *Span) SetStatusCode(statusCode int) {
+	if s == nil {
+		return
+	}
+
+	s.SetAttribute("http.status_code", statusCode)
+	if statusCode >= 500 {
+		s.lock.Lock()
+		if len(s.Error) == 0 {
+			s.Error = "HTTP " + strconv.Itoa(statusCode)
+		}
+		s.lock.Unlock()
+	}
+}
+
+// End ends the span and queues it for export, later calls do nothing
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.lock.Unlock()
+
+	s.tracer.end(s)
+}
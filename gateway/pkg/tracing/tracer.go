@@ -0,0 +1,187 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+// Package tracing records OpenTelemetry spans for the gateway, propagates
+// W3C trace context and exports spans to a collector with OTLP over HTTP.
+//
+// It is a small implementation of the parts of OpenTelemetry which the
+// gateway uses, written so that the gateway does not depend on the
+// OpenTelemetry SDK. It does not read the OTEL_* environment variables, the
+// resource only has service.name, and a batch which fails to export is
+// dropped rather than retried.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	mrand "math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind is the OTLP kind of a span
+type SpanKind int
+
+// Kinds of span
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+	KindProducer SpanKind = 4
+)
+
+// Exporter sends ended spans to a collector
+type Exporter interface {
+	Export(spans []*Span) error
+}
+
+// Config configures a Tracer
+type Config struct {
+	// SampleRatio is the ratio of new traces which are recorded, a trace
+	// started by a caller follows the caller's sampled flag
+	SampleRatio float64
+
+	// BatchSize is the most spans sent in one export
+	BatchSize int
+
+	// Interval between exports
+	Interval time.Duration
+}
+
+// Tracer records spans and exports them in batches
+type Tracer struct {
+	config   Config
+	exporter Exporter
+	queue    chan *Span
+
+	lock sync.Mutex
+}
+
+var current atomic.Pointer[Tracer]
+
+// NewTracer creates a Tracer which sends spans to exporter
+func NewTracer(config Config, exporter Exporter) *Tracer {
+	if config.BatchSize < 1 {
+		config.BatchSize = 512
+	}
+
+	return &Tracer{
+		config:   config,
+		exporter: exporter,
+		queue:    make(chan *Span, config.BatchSize*4),
+	}
+}
+
+// SetTracer sets the Tracer used by Start, nil disables tracing
+func SetTracer(t *Tracer) {
+	current.Store(t)
+}
+
+// Start exports spans on a ticker in a separate goroutine
+func (t *Tracer) Start() {
+	ticker := time.NewTicker(t.config.Interval)
+
+	go func() {
+		for range ticker.C {
+			if err := t.Flush(); err != nil {
+				slog.Error("unable to export spans", "error", err)
+			}
+		}
+	}()
+}
+
+// Flush exports the spans which have ended
+func (t *Tracer) Flush() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for {
+		batch := t.batch()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := t.exporter.Export(batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *Tracer) batch() []*Span {
+	batch := []*Span{}
+	for len(batch) < t.config.BatchSize {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (t *Tracer) end(span *Span) {
+	select {
+	case t.queue <- span:
+	default:
+		// The span is dropped rather than blocking the request, when the
+		// collector can not keep up
+	}
+}
+
+// Start starts a span as a child of the span context in ctx. The returned
+// span is nil, and can still be used, when tracing is disabled or the
+// trace is not sampled.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	t := current.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+
+	c := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		c.TraceID = parent.TraceID
+		c.Sampled = parent.Sampled
+		c.State = parent.State
+	} else {
+		c.TraceID = newTraceID()
+		c.Sampled = mrand.Float64() < t.config.SampleRatio
+	}
+
+	ctx = ContextWithSpanContext(ctx, c)
+	if !c.Sampled {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer:    t,
+		Name:      name,
+		Kind:      kind,
+		Context:   c,
+		StartTime: time.Now(),
+	}
+	if parent.IsValid() {
+		span.Parent = parent.SpanID
+	}
+
+	return ctx, span
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
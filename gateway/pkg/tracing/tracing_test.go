@@ -0,0 +1,212 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const parentTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+type recordingExporter struct {
+	spans []*Span
+}
+
+func (e *recordingExporter) Export(spans []*Span) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func useTracer(t *testing.T, sampleRatio float64) (*Tracer, *recordingExporter) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(Config{SampleRatio: sampleRatio, Interval: time.Second}, exporter)
+
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+
+	return tracer, exporter
+}
+
+func Test_ParseTraceparent(t *testing.T) {
+	c, err := ParseTraceparent(parentTraceparent)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if c.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || c.SpanID.String() != "00f067aa0ba902b7" || !c.Sampled {
+		t.Fatalf("unexpected span context: %+v", c)
+	}
+	if c.Traceparent() != parentTraceparent {
+		t.Fatalf("Traceparent, want: %s, got: %s", parentTraceparent, c.Traceparent())
+	}
+}
+
+func Test_ParseTraceparent_Invalid(t *testing.T) {
+	values := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+
+	for _, value := range values {
+		if _, err := ParseTraceparent(value); err == nil {
+			t.Errorf("want an error for: %q", value)
+		}
+	}
+}
+
+func Test_ParseTraceparent_LaterVersion(t *testing.T) {
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Fatalf("want a later version to be read as version 00, got: %s", err)
+	}
+}
+
+func Test_Start_Disabled(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, parentTraceparent)
+	header.Set(TracestateHeader, "vendor=value")
+
+	ctx, span := Start(Extract(context.Background(), header), "proxy", KindClient)
+	if span != nil {
+		t.Fatalf("want no span when tracing is disabled")
+	}
+
+	// The caller's context is still passed on
+	out := http.Header{}
+	Inject(ctx, out)
+	if out.Get(TraceparentHeader) != parentTraceparent || out.Get(TracestateHeader) != "vendor=value" {
+		t.Fatalf("want the incoming context to be injected, got: %v", out)
+	}
+}
+
+func Test_Start_ChildOfTraceparent(t *testing.T) {
+	tracer, exporter := useTracer(t, 0)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, parentTraceparent)
+
+	ctx, span := Start(Extract(context.Background(), header), "scale", KindInternal)
+	span.SetAttribute("faas.function", "echo")
+	span.SetError(errors.New("timed out"))
+	span.End()
+	span.End()
+
+	out := http.Header{}
+	Inject(ctx, out)
+	c, _ := ParseTraceparent(out.Get(TraceparentHeader))
+	if c.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || c.SpanID != span.Context.SpanID {
+		t.Fatalf("want the span's context to be injected, got: %s", out.Get(TraceparentHeader))
+	}
+
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(exporter.spans) != 1 {
+		t.Fatalf("exported spans, want: 1, got: %d", len(exporter.spans))
+	}
+	if got := exporter.spans[0].Parent.String(); got != "00f067aa0ba902b7" {
+		t.Fatalf("parent, want: 00f067aa0ba902b7, got: %s", got)
+	}
+}
+
+func Test_Start_FollowsUnsampledParent(t *testing.T) {
+	tracer, exporter := useTracer(t, 1)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	ctx, span := Start(Extract(context.Background(), header), "proxy", KindClient)
+	span.End()
+
+	if span != nil {
+		t.Fatalf("want no span for an unsampled trace")
+	}
+	if c := SpanContextFromContext(ctx); !c.IsValid() || c.Sampled {
+		t.Fatalf("want an unsampled span context to be passed on, got: %+v", c)
+	}
+
+	tracer.Flush()
+	if len(exporter.spans) != 0 {
+		t.Fatalf("exported spans, want: 0, got: %d", len(exporter.spans))
+	}
+}
+
+func Test_Handler_RecordsServerSpan(t *testing.T) {
+	tracer, exporter := useTracer(t, 1)
+
+	var child SpanContext
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		child = SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/function/echo", nil)
+	req.Header.Set(TraceparentHeader, parentTraceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	tracer.Flush()
+	if len(exporter.spans) != 1 {
+		t.Fatalf("exported spans, want: 1, got: %d", len(exporter.spans))
+	}
+
+	span := exporter.spans[0]
+	if span.Name != "HTTP GET" || span.Kind != KindServer || span.Error != "HTTP 502" {
+		t.Fatalf("unexpected span: %s, kind %d, error %q", span.Name, span.Kind, span.Error)
+	}
+	if child.SpanID != span.Context.SpanID {
+		t.Fatalf("want the handler to run within the server span")
+	}
+}
+
+func Test_OTLPExporter(t *testing.T) {
+	var body map[string]interface{}
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+	}))
+	defer server.Close()
+
+	tracer, _ := useTracer(t, 1)
+	exporter := NewOTLPExporter(server.URL, "gateway", time.Second)
+
+	_, span := Start(context.Background(), "proxy", KindClient)
+	span.SetAttribute("http.status_code", 200)
+	span.End()
+
+	batch := tracer.batch()
+	if err := exporter.Export(batch); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if path != "/v1/traces" {
+		t.Fatalf("path, want: /v1/traces, got: %s", path)
+	}
+
+	resourceSpans := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	scopeSpans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})
+	exported := scopeSpans["spans"].([]interface{})[0].(map[string]interface{})
+
+	if exported["traceId"] != span.Context.TraceID.String() || exported["name"] != "proxy" || exported["kind"] != float64(KindClient) {
+		t.Fatalf("unexpected span: %v", exported)
+	}
+
+	attribute := exported["attributes"].([]interface{})[0].(map[string]interface{})
+	value := attribute["value"].(map[string]interface{})
+	if attribute["key"] != "http.status_code" || value["intValue"] != "200" {
+		t.Fatalf("unexpected attribute: %v", attribute)
+	}
+}
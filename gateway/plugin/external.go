@@ -5,10 +5,10 @@ package plugin
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -17,6 +17,7 @@ import (
 
 	types "github.com/openfaas/faas-provider/types"
 	middleware "github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 	"github.com/openfaas/faas/gateway/scaling"
 )
 
@@ -34,16 +35,18 @@ type ExternalServiceQuery struct {
 func NewExternalServiceQuery(externalURL url.URL, authInjector middleware.AuthInjector) scaling.ServiceQuery {
 	timeout := 3 * time.Second
 
+	// Connections are kept alive, so that the provider is not sent a new
+	// connection for each query during a scale from zero.
 	proxyClient := http.Client{
 		Transport: &http.Transport{
 			Proxy: http.ProxyFromEnvironment,
 			DialContext: (&net.Dialer{
 				Timeout:   timeout,
-				KeepAlive: 0,
+				KeepAlive: 30 * time.Second,
 			}).DialContext,
-			MaxIdleConns:          1,
-			DisableKeepAlives:     true,
-			IdleConnTimeout:       120 * time.Millisecond,
+			MaxIdleConns:          10,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
 			ExpectContinueTimeout: 1500 * time.Millisecond,
 		},
 	}
@@ -58,9 +61,28 @@ func NewExternalServiceQuery(externalURL url.URL, authInjector middleware.AuthIn
 
 // GetReplicas replica count for function
 func (s ExternalServiceQuery) GetReplicas(serviceName, serviceNamespace string) (scaling.ServiceQueryResponse, error) {
+	return s.getReplicas(context.Background(), serviceName, serviceNamespace)
+}
+
+// GetReplicasContext is GetReplicas traced as part of the request in ctx
+func (s ExternalServiceQuery) GetReplicasContext(ctx context.Context, serviceName, serviceNamespace string) (scaling.ServiceQueryResponse, error) {
+	return s.getReplicas(ctx, serviceName, serviceNamespace)
+}
+
+// getReplicas queries the function's status. The request is traced as part
+// of ctx, but is not cancelled with it, so that a call shared by several
+// requests is not cancelled by the first.
+func (s ExternalServiceQuery) getReplicas(ctx context.Context, serviceName, serviceNamespace string) (response scaling.ServiceQueryResponse, err error) {
 	start := time.Now()
 
-	var err error
+	ctx, span := tracing.Start(ctx, "provider GetReplicas", tracing.KindClient)
+	span.SetAttribute("faas.function", serviceName)
+	span.SetAttribute("faas.namespace", serviceNamespace)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	var emptyServiceQueryResponse scaling.ServiceQueryResponse
 
 	function := types.FunctionStatus{}
@@ -79,13 +101,15 @@ func (s ExternalServiceQuery) GetReplicas(serviceName, serviceNamespace string)
 	if s.AuthInjector != nil {
 		s.AuthInjector.Inject(req)
 	}
+	tracing.Inject(ctx, req.Header)
 
 	res, err := s.ProxyClient.Do(req)
 	if err != nil {
-		log.Println(urlPath, err)
+		logger.ErrorContext(ctx, "unable to query replicas", "url", urlPath, "error", err)
 		return emptyServiceQueryResponse, err
 
 	}
+	span.SetStatusCode(res.StatusCode)
 
 	var bytesOut []byte
 	if res.Body != nil {
@@ -95,28 +119,74 @@ func (s ExternalServiceQuery) GetReplicas(serviceName, serviceNamespace string)
 
 	if res.StatusCode == http.StatusOK {
 		if err := json.Unmarshal(bytesOut, &function); err != nil {
-			log.Printf("Unable to unmarshal: %q, %s", string(bytesOut), err)
+			logger.ErrorContext(ctx, "unable to unmarshal function", "body", string(bytesOut), "error", err)
 			return emptyServiceQueryResponse, err
 		}
 
-		// log.Printf("GetReplicas [%s.%s] took: %fs", serviceName, serviceNamespace, time.Since(start).Seconds())
+		logger.DebugContext(ctx, "queried replicas",
+			"function", serviceName, "namespace", serviceNamespace, "duration_seconds", time.Since(start).Seconds())
 
 	} else {
-		log.Printf("GetReplicas [%s.%s] took: %.4fs, code: %d\n", serviceName, serviceNamespace, time.Since(start).Seconds(), res.StatusCode)
+		logger.WarnContext(ctx, "unable to query replicas",
+			"function", serviceName, "namespace", serviceNamespace,
+			"duration_seconds", time.Since(start).Seconds(), "status", res.StatusCode)
+		if res.StatusCode == http.StatusNotFound {
+			return emptyServiceQueryResponse, fmt.Errorf("%w: %s, body: %s", scaling.ErrFunctionNotFound, serviceName, string(bytesOut))
+		}
 		return emptyServiceQueryResponse, fmt.Errorf("server returned non-200 status code (%d) for function, %s, body: %s", res.StatusCode, serviceName, string(bytesOut))
 	}
 
 	minReplicas := uint64(scaling.DefaultMinReplicas)
 	maxReplicas := uint64(scaling.DefaultMaxReplicas)
 	scalingFactor := uint64(scaling.DefaultScalingFactor)
+	scalingType := scaling.DefaultTypeScale
+	targetLoad := uint64(0)
+	scaleToZero := false
+	scaleToZeroDuration := time.Duration(0)
+	coldStartTimeout := time.Duration(0)
+	policy := scaling.ScalingPolicy{}
 	availableReplicas := function.AvailableReplicas
 
+	// Cron expressions are not valid Kubernetes label values, so the
+	// schedule can also be given as an annotation
+	schedule, scheduleTimezone := "", ""
+	if function.Annotations != nil {
+		annotations := *function.Annotations
+		schedule = annotations[scaling.ScheduleLabel]
+		scheduleTimezone = annotations[scaling.ScheduleTimezoneLabel]
+	}
+
 	if function.Labels != nil {
 		labels := *function.Labels
 
 		minReplicas = extractLabelValue(labels[scaling.MinScaleLabel], minReplicas)
 		maxReplicas = extractLabelValue(labels[scaling.MaxScaleLabel], maxReplicas)
 		extractedScalingFactor := extractLabelValue(labels[scaling.ScalingFactorLabel], scalingFactor)
+		targetLoad = extractLabelValue(labels[scaling.TargetLoadLabel], targetLoad)
+
+		if v := labels[scaling.ScaleTypeLabel]; len(v) > 0 {
+			scalingType = v
+		}
+
+		scaleToZero = labels[scaling.ScaleToZeroLabel] == "true"
+		scaleToZeroDuration = extractDurationLabelValue(labels[scaling.ScaleToZeroDurationLabel], scaleToZeroDuration)
+		coldStartTimeout = extractDurationLabelValue(labels[scaling.ColdStartTimeoutLabel], coldStartTimeout)
+
+		policy = scaling.ScalingPolicy{
+			ScaleUpWindow:     extractDurationLabelValue(labels[scaling.ScaleUpWindowLabel], 0),
+			ScaleDownWindow:   extractDurationLabelValue(labels[scaling.ScaleDownWindowLabel], 0),
+			ScaleUpCooldown:   extractDurationLabelValue(labels[scaling.ScaleUpCooldownLabel], 0),
+			ScaleDownCooldown: extractDurationLabelValue(labels[scaling.ScaleDownCooldownLabel], 0),
+			MaxStepUp:         extractLabelValue(labels[scaling.MaxStepUpLabel], 0),
+			MaxStepDown:       extractLabelValue(labels[scaling.MaxStepDownLabel], 0),
+		}
+
+		if v := labels[scaling.ScheduleLabel]; len(v) > 0 {
+			schedule = v
+		}
+		if v := labels[scaling.ScheduleTimezoneLabel]; len(v) > 0 {
+			scheduleTimezone = v
+		}
 
 		if extractedScalingFactor > 0 && extractedScalingFactor <= 100 {
 			scalingFactor = extractedScalingFactor
@@ -126,18 +196,38 @@ func (s ExternalServiceQuery) GetReplicas(serviceName, serviceNamespace string)
 	}
 
 	return scaling.ServiceQueryResponse{
-		Replicas:          function.Replicas,
-		MaxReplicas:       maxReplicas,
-		MinReplicas:       minReplicas,
-		ScalingFactor:     scalingFactor,
-		AvailableReplicas: availableReplicas,
-		Annotations:       function.Annotations,
+		Replicas:            function.Replicas,
+		MaxReplicas:         maxReplicas,
+		MinReplicas:         minReplicas,
+		ScalingFactor:       scalingFactor,
+		AvailableReplicas:   availableReplicas,
+		Annotations:         function.Annotations,
+		ScalingType:         scalingType,
+		TargetLoad:          targetLoad,
+		ScaleToZero:         scaleToZero,
+		ScaleToZeroDuration: scaleToZeroDuration,
+		ColdStartTimeout:    coldStartTimeout,
+		Schedule:            schedule,
+		ScheduleTimezone:    scheduleTimezone,
+		Policy:              policy,
 	}, err
 }
 
 // SetReplicas update the replica count
 func (s ExternalServiceQuery) SetReplicas(serviceName, serviceNamespace string, count uint64) error {
-	var err error
+	return s.SetReplicasContext(context.Background(), serviceName, serviceNamespace, count)
+}
+
+// SetReplicasContext is SetReplicas traced as part of the request in ctx
+func (s ExternalServiceQuery) SetReplicasContext(ctx context.Context, serviceName, serviceNamespace string, count uint64) (err error) {
+	ctx, span := tracing.Start(ctx, "provider SetReplicas", tracing.KindClient)
+	span.SetAttribute("faas.function", serviceName)
+	span.SetAttribute("faas.namespace", serviceNamespace)
+	span.SetAttribute("faas.replicas", count)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
 
 	scaleReq := types.ScaleServiceRequest{
 		ServiceName: serviceName,
@@ -156,24 +246,27 @@ func (s ExternalServiceQuery) SetReplicas(serviceName, serviceNamespace string,
 	if s.AuthInjector != nil {
 		s.AuthInjector.Inject(req)
 	}
+	tracing.Inject(ctx, req.Header)
 
 	defer req.Body.Close()
 	res, err := s.ProxyClient.Do(req)
 
 	if err != nil {
-		log.Println(urlPath, err)
-	} else {
-		if res.Body != nil {
-			defer res.Body.Close()
-		}
+		logger.ErrorContext(ctx, "unable to set replicas", "url", urlPath, "error", err)
+		return err
+	}
+	span.SetStatusCode(res.StatusCode)
+
+	if res.Body != nil {
+		defer res.Body.Close()
 	}
 
 	if !(res.StatusCode == http.StatusOK || res.StatusCode == http.StatusAccepted) {
 		err = fmt.Errorf("error scaling HTTP code %d, %s", res.StatusCode, urlPath)
 	}
 
-	log.Printf("SetReplicas [%s.%s] took: %.4fs",
-		serviceName, serviceNamespace, time.Since(start).Seconds())
+	logger.InfoContext(ctx, "set replicas",
+		"function", serviceName, "namespace", serviceNamespace, "duration_seconds", time.Since(start).Seconds())
 
 	return err
 }
@@ -188,9 +281,26 @@ func extractLabelValue(rawLabelValue string, fallback uint64) uint64 {
 	value, err := strconv.Atoi(rawLabelValue)
 
 	if err != nil {
-		log.Printf("Provided label value %s should be of type uint", rawLabelValue)
+		logger.Warn("label value should be of type uint", "value", rawLabelValue)
 		return fallback
 	}
 
 	return uint64(value)
 }
+
+// extractDurationLabelValue will parse the provided raw label value as a
+// duration and if it fails it will return the provided fallback value and
+// log an message
+func extractDurationLabelValue(rawLabelValue string, fallback time.Duration) time.Duration {
+	if len(rawLabelValue) <= 0 {
+		return fallback
+	}
+
+	value, err := time.ParseDuration(rawLabelValue)
+	if err != nil {
+		logger.Warn("label value should be a duration", "value", rawLabelValue)
+		return fallback
+	}
+
+	return value
+}
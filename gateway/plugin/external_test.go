@@ -1,6 +1,7 @@
 package plugin
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -75,6 +76,7 @@ func TestGetReplicasExistentFn(t *testing.T) {
 		MinReplicas:       uint64(scaling.DefaultMinReplicas),
 		ScalingFactor:     uint64(scaling.DefaultScalingFactor),
 		AvailableReplicas: 0,
+		ScalingType:       scaling.DefaultTypeScale,
 	}
 
 	var injector middleware.AuthInjector
@@ -136,3 +138,29 @@ func TestSetReplicasExistentFn(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGetReplicasNonExistentFn_IsNotFound(t *testing.T) {
+	testServer := httptest.NewServer(
+		http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			res.WriteHeader(http.StatusNotFound)
+		}))
+	defer testServer.Close()
+
+	url, _ := url.Parse(testServer.URL + "/")
+	esq := NewExternalServiceQuery(*url, nil)
+
+	if _, err := esq.GetReplicas("figlet", ""); !errors.Is(err, scaling.ErrFunctionNotFound) {
+		t.Fatalf("want ErrFunctionNotFound, got: %v", err)
+	}
+}
+
+func TestSetReplicas_UnreachableProvider(t *testing.T) {
+	testServer := httptest.NewServer(http.NotFoundHandler())
+	url, _ := url.Parse(testServer.URL + "/")
+	testServer.Close()
+
+	esq := NewExternalServiceQuery(*url, nil)
+	if err := esq.SetReplicas("figlet", "openfaas-fn", 1); err == nil {
+		t.Fatalf("want an error when the provider is unreachable")
+	}
+}
@@ -3,21 +3,74 @@
 
 package requests
 
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
 // PrometheusInnerAlertLabel PrometheusInnerAlertLabel
 type PrometheusInnerAlertLabel struct {
 	AlertName    string `json:"alertname"`
 	FunctionName string `json:"function_name"`
+
+	// Namespace of the function, when not set the namespace is read from
+	// the FunctionName i.e. "echo.openfaas-fn"
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // PrometheusInnerAlert PrometheusInnerAlert
 type PrometheusInnerAlert struct {
 	Status string                    `json:"status"`
 	Labels PrometheusInnerAlertLabel `json:"labels"`
+
+	// Annotations can set the scaling action for the alert
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	StartsAt     time.Time `json:"startsAt"`
+	EndsAt       time.Time `json:"endsAt"`
+	GeneratorURL string    `json:"generatorURL,omitempty"`
+
+	// Fingerprint identifies the alert across notifications
+	Fingerprint string `json:"fingerprint,omitempty"`
 }
 
-// PrometheusAlert as produced by AlertManager
+// PrometheusAlert as produced by AlertManager, version 4 of the webhook
+// payload is supported along with earlier versions
 type PrometheusAlert struct {
-	Status   string                 `json:"status"`
-	Receiver string                 `json:"receiver"`
-	Alerts   []PrometheusInnerAlert `json:"alerts"`
+	Version         string                 `json:"version,omitempty"`
+	GroupKey        AlertGroupKey          `json:"groupKey,omitempty"`
+	TruncatedAlerts int                    `json:"truncatedAlerts,omitempty"`
+	Status          string                 `json:"status"`
+	Receiver        string                 `json:"receiver"`
+	Alerts          []PrometheusInnerAlert `json:"alerts"`
+
+	GroupLabels       map[string]string `json:"groupLabels,omitempty"`
+	CommonLabels      map[string]string `json:"commonLabels,omitempty"`
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	ExternalURL       string            `json:"externalURL,omitempty"`
+}
+
+// AlertGroupKey is a string in version 4 of the webhook payload and a
+// number in earlier versions
+type AlertGroupKey string
+
+// UnmarshalJSON reads the group key as either a string or a number
+func (k *AlertGroupKey) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*k = AlertGroupKey(value)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(number.String(), 10, 64); err != nil {
+		return err
+	}
+
+	*k = AlertGroupKey(number.String())
+	return nil
 }
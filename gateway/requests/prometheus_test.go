@@ -86,3 +86,56 @@ func TestUnmarshallAlert(t *testing.T) {
 	}
 
 }
+
+func TestUnmarshallAlert_Version4(t *testing.T) {
+	file := []byte(`{
+    "version": "4",
+    "groupKey": "{}:{alertname=\"APIHighInvocationRate\"}",
+    "truncatedAlerts": 0,
+    "status": "firing",
+    "receiver": "scale-up",
+    "groupLabels": {"alertname": "APIHighInvocationRate"},
+    "commonLabels": {"alertname": "APIHighInvocationRate"},
+    "commonAnnotations": {},
+    "externalURL": "http://alertmanager:9093",
+    "alerts": [{
+        "status": "firing",
+        "labels": {
+            "alertname": "APIHighInvocationRate",
+            "function_name": "nodeinfo",
+            "namespace": "staging"
+        },
+        "annotations": {
+            "scale_action": "set",
+            "scale_replicas": "4"
+        },
+        "startsAt": "2023-03-15T15:52:57.805Z",
+        "endsAt": "0001-01-01T00:00:00Z",
+        "generatorURL": "http://prometheus:9090/graph",
+        "fingerprint": "6b2f4f2d2e6b9a1c"
+    }]
+}`)
+
+	var alert PrometheusAlert
+	if err := json.Unmarshal(file, &alert); err != nil {
+		t.Fatal(err)
+	}
+
+	if alert.Version != "4" || len(alert.GroupKey) == 0 {
+		t.Fatalf("want version 4 with a group key, got: %q, %q", alert.Version, alert.GroupKey)
+	}
+
+	inner := alert.Alerts[0]
+	if inner.Labels.Namespace != "staging" {
+		t.Fatalf("namespace, want: staging, got: %q", inner.Labels.Namespace)
+	}
+	if inner.Fingerprint != "6b2f4f2d2e6b9a1c" {
+		t.Fatalf("fingerprint, want: 6b2f4f2d2e6b9a1c, got: %q", inner.Fingerprint)
+	}
+	if inner.Annotations["scale_action"] != "set" {
+		t.Fatalf("scale_action, want: set, got: %q", inner.Annotations["scale_action"])
+	}
+	if inner.StartsAt.IsZero() || !inner.EndsAt.IsZero() {
+		t.Fatalf("want startsAt to be set and endsAt to be zero, got: %s, %s", inner.StartsAt, inner.EndsAt)
+	}
+}
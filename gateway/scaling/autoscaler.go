@@ -0,0 +1,263 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultTargetLoad is used when a function does not set TargetLoadLabel
+var defaultTargetLoad = map[string]uint64{
+	ScaleTypeRPS:      50,
+	ScaleTypeCapacity: 10,
+	ScaleTypeLatency:  500,
+}
+
+// AutoscalerConfig configures the built-in autoscaler
+type AutoscalerConfig struct {
+	// Interval between each evaluation of the functions
+	Interval time.Duration
+
+	// ScaleDownWindow is how long the highest recommendation is kept
+	// before a function is allowed to scale down
+	ScaleDownWindow time.Duration
+
+	// ServiceQuery queries and sets the replicas of a function
+	ServiceQuery ServiceQuery
+
+	// History records each change of replicas, it can be nil
+	History *ScalingHistory
+}
+
+// Autoscaler computes the target replicas for each function from the
+// load observed by the gateway, as an alternative to Alertmanager.
+type Autoscaler struct {
+	config  AutoscalerConfig
+	tracker *InvocationTracker
+
+	lock            sync.Mutex
+	previous        map[string]sample
+	recommendations map[string][]recommendation
+}
+
+// sample is the state of a FunctionLoad at the last evaluation
+type sample struct {
+	completed     uint64
+	totalDuration time.Duration
+	at            time.Time
+}
+
+type recommendation struct {
+	replicas uint64
+	at       time.Time
+}
+
+// AutoscaleResult is the outcome of evaluating a single function
+type AutoscaleResult struct {
+	Name            string
+	Namespace       string
+	Load            float64
+	CurrentReplicas uint64
+	TargetReplicas  uint64
+	Error           error
+}
+
+// NewAutoscaler creates an Autoscaler which reads load from tracker
+func NewAutoscaler(config AutoscalerConfig, tracker *InvocationTracker) *Autoscaler {
+	return &Autoscaler{
+		config:          config,
+		tracker:         tracker,
+		previous:        make(map[string]sample),
+		recommendations: make(map[string][]recommendation),
+	}
+}
+
+// Start evaluates all functions on a ticker in a separate goroutine
+func (a *Autoscaler) Start() {
+	ticker := time.NewTicker(a.config.Interval)
+
+	go func() {
+		for range ticker.C {
+			for _, res := range a.Evaluate(time.Now()) {
+				if res.Error != nil {
+					logger.Error("unable to autoscale", "function", res.Name, "namespace", res.Namespace, "error", res.Error)
+				}
+			}
+		}
+	}()
+}
+
+// Evaluate computes and applies the target replicas for every function
+// in the tracker's Snapshot. The tracker prunes functions which have been
+// idle for the IdleWindow, so that they are not queried on every interval.
+func (a *Autoscaler) Evaluate(now time.Time) []AutoscaleResult {
+	loads := a.tracker.Snapshot()
+	results := make([]AutoscaleResult, 0, len(loads))
+
+	// A pruned function starts from a new sample if it is invoked again
+	active := map[string]bool{}
+	for _, load := range loads {
+		results = append(results, a.evaluate(load, now))
+		active[load.Name+"."+load.Namespace] = true
+	}
+
+	a.lock.Lock()
+	for key := range a.previous {
+		if !active[key] {
+			delete(a.previous, key)
+			delete(a.recommendations, key)
+		}
+	}
+	a.lock.Unlock()
+
+	return results
+}
+
+// IdleWindow is how long a function is kept in the tracker without a
+// request. The recommendations made from its last requests expire from the
+// ScaleDownWindow within two intervals, so it has been scaled down by then.
+func (c AutoscalerConfig) IdleWindow() time.Duration {
+	return c.ScaleDownWindow + c.Interval*2
+}
+
+func (a *Autoscaler) evaluate(load FunctionLoad, now time.Time) AutoscaleResult {
+	key := load.Name + "." + load.Namespace
+	result := AutoscaleResult{Name: load.Name, Namespace: load.Namespace}
+
+	a.lock.Lock()
+	last, seen := a.previous[key]
+	a.previous[key] = sample{completed: load.Completed, totalDuration: load.TotalDuration, at: now}
+	a.lock.Unlock()
+
+	// At least two samples are needed to work out a rate
+	if !seen {
+		return result
+	}
+
+	queryResponse, err := a.config.ServiceQuery.GetReplicas(load.Name, load.Namespace)
+	if err != nil {
+		if errors.Is(err, ErrFunctionNotFound) {
+			a.tracker.Forget(load.Name, load.Namespace)
+		}
+		result.Error = err
+		return result
+	}
+
+	result.CurrentReplicas = queryResponse.Replicas
+
+	// Scaling up from zero is left to the scale from zero handler, and
+	// functions which are idle at zero are left there.
+	if queryResponse.Replicas == 0 {
+		result.TargetReplicas = 0
+		return result
+	}
+
+	scalingType := queryResponse.ScalingType
+	if len(scalingType) == 0 {
+		scalingType = DefaultTypeScale
+	}
+
+	targetLoad := queryResponse.TargetLoad
+	if targetLoad == 0 {
+		targetLoad = defaultTargetLoad[scalingType]
+	}
+
+	completed := load.Completed - last.completed
+	elapsed := now.Sub(last.at).Seconds()
+
+	var desired float64
+	switch scalingType {
+	case ScaleTypeRPS:
+		if elapsed > 0 {
+			result.Load = float64(completed) / elapsed
+		}
+		desired = result.Load / float64(targetLoad)
+	case ScaleTypeCapacity:
+		result.Load = float64(load.InFlight)
+		desired = result.Load / float64(targetLoad)
+	case ScaleTypeLatency:
+		if completed > 0 {
+			meanLatency := (load.TotalDuration - last.totalDuration) / time.Duration(completed)
+			result.Load = float64(meanLatency.Milliseconds())
+		}
+		desired = float64(queryResponse.Replicas) * result.Load / float64(targetLoad)
+	default:
+		result.Error = fmt.Errorf("unknown scaling type: %q", scalingType)
+		return result
+	}
+
+	target := boundReplicas(uint64(math.Ceil(desired)), queryResponse.MinReplicas, queryResponse.MaxReplicas)
+	target = a.stabilize(key, target, now)
+	result.TargetReplicas = target
+
+	if target == queryResponse.Replicas {
+		return result
+	}
+
+	logger.Info("autoscaling",
+		"function", load.Name, "namespace", load.Namespace,
+		"replicas", queryResponse.Replicas, "target_replicas", target,
+		"type", scalingType, "load", result.Load, "target", targetLoad)
+
+	if err := a.config.ServiceQuery.SetReplicas(load.Name, load.Namespace, target); err != nil {
+		result.Error = err
+	}
+
+	a.config.History.Record(ScalingEvent{
+		Time:      now,
+		Function:  load.Name,
+		Namespace: load.Namespace,
+		Trigger:   TriggerAutoscaler,
+		Inputs: map[string]string{
+			"type":   scalingType,
+			"load":   strconv.FormatFloat(result.Load, 'f', 2, 64),
+			"target": strconv.FormatUint(targetLoad, 10),
+		},
+		OldReplicas: queryResponse.Replicas,
+		NewReplicas: target,
+		Error:       errorString(result.Error),
+	})
+
+	return result
+}
+
+// stabilize returns the highest recommendation within the ScaleDownWindow,
+// so that replicas are not removed on a short dip in traffic.
+func (a *Autoscaler) stabilize(key string, target uint64, now time.Time) uint64 {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	kept := []recommendation{{replicas: target, at: now}}
+	highest := target
+
+	for _, r := range a.recommendations[key] {
+		if now.Sub(r.at) < a.config.ScaleDownWindow {
+			kept = append(kept, r)
+			if r.replicas > highest {
+				highest = r.replicas
+			}
+		}
+	}
+
+	a.recommendations[key] = kept
+	return highest
+}
+
+func boundReplicas(replicas, minReplicas, maxReplicas uint64) uint64 {
+	if minReplicas == 0 {
+		minReplicas = 1
+	}
+	if replicas < minReplicas {
+		replicas = minReplicas
+	}
+	if maxReplicas > 0 && replicas > maxReplicas {
+		replicas = maxReplicas
+	}
+	return replicas
+}
@@ -0,0 +1,291 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/types"
+)
+
+type fakeServiceQuery struct {
+	lock     sync.Mutex
+	response ServiceQueryResponse
+	setCalls []uint64
+
+	// readyOnSet makes the replicas available as soon as they are set
+	readyOnSet bool
+}
+
+func (f *fakeServiceQuery) GetReplicas(service, namespace string) (ServiceQueryResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.response, nil
+}
+
+func (f *fakeServiceQuery) SetReplicas(service, namespace string, count uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.setCalls = append(f.setCalls, count)
+	f.response.Replicas = count
+	if f.readyOnSet {
+		f.response.AvailableReplicas = count
+	}
+	return nil
+}
+
+func invoke(tracker *InvocationTracker, url string, count int, duration time.Duration) {
+	for i := 0; i < count; i++ {
+		tracker.Notify(types.HTTPNotification{Method: "GET", URL: url, OriginalURL: url, StatusCode: 102, Event: "started"})
+		tracker.Notify(types.HTTPNotification{Method: "GET", URL: url, OriginalURL: url, StatusCode: 200, Event: "completed", Duration: duration})
+	}
+}
+
+func Test_InvocationTracker_InFlight(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+
+	tracker.Notify(types.HTTPNotification{Method: "GET", URL: "/function/echo", OriginalURL: "/function/echo", StatusCode: 102, Event: "started"})
+	tracker.Notify(types.HTTPNotification{Method: "GET", URL: "/function/echo", OriginalURL: "/function/echo", StatusCode: 102, Event: "started"})
+	tracker.Notify(types.HTTPNotification{Method: "GET", URL: "/function/echo", OriginalURL: "/function/echo", StatusCode: 200, Event: "completed", Duration: time.Second})
+
+	load, ok := tracker.Get("echo", "openfaas-fn")
+	if !ok {
+		t.Fatalf("want load for echo.openfaas-fn")
+	}
+	if load.InFlight != 1 {
+		t.Errorf("InFlight want: %d, got: %d", 1, load.InFlight)
+	}
+	if load.Completed != 1 {
+		t.Errorf("Completed want: %d, got: %d", 1, load.Completed)
+	}
+}
+
+func Test_InvocationTracker_IgnoresNonFunctionURLs(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+
+	tracker.Notify(types.HTTPNotification{Method: "GET", URL: "/system/functions", OriginalURL: "/system/functions", StatusCode: 200, Event: "completed", Duration: time.Second})
+
+	if got := len(tracker.Snapshot()); got != 0 {
+		t.Errorf("want no functions tracked, got: %d", got)
+	}
+}
+
+// knownFunctions is a FunctionChecker for a fixed set of functions
+type knownFunctions map[string]bool
+
+func (k knownFunctions) FunctionExists(serviceName string) bool {
+	return k[serviceName]
+}
+
+func Test_InvocationTracker_IgnoresUnknownFunctions(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	tracker.Functions = knownFunctions{"echo.openfaas-fn": true}
+
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+	invoke(tracker, "/function/does-not-exist", 1, time.Millisecond)
+
+	if got := tracker.Snapshot(); len(got) != 1 || got[0].Name != "echo" {
+		t.Fatalf("want only echo tracked, got: %+v", got)
+	}
+}
+
+func Test_InvocationTracker_PruneKeepsLastInvocation(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	functions := knownFunctions{"echo.openfaas-fn": true, "figlet.openfaas-fn": true}
+	tracker.Functions = functions
+
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+	invoke(tracker, "/function/figlet", 1, time.Millisecond)
+	last, _ := tracker.Get("echo", "openfaas-fn")
+
+	delete(functions, "figlet.openfaas-fn")
+	tracker.Prune(time.Minute, time.Now().Add(time.Minute*2))
+
+	if got := len(tracker.Snapshot()); got != 0 {
+		t.Fatalf("want idle functions pruned, got: %d", got)
+	}
+	if load, ok := tracker.Get("echo", "openfaas-fn"); !ok || !load.LastInvocation.Equal(last.LastInvocation) {
+		t.Fatalf("want the last invocation of echo kept, got: %+v, %v", load, ok)
+	}
+	if _, ok := tracker.Get("figlet", "openfaas-fn"); ok {
+		t.Fatalf("want figlet removed once it is no longer deployed")
+	}
+
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+	if load, ok := tracker.Get("echo", "openfaas-fn"); !ok || load.Completed != 1 {
+		t.Fatalf("want echo tracked again from a new request, got: %+v", load)
+	}
+}
+
+// notFoundServiceQuery returns ErrFunctionNotFound for every function
+type notFoundServiceQuery struct {
+	calls int
+}
+
+func (q *notFoundServiceQuery) GetReplicas(service, namespace string) (ServiceQueryResponse, error) {
+	q.calls++
+	return ServiceQueryResponse{}, fmt.Errorf("%w: %s.%s", ErrFunctionNotFound, service, namespace)
+}
+
+func (q *notFoundServiceQuery) SetReplicas(service, namespace string, count uint64) error {
+	return nil
+}
+
+func Test_Autoscaler_ForgetsFunctionsNotFound(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &notFoundServiceQuery{}
+	autoscaler := NewAutoscaler(AutoscalerConfig{ServiceQuery: query, ScaleDownWindow: time.Hour}, tracker)
+
+	now := time.Now()
+	invoke(tracker, "/function/deleted", 1, time.Millisecond)
+	autoscaler.Evaluate(now)
+	autoscaler.Evaluate(now.Add(time.Second))
+
+	if query.calls != 1 {
+		t.Fatalf("GetReplicas calls, want: 1, got: %d", query.calls)
+	}
+	if _, ok := tracker.Get("deleted", "openfaas-fn"); ok {
+		t.Fatalf("want the function forgotten")
+	}
+	if results := autoscaler.Evaluate(now.Add(time.Second * 2)); len(results) != 0 {
+		t.Fatalf("want no functions evaluated, got: %+v", results)
+	}
+}
+
+func Test_Autoscaler_ScalesUpOnRPS(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 1, MinReplicas: 1, MaxReplicas: 20, ScalingType: ScaleTypeRPS, TargetLoad: 10,
+	}}
+	autoscaler := NewAutoscaler(AutoscalerConfig{ServiceQuery: query}, tracker)
+
+	now := time.Now()
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+	autoscaler.Evaluate(now)
+
+	// 300 requests over 10 seconds is 30 RPS, or 3 replicas at 10 RPS each
+	invoke(tracker, "/function/echo", 300, time.Millisecond)
+	results := autoscaler.Evaluate(now.Add(time.Second * 10))
+
+	if len(results) != 1 {
+		t.Fatalf("want 1 result, got: %d", len(results))
+	}
+	if results[0].TargetReplicas != 3 {
+		t.Errorf("TargetReplicas want: %d, got: %d", 3, results[0].TargetReplicas)
+	}
+	if len(query.setCalls) != 1 || query.setCalls[0] != 3 {
+		t.Errorf("want SetReplicas(3), got: %v", query.setCalls)
+	}
+}
+
+func Test_Autoscaler_RespectsMaxReplicas(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 1, MinReplicas: 1, MaxReplicas: 2, ScalingType: ScaleTypeRPS, TargetLoad: 1,
+	}}
+	autoscaler := NewAutoscaler(AutoscalerConfig{ServiceQuery: query}, tracker)
+
+	now := time.Now()
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+	autoscaler.Evaluate(now)
+
+	invoke(tracker, "/function/echo", 100, time.Millisecond)
+	results := autoscaler.Evaluate(now.Add(time.Second))
+
+	if results[0].TargetReplicas != 2 {
+		t.Errorf("TargetReplicas want: %d, got: %d", 2, results[0].TargetReplicas)
+	}
+}
+
+func Test_Autoscaler_ScaleDownWindowHoldsReplicas(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 1, MinReplicas: 1, MaxReplicas: 20, ScalingType: ScaleTypeRPS, TargetLoad: 10,
+	}}
+	autoscaler := NewAutoscaler(AutoscalerConfig{ServiceQuery: query, ScaleDownWindow: time.Minute}, tracker)
+
+	now := time.Now()
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+	autoscaler.Evaluate(now)
+
+	invoke(tracker, "/function/echo", 400, time.Millisecond)
+	autoscaler.Evaluate(now.Add(time.Second * 10))
+
+	// No traffic, but still within the scale down window
+	results := autoscaler.Evaluate(now.Add(time.Second * 20))
+	if results[0].TargetReplicas != 4 {
+		t.Errorf("TargetReplicas within window want: %d, got: %d", 4, results[0].TargetReplicas)
+	}
+
+	results = autoscaler.Evaluate(now.Add(time.Minute * 2))
+	if results[0].TargetReplicas != 1 {
+		t.Errorf("TargetReplicas after window want: %d, got: %d", 1, results[0].TargetReplicas)
+	}
+
+	// Scaled down and idle for longer than the window, so no longer queried
+	tracker.Prune(autoscaler.config.IdleWindow(), now.Add(time.Minute*3))
+	if results = autoscaler.Evaluate(now.Add(time.Minute * 3)); len(results) != 0 {
+		t.Errorf("want the idle function pruned, got: %+v", results)
+	}
+}
+
+func Test_Autoscaler_LeavesFunctionsAtZero(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 0, MinReplicas: 1, MaxReplicas: 20, ScalingType: ScaleTypeRPS,
+	}}
+	autoscaler := NewAutoscaler(AutoscalerConfig{ServiceQuery: query}, tracker)
+
+	now := time.Now()
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+	autoscaler.Evaluate(now)
+	autoscaler.Evaluate(now.Add(time.Second))
+
+	if len(query.setCalls) != 0 {
+		t.Errorf("want no calls to SetReplicas, got: %v", query.setCalls)
+	}
+}
+
+func Test_Autoscaler_UnknownType(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 1, MinReplicas: 1, MaxReplicas: 20, ScalingType: "cpu",
+	}}
+	autoscaler := NewAutoscaler(AutoscalerConfig{ServiceQuery: query}, tracker)
+
+	now := time.Now()
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+	autoscaler.Evaluate(now)
+	results := autoscaler.Evaluate(now.Add(time.Second))
+
+	if results[0].Error == nil {
+		t.Fatalf("want an error for an unknown scaling type")
+	}
+}
+
+func Test_TouchNotifier_RecordsActivityWithoutInvocation(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	tracker.Functions = knownFunctions{"echo.openfaas-fn": true}
+	notifier := TouchNotifier{Tracker: tracker}
+
+	before := time.Now()
+	notifier.Notify(types.HTTPNotification{OriginalURL: "/async-function/echo", Event: "completed", Duration: time.Millisecond})
+	notifier.Notify(types.HTTPNotification{OriginalURL: "/async-function/missing", Event: "completed", Duration: time.Millisecond})
+
+	load, ok := tracker.Get("echo", "openfaas-fn")
+	if !ok || load.LastInvocation.Before(before) {
+		t.Fatalf("want echo to be touched, got: %+v", load)
+	}
+	if load.Completed != 0 || load.TotalDuration != 0 || load.InFlight != 0 {
+		t.Fatalf("want no invocation to be counted, got: %+v", load)
+	}
+	if _, ok := tracker.Get("missing", "openfaas-fn"); ok {
+		t.Fatalf("want functions which are not deployed to be ignored")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReplicasOutOfRangeError is returned when a requested replica count falls
+// outside of the limits set for a function.
+type ReplicasOutOfRangeError struct {
+	Function    string
+	Replicas    uint64
+	MinReplicas uint64
+	MaxReplicas uint64
+}
+
+func (e *ReplicasOutOfRangeError) Error() string {
+	return fmt.Sprintf("replicas %d for function %s is outside of the allowed range [%d - %d]",
+		e.Replicas, e.Function, e.MinReplicas, e.MaxReplicas)
+}
+
+// CeilingServiceQuery applies an operator-configured maximum to the
+// replicas of every function, regardless of its labels.
+type CeilingServiceQuery struct {
+	ServiceQuery ServiceQuery
+	Ceiling      uint64
+}
+
+// NewCeilingServiceQuery wraps serviceQuery with a global ceiling, a
+// ceiling of 0 disables the limit.
+func NewCeilingServiceQuery(serviceQuery ServiceQuery, ceiling uint64) ServiceQuery {
+	if ceiling == 0 {
+		return serviceQuery
+	}
+
+	return &CeilingServiceQuery{
+		ServiceQuery: serviceQuery,
+		Ceiling:      ceiling,
+	}
+}
+
+// GetReplicas queries the function and lowers its min and max replicas
+// to the ceiling.
+func (c *CeilingServiceQuery) GetReplicas(service, namespace string) (ServiceQueryResponse, error) {
+	return c.GetReplicasContext(context.Background(), service, namespace)
+}
+
+// GetReplicasContext is GetReplicas as part of the request in ctx
+func (c *CeilingServiceQuery) GetReplicasContext(ctx context.Context, service, namespace string) (ServiceQueryResponse, error) {
+	res, err := getReplicasContext(ctx, c.ServiceQuery, service, namespace)
+	if err != nil {
+		return res, err
+	}
+
+	if res.MaxReplicas > c.Ceiling {
+		res.MaxReplicas = c.Ceiling
+	}
+	if res.MinReplicas > c.Ceiling {
+		res.MinReplicas = c.Ceiling
+	}
+
+	return res, nil
+}
+
+// SetReplicas rejects any count above the ceiling instead of clamping it
+func (c *CeilingServiceQuery) SetReplicas(service, namespace string, count uint64) error {
+	return c.SetReplicasContext(context.Background(), service, namespace, count)
+}
+
+// SetReplicasContext is SetReplicas as part of the request in ctx
+func (c *CeilingServiceQuery) SetReplicasContext(ctx context.Context, service, namespace string, count uint64) error {
+	if count > c.Ceiling {
+		return &ReplicasOutOfRangeError{
+			Function:    functionLabel(service, namespace),
+			Replicas:    count,
+			MaxReplicas: c.Ceiling,
+		}
+	}
+
+	return setReplicasContext(ctx, c.ServiceQuery, service, namespace, count)
+}
@@ -0,0 +1,168 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronLookbackDays is how far back Previous searches for a matching time,
+// long enough to find a schedule which only fires on the 29th of February.
+const cronLookbackDays = 366 * 8
+
+// CronSchedule is a standard five field cron expression:
+// minute, hour, day of month, month and day of week.
+type CronSchedule struct {
+	minute     [60]bool
+	hour       [24]bool
+	dayOfMonth [32]bool
+	month      [13]bool
+	dayOfWeek  [7]bool
+
+	// Cron matches either the day of month or the day of week when both
+	// are restricted, rather than both of them
+	dayOfMonthAny bool
+	dayOfWeekAny  bool
+}
+
+// ParseCron parses a five field cron expression, each field accepts "*",
+// single values, ranges, lists and steps such as "*/15" or "1-5".
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", expression, len(fields))
+	}
+
+	c := &CronSchedule{
+		dayOfMonthAny: fields[2] == "*",
+		dayOfWeekAny:  fields[4] == "*",
+	}
+
+	if err := parseCronField(fields[0], 0, 59, c.minute[:]); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, c.hour[:]); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, c.dayOfMonth[:]); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, c.month[:]); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+
+	// 7 is accepted for Sunday, as well as 0
+	var dayOfWeek [8]bool
+	if err := parseCronField(fields[4], 0, 7, dayOfWeek[:]); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	copy(c.dayOfWeek[:], dayOfWeek[:7])
+	c.dayOfWeek[0] = c.dayOfWeek[0] || dayOfWeek[7]
+
+	return c, nil
+}
+
+func parseCronField(field string, min, max int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			val, err := strconv.Atoi(part[i+1:])
+			if err != nil || val <= 0 {
+				return fmt.Errorf("invalid step in %q", part)
+			}
+			step = val
+			part = part[:i]
+		}
+
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			val, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return fmt.Errorf("invalid value %q", part)
+			}
+			start, end = val, val
+
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return fmt.Errorf("invalid range %q", part)
+				}
+			} else if step > 1 {
+				// "5/10" runs from 5 until the end of the range
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return fmt.Errorf("%q is outside of the range [%d - %d]", part, min, max)
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true when t falls within a minute matched by the schedule
+func (c *CronSchedule) Matches(t time.Time) bool {
+	return c.minute[t.Minute()] && c.hour[t.Hour()] && c.matchesDay(t)
+}
+
+func (c *CronSchedule) matchesDay(t time.Time) bool {
+	if !c.month[t.Month()] {
+		return false
+	}
+
+	dayOfMonth := c.dayOfMonth[t.Day()]
+	dayOfWeek := c.dayOfWeek[t.Weekday()]
+
+	if c.dayOfMonthAny || c.dayOfWeekAny {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Previous returns the latest time at or before t matched by the schedule,
+// in t's location. False is returned if the schedule never matches.
+func (c *CronSchedule) Previous(t time.Time) (time.Time, bool) {
+	loc := t.Location()
+	year, month, day := t.Date()
+
+	for d := 0; d <= cronLookbackDays; d++ {
+		date := time.Date(year, month, day-d, 0, 0, 0, 0, loc)
+		if !c.matchesDay(date) {
+			continue
+		}
+
+		startHour := 23
+		if d == 0 {
+			startHour = t.Hour()
+		}
+
+		for h := startHour; h >= 0; h-- {
+			if !c.hour[h] {
+				continue
+			}
+
+			startMinute := 59
+			if d == 0 && h == t.Hour() {
+				startMinute = t.Minute()
+			}
+
+			for m := startMinute; m >= 0; m-- {
+				if c.minute[m] {
+					return time.Date(date.Year(), date.Month(), date.Day(), h, m, 0, 0, loc), true
+				}
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
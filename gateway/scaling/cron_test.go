@@ -0,0 +1,96 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseCron_Invalid(t *testing.T) {
+	cases := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	}
+
+	for _, expression := range cases {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("want an error for %q", expression)
+		}
+	}
+}
+
+func Test_CronSchedule_Matches(t *testing.T) {
+	cases := []struct {
+		expression string
+		time       time.Time
+		want       bool
+	}{
+		// Monday
+		{"0 8 * * 1-5", time.Date(2024, 1, 8, 8, 0, 0, 0, time.UTC), true},
+		{"0 8 * * 1-5", time.Date(2024, 1, 8, 8, 1, 0, 0, time.UTC), false},
+		// Saturday
+		{"0 8 * * 1-5", time.Date(2024, 1, 13, 8, 0, 0, 0, time.UTC), false},
+		{"*/15 * * * *", time.Date(2024, 1, 13, 3, 45, 0, 0, time.UTC), true},
+		{"*/15 * * * *", time.Date(2024, 1, 13, 3, 46, 0, 0, time.UTC), false},
+		{"0 0 1,15 * *", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), true},
+		// Sunday as 7
+		{"0 0 * * 7", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC), true},
+		// Either the day of month or the day of week when both are set
+		{"0 0 1 * 1", time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), true},
+		{"0 0 1 * 1", time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, c := range cases {
+		cron, err := ParseCron(c.expression)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", c.expression, err)
+		}
+		if got := cron.Matches(c.time); got != c.want {
+			t.Errorf("%q at %s, want: %v, got: %v", c.expression, c.time, c.want, got)
+		}
+	}
+}
+
+func Test_CronSchedule_Previous(t *testing.T) {
+	cron, _ := ParseCron("0 18 * * 1-5")
+
+	// Sunday at noon, the previous match was Friday at 18:00
+	now := time.Date(2024, 1, 14, 12, 0, 0, 0, time.UTC)
+	want := time.Date(2024, 1, 12, 18, 0, 0, 0, time.UTC)
+
+	got, ok := cron.Previous(now)
+	if !ok {
+		t.Fatalf("want a previous time")
+	}
+	if !got.Equal(want) {
+		t.Fatalf("Previous, want: %s, got: %s", want, got)
+	}
+
+	// A match in the current minute is returned
+	now = time.Date(2024, 1, 12, 18, 0, 30, 0, time.UTC)
+	if got, _ := cron.Previous(now); !got.Equal(want) {
+		t.Fatalf("Previous, want: %s, got: %s", want, got)
+	}
+}
+
+func Test_CronSchedule_PreviousLeapDay(t *testing.T) {
+	cron, _ := ParseCron("0 0 29 2 *")
+
+	got, ok := cron.Previous(time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC))
+	if !ok {
+		t.Fatalf("want a previous time")
+	}
+	if want := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("Previous, want: %s, got: %s", want, got)
+	}
+}
@@ -4,14 +4,61 @@
 package scaling
 
 import (
+	"container/list"
 	"sync"
 	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
 )
 
 // FunctionCacher queries functions and caches the results
 type FunctionCacher interface {
 	Set(functionName, namespace string, serviceQueryResponse ServiceQueryResponse)
 	Get(functionName, namespace string) (ServiceQueryResponse, bool)
+
+	// SetNotFound caches that the provider does not have the function
+	SetNotFound(functionName, namespace string)
+
+	// NotFound is true when the function was cached as not found
+	NotFound(functionName, namespace string) bool
+}
+
+// Results of a lookup recorded in the FunctionCacheRequests metric
+const (
+	cacheHit      = "hit"
+	cacheMiss     = "miss"
+	cacheNotFound = "not_found"
+)
+
+// lruTouchInterval is how often a Get moves an entry to the front of the
+// LRU. Gets in between only take the read lock, so that the invocations of
+// a function are not serialised on the cache.
+const lruTouchInterval = time.Second
+
+// Reasons for an eviction recorded in the FunctionCacheEvictions metric
+const (
+	evictedSize    = "size"
+	evictedExpired = "expired"
+)
+
+// FunctionCacheConfig configures a FunctionCache
+type FunctionCacheConfig struct {
+	// Name labels the cache's metrics i.e. "scaler"
+	Name string
+
+	// Expiry is how long an entry is a hit for
+	Expiry time.Duration
+
+	// NotFoundExpiry is how long a function is cached as not found for,
+	// 0 disables negative caching
+	NotFoundExpiry time.Duration
+
+	// MaxEntries bounds the number of entries, the least recently used
+	// entry is evicted when it is reached. 0 means no bound
+	MaxEntries int
+
+	// Metrics records lookups and evictions, it can be nil
+	Metrics *metrics.MetricOptions
 }
 
 // FunctionCache provides a cache of Function replica counts
@@ -19,27 +66,145 @@ type FunctionCache struct {
 	Cache  map[string]*FunctionMeta
 	Expiry time.Duration
 	Sync   sync.RWMutex
+
+	// NotFoundExpiry is how long a function is cached as not found for,
+	// 0 disables negative caching
+	NotFoundExpiry time.Duration
+
+	// MaxEntries bounds the number of entries, 0 means no bound
+	MaxEntries int
+
+	name    string
+	metrics *metrics.MetricOptions
+
+	// lru orders the keys of Cache from most to least recently used
+	lru *list.List
 }
 
 // NewFunctionCache creates a function cache to query function metadata
 func NewFunctionCache(cacheExpiry time.Duration) FunctionCacher {
+	return NewFunctionCacheWithConfig(FunctionCacheConfig{Expiry: cacheExpiry})
+}
+
+// NewFunctionCacheWithConfig creates a bounded function cache
+func NewFunctionCacheWithConfig(config FunctionCacheConfig) *FunctionCache {
 	return &FunctionCache{
-		Cache:  make(map[string]*FunctionMeta),
-		Expiry: cacheExpiry,
+		Cache:          make(map[string]*FunctionMeta),
+		Expiry:         config.Expiry,
+		NotFoundExpiry: config.NotFoundExpiry,
+		MaxEntries:     config.MaxEntries,
+		name:           config.Name,
+		metrics:        config.Metrics,
+		lru:            list.New(),
 	}
 }
 
+// Start removes expired entries on a ticker in a separate goroutine
+func (fc *FunctionCache) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			fc.Sweep(time.Now())
+		}
+	}()
+}
+
 // Set replica count for functionName
 func (fc *FunctionCache) Set(functionName, namespace string, queryRes ServiceQueryResponse) {
+	fc.setAt(functionName, namespace, queryRes, time.Now())
+}
+
+// setAt stores queryRes as refreshed at the given time, unless the entry
+// was refreshed more recently, so that older values from peers do not
+// replace newer ones.
+func (fc *FunctionCache) setAt(functionName, namespace string, queryRes ServiceQueryResponse, refreshed time.Time) {
+	fc.Sync.Lock()
+	defer fc.Sync.Unlock()
+
+	meta := fc.entry(functionName + "." + namespace)
+	if refreshed.Before(meta.LastRefresh) {
+		return
+	}
+
+	meta.LastRefresh = refreshed
+	meta.ServiceQueryResponse = queryRes
+	meta.NotFound = false
+}
+
+// SetNotFound caches that the provider does not have the function, when
+// NotFoundExpiry is set
+func (fc *FunctionCache) SetNotFound(functionName, namespace string) {
+	if fc.NotFoundExpiry <= 0 {
+		return
+	}
+
 	fc.Sync.Lock()
 	defer fc.Sync.Unlock()
 
-	if _, exists := fc.Cache[functionName+"."+namespace]; !exists {
-		fc.Cache[functionName+"."+namespace] = &FunctionMeta{}
+	meta := fc.entry(functionName + "." + namespace)
+	meta.LastRefresh = time.Now()
+	meta.ServiceQueryResponse = ServiceQueryResponse{}
+	meta.NotFound = true
+}
+
+// entry returns the entry for key, creating it and evicting the least
+// recently used entry if needed. It must be called with the write lock held.
+func (fc *FunctionCache) entry(key string) *FunctionMeta {
+	if fc.lru == nil {
+		fc.lru = list.New()
+	}
+
+	if meta, exists := fc.Cache[key]; exists {
+		fc.touch(key, meta)
+		return meta
+	}
+
+	for fc.MaxEntries > 0 && len(fc.Cache) >= fc.MaxEntries && fc.lru.Len() > 0 {
+		fc.remove(fc.lru.Back().Value.(string), evictedSize)
 	}
 
-	fc.Cache[functionName+"."+namespace].LastRefresh = time.Now()
-	fc.Cache[functionName+"."+namespace].ServiceQueryResponse = queryRes
+	meta := &FunctionMeta{}
+	meta.element = fc.lru.PushFront(key)
+	fc.Cache[key] = meta
+	fc.setEntries()
+
+	return meta
+}
+
+// touch marks key as the most recently used, it must be called with the
+// write lock held
+func (fc *FunctionCache) touch(key string, meta *FunctionMeta) {
+	if meta.element == nil {
+		// Entries added to Cache directly are tracked once used
+		meta.element = fc.lru.PushFront(key)
+		return
+	}
+	fc.lru.MoveToFront(meta.element)
+}
+
+// remove must be called with the write lock held
+func (fc *FunctionCache) remove(key, reason string) {
+	meta, exists := fc.Cache[key]
+	if !exists {
+		return
+	}
+
+	if meta.element != nil {
+		fc.lru.Remove(meta.element)
+	}
+	delete(fc.Cache, key)
+
+	if fc.metrics != nil {
+		fc.metrics.FunctionCacheEvictions.WithLabelValues(fc.name, reason).Inc()
+	}
+	fc.setEntries()
+}
+
+func (fc *FunctionCache) setEntries() {
+	if fc.metrics != nil {
+		fc.metrics.FunctionCacheEntries.WithLabelValues(fc.name).Set(float64(len(fc.Cache)))
+	}
 }
 
 // Get replica count for functionName
@@ -49,13 +214,87 @@ func (fc *FunctionCache) Get(functionName, namespace string) (ServiceQueryRespon
 	}
 
 	hit := false
-	fc.Sync.RLock()
-	defer fc.Sync.RUnlock()
+	now := time.Now()
+	key := functionName + "." + namespace
 
-	if val, exists := fc.Cache[functionName+"."+namespace]; exists {
+	fc.Sync.RLock()
+	val, exists := fc.Cache[key]
+	found := exists && !val.NotFound
+	if found {
 		queryRes = val.ServiceQueryResponse
 		hit = !val.Expired(fc.Expiry)
 	}
+	stale := found && fc.needsTouch(val, now)
+	fc.Sync.RUnlock()
+
+	if stale {
+		fc.Sync.Lock()
+		if val, exists := fc.Cache[key]; exists {
+			if fc.lru == nil {
+				fc.lru = list.New()
+			}
+			fc.touch(key, val)
+			val.touched = now
+		}
+		fc.Sync.Unlock()
+	}
+
+	if hit {
+		fc.observe(cacheHit)
+	} else {
+		fc.observe(cacheMiss)
+	}
 
 	return queryRes, hit
 }
+
+// needsTouch is true when a Get should move meta to the front of the LRU,
+// it must be called with the read lock held
+func (fc *FunctionCache) needsTouch(meta *FunctionMeta, now time.Time) bool {
+	if fc.lru == nil || meta.element == nil {
+		return true
+	}
+	return fc.lru.Front() != meta.element && now.Sub(meta.touched) >= lruTouchInterval
+}
+
+// NotFound is true when the function was cached as not found within the
+// NotFoundExpiry
+func (fc *FunctionCache) NotFound(functionName, namespace string) bool {
+	fc.Sync.RLock()
+	defer fc.Sync.RUnlock()
+
+	val, exists := fc.Cache[functionName+"."+namespace]
+	notFound := exists && val.NotFound && !val.Expired(fc.NotFoundExpiry)
+	if notFound {
+		fc.observe(cacheNotFound)
+	}
+	return notFound
+}
+
+func (fc *FunctionCache) observe(result string) {
+	if fc.metrics != nil {
+		fc.metrics.FunctionCacheRequests.WithLabelValues(fc.name, result).Inc()
+	}
+}
+
+// Sweep removes the entries which have expired at now, so that deleted
+// functions do not stay in the cache. It returns the number removed.
+func (fc *FunctionCache) Sweep(now time.Time) int {
+	fc.Sync.Lock()
+	defer fc.Sync.Unlock()
+
+	removed := 0
+	for key, meta := range fc.Cache {
+		expiry := fc.Expiry
+		if meta.NotFound {
+			expiry = fc.NotFoundExpiry
+		}
+
+		if now.After(meta.LastRefresh.Add(expiry)) {
+			fc.remove(key, evictedExpired)
+			removed++
+		}
+	}
+
+	return removed
+}
@@ -4,8 +4,12 @@
 package scaling
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
 )
 
 func Test_LastRefreshSet(t *testing.T) {
@@ -137,3 +141,171 @@ func Test_CacheFunctionNotExist(t *testing.T) {
 		t.Errorf("hit, want: %v, got %v", wantHit, hit)
 	}
 }
+
+func Test_CacheEvictsLeastRecentlyUsed(t *testing.T) {
+	options := metrics.BuildMetricsOptions()
+	cache := NewFunctionCacheWithConfig(FunctionCacheConfig{
+		Name:       "test",
+		Expiry:     time.Minute,
+		MaxEntries: 2,
+		Metrics:    &options,
+	})
+
+	cache.Set("a", "", ServiceQueryResponse{})
+	cache.Set("b", "", ServiceQueryResponse{})
+
+	// a is now used more recently than b
+	cache.Get("a", "")
+	cache.Set("c", "", ServiceQueryResponse{})
+
+	if _, hit := cache.Get("b", ""); hit {
+		t.Fatalf("want b to be evicted")
+	}
+	for _, name := range []string{"a", "c"} {
+		if _, hit := cache.Get(name, ""); !hit {
+			t.Fatalf("want %s to be kept", name)
+		}
+	}
+
+	if got := readValue(options.FunctionCacheEvictions.WithLabelValues("test", evictedSize)); got != 1 {
+		t.Fatalf("size evictions, want: 1, got: %v", got)
+	}
+	if got := readValue(options.FunctionCacheEntries.WithLabelValues("test")); got != 2 {
+		t.Fatalf("entries, want: 2, got: %v", got)
+	}
+	if got := readValue(options.FunctionCacheRequests.WithLabelValues("test", cacheHit)); got != 3 {
+		t.Fatalf("hits, want: 3, got: %v", got)
+	}
+	if got := readValue(options.FunctionCacheRequests.WithLabelValues("test", cacheMiss)); got != 1 {
+		t.Fatalf("misses, want: 1, got: %v", got)
+	}
+}
+
+func Test_CacheSweepRemovesExpired(t *testing.T) {
+	cache := NewFunctionCacheWithConfig(FunctionCacheConfig{
+		Expiry:         time.Second,
+		NotFoundExpiry: time.Minute,
+	})
+
+	cache.Set("deleted", "", ServiceQueryResponse{})
+	cache.SetNotFound("missing", "")
+
+	if removed := cache.Sweep(time.Now().Add(time.Second * 2)); removed != 1 {
+		t.Fatalf("removed, want: 1, got: %d", removed)
+	}
+	if _, exists := cache.Cache["deleted."]; exists {
+		t.Fatalf("want the expired entry to be removed")
+	}
+	if !cache.NotFound("missing", "") {
+		t.Fatalf("want the not found entry to be kept until its own expiry")
+	}
+
+	if removed := cache.Sweep(time.Now().Add(time.Minute * 2)); removed != 1 {
+		t.Fatalf("removed, want: 1, got: %d", removed)
+	}
+	if len(cache.Cache) != 0 || cache.lru.Len() != 0 {
+		t.Fatalf("want an empty cache, got: %d entries", len(cache.Cache))
+	}
+}
+
+func Test_CacheNotFound(t *testing.T) {
+	cache := NewFunctionCacheWithConfig(FunctionCacheConfig{
+		Expiry:         time.Minute,
+		NotFoundExpiry: time.Minute,
+	})
+
+	cache.SetNotFound("echo", "")
+	if _, hit := cache.Get("echo", ""); hit {
+		t.Fatalf("want a miss for a function cached as not found")
+	}
+	if !cache.NotFound("echo", "") {
+		t.Fatalf("want echo to be cached as not found")
+	}
+
+	cache.Set("echo", "", ServiceQueryResponse{AvailableReplicas: 1})
+	if cache.NotFound("echo", "") {
+		t.Fatalf("want echo to be found once it is set")
+	}
+}
+
+func Test_CacheNotFoundDisabled(t *testing.T) {
+	cache := NewFunctionCacheWithConfig(FunctionCacheConfig{Expiry: time.Minute})
+
+	cache.SetNotFound("echo", "")
+	if cache.NotFound("echo", "") || len(cache.Cache) != 0 {
+		t.Fatalf("want nothing cached without a NotFoundExpiry")
+	}
+}
+
+type notFoundQuery struct {
+	gets int
+}
+
+func (q *notFoundQuery) GetReplicas(service, namespace string) (ServiceQueryResponse, error) {
+	q.gets++
+	return ServiceQueryResponse{}, fmt.Errorf("%w: %s", ErrFunctionNotFound, service)
+}
+
+func (q *notFoundQuery) SetReplicas(service, namespace string, count uint64) error {
+	return nil
+}
+
+func Test_CachedFunctionQuery_CachesNotFound(t *testing.T) {
+	query := &notFoundQuery{}
+	cache := NewFunctionCacheWithConfig(FunctionCacheConfig{
+		Expiry:         time.Minute,
+		NotFoundExpiry: time.Minute,
+	})
+	functionQuery := NewCachedFunctionQuery(cache, query)
+
+	for i := 0; i < 3; i++ {
+		if _, err := functionQuery.Get("echo", "openfaas-fn"); !errors.Is(err, ErrFunctionNotFound) {
+			t.Fatalf("want ErrFunctionNotFound, got: %v", err)
+		}
+	}
+
+	if query.gets != 1 {
+		t.Fatalf("GetReplicas calls, want: 1, got: %d", query.gets)
+	}
+}
+
+func Test_CacheGetTouchesAtMostOncePerInterval(t *testing.T) {
+	cache := NewFunctionCacheWithConfig(FunctionCacheConfig{Expiry: time.Minute, MaxEntries: 2})
+
+	cache.Set("a", "", ServiceQueryResponse{})
+	cache.Set("b", "", ServiceQueryResponse{})
+
+	// a is moved to the front by its first Get
+	cache.Get("a", "")
+	if cache.lru.Front().Value.(string) != "a." {
+		t.Fatalf("want a at the front, got: %s", cache.lru.Front().Value)
+	}
+
+	// b was touched recently by Set, but never by Get
+	cache.Get("b", "")
+	if cache.lru.Front().Value.(string) != "b." {
+		t.Fatalf("want b at the front, got: %s", cache.lru.Front().Value)
+	}
+
+	// a was touched by a Get within the interval, so it stays behind b
+	cache.Get("a", "")
+	if cache.lru.Front().Value.(string) != "b." {
+		t.Fatalf("want b to stay at the front, got: %s", cache.lru.Front().Value)
+	}
+}
+
+func BenchmarkFunctionCache_GetParallel(b *testing.B) {
+	cache := NewFunctionCacheWithConfig(FunctionCacheConfig{Expiry: time.Minute, MaxEntries: 100})
+	names := []string{"a", "b", "c", "d"}
+	for _, name := range names {
+		cache.Set(name, "openfaas-fn", ServiceQueryResponse{AvailableReplicas: 1})
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get(names[i%len(names)], "openfaas-fn")
+			i++
+		}
+	})
+}
@@ -4,6 +4,7 @@
 package scaling
 
 import (
+	"container/list"
 	"time"
 )
 
@@ -12,6 +13,15 @@ import (
 type FunctionMeta struct {
 	LastRefresh          time.Time
 	ServiceQueryResponse ServiceQueryResponse
+
+	// NotFound is true when the provider did not have the function
+	NotFound bool
+
+	// element is the entry's position in the cache's LRU list
+	element *list.Element
+
+	// touched is when a Get last moved the entry to the front of the LRU
+	touched time.Time
 }
 
 // Expired find out whether the cache item has expired with
@@ -4,8 +4,8 @@
 package scaling
 
 import (
+	"errors"
 	"fmt"
-	"log"
 
 	"golang.org/x/sync/singleflight"
 )
@@ -42,14 +42,21 @@ func (c *CachedFunctionQuery) Get(fn string, ns string) (ServiceQueryResponse, e
 
 	query, hit := c.cache.Get(fn, ns)
 	if !hit {
+		if c.cache.NotFound(fn, ns) {
+			return ServiceQueryResponse{}, fmt.Errorf("%w: %s.%s", ErrFunctionNotFound, fn, ns)
+		}
+
 		key := fmt.Sprintf("GetReplicas-%s.%s", fn, ns)
 		queryResponse, err, _ := c.singleFlight.Do(key, func() (interface{}, error) {
-			log.Printf("Cache miss - run GetReplicas")
+			logger.Debug("function cache miss", "function", fn, "namespace", ns)
 			// If there is a cache miss, then fetch the value from the provider API
 			return c.serviceQuery.GetReplicas(fn, ns)
 		})
 
 		if err != nil {
+			if errors.Is(err, ErrFunctionNotFound) {
+				c.cache.SetNotFound(fn, ns)
+			}
 			return ServiceQueryResponse{}, err
 		}
 
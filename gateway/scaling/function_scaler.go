@@ -1,10 +1,13 @@
 package scaling
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
 	"time"
 
+	"github.com/openfaas/faas/gateway/pkg/tracing"
 	"github.com/openfaas/faas/gateway/types"
 	"golang.org/x/sync/singleflight"
 )
@@ -12,10 +15,22 @@ import (
 // NewFunctionScaler create a new scaler with the specified
 // ScalingConfig
 func NewFunctionScaler(config ScalingConfig, functionCacher FunctionCacher) FunctionScaler {
+	var flightGroup FlightGroup = &singleflight.Group{}
+	if config.FlightGroup != nil {
+		flightGroup = config.FlightGroup
+	}
+
+	// A single watcher is shared by every request, so that requests held
+	// for the same function share each query of its readiness
+	if config.ReadinessWatcher == nil {
+		config.ReadinessWatcher = NewPollingReadinessWatcher(config.ServiceQuery, config.FunctionPollInterval, flightGroup)
+	}
+
 	return FunctionScaler{
 		Cache:        functionCacher,
 		Config:       config,
-		SingleFlight: &singleflight.Group{},
+		SingleFlight: flightGroup,
+		Queue:        NewHoldingQueue(config.MaxHeldRequests, config.Metrics),
 	}
 }
 
@@ -23,7 +38,10 @@ func NewFunctionScaler(config ScalingConfig, functionCacher FunctionCacher) Func
 type FunctionScaler struct {
 	Cache        FunctionCacher
 	Config       ScalingConfig
-	SingleFlight *singleflight.Group
+	SingleFlight FlightGroup
+
+	// Queue holds requests while a function scales up from zero
+	Queue *HoldingQueue
 }
 
 // FunctionScaleResult holds the result of scaling from zero
@@ -32,11 +50,46 @@ type FunctionScaleResult struct {
 	Error     error
 	Found     bool
 	Duration  time.Duration
+
+	// TimedOut is true when no replica became available before the
+	// cold start deadline
+	TimedOut bool
+
+	// Rejected is true when the holding queue for the function was full
+	Rejected bool
+
+	// Triggered is true when this request scaled the function up from
+	// zero, Waited when it waited for a scale up which was already
+	// requested
+	Triggered bool
+	Waited    bool
 }
 
 // Scale scales a function from zero replicas to 1 or the value set in
 // the minimum replicas metadata
 func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResult {
+	return f.ScaleContext(context.Background(), functionName, namespace)
+}
+
+// ScaleContext is Scale traced as part of the request in ctx
+func (f *FunctionScaler) ScaleContext(ctx context.Context, functionName, namespace string) FunctionScaleResult {
+	ctx, span := tracing.Start(ctx, "scale", tracing.KindInternal)
+	span.SetAttribute("faas.function", functionName)
+	span.SetAttribute("faas.namespace", namespace)
+
+	result := f.scale(ctx, functionName, namespace)
+
+	span.SetAttribute("faas.available", result.Available)
+	span.SetAttribute("faas.found", result.Found)
+	span.SetAttribute("faas.timed_out", result.TimedOut)
+	span.SetAttribute("faas.rejected", result.Rejected)
+	span.SetError(result.Error)
+	span.End()
+
+	return result
+}
+
+func (f *FunctionScaler) scale(ctx context.Context, functionName, namespace string) FunctionScaleResult {
 	start := time.Now()
 
 	// First check the cache, if there are available replicas, then the
@@ -51,14 +104,28 @@ func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResu
 		}
 	}
 
+	// Functions which the provider recently did not have are not queried
+	// again until the entry expires
+	if f.Cache.NotFound(functionName, namespace) {
+		return FunctionScaleResult{
+			Error:     fmt.Errorf("%w: %s.%s", ErrFunctionNotFound, functionName, namespace),
+			Available: false,
+			Found:     false,
+			Duration:  time.Since(start),
+		}
+	}
+
 	// The wasn't a hit, or there were no available replicas found
 	// so query the live endpoint
 	getKey := fmt.Sprintf("GetReplicas-%s.%s", functionName, namespace)
 	res, err, _ := f.SingleFlight.Do(getKey, func() (interface{}, error) {
-		return f.Config.ServiceQuery.GetReplicas(functionName, namespace)
+		return getReplicasContext(ctx, f.Config.ServiceQuery, functionName, namespace)
 	})
 
 	if err != nil {
+		if errors.Is(err, ErrFunctionNotFound) {
+			f.Cache.SetNotFound(functionName, namespace)
+		}
 		return FunctionScaleResult{
 			Error:     err,
 			Available: false,
@@ -89,6 +156,82 @@ func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResu
 	queryResponse := res.(ServiceQueryResponse)
 	f.Cache.Set(functionName, namespace, queryResponse)
 
+	// From here on the request is held until a replica is available, the
+	// deadline passes, or the queue for the function is full.
+	if f.Queue != nil && !f.Queue.Enter(functionName, namespace) {
+		return FunctionScaleResult{
+			Error:     nil,
+			Available: false,
+			Found:     true,
+			Rejected:  true,
+			Duration:  time.Since(start),
+		}
+	}
+
+	result := f.scaleAndWait(ctx, functionName, namespace, queryResponse, start)
+
+	if f.Queue != nil {
+		f.Queue.Leave(functionName, namespace, scaleOutcome(result), result.Duration)
+	}
+
+	return result
+}
+
+// scaleOutcome returns the outcome recorded for a request which was held
+func scaleOutcome(result FunctionScaleResult) string {
+	if result.Error != nil {
+		return HoldError
+	} else if result.TimedOut {
+		return HoldTimeout
+	}
+	return HoldAvailable
+}
+
+// recordColdStart records a scale up from zero once, by the request which
+// triggered it. Only the scale ups which became available are added to the
+// duration, as the others are bounded by the cold start timeout.
+func (f *FunctionScaler) recordColdStart(functionName, namespace string, result FunctionScaleResult) {
+	if f.Config.Metrics == nil {
+		return
+	}
+
+	label := functionLabel(functionName, namespace)
+	f.Config.Metrics.ColdStarts.WithLabelValues(label, scaleOutcome(result)).Inc()
+	if result.Available {
+		f.Config.Metrics.ColdStartDuration.WithLabelValues(label).Observe(result.Duration.Seconds())
+	}
+}
+
+// ColdStartTimeout is how long a request is held for a replica to become
+// available, set by the function's label, then the ScalingConfig, and
+// finally the poll limits.
+func (f *FunctionScaler) ColdStartTimeout(queryResponse ServiceQueryResponse) time.Duration {
+	if queryResponse.ColdStartTimeout > 0 {
+		return queryResponse.ColdStartTimeout
+	}
+	if f.Config.ColdStartTimeout > 0 {
+		return f.Config.ColdStartTimeout
+	}
+	return time.Duration(f.Config.MaxPollCount) * f.Config.FunctionPollInterval
+}
+
+// scaleAndWait requests a scale up when the desired replica count is zero,
+// then waits for at least one replica to become available.
+func (f *FunctionScaler) scaleAndWait(ctx context.Context, functionName, namespace string, queryResponse ServiceQueryResponse, start time.Time) (result FunctionScaleResult) {
+	getKey := fmt.Sprintf("GetReplicas-%s.%s", functionName, namespace)
+	deadline := start.Add(f.ColdStartTimeout(queryResponse))
+
+	// Concurrent requests share a single call to SetReplicas, only the
+	// request which makes it triggered the scale up
+	triggered := false
+	defer func() {
+		result.Triggered = triggered
+		result.Waited = !triggered
+		if triggered {
+			f.recordColdStart(functionName, namespace, result)
+		}
+	}()
+
 	// If the desired replica count is 0, then a scale up event
 	// is required.
 	if queryResponse.Replicas == 0 {
@@ -102,7 +245,7 @@ func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResu
 		scaleResult := types.Retry(func(attempt int) error {
 
 			res, err, _ := f.SingleFlight.Do(getKey, func() (interface{}, error) {
-				return f.Config.ServiceQuery.GetReplicas(functionName, namespace)
+				return getReplicasContext(ctx, f.Config.ServiceQuery, functionName, namespace)
 			})
 
 			if err != nil {
@@ -123,11 +266,26 @@ func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResu
 			setKey := fmt.Sprintf("SetReplicas-%s.%s", functionName, namespace)
 
 			if _, err, _ := f.SingleFlight.Do(setKey, func() (interface{}, error) {
-
-				log.Printf("[Scale %d/%d] function=%s 0 => %d requested",
-					attempt, int(f.Config.SetScaleRetries), functionName, minReplicas)
-
-				if err := f.Config.ServiceQuery.SetReplicas(functionName, namespace, minReplicas); err != nil {
+				triggered = true
+
+				logger.InfoContext(ctx, "scaling from zero",
+					"function", functionName, "namespace", namespace,
+					"attempt", attempt, "attempts", int(f.Config.SetScaleRetries), "target_replicas", minReplicas)
+
+				err := setReplicasContext(ctx, f.Config.ServiceQuery, functionName, namespace, minReplicas)
+				f.Config.History.Record(ScalingEvent{
+					Function:  functionName,
+					Namespace: namespace,
+					Trigger:   TriggerColdStart,
+					Inputs: map[string]string{
+						"attempt": strconv.Itoa(attempt),
+					},
+					OldReplicas: 0,
+					NewReplicas: minReplicas,
+					Error:       errorString(err),
+				})
+
+				if err != nil {
 					return nil, fmt.Errorf("unable to scale function [%s], err: %s", functionName, err)
 				}
 				return nil, nil
@@ -151,47 +309,50 @@ func (f *FunctionScaler) Scale(functionName, namespace string) FunctionScaleResu
 	}
 
 	// Holding pattern for at least one function replica to be available
-	for i := 0; i < int(f.Config.MaxPollCount); i++ {
+	watcher := f.Config.ReadinessWatcher
+	if watcher == nil {
+		watcher = NewPollingReadinessWatcher(f.Config.ServiceQuery, f.Config.FunctionPollInterval, f.SingleFlight)
+	}
 
-		res, err, _ := f.SingleFlight.Do(getKey, func() (interface{}, error) {
-			return f.Config.ServiceQuery.GetReplicas(functionName, namespace)
-		})
-		queryResponse := res.(ServiceQueryResponse)
+	_, span := tracing.Start(ctx, "scale wait for ready", tracing.KindInternal)
+	readyResponse, err := watcher.WaitForReady(functionName, namespace, deadline)
+	totalTime := time.Since(start)
+	if err != ErrNotReady {
+		span.SetError(err)
+	}
+	span.End()
 
-		if err == nil {
-			f.Cache.Set(functionName, namespace, queryResponse)
+	if err == ErrNotReady {
+		return FunctionScaleResult{
+			Error:     nil,
+			Available: false,
+			Found:     true,
+			TimedOut:  true,
+			Duration:  totalTime,
 		}
+	}
 
-		totalTime := time.Since(start)
-
-		if err != nil {
-			return FunctionScaleResult{
-				Error:     err,
-				Available: false,
-				Found:     true,
-				Duration:  totalTime,
-			}
+	if err != nil {
+		return FunctionScaleResult{
+			Error:     err,
+			Available: false,
+			Found:     true,
+			Duration:  totalTime,
 		}
+	}
 
-		if queryResponse.AvailableReplicas > 0 {
-
-			log.Printf("[Ready] function=%s waited for - %.4fs", functionName, totalTime.Seconds())
-
-			return FunctionScaleResult{
-				Error:     nil,
-				Available: true,
-				Found:     true,
-				Duration:  totalTime,
-			}
-		}
+	// Only the replica counts are taken from the watcher, the limits were
+	// already resolved by the ServiceQuery
+	queryResponse.Replicas = readyResponse.Replicas
+	queryResponse.AvailableReplicas = readyResponse.AvailableReplicas
+	f.Cache.Set(functionName, namespace, queryResponse)
 
-		time.Sleep(f.Config.FunctionPollInterval)
-	}
+	logger.InfoContext(ctx, "ready", "function", functionName, "namespace", namespace, "duration_seconds", totalTime.Seconds())
 
 	return FunctionScaleResult{
 		Error:     nil,
 		Available: true,
 		Found:     true,
-		Duration:  time.Since(start),
+		Duration:  totalTime,
 	}
 }
@@ -0,0 +1,161 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func newTestScaler(query ServiceQuery, timeout time.Duration, maxHeld int) FunctionScaler {
+	config := ScalingConfig{
+		MaxPollCount:         uint(1000),
+		SetScaleRetries:      uint(2),
+		FunctionPollInterval: time.Millisecond * 5,
+		CacheExpiry:          time.Millisecond * 1,
+		ServiceQuery:         query,
+		ColdStartTimeout:     timeout,
+		MaxHeldRequests:      maxHeld,
+	}
+	return NewFunctionScaler(config, NewFunctionCache(config.CacheExpiry))
+}
+
+func Test_Scale_AvailableAfterScaleUp(t *testing.T) {
+	query := &fakeServiceQuery{readyOnSet: true, response: ServiceQueryResponse{MinReplicas: 2}}
+	scaler := newTestScaler(query, time.Second, 0)
+
+	res := scaler.Scale("echo", "openfaas-fn")
+
+	if !res.Available {
+		t.Fatalf("want available, got: %+v", res)
+	}
+	if len(query.setCalls) != 1 || query.setCalls[0] != 2 {
+		t.Errorf("want SetReplicas(2), got: %v", query.setCalls)
+	}
+}
+
+func Test_Scale_TimesOutAtDeadline(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{MinReplicas: 1}}
+	scaler := newTestScaler(query, time.Millisecond*50, 0)
+
+	res := scaler.Scale("echo", "openfaas-fn")
+
+	if res.Available {
+		t.Fatalf("want not available when no replica became ready")
+	}
+	if !res.TimedOut {
+		t.Fatalf("want TimedOut, got: %+v", res)
+	}
+	if res.Duration > time.Second {
+		t.Errorf("want the deadline to be respected, waited: %s", res.Duration)
+	}
+}
+
+func Test_Scale_LabelOverridesTimeout(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{MinReplicas: 1, ColdStartTimeout: time.Millisecond * 20}}
+	scaler := newTestScaler(query, time.Minute, 0)
+
+	res := scaler.Scale("echo", "openfaas-fn")
+
+	if !res.TimedOut {
+		t.Fatalf("want TimedOut, got: %+v", res)
+	}
+	if res.Duration > time.Second {
+		t.Errorf("want the label's deadline to be respected, waited: %s", res.Duration)
+	}
+}
+
+func Test_Scale_RejectsWhenQueueFull(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{MinReplicas: 1}}
+	scaler := newTestScaler(query, time.Millisecond*200, 1)
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scaler.Scale("echo", "openfaas-fn")
+	}()
+
+	for i := 0; i < 100 && scaler.Queue.Held("echo", "openfaas-fn") == 0; i++ {
+		time.Sleep(time.Millisecond * 2)
+	}
+
+	res := scaler.Scale("echo", "openfaas-fn")
+	wg.Wait()
+
+	if !res.Rejected {
+		t.Fatalf("want Rejected when the queue is full, got: %+v", res)
+	}
+	if got := scaler.Queue.Held("echo", "openfaas-fn"); got != 0 {
+		t.Errorf("want the queue to be empty, got: %d", got)
+	}
+}
+
+func Test_Scale_RecordsColdStartOfTrigger(t *testing.T) {
+	options := metrics.BuildMetricsOptions()
+	query := &fakeServiceQuery{readyOnSet: true, response: ServiceQueryResponse{MinReplicas: 1}}
+	scaler := newTestScaler(query, time.Second, 0)
+	scaler.Config.Metrics = &options
+
+	res := scaler.Scale("echo", "openfaas-fn")
+
+	if !res.Triggered || res.Waited {
+		t.Fatalf("want Triggered, got: %+v", res)
+	}
+	if got := readValue(options.ColdStarts.WithLabelValues("echo.openfaas-fn", HoldAvailable)); got != 1 {
+		t.Errorf("cold starts, want: 1, got: %v", got)
+	}
+
+	m := &dto.Metric{}
+	options.ColdStartDuration.WithLabelValues("echo.openfaas-fn").(prometheus.Metric).Write(m)
+	if got := m.GetHistogram().GetSampleCount(); got != 1 {
+		t.Errorf("cold start durations, want: 1, got: %d", got)
+	}
+}
+
+func Test_Scale_RecordsColdStartTimeout(t *testing.T) {
+	options := metrics.BuildMetricsOptions()
+	query := &fakeServiceQuery{response: ServiceQueryResponse{MinReplicas: 1}}
+	scaler := newTestScaler(query, time.Millisecond*30, 0)
+	scaler.Config.Metrics = &options
+
+	res := scaler.Scale("echo", "openfaas-fn")
+
+	if !res.Triggered || !res.TimedOut {
+		t.Fatalf("want Triggered and TimedOut, got: %+v", res)
+	}
+	if got := readValue(options.ColdStarts.WithLabelValues("echo.openfaas-fn", HoldTimeout)); got != 1 {
+		t.Errorf("timed out cold starts, want: 1, got: %v", got)
+	}
+
+	m := &dto.Metric{}
+	options.ColdStartDuration.WithLabelValues("echo.openfaas-fn").(prometheus.Metric).Write(m)
+	if got := m.GetHistogram().GetSampleCount(); got != 0 {
+		t.Errorf("want no duration for a timeout, got: %d", got)
+	}
+}
+
+func Test_Scale_WaitsForScaleUpInProgress(t *testing.T) {
+	options := metrics.BuildMetricsOptions()
+	query := &fakeServiceQuery{response: ServiceQueryResponse{MinReplicas: 1, Replicas: 1}}
+	scaler := newTestScaler(query, time.Millisecond*30, 0)
+	scaler.Config.Metrics = &options
+
+	res := scaler.Scale("echo", "openfaas-fn")
+
+	if res.Triggered || !res.Waited {
+		t.Fatalf("want Waited, got: %+v", res)
+	}
+	if len(query.setCalls) != 0 {
+		t.Errorf("want no SetReplicas, got: %v", query.setCalls)
+	}
+	if got := readValue(options.ColdStarts.WithLabelValues("echo.openfaas-fn", HoldTimeout)); got != 0 {
+		t.Errorf("want the cold start to be recorded by its trigger only, got: %v", got)
+	}
+}
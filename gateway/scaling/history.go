@@ -0,0 +1,251 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Triggers recorded for a ScalingEvent
+const (
+	TriggerAlert       = "alert"
+	TriggerColdStart   = "cold-start"
+	TriggerManual      = "manual"
+	TriggerAutoscaler  = "autoscaler"
+	TriggerScaleToZero = "scale-to-zero"
+	TriggerSchedule    = "schedule"
+	TriggerPrewarm     = "prewarm"
+)
+
+// ScalingEvent is a single decision to change, or to hold, the replicas of
+// a function.
+type ScalingEvent struct {
+	Time      time.Time `json:"time"`
+	Function  string    `json:"function"`
+	Namespace string    `json:"namespace"`
+
+	// Trigger is what made the decision, i.e. TriggerAlert
+	Trigger string `json:"trigger"`
+
+	// Inputs are the values the decision was based on
+	Inputs map[string]string `json:"inputs,omitempty"`
+
+	OldReplicas uint64 `json:"oldReplicas"`
+	NewReplicas uint64 `json:"newReplicas"`
+
+	// Error is set when the replicas could not be changed
+	Error string `json:"error,omitempty"`
+}
+
+// ScalingHistory keeps the most recent ScalingEvents in a bounded ring.
+// Events can also be appended to a file, so that they are kept across
+// restarts.
+//
+// A nil *ScalingHistory can be used, and records nothing.
+type ScalingHistory struct {
+	lock   sync.RWMutex
+	events []ScalingEvent
+	next   int
+	full   bool
+
+	path    string
+	file    *os.File
+	written int
+}
+
+// NewScalingHistory creates a ScalingHistory which keeps up to size events.
+// When path is set, events are loaded from and appended to that file.
+func NewScalingHistory(size int, path string) (*ScalingHistory, error) {
+	if size < 1 {
+		size = 1
+	}
+
+	h := &ScalingHistory{
+		events: make([]ScalingEvent, size),
+		path:   path,
+	}
+
+	if len(path) == 0 {
+		return h, nil
+	}
+
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+
+	// Start from a file which only holds the events kept in memory
+	if err := h.compact(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *ScalingHistory) load() error {
+	file, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event ScalingEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// A partly written line is skipped
+			continue
+		}
+		h.add(event)
+	}
+
+	return scanner.Err()
+}
+
+// Record adds an event to the history, Time is set if it is empty
+func (h *ScalingHistory) Record(event ScalingEvent) {
+	if h == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.add(event)
+
+	if h.file == nil {
+		return
+	}
+
+	if h.written >= len(h.events) {
+		if err := h.compact(); err != nil {
+			logger.Error("unable to compact scaling history", "path", h.path, "error", err)
+		}
+		return
+	}
+
+	data, _ := json.Marshal(event)
+	if _, err := h.file.Write(append(data, '\n')); err != nil {
+		logger.Error("unable to write scaling history", "path", h.path, "error", err)
+		return
+	}
+	h.written++
+}
+
+// add must be called with the write lock held
+func (h *ScalingHistory) add(event ScalingEvent) {
+	h.events[h.next] = event
+	h.next = (h.next + 1) % len(h.events)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// compact rewrites the file with the events kept in memory, so that it
+// does not grow without a bound. It must be called with the write lock held.
+func (h *ScalingHistory) compact() error {
+	if h.file != nil {
+		h.file.Close()
+		h.file = nil
+	}
+
+	tmp := h.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(out)
+	for _, event := range h.ordered() {
+		data, _ := json.Marshal(event)
+		writer.Write(append(data, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	out.Close()
+
+	if err := os.Rename(tmp, h.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	h.file = file
+	h.written = 0
+	return nil
+}
+
+// ordered returns the events from oldest to newest, it must be called
+// with the lock held
+func (h *ScalingHistory) ordered() []ScalingEvent {
+	if !h.full {
+		return append([]ScalingEvent{}, h.events[:h.next]...)
+	}
+
+	ordered := make([]ScalingEvent, 0, len(h.events))
+	ordered = append(ordered, h.events[h.next:]...)
+	return append(ordered, h.events[:h.next]...)
+}
+
+// Events returns the events for a function from oldest to newest, or the
+// events for every function when name is empty.
+func (h *ScalingHistory) Events(name, namespace string) []ScalingEvent {
+	if h == nil {
+		return []ScalingEvent{}
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	events := []ScalingEvent{}
+	for _, event := range h.ordered() {
+		if len(name) > 0 && (event.Function != name || event.Namespace != namespace) {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// errorString is the Error of a ScalingEvent for err
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Close closes the file events are appended to
+func (h *ScalingHistory) Close() error {
+	if h == nil {
+		return nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
@@ -0,0 +1,80 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ScalingHistory_KeepsMostRecentEvents(t *testing.T) {
+	history, err := NewScalingHistory(3, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i := uint64(1); i <= 5; i++ {
+		history.Record(ScalingEvent{Function: "echo", Namespace: "openfaas-fn", NewReplicas: i})
+	}
+
+	events := history.Events("echo", "openfaas-fn")
+	if len(events) != 3 {
+		t.Fatalf("events, want: 3, got: %d", len(events))
+	}
+	for i, want := range []uint64{3, 4, 5} {
+		if events[i].NewReplicas != want {
+			t.Fatalf("event %d, want replicas: %d, got: %d", i, want, events[i].NewReplicas)
+		}
+	}
+	if events[0].Time.IsZero() {
+		t.Fatalf("want Time to be set")
+	}
+}
+
+func Test_ScalingHistory_NilRecordsNothing(t *testing.T) {
+	var history *ScalingHistory
+	history.Record(ScalingEvent{Function: "echo"})
+
+	if got := history.Events("", ""); len(got) != 0 {
+		t.Fatalf("events, want: 0, got: %d", len(got))
+	}
+}
+
+func Test_ScalingHistory_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	history, err := NewScalingHistory(2, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// More events than the size, so that the file is compacted
+	for i := uint64(1); i <= 5; i++ {
+		history.Record(ScalingEvent{
+			Time:        time.Unix(int64(i), 0),
+			Function:    "echo",
+			Namespace:   "openfaas-fn",
+			Trigger:     TriggerManual,
+			NewReplicas: i,
+			Error:       fmt.Sprintf("error %d", i),
+		})
+	}
+	history.Close()
+
+	restored, err := NewScalingHistory(2, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer restored.Close()
+
+	events := restored.Events("", "")
+	if len(events) != 2 {
+		t.Fatalf("events, want: 2, got: %d", len(events))
+	}
+	if events[0].NewReplicas != 4 || events[1].NewReplicas != 5 || events[1].Error != "error 5" {
+		t.Fatalf("want the last two events, got: %+v", events)
+	}
+}
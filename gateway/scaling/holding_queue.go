@@ -0,0 +1,97 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+)
+
+// Outcomes recorded for a request leaving the HoldingQueue
+const (
+	HoldAvailable = "available"
+	HoldTimeout   = "timeout"
+	HoldError     = "error"
+	HoldRejected  = "rejected"
+)
+
+// HoldingQueue bounds the number of requests held for each function while
+// it scales up from zero.
+type HoldingQueue struct {
+	// MaxHeld is the maximum number of held requests per function, or 0
+	// for no limit
+	MaxHeld int
+
+	// Metrics records the held requests and their wait time, it can be nil
+	Metrics *metrics.MetricOptions
+
+	lock sync.Mutex
+	held map[string]int
+}
+
+// NewHoldingQueue creates a HoldingQueue which holds up to maxHeld requests per function
+func NewHoldingQueue(maxHeld int, metricOptions *metrics.MetricOptions) *HoldingQueue {
+	return &HoldingQueue{
+		MaxHeld: maxHeld,
+		Metrics: metricOptions,
+		held:    make(map[string]int),
+	}
+}
+
+// Enter adds a request to the queue for a function, false is returned
+// when the queue is full and the request must be rejected.
+func (q *HoldingQueue) Enter(functionName, namespace string) bool {
+	label := functionLabel(functionName, namespace)
+
+	q.lock.Lock()
+	if q.MaxHeld > 0 && q.held[label] >= q.MaxHeld {
+		q.lock.Unlock()
+		q.observe(label, HoldRejected, 0)
+		return false
+	}
+	q.held[label]++
+	q.lock.Unlock()
+
+	if q.Metrics != nil {
+		q.Metrics.ColdStartHeldRequests.WithLabelValues(label).Inc()
+	}
+	return true
+}
+
+// Leave removes a request from the queue and records how long it was held
+func (q *HoldingQueue) Leave(functionName, namespace, outcome string, waited time.Duration) {
+	label := functionLabel(functionName, namespace)
+
+	q.lock.Lock()
+	if q.held[label] > 0 {
+		q.held[label]--
+	}
+	if q.held[label] == 0 {
+		delete(q.held, label)
+	}
+	q.lock.Unlock()
+
+	if q.Metrics != nil {
+		q.Metrics.ColdStartHeldRequests.WithLabelValues(label).Dec()
+	}
+	q.observe(label, outcome, waited)
+}
+
+// Held returns the number of requests currently held for a function
+func (q *HoldingQueue) Held(functionName, namespace string) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.held[functionLabel(functionName, namespace)]
+}
+
+func (q *HoldingQueue) observe(label, outcome string, waited time.Duration) {
+	if q.Metrics == nil {
+		return
+	}
+
+	q.Metrics.ColdStartHoldSeconds.WithLabelValues(label, outcome).Observe(waited.Seconds())
+}
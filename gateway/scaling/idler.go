@@ -0,0 +1,200 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"errors"
+	"time"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/metrics"
+)
+
+// FunctionLister lists the functions deployed to the provider
+type FunctionLister interface {
+	Services() []types.FunctionStatus
+}
+
+// IdlerConfig configures the idle scale-to-zero controller
+type IdlerConfig struct {
+	// Interval between each check for idle functions
+	Interval time.Duration
+
+	// DefaultIdleDuration is used when a function does not set
+	// ScaleToZeroDurationLabel
+	DefaultIdleDuration time.Duration
+
+	// ServiceQuery queries and sets the replicas of a function
+	ServiceQuery ServiceQuery
+
+	// Lister finds functions which have not been invoked since the
+	// gateway started, it can be nil
+	Lister FunctionLister
+
+	// Metrics records each decision to scale to zero
+	Metrics *metrics.MetricOptions
+
+	// History records each scale to zero, it can be nil
+	History *ScalingHistory
+}
+
+// Idler scales functions which have opted in with ScaleToZeroLabel to
+// zero replicas once they have been idle for their configured duration.
+type Idler struct {
+	config  IdlerConfig
+	tracker *InvocationTracker
+
+	// started is used as the last invocation for functions which have
+	// not been invoked since the gateway started
+	started time.Time
+}
+
+// IdleResult is the outcome of checking a single function
+type IdleResult struct {
+	Name      string
+	Namespace string
+	IdleFor   time.Duration
+	Scaled    bool
+	Error     error
+}
+
+// NewIdler creates an Idler which reads the last invocation of each
+// function from tracker.
+func NewIdler(config IdlerConfig, tracker *InvocationTracker) *Idler {
+	return &Idler{
+		config:  config,
+		tracker: tracker,
+		started: time.Now(),
+	}
+}
+
+// Start checks for idle functions on a ticker in a separate goroutine
+func (i *Idler) Start() {
+	ticker := time.NewTicker(i.config.Interval)
+
+	go func() {
+		for range ticker.C {
+			i.Reconcile(time.Now())
+		}
+	}()
+}
+
+// Reconcile scales any idle function to zero
+func (i *Idler) Reconcile(now time.Time) []IdleResult {
+	candidates := map[string][2]string{}
+
+	for _, load := range i.tracker.Snapshot() {
+		candidates[load.Name+"."+load.Namespace] = [2]string{load.Name, load.Namespace}
+	}
+
+	if i.config.Lister != nil {
+		for _, fn := range i.config.Lister.Services() {
+			if fn.Replicas == 0 || fn.Labels == nil || (*fn.Labels)[ScaleToZeroLabel] != "true" {
+				continue
+			}
+			candidates[fn.Name+"."+fn.Namespace] = [2]string{fn.Name, fn.Namespace}
+		}
+	}
+
+	results := []IdleResult{}
+	for _, c := range candidates {
+		if res, checked := i.reconcile(c[0], c[1], now); checked {
+			results = append(results, res)
+		}
+	}
+
+	return results
+}
+
+func (i *Idler) reconcile(name, namespace string, now time.Time) (IdleResult, bool) {
+	result := IdleResult{Name: name, Namespace: namespace}
+
+	lastInvocation := i.started
+	load, invoked := i.tracker.Get(name, namespace)
+	if invoked {
+		if load.InFlight > 0 {
+			return result, false
+		}
+		if load.LastInvocation.After(lastInvocation) {
+			lastInvocation = load.LastInvocation
+		}
+	}
+
+	queryResponse, err := i.config.ServiceQuery.GetReplicas(name, namespace)
+	if err != nil {
+		if errors.Is(err, ErrFunctionNotFound) {
+			i.tracker.Forget(name, namespace)
+		}
+		result.Error = err
+		logger.Error("unable to query replicas to scale to zero", "function", name, "namespace", namespace, "error", err)
+		return result, true
+	}
+
+	if !queryResponse.ScaleToZero || queryResponse.Replicas == 0 {
+		return result, false
+	}
+
+	// An active schedule profile with a minimum takes precedence
+	if queryResponse.ScheduledMinReplicas > 0 {
+		return result, false
+	}
+
+	idleDuration := queryResponse.ScaleToZeroDuration
+	if idleDuration == 0 {
+		idleDuration = i.config.DefaultIdleDuration
+	}
+
+	result.IdleFor = now.Sub(lastInvocation)
+	if result.IdleFor < idleDuration {
+		return result, true
+	}
+
+	// A request may have started while the replicas were being queried
+	if load, ok := i.tracker.Get(name, namespace); ok && (load.InFlight > 0 || load.LastInvocation.After(lastInvocation)) {
+		return result, true
+	}
+
+	logger.Info("scaling to zero",
+		"function", name, "namespace", namespace,
+		"idle_seconds", result.IdleFor.Round(time.Second).Seconds(), "replicas", queryResponse.Replicas)
+
+	outcome := "scaled"
+	if err := i.config.ServiceQuery.SetReplicas(name, namespace, 0); err != nil {
+		outcome = "error"
+		result.Error = err
+		logger.Error("unable to scale to zero", "function", name, "namespace", namespace, "error", err)
+	} else {
+		result.Scaled = true
+	}
+
+	i.config.History.Record(ScalingEvent{
+		Time:      now,
+		Function:  name,
+		Namespace: namespace,
+		Trigger:   TriggerScaleToZero,
+		Inputs: map[string]string{
+			"idleFor":      result.IdleFor.Round(time.Second).String(),
+			"idleDuration": idleDuration.String(),
+		},
+		OldReplicas: queryResponse.Replicas,
+		NewReplicas: 0,
+		Error:       errorString(result.Error),
+	})
+
+	if i.config.Metrics != nil {
+		i.config.Metrics.GatewayFunctionScaleToZero.
+			WithLabelValues(functionLabel(name, namespace), outcome).
+			Inc()
+	}
+
+	return result, true
+}
+
+// functionLabel is the function_name label used in gateway metrics
+func functionLabel(name, namespace string) string {
+	if len(namespace) == 0 {
+		return name
+	}
+	return name + "." + namespace
+}
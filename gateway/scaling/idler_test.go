@@ -0,0 +1,122 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"testing"
+	"time"
+
+	providerTypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/types"
+)
+
+type fakeLister struct {
+	services []providerTypes.FunctionStatus
+}
+
+func (f fakeLister) Services() []providerTypes.FunctionStatus {
+	return f.services
+}
+
+func Test_Idler_ScalesIdleFunctionToZero(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 2, ScaleToZero: true}}
+	metricsOptions := metrics.BuildMetricsOptions()
+
+	idler := NewIdler(IdlerConfig{
+		DefaultIdleDuration: time.Minute,
+		ServiceQuery:        query,
+		Metrics:             &metricsOptions,
+	}, tracker)
+
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+
+	results := idler.Reconcile(time.Now())
+	if len(results) != 1 || results[0].Scaled {
+		t.Fatalf("want function not scaled before the idle duration, got: %+v", results)
+	}
+
+	results = idler.Reconcile(time.Now().Add(time.Minute * 2))
+	if len(results) != 1 || !results[0].Scaled {
+		t.Fatalf("want function scaled after the idle duration, got: %+v", results)
+	}
+	if len(query.setCalls) != 1 || query.setCalls[0] != 0 {
+		t.Errorf("want SetReplicas(0), got: %v", query.setCalls)
+	}
+}
+
+func Test_Idler_RespectsInFlightRequests(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1, ScaleToZero: true}}
+
+	idler := NewIdler(IdlerConfig{DefaultIdleDuration: time.Minute, ServiceQuery: query}, tracker)
+
+	tracker.Notify(types.HTTPNotification{Method: "POST", URL: "/function/echo", OriginalURL: "/function/echo", StatusCode: 102, Event: "started"})
+
+	idler.Reconcile(time.Now().Add(time.Hour))
+	if len(query.setCalls) != 0 {
+		t.Errorf("want no calls to SetReplicas with a request in flight, got: %v", query.setCalls)
+	}
+}
+
+func Test_Idler_SkipsFunctionsWithoutLabel(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1}}
+
+	idler := NewIdler(IdlerConfig{DefaultIdleDuration: time.Minute, ServiceQuery: query}, tracker)
+
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+
+	idler.Reconcile(time.Now().Add(time.Hour))
+	if len(query.setCalls) != 0 {
+		t.Errorf("want no calls to SetReplicas without the label, got: %v", query.setCalls)
+	}
+}
+
+func Test_Idler_PerFunctionDuration(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1, ScaleToZero: true, ScaleToZeroDuration: time.Hour}}
+
+	idler := NewIdler(IdlerConfig{DefaultIdleDuration: time.Minute, ServiceQuery: query}, tracker)
+
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+
+	idler.Reconcile(time.Now().Add(time.Minute * 30))
+	if len(query.setCalls) != 0 {
+		t.Errorf("want no calls to SetReplicas before the label's duration, got: %v", query.setCalls)
+	}
+}
+
+func Test_Idler_FindsFunctionsFromLister(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1, ScaleToZero: true}}
+
+	idler := NewIdler(IdlerConfig{
+		DefaultIdleDuration: time.Minute,
+		ServiceQuery:        query,
+		Lister: fakeLister{services: []providerTypes.FunctionStatus{
+			{Name: "nodeinfo", Namespace: "openfaas-fn", Replicas: 1, Labels: &map[string]string{ScaleToZeroLabel: "true"}},
+		}},
+	}, tracker)
+
+	results := idler.Reconcile(time.Now().Add(time.Hour))
+	if len(results) != 1 || !results[0].Scaled {
+		t.Fatalf("want function from lister scaled to zero, got: %+v", results)
+	}
+}
+
+func Test_Idler_RespectsScheduledMinReplicas(t *testing.T) {
+	tracker := NewInvocationTracker("openfaas-fn")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 5, ScaleToZero: true, ScheduledMinReplicas: 5}}
+
+	idler := NewIdler(IdlerConfig{DefaultIdleDuration: time.Minute, ServiceQuery: query}, tracker)
+
+	invoke(tracker, "/function/echo", 1, time.Millisecond)
+
+	idler.Reconcile(time.Now().Add(time.Hour))
+	if len(query.setCalls) != 0 {
+		t.Errorf("want no calls to SetReplicas during a scheduled minimum, got: %v", query.setCalls)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/types"
+)
+
+// FunctionLoad is a point-in-time view of the invocations of a function
+// as seen by this gateway.
+type FunctionLoad struct {
+	Name      string
+	Namespace string
+
+	// InFlight is the number of requests started, but not yet completed
+	InFlight int64
+
+	// Completed is the total number of completed requests
+	Completed uint64
+
+	// TotalDuration is the sum of the durations of all completed requests
+	TotalDuration time.Duration
+
+	// LastInvocation is when a request was last started or completed
+	LastInvocation time.Time
+}
+
+// FunctionChecker reports whether a function is deployed, serviceName is
+// in the form name.namespace
+type FunctionChecker interface {
+	FunctionExists(serviceName string) bool
+}
+
+// InvocationTracker records the load of each function from the gateway's
+// own proxy events. It implements the same Notify method as the
+// handlers.HTTPNotifier, so that it can be added to a list of notifiers.
+type InvocationTracker struct {
+	// Functions filters out invocations of functions which are not
+	// deployed, so that any path under /function/ does not add an entry.
+	// It can be nil.
+	Functions FunctionChecker
+
+	defaultNamespace string
+
+	lock      sync.RWMutex
+	functions map[string]*FunctionLoad
+
+	// idle holds the last invocation of functions pruned from functions,
+	// so that the idle time of a function is still known
+	idle map[string]time.Time
+}
+
+// NewInvocationTracker creates an InvocationTracker, functions without a
+// namespace in their URL are recorded in defaultNamespace.
+func NewInvocationTracker(defaultNamespace string) *InvocationTracker {
+	return &InvocationTracker{
+		defaultNamespace: defaultNamespace,
+		functions:        make(map[string]*FunctionLoad),
+		idle:             make(map[string]time.Time),
+	}
+}
+
+// Notify records a "started" or "completed" event for the function in originalURL
+func (t *InvocationTracker) Notify(n types.HTTPNotification) {
+	serviceName := middleware.GetServiceName(n.OriginalURL)
+	if len(serviceName) == 0 {
+		return
+	}
+
+	name, namespace := middleware.GetNamespace(t.defaultNamespace, serviceName)
+	if !t.exists(name, namespace) {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	load := t.get(name, namespace)
+	load.LastInvocation = time.Now()
+
+	switch n.Event {
+	case "started":
+		load.InFlight++
+	case "completed":
+		if load.InFlight > 0 {
+			load.InFlight--
+		}
+		load.Completed++
+		load.TotalDuration += n.Duration
+	}
+}
+
+// Touch records activity for a function without an invocation, so that a
+// function scaled up ahead of its traffic is not considered idle.
+func (t *InvocationTracker) Touch(name, namespace string, at time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	load := t.get(name, namespace)
+	if at.After(load.LastInvocation) {
+		load.LastInvocation = at
+	}
+}
+
+// TouchNotifier records each event as activity of its function in Tracker,
+// without counting it as an invocation. It is used for asynchronous
+// requests, whose enqueue is not an invocation of the function, so that
+// the function is not scaled to zero while requests are being queued for it.
+type TouchNotifier struct {
+	Tracker *InvocationTracker
+}
+
+// Notify touches the function in originalURL
+func (n TouchNotifier) Notify(notification types.HTTPNotification) {
+	serviceName := middleware.GetServiceName(notification.OriginalURL)
+	if len(serviceName) == 0 {
+		return
+	}
+
+	name, namespace := middleware.GetNamespace(n.Tracker.defaultNamespace, serviceName)
+	if !n.Tracker.exists(name, namespace) {
+		return
+	}
+	n.Tracker.Touch(name, namespace, time.Now())
+}
+
+func (t *InvocationTracker) exists(name, namespace string) bool {
+	return t.Functions == nil || t.Functions.FunctionExists(functionLabel(name, namespace))
+}
+
+// get must be called with the write lock held
+func (t *InvocationTracker) get(name, namespace string) *FunctionLoad {
+	key := name + "." + namespace
+	load, ok := t.functions[key]
+	if !ok {
+		load = &FunctionLoad{Name: name, Namespace: namespace, LastInvocation: t.idle[key]}
+		t.functions[key] = load
+		delete(t.idle, key)
+	}
+	return load
+}
+
+// Get returns the load for a function, false is returned if the function
+// has not been invoked through this gateway. A pruned function only has
+// its LastInvocation.
+func (t *InvocationTracker) Get(name, namespace string) (FunctionLoad, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	key := name + "." + namespace
+	if load, ok := t.functions[key]; ok {
+		return *load, true
+	}
+	if last, ok := t.idle[key]; ok {
+		return FunctionLoad{Name: name, Namespace: namespace, LastInvocation: last}, true
+	}
+	return FunctionLoad{}, false
+}
+
+// Forget removes a function, such as one which the provider no longer has
+func (t *InvocationTracker) Forget(name, namespace string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := name + "." + namespace
+	delete(t.functions, key)
+	delete(t.idle, key)
+}
+
+// Start prunes the tracker on a ticker in a separate goroutine, it runs
+// whether or not the autoscaler or idler are enabled, as every proxied
+// request is tracked
+func (t *InvocationTracker) Start(interval, idleFor time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			t.Prune(idleFor, time.Now())
+		}
+	}()
+}
+
+// Prune removes the functions which are no longer deployed, and moves
+// functions without a request for idleFor out of the Snapshot, keeping
+// only their last invocation
+func (t *InvocationTracker) Prune(idleFor time.Duration, now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for key, load := range t.functions {
+		if !t.exists(load.Name, load.Namespace) {
+			delete(t.functions, key)
+			continue
+		}
+		if load.InFlight == 0 && now.Sub(load.LastInvocation) > idleFor {
+			t.idle[key] = load.LastInvocation
+			delete(t.functions, key)
+		}
+	}
+
+	for key := range t.idle {
+		name, namespace := middleware.GetNamespace(t.defaultNamespace, key)
+		if !t.exists(name, namespace) {
+			delete(t.idle, key)
+		}
+	}
+}
+
+// Snapshot returns a copy of the load of every function which has been
+// invoked through this gateway, and has not been pruned.
+func (t *InvocationTracker) Snapshot() []FunctionLoad {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	loads := make([]FunctionLoad, 0, len(t.functions))
+	for _, load := range t.functions {
+		loads = append(loads, *load)
+	}
+	return loads
+}
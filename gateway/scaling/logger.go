@@ -0,0 +1,10 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"github.com/openfaas/faas/gateway/pkg/logging"
+)
+
+var logger = logging.For(logging.ComponentScaling)
@@ -0,0 +1,516 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"golang.org/x/sync/singleflight"
+)
+
+// Paths served by Peer for the other gateway replicas
+const (
+	PeerCachePath   = "/system/peer/cache"
+	PeerFlightPath  = "/system/peer/flight"
+	PeerReleasePath = "/system/peer/release"
+)
+
+// PeerTokenHeader carries the token shared by the gateway replicas
+const PeerTokenHeader = "X-Peer-Token"
+
+// maxPeerBody bounds the body of a request from a peer
+const maxPeerBody = 4 * 1024 * 1024
+
+// FlightGroup runs a single call for each key at a time, and shares its
+// result with any caller for the same key. *singleflight.Group is the
+// FlightGroup for a single gateway, and Peer for several replicas.
+type FlightGroup interface {
+	Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool)
+}
+
+// PeerConfig configures a Peer
+type PeerConfig struct {
+	// Address of this gateway as seen by its peers i.e. "10.0.0.1:8080"
+	Address string
+
+	// Peers lists the gateway replicas
+	Peers PeerLister
+
+	// Token is shared by every replica, and is required on each request
+	// from a peer. Requests are refused when it is empty.
+	Token string
+
+	// AuthInjector authenticates requests to peers, it can be nil
+	AuthInjector middleware.AuthInjector
+
+	// GossipInterval is how often cache entries are sent to peers
+	GossipInterval time.Duration
+
+	// LeaseTimeout is how long a peer may hold a call before it is run
+	// again by another peer
+	LeaseTimeout time.Duration
+
+	// WaitTimeout is how long a call waits for a peer's result before it
+	// is run locally
+	WaitTimeout time.Duration
+}
+
+// Peer shares a FunctionCache and the calls in progress for each function
+// with the other gateway replicas over HTTP, so that a cold start only
+// queries and scales a function from one replica.
+//
+// Each key of a call is owned by one replica, chosen by rendezvous hashing
+// over the address of every replica. A replica leases the key from its
+// owner before running the call, and the result is released to the owner
+// for the replicas waiting on it. A call is run locally when the owner can
+// not be reached, so that a replica is never blocked by its peers.
+//
+// Peer implements both FunctionCacher and FlightGroup.
+type Peer struct {
+	config PeerConfig
+	cache  *FunctionCache
+	client *http.Client
+	local  singleflight.Group
+
+	updates chan peerCacheEntry
+
+	lock    sync.Mutex
+	flights map[string]*peerFlight
+}
+
+// peerCacheEntry is a cache entry sent to peers, Age is used rather than
+// the time of the refresh so that clocks do not need to be in sync
+type peerCacheEntry struct {
+	Name      string               `json:"name"`
+	Namespace string               `json:"namespace"`
+	Response  ServiceQueryResponse `json:"response"`
+	Age       time.Duration        `json:"age"`
+
+	refreshed time.Time
+}
+
+// peerFlight is a call leased by a replica
+type peerFlight struct {
+	holder  string
+	expires time.Time
+	done    chan struct{}
+	result  flightResult
+}
+
+// flightResult is the result of a call released to the owner, only
+// ServiceQueryResponse values and nil can be shared
+type flightResult struct {
+	Shared   bool                  `json:"shared"`
+	Response *ServiceQueryResponse `json:"response,omitempty"`
+	Error    string                `json:"error,omitempty"`
+	NotFound bool                  `json:"notFound,omitempty"`
+}
+
+// flightResponse is the owner's answer to a request for a lease
+type flightResponse struct {
+	Acquired bool         `json:"acquired"`
+	Done     bool         `json:"done"`
+	Result   flightResult `json:"result"`
+}
+
+// NewPeer creates a Peer which shares cache with its peers
+func NewPeer(config PeerConfig, cache *FunctionCache) *Peer {
+	return &Peer{
+		config: config,
+		cache:  cache,
+		client: &http.Client{
+			// Requests for a lease wait for up to the WaitTimeout
+			Timeout: config.WaitTimeout + time.Second*2,
+		},
+		updates: make(chan peerCacheEntry, 1024),
+		flights: make(map[string]*peerFlight),
+	}
+}
+
+// Start sends cache entries to peers on a ticker in a separate goroutine
+func (p *Peer) Start() {
+	ticker := time.NewTicker(p.config.GossipInterval)
+
+	go func() {
+		pending := map[string]peerCacheEntry{}
+		for {
+			select {
+			case entry := <-p.updates:
+				pending[functionLabel(entry.Name, entry.Namespace)] = entry
+			case <-ticker.C:
+				if len(pending) == 0 {
+					continue
+				}
+				p.gossip(pending)
+				pending = map[string]peerCacheEntry{}
+			}
+		}
+	}()
+}
+
+// Set stores the response locally and queues it to be sent to peers
+func (p *Peer) Set(functionName, namespace string, serviceQueryResponse ServiceQueryResponse) {
+	now := time.Now()
+	p.cache.setAt(functionName, namespace, serviceQueryResponse, now)
+
+	entry := peerCacheEntry{Name: functionName, Namespace: namespace, Response: serviceQueryResponse, refreshed: now}
+	select {
+	case p.updates <- entry:
+	default:
+		// Peers refresh the entry themselves when the queue is full
+	}
+}
+
+// Get returns the response from the local cache, which includes entries
+// sent by peers
+func (p *Peer) Get(functionName, namespace string) (ServiceQueryResponse, bool) {
+	return p.cache.Get(functionName, namespace)
+}
+
+// SetNotFound caches locally that the provider does not have the function,
+// peers find out from the provider themselves
+func (p *Peer) SetNotFound(functionName, namespace string) {
+	p.cache.SetNotFound(functionName, namespace)
+}
+
+// NotFound is true when the function was cached locally as not found
+func (p *Peer) NotFound(functionName, namespace string) bool {
+	return p.cache.NotFound(functionName, namespace)
+}
+
+func (p *Peer) gossip(pending map[string]peerCacheEntry) {
+	now := time.Now()
+	entries := make([]peerCacheEntry, 0, len(pending))
+	for _, entry := range pending {
+		entry.Age = now.Sub(entry.refreshed)
+		entries = append(entries, entry)
+	}
+
+	body, _ := json.Marshal(entries)
+
+	var wg sync.WaitGroup
+	for _, peer := range p.peers() {
+		if peer == p.config.Address {
+			continue
+		}
+
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+
+			res, err := p.post(peer, PeerCachePath, nil, body)
+			if err != nil {
+				logger.Warn("unable to send cache to peer", "peer", peer, "error", err)
+				return
+			}
+			res.Body.Close()
+		}(peer)
+	}
+	wg.Wait()
+}
+
+// Do runs fn once for key across every replica. The result of a call run
+// by another replica is shared when it is a ServiceQueryResponse or nil.
+func (p *Peer) Do(key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	return p.local.Do(key, func() (interface{}, error) {
+		return p.do(key, fn)
+	})
+}
+
+func (p *Peer) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	owner := p.owner(key)
+
+	if owner == p.config.Address {
+		acquired, flight := p.acquire(key, p.config.Address, time.Now())
+		if !acquired {
+			if result, ok := p.wait(flight, p.config.WaitTimeout); ok && result.Shared {
+				return result.decode()
+			}
+			return fn()
+		}
+
+		v, err := fn()
+		p.release(key, encodeFlightResult(v, err))
+		return v, err
+	}
+
+	response, err := p.requestLease(owner, key)
+	if err != nil {
+		logger.Warn("unable to lease from peer, running locally", "key", key, "peer", owner, "error", err)
+		return fn()
+	}
+
+	if response.Done && response.Result.Shared {
+		return response.Result.decode()
+	}
+	if !response.Acquired {
+		// The peer holding the lease did not finish within the WaitTimeout
+		return fn()
+	}
+
+	v, err := fn()
+	if releaseErr := p.releaseLease(owner, key, encodeFlightResult(v, err)); releaseErr != nil {
+		logger.Warn("unable to release to peer", "key", key, "peer", owner, "error", releaseErr)
+	}
+	return v, err
+}
+
+// owner chooses the replica which owns key by rendezvous hashing
+func (p *Peer) owner(key string) string {
+	owner := p.config.Address
+	highest := peerScore(p.config.Address, key)
+
+	for _, peer := range p.peers() {
+		if score := peerScore(peer, key); score > highest || (score == highest && peer < owner) {
+			owner, highest = peer, score
+		}
+	}
+	return owner
+}
+
+func peerScore(peer, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(peer))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+func (p *Peer) peers() []string {
+	if p.config.Peers == nil {
+		return nil
+	}
+	return p.config.Peers.Peers()
+}
+
+// acquire leases key to holder, or returns the flight already in progress
+func (p *Peer) acquire(key, holder string, now time.Time) (bool, *peerFlight) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if flight, ok := p.flights[key]; ok && now.Before(flight.expires) {
+		return false, flight
+	}
+
+	flight := &peerFlight{
+		holder:  holder,
+		expires: now.Add(p.config.LeaseTimeout),
+		done:    make(chan struct{}),
+	}
+	p.flights[key] = flight
+	return true, flight
+}
+
+func (p *Peer) wait(flight *peerFlight, timeout time.Duration) (flightResult, bool) {
+	select {
+	case <-flight.done:
+		return flight.result, true
+	case <-time.After(timeout):
+		return flightResult{}, false
+	}
+}
+
+// release completes the flight for key and wakes its waiters
+func (p *Peer) release(key string, result flightResult) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	flight, ok := p.flights[key]
+	if !ok {
+		return
+	}
+
+	flight.result = result
+	close(flight.done)
+	delete(p.flights, key)
+}
+
+func (p *Peer) requestLease(owner, key string) (flightResponse, error) {
+	query := url.Values{}
+	query.Set("key", key)
+	query.Set("holder", p.config.Address)
+
+	res, err := p.post(owner, PeerFlightPath, query, nil)
+	if err != nil {
+		return flightResponse{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return flightResponse{}, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	response := flightResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return flightResponse{}, err
+	}
+	return response, nil
+}
+
+func (p *Peer) releaseLease(owner, key string, result flightResult) error {
+	query := url.Values{}
+	query.Set("key", key)
+
+	body, _ := json.Marshal(result)
+	res, err := p.post(owner, PeerReleasePath, query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (p *Peer) post(peer, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := url.URL{Scheme: "http", Host: peer, Path: path, RawQuery: query.Encode()}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(PeerTokenHeader, p.config.Token)
+
+	if p.config.AuthInjector != nil {
+		p.config.AuthInjector.Inject(req)
+	}
+
+	return p.client.Do(req)
+}
+
+func encodeFlightResult(v interface{}, err error) flightResult {
+	result := flightResult{}
+	if err != nil {
+		result.Error = err.Error()
+		result.NotFound = errors.Is(err, ErrFunctionNotFound)
+	}
+
+	switch value := v.(type) {
+	case nil:
+		result.Shared = true
+	case ServiceQueryResponse:
+		result.Shared = true
+		result.Response = &value
+	}
+
+	return result
+}
+
+func (r flightResult) decode() (interface{}, error) {
+	var err error
+	if r.NotFound {
+		err = fmt.Errorf("%w: %s", ErrFunctionNotFound, r.Error)
+	} else if len(r.Error) > 0 {
+		err = errors.New(r.Error)
+	}
+
+	if r.Response == nil {
+		return nil, err
+	}
+	return *r.Response, err
+}
+
+// ServeHTTP handles the requests of peers for the PeerCachePath,
+// PeerFlightPath and PeerReleasePath, which must carry the shared Token
+func (p *Peer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !p.authorized(r) {
+		http.Error(w, "Invalid peer token", http.StatusUnauthorized)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPeerBody)
+
+	switch r.URL.Path {
+	case PeerCachePath:
+		p.serveCache(w, r)
+	case PeerFlightPath:
+		p.serveFlight(w, r)
+	case PeerReleasePath:
+		p.serveRelease(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorized is true when the request carries the shared token, requests
+// are never authorized without one
+func (p *Peer) authorized(r *http.Request) bool {
+	token := r.Header.Get(PeerTokenHeader)
+	if len(p.config.Token) == 0 || len(token) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(p.config.Token)) == 1
+}
+
+func (p *Peer) serveCache(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	entries := []peerCacheEntry{}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		http.Error(w, "Unable to parse cache entries", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		p.cache.setAt(entry.Name, entry.Namespace, entry.Response, now.Add(-entry.Age))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p *Peer) serveFlight(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	holder := r.URL.Query().Get("holder")
+	if len(key) == 0 || len(holder) == 0 {
+		http.Error(w, "key and holder are required", http.StatusBadRequest)
+		return
+	}
+
+	response := flightResponse{}
+
+	acquired, flight := p.acquire(key, holder, time.Now())
+	if acquired {
+		response.Acquired = true
+	} else {
+		response.Result, response.Done = p.wait(flight, p.config.WaitTimeout)
+	}
+
+	out, _ := json.Marshal(response)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
+
+func (p *Peer) serveRelease(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	body, _ := io.ReadAll(r.Body)
+
+	result := flightResult{}
+	if err := json.Unmarshal(body, &result); err != nil || len(key) == 0 {
+		http.Error(w, "Unable to parse result", http.StatusBadRequest)
+		return
+	}
+
+	p.release(key, result)
+	w.WriteHeader(http.StatusOK)
+}
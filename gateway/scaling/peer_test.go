@@ -0,0 +1,233 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startPeers starts n in-process gateways which know about each other
+func startPeers(t *testing.T, n int) []*Peer {
+	servers := make([]*httptest.Server, n)
+	addresses := StaticPeers{}
+	for i := range servers {
+		servers[i] = httptest.NewUnstartedServer(nil)
+		addresses = append(addresses, servers[i].Listener.Addr().String())
+	}
+
+	peers := make([]*Peer, n)
+	for i, server := range servers {
+		peers[i] = NewPeer(PeerConfig{
+			Address:        addresses[i],
+			Peers:          addresses,
+			Token:          "secret",
+			GossipInterval: time.Millisecond * 10,
+			LeaseTimeout:   time.Second * 5,
+			WaitTimeout:    time.Second * 2,
+		}, NewFunctionCacheWithConfig(FunctionCacheConfig{Expiry: time.Minute}))
+		peers[i].Start()
+
+		server.Config.Handler = peers[i]
+		server.Start()
+		t.Cleanup(server.Close)
+	}
+
+	return peers
+}
+
+func Test_Peer_SharesCacheEntries(t *testing.T) {
+	peers := startPeers(t, 3)
+
+	peers[0].Set("echo", "openfaas-fn", ServiceQueryResponse{Replicas: 2, AvailableReplicas: 1})
+
+	deadline := time.Now().Add(time.Second * 2)
+	for _, peer := range peers[1:] {
+		for {
+			res, hit := peer.Get("echo", "openfaas-fn")
+			if hit && res.AvailableReplicas == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("want the entry on every peer, got: %+v, hit: %v", res, hit)
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+}
+
+func Test_Peer_RefusesRequestsWithoutToken(t *testing.T) {
+	cases := []struct {
+		name       string
+		configured string
+		token      string
+		want       int
+	}{
+		{name: "no token sent", configured: "secret", token: "", want: http.StatusUnauthorized},
+		{name: "wrong token", configured: "secret", token: "guess", want: http.StatusUnauthorized},
+		{name: "no token configured", configured: "", token: "", want: http.StatusUnauthorized},
+		{name: "shared token", configured: "secret", token: "secret", want: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			peer := NewPeer(PeerConfig{Token: tc.configured}, NewFunctionCacheWithConfig(FunctionCacheConfig{Expiry: time.Minute}))
+
+			body := []byte(`[{"name":"echo","namespace":"openfaas-fn","response":{"availableReplicas":1}}]`)
+			req := httptest.NewRequest(http.MethodPost, PeerCachePath, bytes.NewReader(body))
+			if len(tc.token) > 0 {
+				req.Header.Set(PeerTokenHeader, tc.token)
+			}
+			rr := httptest.NewRecorder()
+			peer.ServeHTTP(rr, req)
+
+			if rr.Code != tc.want {
+				t.Fatalf("status code, want: %d, got: %d", tc.want, rr.Code)
+			}
+			if _, cached := peer.Get("echo", "openfaas-fn"); cached != (tc.want == http.StatusOK) {
+				t.Fatalf("cached, want: %v, got: %v", tc.want == http.StatusOK, cached)
+			}
+		})
+	}
+}
+
+func Test_Peer_OlderEntryDoesNotReplaceNewer(t *testing.T) {
+	peer := NewPeer(PeerConfig{}, NewFunctionCacheWithConfig(FunctionCacheConfig{Expiry: time.Minute}))
+
+	peer.Set("echo", "openfaas-fn", ServiceQueryResponse{Replicas: 2})
+	peer.cache.setAt("echo", "openfaas-fn", ServiceQueryResponse{Replicas: 1}, time.Now().Add(-time.Second))
+
+	if res, _ := peer.Get("echo", "openfaas-fn"); res.Replicas != 2 {
+		t.Fatalf("replicas, want: 2, got: %d", res.Replicas)
+	}
+}
+
+func Test_Peer_RunsCallOnceAcrossPeers(t *testing.T) {
+	peers := startPeers(t, 3)
+
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond * 200)
+		return ServiceQueryResponse{Replicas: 3}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, len(peers)*2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = peers[i%len(peers)].Do("GetReplicas-echo.openfaas-fn", fn)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("calls, want: 1, got: %d", got)
+	}
+	for i, result := range results {
+		if res, ok := result.(ServiceQueryResponse); !ok || res.Replicas != 3 {
+			t.Fatalf("result %d, want 3 replicas, got: %#v", i, result)
+		}
+	}
+}
+
+func Test_Peer_SharesNotFound(t *testing.T) {
+	result := encodeFlightResult(nil, fmt.Errorf("%w: echo", ErrFunctionNotFound))
+
+	v, err := result.decode()
+	if v != nil || !errors.Is(err, ErrFunctionNotFound) {
+		t.Fatalf("want ErrFunctionNotFound without a value, got: %v, %v", v, err)
+	}
+}
+
+func Test_Peer_RunsLocallyWhenOwnerUnreachable(t *testing.T) {
+	dead := httptest.NewServer(nil)
+	deadAddress := dead.Listener.Addr().String()
+	dead.Close()
+
+	peer := NewPeer(PeerConfig{
+		Address:      "127.0.0.1:1",
+		Peers:        StaticPeers{deadAddress},
+		LeaseTimeout: time.Second,
+		WaitTimeout:  time.Second,
+	}, NewFunctionCacheWithConfig(FunctionCacheConfig{Expiry: time.Minute}))
+
+	key := ""
+	for i := 0; len(key) == 0; i++ {
+		if candidate := fmt.Sprintf("GetReplicas-fn%d.openfaas-fn", i); peer.owner(candidate) == deadAddress {
+			key = candidate
+		}
+	}
+
+	v, err, _ := peer.Do(key, func() (interface{}, error) {
+		return ServiceQueryResponse{Replicas: 1}, nil
+	})
+	if err != nil || v.(ServiceQueryResponse).Replicas != 1 {
+		t.Fatalf("want the local result, got: %v, %v", v, err)
+	}
+}
+
+func Test_DNSPeers_ResolvesAddresses(t *testing.T) {
+	peers, err := NewDNSPeers("gateway-peers.openfaas:8080", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lookups := 0
+	peers.lookup = func(host string) ([]string, error) {
+		lookups++
+		return []string{"10.0.0.2", "10.0.0.1"}, nil
+	}
+
+	got := peers.Peers()
+	peers.Peers()
+
+	if len(got) != 2 || got[0] != "10.0.0.1:8080" || got[1] != "10.0.0.2:8080" {
+		t.Fatalf("want sorted addresses with the port, got: %v", got)
+	}
+	if lookups != 1 {
+		t.Fatalf("lookups within the interval, want: 1, got: %d", lookups)
+	}
+}
+
+func Test_Peer_ScalesFromZeroOnceAcrossGateways(t *testing.T) {
+	peers := startPeers(t, 3)
+	query := &fakeServiceQuery{readyOnSet: true, response: ServiceQueryResponse{MinReplicas: 1}}
+
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		scaler := NewFunctionScaler(ScalingConfig{
+			MaxPollCount:         uint(1000),
+			SetScaleRetries:      uint(2),
+			FunctionPollInterval: time.Millisecond * 5,
+			ServiceQuery:         query,
+			ColdStartTimeout:     time.Second * 2,
+			FlightGroup:          peer,
+		}, peer)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := scaler.Scale("echo", "openfaas-fn"); !res.Available {
+				t.Errorf("want available, got: %+v", res)
+			}
+		}()
+	}
+	wg.Wait()
+
+	query.lock.Lock()
+	defer query.lock.Unlock()
+	if len(query.setCalls) != 1 {
+		t.Fatalf("setCalls, want: 1, got: %v", query.setCalls)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PeerLister lists the addresses of the gateway replicas i.e. "10.0.0.1:8080",
+// the list may include the gateway's own address
+type PeerLister interface {
+	Peers() []string
+}
+
+// StaticPeers is a fixed list of peer addresses
+type StaticPeers []string
+
+// Peers returns the static list of peers
+func (s StaticPeers) Peers() []string {
+	return s
+}
+
+// DNSPeers finds peers from the addresses a host name resolves to, such
+// as a headless Kubernetes service. Addresses are resolved again once
+// they are older than Interval.
+type DNSPeers struct {
+	Host     string
+	Port     string
+	Interval time.Duration
+
+	// lookup resolves Host, it is replaced in tests
+	lookup func(host string) ([]string, error)
+
+	lock     sync.Mutex
+	peers    []string
+	resolved time.Time
+}
+
+// NewDNSPeers creates DNSPeers for an address of the form "host:port"
+func NewDNSPeers(address string, interval time.Duration) (*DNSPeers, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DNSPeers{
+		Host:     host,
+		Port:     port,
+		Interval: interval,
+		lookup:   net.LookupHost,
+	}, nil
+}
+
+// Peers returns the resolved addresses, the last addresses are kept when
+// the host can not be resolved
+func (d *DNSPeers) Peers() []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !d.resolved.IsZero() && time.Since(d.resolved) < d.Interval {
+		return d.peers
+	}
+	d.resolved = time.Now()
+
+	addresses, err := d.lookup(d.Host)
+	if err != nil {
+		logger.Warn("unable to resolve peers", "host", d.Host, "error", err)
+		return d.peers
+	}
+
+	peers := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		peers = append(peers, net.JoinHostPort(address, d.Port))
+	}
+	sort.Strings(peers)
+
+	if strings.Join(peers, ",") != strings.Join(d.peers, ",") {
+		logger.Info("peers changed", "peers", peers)
+	}
+	d.peers = peers
+
+	return d.peers
+}
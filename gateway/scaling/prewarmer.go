@@ -0,0 +1,461 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hoursPerWeek is the number of seasonal buckets kept for each function
+const hoursPerWeek = 7 * 24
+
+// seasonalSmoothing is the weight given to the latest week in each bucket
+const seasonalSmoothing = 0.5
+
+// Outcomes recorded for pre-warm decisions
+const (
+	PrewarmScaled = "scaled"
+	PrewarmError  = "error"
+	PrewarmHit    = "hit"
+	PrewarmWasted = "wasted"
+)
+
+// PrewarmerConfig configures the predictive pre-warmer
+type PrewarmerConfig struct {
+	// Interval between each evaluation of the forecasts
+	Interval time.Duration
+
+	// Lead is how far ahead of the forecast traffic a function is scaled up
+	Lead time.Duration
+
+	// Threshold is the number of invocations forecast in an hour for which
+	// a function is scaled up from zero
+	Threshold float64
+
+	// MinWeeks is the number of weeks a bucket must have been observed for
+	// before it is used for a forecast
+	MinWeeks int
+
+	// ServiceQuery queries and sets the replicas of a function
+	ServiceQuery ServiceQuery
+
+	// Functions filters out invocations of functions which are not
+	// deployed, and removes the history of deleted functions. It can be nil.
+	Functions FunctionChecker
+
+	// StateFile keeps the learned seasonality across restarts, it is
+	// optional
+	StateFile string
+
+	// Metrics records forecasts and decisions, it can be nil
+	Metrics *metrics.MetricOptions
+
+	// History records each pre-warm, it can be nil
+	History *ScalingHistory
+}
+
+// seasonality is the smoothed number of invocations of a function in each
+// hour of the week, in UTC.
+type seasonality struct {
+	Name      string                `json:"name"`
+	Namespace string                `json:"namespace"`
+	Buckets   [hoursPerWeek]float64 `json:"buckets"`
+	Weeks     [hoursPerWeek]int     `json:"weeks"`
+
+	// hour is the start of the hour being counted
+	hour  time.Time
+	count uint64
+}
+
+// Prewarmer learns the invocations of each function per hour of the week
+// from the gateway's own proxy events, and scales a function up from zero
+// shortly before the hour in which traffic is forecast.
+//
+// It implements the same Notify method as the handlers.HTTPNotifier.
+type Prewarmer struct {
+	config           PrewarmerConfig
+	defaultNamespace string
+
+	// tracker is touched when a function is pre-warmed, so that the
+	// idler does not scale it straight back to zero, it can be nil
+	tracker *InvocationTracker
+
+	lock      sync.Mutex
+	functions map[string]*seasonality
+
+	// prewarmed is the hour each function was scaled up for
+	prewarmed map[string]time.Time
+}
+
+// PrewarmResult is the outcome of evaluating a single function
+type PrewarmResult struct {
+	Name      string
+	Namespace string
+	Forecast  float64
+	Scaled    bool
+	Error     error
+}
+
+// NewPrewarmer creates a Prewarmer, functions without a namespace in their
+// URL are recorded in defaultNamespace.
+func NewPrewarmer(config PrewarmerConfig, defaultNamespace string, tracker *InvocationTracker) *Prewarmer {
+	return &Prewarmer{
+		config:           config,
+		defaultNamespace: defaultNamespace,
+		tracker:          tracker,
+		functions:        make(map[string]*seasonality),
+		prewarmed:        make(map[string]time.Time),
+	}
+}
+
+// Notify counts the "started" event of each invocation
+func (p *Prewarmer) Notify(n types.HTTPNotification) {
+	if n.Event != "started" {
+		return
+	}
+
+	p.notify(middleware.GetServiceName(n.OriginalURL))
+}
+
+func (p *Prewarmer) notify(serviceName string) {
+	if len(serviceName) == 0 {
+		return
+	}
+
+	name, namespace := middleware.GetNamespace(p.defaultNamespace, serviceName)
+	if !p.exists(functionLabel(name, namespace)) {
+		return
+	}
+	p.record(name, namespace, time.Now())
+}
+
+// PrewarmEnqueueNotifier counts each asynchronous request accepted by the
+// queue as an invocation in the Prewarmer's history. The queued proxy only
+// sends a "completed" event, once the request has been enqueued.
+type PrewarmEnqueueNotifier struct {
+	Prewarmer *Prewarmer
+}
+
+// Notify counts a "completed" event of an accepted request
+func (n PrewarmEnqueueNotifier) Notify(notification types.HTTPNotification) {
+	if notification.Event != "completed" || notification.StatusCode != http.StatusAccepted {
+		return
+	}
+
+	n.Prewarmer.notify(middleware.GetServiceName(notification.OriginalURL))
+}
+
+func (p *Prewarmer) exists(key string) bool {
+	return p.config.Functions == nil || p.config.Functions.FunctionExists(key)
+}
+
+// forget removes the history and the series of a function, it must be
+// called with the lock held
+func (p *Prewarmer) forget(key string) {
+	delete(p.functions, key)
+	delete(p.prewarmed, key)
+
+	if p.config.Metrics != nil {
+		p.config.Metrics.PrewarmForecast.DeleteLabelValues(key)
+		p.config.Metrics.PrewarmForecastError.DeleteLabelValues(key)
+		p.config.Metrics.PrewarmDecisions.DeletePartialMatch(prometheus.Labels{"function_name": key})
+	}
+}
+
+func (p *Prewarmer) record(name, namespace string, at time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	s := p.get(name, namespace)
+	p.roll(s, at)
+	s.count++
+}
+
+// get must be called with the lock held
+func (p *Prewarmer) get(name, namespace string) *seasonality {
+	key := functionLabel(name, namespace)
+	s, ok := p.functions[key]
+	if !ok {
+		s = &seasonality{Name: name, Namespace: namespace}
+		p.functions[key] = s
+	}
+	return s
+}
+
+// roll folds the count of each complete hour into its bucket, hours
+// without any invocations are folded in as zero. It must be called with
+// the lock held.
+func (p *Prewarmer) roll(s *seasonality, now time.Time) {
+	hour := now.UTC().Truncate(time.Hour)
+
+	if s.hour.IsZero() {
+		s.hour = hour
+		return
+	}
+
+	for folded := 0; s.hour.Before(hour) && folded < hoursPerWeek; folded++ {
+		p.fold(s, s.hour, float64(s.count))
+		s.hour = s.hour.Add(time.Hour)
+		s.count = 0
+	}
+	s.hour = hour
+}
+
+func (p *Prewarmer) fold(s *seasonality, hour time.Time, actual float64) {
+	key := functionLabel(s.Name, s.Namespace)
+	bucket := hourOfWeek(hour)
+
+	if s.Weeks[bucket] >= p.minWeeks() && p.config.Metrics != nil {
+		p.config.Metrics.PrewarmForecastError.WithLabelValues(key).Set(math.Abs(s.Buckets[bucket] - actual))
+	}
+
+	if prewarmedHour, ok := p.prewarmed[key]; ok && prewarmedHour.Equal(hour) {
+		outcome := PrewarmWasted
+		if actual > 0 {
+			outcome = PrewarmHit
+		}
+		p.observe(key, outcome)
+		delete(p.prewarmed, key)
+	}
+
+	if s.Weeks[bucket] == 0 {
+		s.Buckets[bucket] = actual
+	} else {
+		s.Buckets[bucket] = seasonalSmoothing*actual + (1-seasonalSmoothing)*s.Buckets[bucket]
+	}
+	s.Weeks[bucket]++
+}
+
+func (p *Prewarmer) minWeeks() int {
+	if p.config.MinWeeks < 1 {
+		return 1
+	}
+	return p.config.MinWeeks
+}
+
+// hourOfWeek is the bucket for hour, starting from Sunday at 00:00 UTC
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// Forecast returns the number of invocations expected for a function in
+// the hour containing at. False is returned when not enough weeks have
+// been observed.
+func (p *Prewarmer) Forecast(name, namespace string, at time.Time) (float64, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.forecast(functionLabel(name, namespace), at)
+}
+
+// forecast must be called with the lock held
+func (p *Prewarmer) forecast(key string, at time.Time) (float64, bool) {
+	s, ok := p.functions[key]
+	if !ok {
+		return 0, false
+	}
+
+	bucket := hourOfWeek(at)
+	if s.Weeks[bucket] < p.minWeeks() {
+		return 0, false
+	}
+	return s.Buckets[bucket], true
+}
+
+// Start evaluates the forecasts on a ticker in a separate goroutine, and
+// saves the learned seasonality when a StateFile is configured.
+func (p *Prewarmer) Start() {
+	ticker := time.NewTicker(p.config.Interval)
+
+	go func() {
+		for range ticker.C {
+			p.Evaluate(time.Now())
+
+			if len(p.config.StateFile) > 0 {
+				if err := p.Save(); err != nil {
+					logger.Error("unable to save pre-warming state", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Evaluate scales up any function at zero replicas with traffic forecast
+// within the lead time. Functions which are no longer deployed are
+// forgotten.
+func (p *Prewarmer) Evaluate(now time.Time) []PrewarmResult {
+	target := now.Add(p.config.Lead).UTC().Truncate(time.Hour)
+
+	type candidate struct {
+		name, namespace string
+		forecast        float64
+	}
+
+	p.lock.Lock()
+	candidates := []candidate{}
+	for key, s := range p.functions {
+		if !p.exists(key) {
+			p.forget(key)
+			continue
+		}
+
+		p.roll(s, now)
+
+		forecast, ok := p.forecast(key, target)
+		if p.config.Metrics != nil {
+			p.config.Metrics.PrewarmForecast.WithLabelValues(key).Set(forecast)
+		}
+
+		if !ok || forecast < p.config.Threshold {
+			continue
+		}
+		if hour, ok := p.prewarmed[key]; ok && hour.Equal(target) {
+			continue
+		}
+		candidates = append(candidates, candidate{name: s.Name, namespace: s.Namespace, forecast: forecast})
+	}
+	p.lock.Unlock()
+
+	results := []PrewarmResult{}
+	for _, c := range candidates {
+		results = append(results, p.prewarm(c.name, c.namespace, c.forecast, target, now))
+	}
+
+	return results
+}
+
+func (p *Prewarmer) prewarm(name, namespace string, forecast float64, target, now time.Time) PrewarmResult {
+	key := functionLabel(name, namespace)
+	result := PrewarmResult{Name: name, Namespace: namespace, Forecast: forecast}
+
+	queryResponse, err := p.config.ServiceQuery.GetReplicas(name, namespace)
+	if err != nil {
+		if errors.Is(err, ErrFunctionNotFound) {
+			p.lock.Lock()
+			p.forget(key)
+			p.lock.Unlock()
+		}
+		result.Error = err
+		logger.Error("unable to query replicas to pre-warm", "function", name, "namespace", namespace, "error", err)
+		return result
+	}
+
+	if queryResponse.Replicas > 0 {
+		return result
+	}
+
+	replicas := queryResponse.MinReplicas
+	if replicas == 0 {
+		replicas = 1
+	}
+
+	logger.Info("pre-warming",
+		"function", name, "namespace", namespace,
+		"forecast", forecast, "from", target.Format(time.RFC3339), "replicas", 0, "target_replicas", replicas)
+
+	err = p.config.ServiceQuery.SetReplicas(name, namespace, replicas)
+	p.config.History.Record(ScalingEvent{
+		Time:      now,
+		Function:  name,
+		Namespace: namespace,
+		Trigger:   TriggerPrewarm,
+		Inputs: map[string]string{
+			"forecast": strconv.FormatFloat(forecast, 'f', 1, 64),
+			"hour":     target.Format(time.RFC3339),
+		},
+		OldReplicas: 0,
+		NewReplicas: replicas,
+		Error:       errorString(err),
+	})
+
+	if err != nil {
+		result.Error = err
+		logger.Error("unable to pre-warm", "function", name, "namespace", namespace, "error", err)
+		p.observe(key, PrewarmError)
+		return result
+	}
+
+	result.Scaled = true
+	p.observe(key, PrewarmScaled)
+
+	if p.tracker != nil {
+		p.tracker.Touch(name, namespace, now)
+	}
+
+	p.lock.Lock()
+	p.prewarmed[key] = target
+	p.lock.Unlock()
+
+	return result
+}
+
+func (p *Prewarmer) observe(key, outcome string) {
+	if p.config.Metrics == nil {
+		return
+	}
+	p.config.Metrics.PrewarmDecisions.WithLabelValues(key, outcome).Inc()
+}
+
+// Save writes the learned seasonality to the StateFile
+func (p *Prewarmer) Save() error {
+	p.lock.Lock()
+	functions := make([]seasonality, 0, len(p.functions))
+	for _, s := range p.functions {
+		functions = append(functions, *s)
+	}
+	p.lock.Unlock()
+
+	data, err := json.Marshal(functions)
+	if err != nil {
+		return err
+	}
+
+	tmp := p.config.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.config.StateFile)
+}
+
+// Load reads the seasonality saved to the StateFile, a missing file is
+// not an error. The hour in progress when the state was saved is not
+// restored, so that the time the gateway was stopped is not learned as
+// hours without invocations.
+func (p *Prewarmer) Load() error {
+	data, err := os.ReadFile(p.config.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	functions := []seasonality{}
+	if err := json.Unmarshal(data, &functions); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for i := range functions {
+		s := functions[i]
+		p.functions[functionLabel(s.Name, s.Namespace)] = &s
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"net/http"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// readValue reads the value of a counter or gauge
+func readValue(m prometheus.Metric) float64 {
+	metric := &dto.Metric{}
+	m.Write(metric)
+
+	if metric.Counter != nil {
+		return metric.GetCounter().GetValue()
+	}
+	return metric.GetGauge().GetValue()
+}
+
+// learnWeeks records invocations at 09:00 every Monday for a number of
+// weeks, starting on Monday 1st January 2024.
+func learnWeeks(p *Prewarmer, weeks, invocations int) time.Time {
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for w := 0; w < weeks; w++ {
+		at := monday.AddDate(0, 0, 7*w).Add(time.Hour * 9)
+		for i := 0; i < invocations; i++ {
+			p.record("echo", "openfaas-fn", at.Add(time.Duration(i)*time.Second))
+		}
+		// Fold the hour
+		p.Evaluate(at.Add(time.Hour))
+	}
+
+	return monday.AddDate(0, 0, 7*weeks)
+}
+
+func Test_Prewarmer_Forecast(t *testing.T) {
+	p := NewPrewarmer(PrewarmerConfig{MinWeeks: 2, Threshold: 1, ServiceQuery: &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1}}}, "openfaas-fn", nil)
+	next := learnWeeks(p, 1, 10)
+
+	if _, ok := p.Forecast("echo", "openfaas-fn", next.Add(time.Hour*9)); ok {
+		t.Fatalf("want no forecast with a single week of history")
+	}
+
+	next = learnWeeks(p, 2, 10)
+	forecast, ok := p.Forecast("echo", "openfaas-fn", next.Add(time.Hour*9))
+	if !ok {
+		t.Fatalf("want a forecast after two weeks")
+	}
+	if forecast < 9 || forecast > 11 {
+		t.Fatalf("forecast, want: ~10, got: %.2f", forecast)
+	}
+
+	// No traffic was seen at 10:00
+	forecast, _ = p.Forecast("echo", "openfaas-fn", next.Add(time.Hour*10))
+	if forecast != 0 {
+		t.Fatalf("forecast at 10:00, want: 0, got: %.2f", forecast)
+	}
+}
+
+func Test_Prewarmer_ScalesUpAheadOfTraffic(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 0, MinReplicas: 2}}
+	tracker := NewInvocationTracker("openfaas-fn")
+	metricsOptions := metrics.BuildMetricsOptions()
+
+	p := NewPrewarmer(PrewarmerConfig{
+		Lead:         time.Minute * 5,
+		Threshold:    1,
+		MinWeeks:     2,
+		ServiceQuery: query,
+		Metrics:      &metricsOptions,
+	}, "openfaas-fn", tracker)
+
+	// The function is idle at zero replicas after each week's traffic
+	next := learnWeeks(p, 2, 5)
+	query.response.Replicas = 0
+	query.setCalls = nil
+
+	// 08:50 is before the lead time
+	if results := p.Evaluate(next.Add(time.Hour*8 + time.Minute*50)); len(results) != 0 {
+		t.Fatalf("want no pre-warm before the lead time, got: %+v", results)
+	}
+
+	now := next.Add(time.Hour*8 + time.Minute*56)
+	results := p.Evaluate(now)
+	if len(results) != 1 || !results[0].Scaled {
+		t.Fatalf("want echo pre-warmed, got: %+v", results)
+	}
+	if len(query.setCalls) != 1 || query.setCalls[0] != 2 {
+		t.Fatalf("setCalls, want: [2], got: %v", query.setCalls)
+	}
+
+	if load, ok := tracker.Get("echo", "openfaas-fn"); !ok || !load.LastInvocation.Equal(now) {
+		t.Fatalf("want the tracker touched at %s, got: %+v", now, load)
+	}
+
+	// A pre-warm is only made once for each hour
+	if results := p.Evaluate(now.Add(time.Minute)); len(results) != 0 {
+		t.Fatalf("want a single pre-warm, got: %+v", results)
+	}
+
+	// The forecast traffic arrives
+	p.record("echo", "openfaas-fn", next.Add(time.Hour*9+time.Minute))
+	p.Evaluate(next.Add(time.Hour * 10))
+
+	if got := readValue(metricsOptions.PrewarmDecisions.WithLabelValues("echo.openfaas-fn", PrewarmHit)); got != 1 {
+		t.Fatalf("hit decisions, want: 1, got: %.0f", got)
+	}
+	if got := readValue(metricsOptions.PrewarmDecisions.WithLabelValues("echo.openfaas-fn", PrewarmScaled)); got != 1 {
+		t.Fatalf("scaled decisions, want: 1, got: %.0f", got)
+	}
+}
+
+func Test_Prewarmer_RecordsWastedPrewarm(t *testing.T) {
+	query := &fakeServiceQuery{}
+	metricsOptions := metrics.BuildMetricsOptions()
+
+	p := NewPrewarmer(PrewarmerConfig{
+		Lead:         time.Minute * 5,
+		Threshold:    1,
+		MinWeeks:     1,
+		ServiceQuery: query,
+		Metrics:      &metricsOptions,
+	}, "openfaas-fn", nil)
+
+	next := learnWeeks(p, 1, 5)
+	query.response.Replicas = 0
+
+	p.Evaluate(next.Add(time.Hour*8 + time.Minute*56))
+	p.Evaluate(next.Add(time.Hour * 10))
+
+	if got := readValue(metricsOptions.PrewarmDecisions.WithLabelValues("echo.openfaas-fn", PrewarmWasted)); got != 1 {
+		t.Fatalf("wasted decisions, want: 1, got: %.0f", got)
+	}
+	if got := readValue(metricsOptions.PrewarmForecastError.WithLabelValues("echo.openfaas-fn")); got != 5 {
+		t.Fatalf("forecast error, want: 5, got: %.2f", got)
+	}
+}
+
+func Test_Prewarmer_SaveAndLoad(t *testing.T) {
+	file := path.Join(t.TempDir(), "prewarm.json")
+
+	p := NewPrewarmer(PrewarmerConfig{MinWeeks: 1, StateFile: file, ServiceQuery: &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1}}}, "openfaas-fn", nil)
+	next := learnWeeks(p, 1, 3)
+
+	if err := p.Save(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	loaded := NewPrewarmer(PrewarmerConfig{MinWeeks: 1, StateFile: file}, "openfaas-fn", nil)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	forecast, ok := loaded.Forecast("echo", "openfaas-fn", next.Add(time.Hour*9))
+	if !ok || forecast != 3 {
+		t.Fatalf("forecast, want: 3, got: %.2f (%v)", forecast, ok)
+	}
+}
+
+func Test_Prewarmer_LoadMissingFile(t *testing.T) {
+	p := NewPrewarmer(PrewarmerConfig{StateFile: path.Join(t.TempDir(), "missing.json")}, "openfaas-fn", nil)
+	if err := p.Load(); err != nil {
+		t.Fatalf("want no error for a missing file, got: %s", err)
+	}
+}
+
+// countSeries returns the number of series collected from c
+func countSeries(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	count := 0
+	for range ch {
+		count++
+	}
+	return count
+}
+
+func Test_Prewarmer_IgnoresUnknownFunctions(t *testing.T) {
+	p := NewPrewarmer(PrewarmerConfig{
+		ServiceQuery: &fakeServiceQuery{},
+		Functions:    knownFunctions{"echo.openfaas-fn": true},
+	}, "openfaas-fn", nil)
+
+	p.Notify(types.HTTPNotification{OriginalURL: "/function/echo", Event: "started"})
+	p.Notify(types.HTTPNotification{OriginalURL: "/function/does-not-exist", Event: "started"})
+
+	if got := len(p.functions); got != 1 {
+		t.Fatalf("want only echo recorded, got: %d", got)
+	}
+}
+
+func Test_Prewarmer_ForgetsDeletedFunctions(t *testing.T) {
+	metricsOptions := metrics.BuildMetricsOptions()
+	functions := knownFunctions{"echo.openfaas-fn": true}
+
+	p := NewPrewarmer(PrewarmerConfig{
+		MinWeeks:     1,
+		ServiceQuery: &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1}},
+		Functions:    functions,
+		Metrics:      &metricsOptions,
+	}, "openfaas-fn", nil)
+
+	next := learnWeeks(p, 1, 5)
+	if got := countSeries(metricsOptions.PrewarmForecast); got != 1 {
+		t.Fatalf("forecast series, want: 1, got: %d", got)
+	}
+
+	delete(functions, "echo.openfaas-fn")
+	p.Evaluate(next)
+
+	if _, ok := p.Forecast("echo", "openfaas-fn", next.Add(time.Hour*9)); ok {
+		t.Fatalf("want the history of echo removed")
+	}
+	if got := countSeries(metricsOptions.PrewarmForecast) + countSeries(metricsOptions.PrewarmForecastError); got != 0 {
+		t.Fatalf("want the series of echo removed, got: %d", got)
+	}
+}
+
+func Test_PrewarmEnqueueNotifier_RecordsAcceptedRequests(t *testing.T) {
+	p := NewPrewarmer(PrewarmerConfig{
+		ServiceQuery: &fakeServiceQuery{},
+		Functions:    knownFunctions{"echo.openfaas-fn": true},
+	}, "openfaas-fn", nil)
+	notifier := PrewarmEnqueueNotifier{Prewarmer: p}
+
+	notifier.Notify(types.HTTPNotification{OriginalURL: "/async-function/echo", Event: "completed", StatusCode: http.StatusAccepted})
+	notifier.Notify(types.HTTPNotification{OriginalURL: "/async-function/echo", Event: "completed", StatusCode: http.StatusRequestEntityTooLarge})
+	notifier.Notify(types.HTTPNotification{OriginalURL: "/async-function/does-not-exist", Event: "completed", StatusCode: http.StatusAccepted})
+
+	s, ok := p.functions["echo.openfaas-fn"]
+	if !ok || len(p.functions) != 1 {
+		t.Fatalf("want only echo recorded, got: %d functions", len(p.functions))
+	}
+	if s.count != 1 {
+		t.Fatalf("want the accepted request counted once, got: %d", s.count)
+	}
+}
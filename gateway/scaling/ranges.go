@@ -3,9 +3,13 @@ package scaling
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/gorilla/mux"
 	"github.com/openfaas/faas-provider/types"
 )
 
@@ -19,7 +23,19 @@ const (
 	// DefaultScalingFactor is the defining proportion for the scaling increments.
 	DefaultScalingFactor = 10
 
-	DefaultTypeScale = "rps"
+	// DefaultTypeScale is the metric used by the autoscaler when a function
+	// does not set ScaleTypeLabel.
+	DefaultTypeScale = ScaleTypeRPS
+
+	// ScaleTypeRPS scales on completed requests per second, per replica
+	ScaleTypeRPS = "rps"
+
+	// ScaleTypeCapacity scales on in-flight requests, per replica
+	ScaleTypeCapacity = "capacity"
+
+	// ScaleTypeLatency scales in proportion to the mean latency over
+	// the target latency in milliseconds
+	ScaleTypeLatency = "latency"
 
 	// MinScaleLabel label indicating min scale for a function
 	MinScaleLabel = "com.openfaas.scale.min"
@@ -29,9 +45,72 @@ const (
 
 	// ScalingFactorLabel label indicates the scaling factor for a function
 	ScalingFactorLabel = "com.openfaas.scale.factor"
+
+	// ScaleTypeLabel label indicates the metric the autoscaler uses for a function
+	ScaleTypeLabel = "com.openfaas.scale.type"
+
+	// TargetLoadLabel label indicates the target load per replica for the autoscaler
+	TargetLoadLabel = "com.openfaas.scale.target"
+
+	// ScaleToZeroLabel label set to "true" allows an idle function to be scaled to zero
+	ScaleToZeroLabel = "com.openfaas.scale.zero"
+
+	// ScaleToZeroDurationLabel label indicates how long a function must be idle
+	// before it is scaled to zero i.e. "15m"
+	ScaleToZeroDurationLabel = "com.openfaas.scale.zero-duration"
+
+	// ColdStartTimeoutLabel label indicates how long a request is held while
+	// the function is scaled up from zero i.e. "30s"
+	ColdStartTimeoutLabel = "com.openfaas.scale.cold-start-timeout"
+
+	// ScheduleLabel label or annotation sets profiles of minimum replicas
+	// by time of day i.e. "0 8 * * 1-5 = 5; 0 18 * * 1-5 = 0"
+	ScheduleLabel = "com.openfaas.scale.schedule"
+
+	// ScheduleTimezoneLabel label or annotation sets the time zone of the
+	// ScheduleLabel i.e. "Europe/London"
+	ScheduleTimezoneLabel = "com.openfaas.scale.schedule-timezone"
+
+	// ScaleUpWindowLabel label indicates how long a higher replica count must
+	// be recommended for before scaling up i.e. "30s"
+	ScaleUpWindowLabel = "com.openfaas.scale.up-window"
+
+	// ScaleDownWindowLabel label indicates how long a lower replica count must
+	// be recommended for before scaling down i.e. "5m"
+	ScaleDownWindowLabel = "com.openfaas.scale.down-window"
+
+	// ScaleUpCooldownLabel label indicates the minimum time between scale ups
+	ScaleUpCooldownLabel = "com.openfaas.scale.up-cooldown"
+
+	// ScaleDownCooldownLabel label indicates the minimum time after any change
+	// of replicas before a scale down
+	ScaleDownCooldownLabel = "com.openfaas.scale.down-cooldown"
+
+	// MaxStepUpLabel label indicates the most replicas added at once
+	MaxStepUpLabel = "com.openfaas.scale.max-step-up"
+
+	// MaxStepDownLabel label indicates the most replicas removed at once
+	MaxStepDownLabel = "com.openfaas.scale.max-step-down"
+
+	// ScheduleActiveAnnotation is added to the function's status with the
+	// name of the active schedule profile
+	ScheduleActiveAnnotation = "com.openfaas.scale.schedule-active"
+
+	// ScheduleMinReplicasAnnotation is added to the function's status with
+	// the minimum replicas of the active schedule profile
+	ScheduleMinReplicasAnnotation = "com.openfaas.scale.schedule-min"
 )
 
-func MakeHorizontalScalingHandler(next http.HandlerFunc) http.HandlerFunc {
+// MakeHorizontalScalingHandler validates a manual scaling request against the
+// function's min and max replicas before passing it on to next. Requests
+// outside of the limits are rejected, rather than being changed silently.
+// A request for zero replicas is only allowed for functions which can be
+// scaled to zero. A function which the provider does not have is a 404, any
+// other error querying the provider is a 502.
+//
+// Each request for a known function is recorded in history as a
+// TriggerManual event, history can be nil.
+func MakeHorizontalScalingHandler(next http.HandlerFunc, functionQuery FunctionQuery, history *ScalingHistory, defaultNamespace string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
@@ -55,18 +134,83 @@ func MakeHorizontalScalingHandler(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		if scaleRequest.Replicas < 1 {
-			scaleRequest.Replicas = 1
+		name := scaleRequest.ServiceName
+		if len(name) == 0 {
+			name = mux.Vars(r)["name"]
 		}
 
-		if scaleRequest.Replicas > DefaultMaxReplicas {
-			scaleRequest.Replicas = DefaultMaxReplicas
+		namespace := scaleRequest.Namespace
+		if len(namespace) == 0 {
+			namespace = r.URL.Query().Get("namespace")
+		}
+		if len(namespace) == 0 {
+			namespace = defaultNamespace
+		}
+
+		queryResponse, err := functionQuery.Get(name, namespace)
+		if err != nil {
+			if errors.Is(err, ErrFunctionNotFound) {
+				http.Error(w, fmt.Sprintf("Unable to find function: %s", name), http.StatusNotFound)
+				return
+			}
+
+			logger.Error("unable to query function", "function", name, "namespace", namespace, "error", err)
+			http.Error(w, fmt.Sprintf("Unable to query function: %s", name), http.StatusBadGateway)
+			return
+		}
+
+		minReplicas := queryResponse.MinReplicas
+		if scaleRequest.Replicas == 0 && queryResponse.ScaleToZero {
+			minReplicas = 0
+		}
+
+		event := ScalingEvent{
+			Function:  name,
+			Namespace: namespace,
+			Trigger:   TriggerManual,
+			Inputs: map[string]string{
+				"minReplicas": strconv.FormatUint(minReplicas, 10),
+				"maxReplicas": strconv.FormatUint(queryResponse.MaxReplicas, 10),
+			},
+			OldReplicas: queryResponse.Replicas,
+			NewReplicas: scaleRequest.Replicas,
+		}
+
+		if scaleRequest.Replicas < minReplicas || scaleRequest.Replicas > queryResponse.MaxReplicas {
+			outOfRange := ReplicasOutOfRangeError{
+				Function:    functionLabel(name, namespace),
+				Replicas:    scaleRequest.Replicas,
+				MinReplicas: minReplicas,
+				MaxReplicas: queryResponse.MaxReplicas,
+			}
+			event.Error = outOfRange.Error()
+			history.Record(event)
+
+			http.Error(w, outOfRange.Error(), http.StatusBadRequest)
+			return
 		}
 
 		upstreamReq, _ := json.Marshal(scaleRequest)
 		// Restore the io.ReadCloser to its original state
 		r.Body = io.NopCloser(bytes.NewBuffer(upstreamReq))
 
-		next.ServeHTTP(w, r)
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.statusCode >= http.StatusBadRequest {
+			event.Error = fmt.Sprintf("provider returned status: %d", recorder.statusCode)
+		}
+		history.Record(event)
 	}
 }
+
+// statusRecorder keeps the status code written by the provider's response
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (s *statusRecorder) WriteHeader(statusCode int) {
+	s.statusCode = statusCode
+	s.ResponseWriter.WriteHeader(statusCode)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_CeilingServiceQuery_LowersMaxReplicas(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{MinReplicas: 1, MaxReplicas: 50}}
+	ceiling := NewCeilingServiceQuery(query, 20)
+
+	res, err := ceiling.GetReplicas("echo", "openfaas-fn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MaxReplicas != 20 {
+		t.Errorf("MaxReplicas want: %d, got: %d", 20, res.MaxReplicas)
+	}
+}
+
+func Test_CeilingServiceQuery_RejectsAboveCeiling(t *testing.T) {
+	query := &fakeServiceQuery{}
+	ceiling := NewCeilingServiceQuery(query, 20)
+
+	err := ceiling.SetReplicas("echo", "openfaas-fn", 21)
+
+	var outOfRange *ReplicasOutOfRangeError
+	if !errors.As(err, &outOfRange) {
+		t.Fatalf("want ReplicasOutOfRangeError, got: %v", err)
+	}
+	if len(query.setCalls) != 0 {
+		t.Errorf("want no calls to SetReplicas, got: %v", query.setCalls)
+	}
+}
+
+func Test_CeilingServiceQuery_ZeroDisablesCeiling(t *testing.T) {
+	query := &fakeServiceQuery{}
+	if got := NewCeilingServiceQuery(query, 0); got != query {
+		t.Fatalf("want the original ServiceQuery when the ceiling is 0")
+	}
+}
+
+func makeScaleRequest(response ServiceQueryResponse, body string) *httptest.ResponseRecorder {
+	return makeScaleRequestTo(&fakeServiceQuery{response: response}, body)
+}
+
+func makeScaleRequestTo(query ServiceQuery, body string) *httptest.ResponseRecorder {
+	functionQuery := NewCachedFunctionQuery(NewFunctionCache(time.Minute), query)
+
+	handler := MakeHorizontalScalingHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}, functionQuery, nil, "openfaas-fn")
+
+	req := httptest.NewRequest(http.MethodPost, "/system/scale-function/echo", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func Test_MakeHorizontalScalingHandler_AllowsLabelledMax(t *testing.T) {
+	rr := makeScaleRequest(ServiceQueryResponse{MinReplicas: 1, MaxReplicas: 50},
+		`{"serviceName":"echo","replicas":50}`)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status want: %d, got: %d, body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+}
+
+func Test_MakeHorizontalScalingHandler_RejectsAboveMax(t *testing.T) {
+	rr := makeScaleRequest(ServiceQueryResponse{MinReplicas: 1, MaxReplicas: 10},
+		`{"serviceName":"echo","replicas":11}`)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status want: %d, got: %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func Test_MakeHorizontalScalingHandler_RejectsBelowMin(t *testing.T) {
+	rr := makeScaleRequest(ServiceQueryResponse{MinReplicas: 2, MaxReplicas: 10},
+		`{"serviceName":"echo","replicas":1}`)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status want: %d, got: %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func Test_MakeHorizontalScalingHandler_ZeroWhenScaleToZero(t *testing.T) {
+	rr := makeScaleRequest(ServiceQueryResponse{MinReplicas: 1, MaxReplicas: 10},
+		`{"serviceName":"echo","replicas":0}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status without label want: %d, got: %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = makeScaleRequest(ServiceQueryResponse{MinReplicas: 1, MaxReplicas: 10, ScaleToZero: true},
+		`{"serviceName":"echo","replicas":0}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status with label want: %d, got: %d", http.StatusAccepted, rr.Code)
+	}
+}
+
+type unavailableServiceQuery struct{}
+
+func (unavailableServiceQuery) GetReplicas(service, namespace string) (ServiceQueryResponse, error) {
+	return ServiceQueryResponse{}, fmt.Errorf("error querying provider: %s.%s", service, namespace)
+}
+
+func (unavailableServiceQuery) SetReplicas(service, namespace string, count uint64) error {
+	return nil
+}
+
+func Test_MakeHorizontalScalingHandler_NotFound(t *testing.T) {
+	rr := makeScaleRequestTo(&notFoundServiceQuery{}, `{"serviceName":"echo","replicas":1}`)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status want: %d, got: %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func Test_MakeHorizontalScalingHandler_ProviderError(t *testing.T) {
+	rr := makeScaleRequestTo(unavailableServiceQuery{}, `{"serviceName":"echo","replicas":1}`)
+
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("status want: %d, got: %d, body: %s", http.StatusBadGateway, rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotReady is returned by a ReadinessWatcher when no replica became
+// available before the deadline.
+var ErrNotReady = errors.New("function not ready before deadline")
+
+// ReadinessWatcher waits for a function to have at least one available
+// replica. It returns as soon as a replica is available, or ErrNotReady
+// once the deadline has passed.
+type ReadinessWatcher interface {
+	WaitForReady(functionName, namespace string, deadline time.Time) (ServiceQueryResponse, error)
+}
+
+// PollingReadinessWatcher queries the provider on an interval. Requests
+// waiting on the same function share each query through SingleFlight,
+// which is shared by every gateway replica when it is a Peer.
+type PollingReadinessWatcher struct {
+	ServiceQuery ServiceQuery
+	Interval     time.Duration
+	SingleFlight FlightGroup
+}
+
+// NewPollingReadinessWatcher creates a ReadinessWatcher which polls
+// serviceQuery, the queries are shared within the gateway when flightGroup
+// is nil
+func NewPollingReadinessWatcher(serviceQuery ServiceQuery, interval time.Duration, flightGroup FlightGroup) ReadinessWatcher {
+	if flightGroup == nil {
+		flightGroup = &singleflight.Group{}
+	}
+
+	return &PollingReadinessWatcher{
+		ServiceQuery: serviceQuery,
+		Interval:     interval,
+		SingleFlight: flightGroup,
+	}
+}
+
+// WaitForReady polls until a replica is available or the deadline passes.
+// Concurrent waiters for the same function share each query.
+func (p *PollingReadinessWatcher) WaitForReady(functionName, namespace string, deadline time.Time) (ServiceQueryResponse, error) {
+	key := fmt.Sprintf("GetReplicas-%s.%s", functionName, namespace)
+
+	var last ServiceQueryResponse
+	for time.Now().Before(deadline) {
+		res, err, _ := p.SingleFlight.Do(key, func() (interface{}, error) {
+			return p.ServiceQuery.GetReplicas(functionName, namespace)
+		})
+		if err != nil {
+			return last, err
+		}
+
+		last = res.(ServiceQueryResponse)
+		if last.AvailableReplicas > 0 {
+			return last, nil
+		}
+
+		time.Sleep(p.Interval)
+	}
+
+	return last, ErrNotReady
+}
@@ -0,0 +1,95 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// readyAfterQuery reports an available replica after a number of calls to GetReplicas
+type readyAfterQuery struct {
+	fakeServiceQuery
+	calls int
+	after int
+}
+
+func (r *readyAfterQuery) GetReplicas(service, namespace string) (ServiceQueryResponse, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.calls++
+	if r.calls >= r.after {
+		r.response.AvailableReplicas = 1
+	}
+	return r.response, nil
+}
+
+func Test_PollingReadinessWatcher_ReadyAfterPolls(t *testing.T) {
+	query := &readyAfterQuery{after: 3}
+	watcher := NewPollingReadinessWatcher(query, time.Millisecond, nil)
+
+	res, err := watcher.WaitForReady("echo", "openfaas-fn", time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err)
+	}
+	if res.AvailableReplicas != 1 {
+		t.Fatalf("AvailableReplicas, want: 1, got: %d", res.AvailableReplicas)
+	}
+	if query.calls != 3 {
+		t.Fatalf("calls, want: 3, got: %d", query.calls)
+	}
+}
+
+func Test_PollingReadinessWatcher_Deadline(t *testing.T) {
+	query := &fakeServiceQuery{}
+	watcher := NewPollingReadinessWatcher(query, time.Millisecond, nil)
+
+	_, err := watcher.WaitForReady("echo", "openfaas-fn", time.Now().Add(time.Millisecond*20))
+	if err != ErrNotReady {
+		t.Fatalf("want: %s, got: %v", ErrNotReady, err)
+	}
+}
+
+func Test_PollingReadinessWatcher_SharesQueries(t *testing.T) {
+	query := &readyAfterQuery{after: 5}
+	watcher := NewPollingReadinessWatcher(query, time.Millisecond*10, nil)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			watcher.WaitForReady("echo", "openfaas-fn", time.Now().Add(time.Second))
+		}()
+	}
+	wg.Wait()
+
+	// Each waiter would make at least 5 calls on its own
+	if query.calls >= 50 {
+		t.Fatalf("calls, want fewer than 50 shared between waiters, got: %d", query.calls)
+	}
+}
+
+func Test_Scale_HeldRequestsSharePolls(t *testing.T) {
+	query := &readyAfterQuery{after: 10}
+	query.response = ServiceQueryResponse{MinReplicas: 1, Replicas: 1}
+	scaler := newTestScaler(query, time.Second, 0)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scaler.Scale("echo", "openfaas-fn")
+		}()
+	}
+	wg.Wait()
+
+	// Each held request would make at least 10 calls on its own
+	if query.calls >= 50 {
+		t.Fatalf("calls, want fewer than 50 shared between held requests, got: %d", query.calls)
+	}
+}
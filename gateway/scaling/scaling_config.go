@@ -2,6 +2,8 @@ package scaling
 
 import (
 	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
 )
 
 // ScalingConfig for scaling behaviours
@@ -22,4 +24,32 @@ type ScalingConfig struct {
 	// SetScaleRetries is the number of times to try scaling a function before
 	// giving up due to errors
 	SetScaleRetries uint
+
+	// ColdStartTimeout is how long a request is held while a function scales
+	// up from zero, unless set by the function's label. When 0, requests are
+	// held for MaxPollCount * FunctionPollInterval
+	ColdStartTimeout time.Duration
+
+	// MaxHeldRequests is the maximum number of requests held per function
+	// while it scales up from zero, 0 means no limit
+	MaxHeldRequests int
+
+	// RetryAfter is sent to clients whose request could not be held or
+	// timed out during a scale up from zero
+	RetryAfter time.Duration
+
+	// Metrics records held requests and cold starts, it can be nil
+	Metrics *metrics.MetricOptions
+
+	// ReadinessWatcher waits for a function scaled from zero to become ready.
+	// When nil, NewFunctionScaler polls ServiceQuery every
+	// FunctionPollInterval, sharing the queries through FlightGroup
+	ReadinessWatcher ReadinessWatcher
+
+	// History records each scale up from zero, it can be nil
+	History *ScalingHistory
+
+	// FlightGroup shares the queries and scale ups of a function in progress,
+	// when nil they are shared within the gateway by a singleflight.Group
+	FlightGroup FlightGroup
 }
@@ -0,0 +1,271 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduleProfile sets the minimum replicas of a function from the time
+// its cron expression fires, until another profile of the same schedule
+// fires.
+type ScheduleProfile struct {
+	// Name of the profile, the cron expression is used when not given
+	Name string
+
+	// Expression is the cron expression which starts the profile
+	Expression string
+
+	// MinReplicas is the minimum number of replicas while the profile is active
+	MinReplicas uint64
+
+	cron *CronSchedule
+}
+
+// Schedule is a set of profiles evaluated in a time zone
+type Schedule struct {
+	Location *time.Location
+	Profiles []ScheduleProfile
+}
+
+// ParseSchedule parses the value of ScheduleLabel, which is a list of
+// profiles separated by ";" in the form "[name:] <cron> = <min replicas>", i.e.
+//
+//	business-hours: 0 8 * * 1-5 = 5; off-hours: 0 18 * * 1-5 = 0
+//
+// timezone is an IANA time zone name such as "Europe/London", or empty
+// for UTC.
+func ParseSchedule(value, timezone string) (*Schedule, error) {
+	location := time.UTC
+	if len(timezone) > 0 {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", timezone, err)
+		}
+		location = loc
+	}
+
+	schedule := &Schedule{Location: location}
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		profile := ScheduleProfile{}
+
+		if i := strings.Index(entry, ":"); i >= 0 {
+			profile.Name = strings.TrimSpace(entry[:i])
+			entry = entry[i+1:]
+		}
+
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("profile %q must be in the form \"<cron> = <min replicas>\"", entry)
+		}
+
+		minReplicas, err := strconv.ParseUint(strings.TrimSpace(entry[i+1:]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min replicas in profile %q", entry)
+		}
+
+		profile.Expression = strings.Join(strings.Fields(entry[:i]), " ")
+		profile.MinReplicas = minReplicas
+
+		if profile.cron, err = ParseCron(profile.Expression); err != nil {
+			return nil, err
+		}
+		if len(profile.Name) == 0 {
+			profile.Name = profile.Expression
+		}
+
+		schedule.Profiles = append(schedule.Profiles, profile)
+	}
+
+	if len(schedule.Profiles) == 0 {
+		return nil, fmt.Errorf("schedule %q has no profiles", value)
+	}
+
+	return schedule, nil
+}
+
+// Active returns the profile which fired most recently before now
+func (s *Schedule) Active(now time.Time) (ScheduleProfile, bool) {
+	now = now.In(s.Location)
+
+	var active ScheduleProfile
+	var activeSince time.Time
+	found := false
+
+	for _, profile := range s.Profiles {
+		since, ok := profile.cron.Previous(now)
+		if ok && (!found || since.After(activeSince)) {
+			active = profile
+			activeSince = since
+			found = true
+		}
+	}
+
+	return active, found
+}
+
+// ScheduleSpec is the schedule of a single function in a SchedulePolicy
+type ScheduleSpec struct {
+	Schedule string `json:"schedule"`
+	Timezone string `json:"timezone"`
+}
+
+// SchedulePolicy sets the schedule of functions from the gateway, rather
+// than their labels. It is keyed by "name.namespace".
+type SchedulePolicy map[string]ScheduleSpec
+
+// LoadSchedulePolicy reads a SchedulePolicy from a JSON file
+func LoadSchedulePolicy(path string) (SchedulePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := SchedulePolicy{}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("unable to parse schedule policy %s: %w", path, err)
+	}
+
+	for key, spec := range policy {
+		if _, err := ParseSchedule(spec.Schedule, spec.Timezone); err != nil {
+			return nil, fmt.Errorf("schedule policy for %s: %w", key, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// ScheduleResolver finds the active profile of a function from its own
+// schedule, or the gateway's SchedulePolicy.
+type ScheduleResolver struct {
+	// Policy is used for functions without their own schedule
+	Policy SchedulePolicy
+
+	// DefaultTimezone is used when a schedule does not set a time zone
+	DefaultTimezone string
+
+	lock   sync.Mutex
+	parsed map[ScheduleSpec]*Schedule
+}
+
+// NewScheduleResolver creates a ScheduleResolver
+func NewScheduleResolver(policy SchedulePolicy, defaultTimezone string) *ScheduleResolver {
+	return &ScheduleResolver{
+		Policy:          policy,
+		DefaultTimezone: defaultTimezone,
+		parsed:          make(map[ScheduleSpec]*Schedule),
+	}
+}
+
+// Resolve returns the active profile for a function, spec is the function's
+// own schedule and takes precedence over the policy.
+func (r *ScheduleResolver) Resolve(name, namespace string, spec ScheduleSpec, now time.Time) (ScheduleProfile, bool, error) {
+	if len(spec.Schedule) == 0 {
+		var ok bool
+		if spec, ok = r.Policy[functionLabel(name, namespace)]; !ok {
+			return ScheduleProfile{}, false, nil
+		}
+	}
+
+	if len(spec.Timezone) == 0 {
+		spec.Timezone = r.DefaultTimezone
+	}
+
+	r.lock.Lock()
+	schedule, ok := r.parsed[spec]
+	r.lock.Unlock()
+
+	if !ok {
+		var err error
+		if schedule, err = ParseSchedule(spec.Schedule, spec.Timezone); err != nil {
+			return ScheduleProfile{}, false, err
+		}
+
+		r.lock.Lock()
+		r.parsed[spec] = schedule
+		r.lock.Unlock()
+	}
+
+	profile, found := schedule.Active(now)
+	return profile, found, nil
+}
+
+// ScheduleServiceQuery raises the minimum replicas of each function to
+// that of its active ScheduleProfile, so that alerts, the autoscaler and
+// scale from zero all respect the schedule.
+type ScheduleServiceQuery struct {
+	ServiceQuery ServiceQuery
+	Resolver     *ScheduleResolver
+}
+
+// NewScheduleServiceQuery wraps serviceQuery with the schedules from resolver
+func NewScheduleServiceQuery(serviceQuery ServiceQuery, resolver *ScheduleResolver) ServiceQuery {
+	return &ScheduleServiceQuery{
+		ServiceQuery: serviceQuery,
+		Resolver:     resolver,
+	}
+}
+
+// GetReplicas queries the function and applies its active profile
+func (s *ScheduleServiceQuery) GetReplicas(service, namespace string) (ServiceQueryResponse, error) {
+	return s.GetReplicasContext(context.Background(), service, namespace)
+}
+
+// GetReplicasContext is GetReplicas as part of the request in ctx
+func (s *ScheduleServiceQuery) GetReplicasContext(ctx context.Context, service, namespace string) (ServiceQueryResponse, error) {
+	res, err := getReplicasContext(ctx, s.ServiceQuery, service, namespace)
+	if err != nil {
+		return res, err
+	}
+
+	return applySchedule(s.Resolver, service, namespace, res, time.Now()), nil
+}
+
+// SetReplicas is passed through to the ServiceQuery
+func (s *ScheduleServiceQuery) SetReplicas(service, namespace string, count uint64) error {
+	return s.ServiceQuery.SetReplicas(service, namespace, count)
+}
+
+// SetReplicasContext is SetReplicas as part of the request in ctx
+func (s *ScheduleServiceQuery) SetReplicasContext(ctx context.Context, service, namespace string, count uint64) error {
+	return setReplicasContext(ctx, s.ServiceQuery, service, namespace, count)
+}
+
+func applySchedule(resolver *ScheduleResolver, service, namespace string, res ServiceQueryResponse, now time.Time) ServiceQueryResponse {
+	spec := ScheduleSpec{Schedule: res.Schedule, Timezone: res.ScheduleTimezone}
+
+	profile, ok, err := resolver.Resolve(service, namespace, spec, now)
+	if err != nil {
+		logger.Warn("invalid schedule", "function", service, "namespace", namespace, "error", err)
+		return res
+	}
+	if !ok {
+		return res
+	}
+
+	res.ScheduleProfile = profile.Name
+	res.ScheduledMinReplicas = profile.MinReplicas
+
+	if profile.MinReplicas > res.MinReplicas {
+		res.MinReplicas = profile.MinReplicas
+	}
+	if res.MaxReplicas > 0 && res.MinReplicas > res.MaxReplicas {
+		res.MinReplicas = res.MaxReplicas
+	}
+
+	return res
+}
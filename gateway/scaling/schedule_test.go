@@ -0,0 +1,243 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas-provider/types"
+)
+
+const businessHours = "business-hours: 0 8 * * 1-5 = 5; off-hours: 0 18 * * 1-5 = 0"
+
+func Test_ParseSchedule(t *testing.T) {
+	schedule, err := ParseSchedule(businessHours, "Europe/London")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(schedule.Profiles) != 2 {
+		t.Fatalf("Profiles, want: 2, got: %d", len(schedule.Profiles))
+	}
+	if schedule.Profiles[0].Name != "business-hours" || schedule.Profiles[0].MinReplicas != 5 {
+		t.Fatalf("Profiles[0], want: business-hours with 5, got: %+v", schedule.Profiles[0])
+	}
+	if schedule.Location.String() != "Europe/London" {
+		t.Fatalf("Location, want: Europe/London, got: %s", schedule.Location)
+	}
+}
+
+func Test_ParseSchedule_Unnamed(t *testing.T) {
+	schedule, err := ParseSchedule("0  8 * * 1-5=2", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := "0 8 * * 1-5"; schedule.Profiles[0].Name != want {
+		t.Fatalf("Name, want: %q, got: %q", want, schedule.Profiles[0].Name)
+	}
+	if schedule.Location != time.UTC {
+		t.Fatalf("Location, want: UTC, got: %s", schedule.Location)
+	}
+}
+
+func Test_ParseSchedule_Invalid(t *testing.T) {
+	cases := map[string][2]string{
+		"no profiles":      {"", ""},
+		"no min replicas":  {"0 8 * * 1-5", ""},
+		"bad min replicas": {"0 8 * * 1-5 = five", ""},
+		"bad cron":         {"0 25 * * 1-5 = 5", ""},
+		"bad time zone":    {"0 8 * * 1-5 = 5", "Mars/Olympus_Mons"},
+	}
+
+	for name, c := range cases {
+		if _, err := ParseSchedule(c[0], c[1]); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func Test_Schedule_Active_InTimezone(t *testing.T) {
+	schedule, _ := ParseSchedule(businessHours, "America/New_York")
+
+	// 13:30 UTC is 08:30 in New York on a Monday
+	profile, ok := schedule.Active(time.Date(2024, 1, 8, 13, 30, 0, 0, time.UTC))
+	if !ok || profile.Name != "business-hours" {
+		t.Fatalf("want business-hours, got: %+v", profile)
+	}
+
+	// 12:30 UTC is 07:30 in New York, the weekend's profile is still active
+	profile, ok = schedule.Active(time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC))
+	if !ok || profile.Name != "off-hours" {
+		t.Fatalf("want off-hours, got: %+v", profile)
+	}
+}
+
+func Test_ScheduleResolver_FunctionTakesPrecedence(t *testing.T) {
+	resolver := NewScheduleResolver(SchedulePolicy{
+		"echo.openfaas-fn": {Schedule: "* * * * * = 3"},
+	}, "UTC")
+	now := time.Now()
+
+	profile, ok, _ := resolver.Resolve("echo", "openfaas-fn", ScheduleSpec{}, now)
+	if !ok || profile.MinReplicas != 3 {
+		t.Fatalf("want the policy's profile, got: %+v", profile)
+	}
+
+	profile, ok, _ = resolver.Resolve("echo", "openfaas-fn", ScheduleSpec{Schedule: "* * * * * = 7"}, now)
+	if !ok || profile.MinReplicas != 7 {
+		t.Fatalf("want the function's profile, got: %+v", profile)
+	}
+
+	if _, ok, _ := resolver.Resolve("other", "openfaas-fn", ScheduleSpec{}, now); ok {
+		t.Fatalf("want no profile for an unscheduled function")
+	}
+}
+
+func Test_ScheduleServiceQuery_RaisesMinReplicas(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 1, MinReplicas: 1, MaxReplicas: 10, Schedule: "peak: * * * * * = 4",
+	}}
+	scheduleQuery := NewScheduleServiceQuery(query, NewScheduleResolver(nil, "UTC"))
+
+	res, err := scheduleQuery.GetReplicas("echo", "openfaas-fn")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.MinReplicas != 4 {
+		t.Fatalf("MinReplicas, want: 4, got: %d", res.MinReplicas)
+	}
+	if res.ScheduleProfile != "peak" || res.ScheduledMinReplicas != 4 {
+		t.Fatalf("want the peak profile with 4 replicas, got: %q, %d", res.ScheduleProfile, res.ScheduledMinReplicas)
+	}
+}
+
+func Test_ScheduleServiceQuery_NeverLowersMinReplicas(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 2, MinReplicas: 2, MaxReplicas: 10, Schedule: "* * * * * = 0",
+	}}
+	scheduleQuery := NewScheduleServiceQuery(query, NewScheduleResolver(nil, "UTC"))
+
+	res, _ := scheduleQuery.GetReplicas("echo", "openfaas-fn")
+	if res.MinReplicas != 2 {
+		t.Fatalf("MinReplicas, want: 2, got: %d", res.MinReplicas)
+	}
+}
+
+func Test_LoadSchedulePolicy(t *testing.T) {
+	file := path.Join(t.TempDir(), "policy.json")
+	os.WriteFile(file, []byte(`{"echo.openfaas-fn": {"schedule": "0 8 * * 1-5 = 5", "timezone": "Europe/Paris"}}`), 0600)
+
+	policy, err := LoadSchedulePolicy(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if policy["echo.openfaas-fn"].Timezone != "Europe/Paris" {
+		t.Fatalf("want the time zone from the file, got: %+v", policy)
+	}
+
+	os.WriteFile(file, []byte(`{"echo.openfaas-fn": {"schedule": "0 8 * * 1-5"}}`), 0600)
+	if _, err := LoadSchedulePolicy(file); err == nil {
+		t.Fatalf("want an error for an invalid schedule")
+	}
+}
+
+func Test_Scheduler_RaisesAndLowersReplicas(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 0, MinReplicas: 1, MaxReplicas: 10, Schedule: businessHours,
+	}}
+	lister := &fakeLister{services: []types.FunctionStatus{
+		{Name: "echo", Namespace: "openfaas-fn", Annotations: &map[string]string{ScheduleLabel: businessHours}},
+	}}
+	scheduler := NewScheduler(SchedulerConfig{ServiceQuery: query, Resolver: NewScheduleResolver(nil, "UTC"), Lister: lister})
+
+	// Monday at 09:00
+	results := scheduler.Reconcile(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC))
+	if len(results) != 1 || results[0].TargetReplicas != 5 {
+		t.Fatalf("want echo scaled to 5, got: %+v", results)
+	}
+
+	// Monday at 18:00, still at the business hours minimum, lowered to the
+	// function's own minimum rather than the profile's 0
+	results = scheduler.Reconcile(time.Date(2024, 1, 8, 18, 0, 0, 0, time.UTC))
+	if results[0].TargetReplicas != 1 {
+		t.Fatalf("want echo scaled to 1, got: %+v", results)
+	}
+	if want := []uint64{5, 1}; len(query.setCalls) != 2 || query.setCalls[0] != want[0] || query.setCalls[1] != want[1] {
+		t.Fatalf("setCalls, want: %v, got: %v", want, query.setCalls)
+	}
+}
+
+func Test_Scheduler_LowersToFunctionMinimum(t *testing.T) {
+	cases := []struct {
+		name        string
+		minReplicas uint64
+		scaleToZero bool
+		want        uint64
+	}{
+		{name: "label minimum above the profile", minReplicas: 3, want: 3},
+		{name: "no minimum without scale to zero", minReplicas: 0, want: 1},
+		{name: "zero with scale to zero", minReplicas: 0, scaleToZero: true, want: 0},
+		{name: "label minimum with scale to zero", minReplicas: 1, scaleToZero: true, want: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := &fakeServiceQuery{response: ServiceQueryResponse{
+				Replicas: 0, MinReplicas: tc.minReplicas, MaxReplicas: 10, ScaleToZero: tc.scaleToZero, Schedule: businessHours,
+			}}
+			scheduler := NewScheduler(SchedulerConfig{ServiceQuery: query, Resolver: NewScheduleResolver(SchedulePolicy{
+				"echo.openfaas-fn": {},
+			}, "UTC")})
+
+			scheduler.Reconcile(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC))
+			results := scheduler.Reconcile(time.Date(2024, 1, 8, 18, 0, 0, 0, time.UTC))
+			if results[0].TargetReplicas != tc.want {
+				t.Fatalf("TargetReplicas, want: %d, got: %d", tc.want, results[0].TargetReplicas)
+			}
+		})
+	}
+}
+
+func Test_Scheduler_LeavesReplicasAddedByAlerts(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 0, MinReplicas: 1, MaxReplicas: 10, Schedule: businessHours,
+	}}
+	scheduler := NewScheduler(SchedulerConfig{
+		ServiceQuery: query,
+		Resolver: NewScheduleResolver(SchedulePolicy{
+			"echo.openfaas-fn": {Schedule: businessHours},
+		}, "UTC"),
+	})
+
+	scheduler.Reconcile(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC))
+
+	// An alert scaled the function above the business hours minimum
+	query.SetReplicas("echo", "openfaas-fn", 8)
+
+	results := scheduler.Reconcile(time.Date(2024, 1, 8, 18, 0, 0, 0, time.UTC))
+	if results[0].TargetReplicas != 8 {
+		t.Fatalf("want replicas left at 8, got: %+v", results)
+	}
+	if len(query.setCalls) != 2 {
+		t.Fatalf("setCalls, want: 2, got: %v", query.setCalls)
+	}
+}
+
+func Test_Scheduler_CappedAtMaxReplicas(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 1, MinReplicas: 1, MaxReplicas: 3, Schedule: "* * * * * = 5",
+	}}
+	scheduler := NewScheduler(SchedulerConfig{ServiceQuery: query, Resolver: NewScheduleResolver(SchedulePolicy{
+		"echo.openfaas-fn": {},
+	}, "UTC")})
+
+	results := scheduler.Reconcile(time.Now())
+	if results[0].TargetReplicas != 3 {
+		t.Fatalf("TargetReplicas, want: 3, got: %d", results[0].TargetReplicas)
+	}
+}
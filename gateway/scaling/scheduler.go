@@ -0,0 +1,204 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchedulerConfig configures the scheduled minimum replica controller
+type SchedulerConfig struct {
+	// Interval between each check of the schedules
+	Interval time.Duration
+
+	// ServiceQuery queries and sets the replicas of a function
+	ServiceQuery ServiceQuery
+
+	// Resolver finds the active profile of each function
+	Resolver *ScheduleResolver
+
+	// Lister finds functions with a schedule in their labels or annotations,
+	// functions in the Resolver's policy are always checked
+	Lister FunctionLister
+
+	// History records each change of replicas, it can be nil
+	History *ScalingHistory
+}
+
+// Scheduler applies the minimum replicas of each function's active
+// ScheduleProfile.
+//
+// Replicas are raised to the profile's minimum whenever they fall below
+// it. When a profile with a lower minimum becomes active, replicas are
+// only lowered if the function is still at the previous profile's minimum,
+// so that replicas added by alerts or the autoscaler are left for them to
+// remove.
+type Scheduler struct {
+	config SchedulerConfig
+
+	lock   sync.Mutex
+	active map[string]ScheduleProfile
+}
+
+// ScheduleResult is the outcome of checking a single function
+type ScheduleResult struct {
+	Name            string
+	Namespace       string
+	Profile         string
+	CurrentReplicas uint64
+	TargetReplicas  uint64
+	Error           error
+}
+
+// NewScheduler creates a Scheduler
+func NewScheduler(config SchedulerConfig) *Scheduler {
+	return &Scheduler{
+		config: config,
+		active: make(map[string]ScheduleProfile),
+	}
+}
+
+// Start checks the schedules on a ticker in a separate goroutine
+func (s *Scheduler) Start() {
+	ticker := time.NewTicker(s.config.Interval)
+
+	go func() {
+		for range ticker.C {
+			s.Reconcile(time.Now())
+		}
+	}()
+}
+
+// Reconcile applies the active profile of every scheduled function
+func (s *Scheduler) Reconcile(now time.Time) []ScheduleResult {
+	candidates := map[string][2]string{}
+
+	for key := range s.config.Resolver.Policy {
+		name, namespace := key, ""
+		if i := strings.Index(key, "."); i >= 0 {
+			name, namespace = key[:i], key[i+1:]
+		}
+		candidates[key] = [2]string{name, namespace}
+	}
+
+	if s.config.Lister != nil {
+		for _, fn := range s.config.Lister.Services() {
+			if !hasSchedule(fn.Labels) && !hasSchedule(fn.Annotations) {
+				continue
+			}
+			candidates[functionLabel(fn.Name, fn.Namespace)] = [2]string{fn.Name, fn.Namespace}
+		}
+	}
+
+	results := []ScheduleResult{}
+	for _, c := range candidates {
+		if res, scheduled := s.reconcile(c[0], c[1], now); scheduled {
+			results = append(results, res)
+		}
+	}
+
+	return results
+}
+
+// scheduledFloor is the fewest replicas a profile may leave a function
+// with, the function's own minimum still applies, and only functions which
+// opted into scale to zero are scheduled down to zero
+func scheduledFloor(queryResponse ServiceQueryResponse, profile ScheduleProfile) uint64 {
+	floor := profile.MinReplicas
+	if queryResponse.MinReplicas > floor {
+		floor = queryResponse.MinReplicas
+	}
+	if floor == 0 && !queryResponse.ScaleToZero {
+		floor = DefaultMinReplicas
+	}
+	return floor
+}
+
+func hasSchedule(values *map[string]string) bool {
+	return values != nil && len((*values)[ScheduleLabel]) > 0
+}
+
+func (s *Scheduler) reconcile(name, namespace string, now time.Time) (ScheduleResult, bool) {
+	key := functionLabel(name, namespace)
+	result := ScheduleResult{Name: name, Namespace: namespace}
+
+	queryResponse, err := s.config.ServiceQuery.GetReplicas(name, namespace)
+	if err != nil {
+		result.Error = err
+		logger.Error("unable to query replicas to schedule", "function", name, "namespace", namespace, "error", err)
+		return result, true
+	}
+
+	spec := ScheduleSpec{Schedule: queryResponse.Schedule, Timezone: queryResponse.ScheduleTimezone}
+	profile, found, err := s.config.Resolver.Resolve(name, namespace, spec, now)
+	if err != nil {
+		result.Error = err
+		logger.Warn("invalid schedule", "function", name, "namespace", namespace, "error", err)
+		return result, true
+	}
+
+	s.lock.Lock()
+	previous, seen := s.active[key]
+	if found {
+		s.active[key] = profile
+	} else {
+		delete(s.active, key)
+	}
+	s.lock.Unlock()
+
+	if !found {
+		return result, false
+	}
+
+	result.Profile = profile.Name
+	result.CurrentReplicas = queryResponse.Replicas
+
+	floor := scheduledFloor(queryResponse, profile)
+
+	target := queryResponse.Replicas
+	if target < floor {
+		target = floor
+	} else if seen && previous.Name != profile.Name &&
+		previous.MinReplicas > profile.MinReplicas &&
+		queryResponse.Replicas == previous.MinReplicas {
+		target = floor
+	}
+
+	if queryResponse.MaxReplicas > 0 && target > queryResponse.MaxReplicas {
+		target = queryResponse.MaxReplicas
+	}
+
+	result.TargetReplicas = target
+	if target == queryResponse.Replicas {
+		return result, true
+	}
+
+	logger.Info("scheduled scaling",
+		"function", name, "namespace", namespace,
+		"profile", profile.Name, "replicas", queryResponse.Replicas, "target_replicas", target)
+
+	if err := s.config.ServiceQuery.SetReplicas(name, namespace, target); err != nil {
+		result.Error = err
+		logger.Error("unable to set scheduled replicas", "function", name, "namespace", namespace, "error", err)
+	}
+
+	s.config.History.Record(ScalingEvent{
+		Time:      now,
+		Function:  name,
+		Namespace: namespace,
+		Trigger:   TriggerSchedule,
+		Inputs: map[string]string{
+			"profile":     profile.Name,
+			"minReplicas": strconv.FormatUint(profile.MinReplicas, 10),
+		},
+		OldReplicas: queryResponse.Replicas,
+		NewReplicas: target,
+		Error:       errorString(result.Error),
+	})
+
+	return result, true
+}
@@ -3,12 +3,47 @@
 
 package scaling
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrFunctionNotFound is wrapped by the error from GetReplicas when the
+// provider does not have the function
+var ErrFunctionNotFound = errors.New("function not found")
+
 // ServiceQuery provides interface for replica querying/setting
 type ServiceQuery interface {
 	GetReplicas(service, namespace string) (response ServiceQueryResponse, err error)
 	SetReplicas(service, namespace string, count uint64) error
 }
 
+// ContextServiceQuery is a ServiceQuery which can query the provider as
+// part of a request, so that the call is traced as part of the request
+type ContextServiceQuery interface {
+	GetReplicasContext(ctx context.Context, service, namespace string) (ServiceQueryResponse, error)
+	SetReplicasContext(ctx context.Context, service, namespace string, count uint64) error
+}
+
+// getReplicasContext uses the ContextServiceQuery method of query, when it
+// has one
+func getReplicasContext(ctx context.Context, query ServiceQuery, service, namespace string) (ServiceQueryResponse, error) {
+	if q, ok := query.(ContextServiceQuery); ok {
+		return q.GetReplicasContext(ctx, service, namespace)
+	}
+	return query.GetReplicas(service, namespace)
+}
+
+// setReplicasContext uses the ContextServiceQuery method of query, when it
+// has one
+func setReplicasContext(ctx context.Context, query ServiceQuery, service, namespace string, count uint64) error {
+	if q, ok := query.(ContextServiceQuery); ok {
+		return q.SetReplicasContext(ctx, service, namespace, count)
+	}
+	return query.SetReplicas(service, namespace, count)
+}
+
 // ServiceQueryResponse response from querying a function status
 type ServiceQueryResponse struct {
 	Replicas          uint64
@@ -17,4 +52,39 @@ type ServiceQueryResponse struct {
 	ScalingFactor     uint64
 	AvailableReplicas uint64
 	Annotations       *map[string]string
+
+	// ScalingType is the metric used by the autoscaler i.e. rps
+	ScalingType string
+
+	// TargetLoad is the target value of ScalingType per replica, or
+	// 0 for the autoscaler's default
+	TargetLoad uint64
+
+	// ScaleToZero is true when the function can be scaled to zero when idle
+	ScaleToZero bool
+
+	// ScaleToZeroDuration is how long the function must be idle, or 0 for
+	// the idler's default
+	ScaleToZeroDuration time.Duration
+
+	// ColdStartTimeout is how long requests are held during a scale up
+	// from zero, or 0 for the scaler's default
+	ColdStartTimeout time.Duration
+
+	// Schedule is the function's own schedule of minimum replica profiles,
+	// see ParseSchedule
+	Schedule string
+
+	// ScheduleTimezone is the time zone the Schedule is evaluated in
+	ScheduleTimezone string
+
+	// ScheduleProfile is the name of the active ScheduleProfile, if any
+	ScheduleProfile string
+
+	// ScheduledMinReplicas is the minimum replicas of the active ScheduleProfile
+	ScheduledMinReplicas uint64
+
+	// Policy limits how quickly alerts change the replicas, zero fields use
+	// the gateway's defaults
+	Policy ScalingPolicy
 }
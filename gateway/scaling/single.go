@@ -1,7 +1,6 @@
 package scaling
 
 import (
-	"log"
 	"sync"
 )
 
@@ -51,7 +50,7 @@ func (s *SingleFlight) Do(key string, f func() (interface{}, error)) (interface{
 	s.lock.Unlock()
 
 	go func() {
-		log.Printf("Miss, so running: %s", key)
+		logger.Debug("single flight miss, running", "key", key)
 		res, err := f()
 
 		s.lock.Lock()
@@ -0,0 +1,365 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ScalingPolicy limits how quickly the replicas of a function change in
+// response to alerts. A zero field uses the gateway's default.
+type ScalingPolicy struct {
+	// ScaleUpWindow is how long a higher recommendation must be sustained
+	// for, the lowest recommendation within the window is used
+	ScaleUpWindow time.Duration
+
+	// ScaleDownWindow is how long a lower recommendation must be sustained
+	// for, the highest recommendation within the window is used
+	ScaleDownWindow time.Duration
+
+	// ScaleUpCooldown is the minimum time between two scale ups
+	ScaleUpCooldown time.Duration
+
+	// ScaleDownCooldown is the minimum time between any change of
+	// replicas and a scale down
+	ScaleDownCooldown time.Duration
+
+	// MaxStepUp is the most replicas added by a single decision
+	MaxStepUp uint64
+
+	// MaxStepDown is the most replicas removed by a single decision
+	MaxStepDown uint64
+}
+
+// WithDefaults returns the policy with each zero field taken from defaults
+func (p ScalingPolicy) WithDefaults(defaults ScalingPolicy) ScalingPolicy {
+	if p.ScaleUpWindow == 0 {
+		p.ScaleUpWindow = defaults.ScaleUpWindow
+	}
+	if p.ScaleDownWindow == 0 {
+		p.ScaleDownWindow = defaults.ScaleDownWindow
+	}
+	if p.ScaleUpCooldown == 0 {
+		p.ScaleUpCooldown = defaults.ScaleUpCooldown
+	}
+	if p.ScaleDownCooldown == 0 {
+		p.ScaleDownCooldown = defaults.ScaleDownCooldown
+	}
+	if p.MaxStepUp == 0 {
+		p.MaxStepUp = defaults.MaxStepUp
+	}
+	if p.MaxStepDown == 0 {
+		p.MaxStepDown = defaults.MaxStepDown
+	}
+	return p
+}
+
+// StabilizerConfig configures the Stabilizer
+type StabilizerConfig struct {
+	// ServiceQuery queries and sets the replicas of a function
+	ServiceQuery ServiceQuery
+
+	// Interval between each check of decisions held back by the policy
+	Interval time.Duration
+
+	// DefaultPolicy is used for any field a function does not set
+	DefaultPolicy ScalingPolicy
+
+	// History records each decision as a TriggerAlert event, it can be nil
+	History *ScalingHistory
+}
+
+// Stabilizer applies the ScalingPolicy of a function to the replica
+// counts recommended for it, so that flapping alerts do not cause the
+// function's replicas to thrash.
+//
+// Decisions for a function are serialized, and a decision held back by a
+// window, cooldown or maximum step is completed later by Start.
+type Stabilizer struct {
+	config StabilizerConfig
+
+	lock      sync.Mutex
+	functions map[string]*stabilizerState
+}
+
+type stabilizerState struct {
+	lock sync.Mutex
+
+	name      string
+	namespace string
+
+	recommendations []recommendation
+	inputs          map[string]string
+	window          time.Duration
+	cooldown        time.Duration
+	lastScaleUp     time.Time
+	lastScale       time.Time
+	pending         bool
+}
+
+// Decision is the outcome of stabilizing a recommendation
+type Decision struct {
+	// Queried is false when the function's replicas could not be queried
+	Queried bool
+
+	CurrentReplicas     uint64
+	RecommendedReplicas uint64
+	TargetReplicas      uint64
+
+	// Pending is true when the target was held back by the policy
+	Pending bool
+
+	// Reason explains why the target differs from the recommendation
+	Reason string
+}
+
+// Reasons for a Decision being held back
+const (
+	ReasonWindow   = "stabilization window"
+	ReasonCooldown = "cooldown"
+	ReasonMaxStep  = "max step"
+)
+
+// NewStabilizer creates a Stabilizer
+func NewStabilizer(config StabilizerConfig) *Stabilizer {
+	return &Stabilizer{
+		config:    config,
+		functions: make(map[string]*stabilizerState),
+	}
+}
+
+// Start completes held back decisions on a ticker in a separate goroutine
+func (s *Stabilizer) Start() {
+	ticker := time.NewTicker(s.config.Interval)
+
+	go func() {
+		for range ticker.C {
+			s.Reconcile(time.Now())
+		}
+	}()
+}
+
+// Reconcile re-evaluates every function with a decision held back by its policy
+func (s *Stabilizer) Reconcile(now time.Time) []Decision {
+	s.lock.Lock()
+	states := make([]*stabilizerState, 0, len(s.functions))
+	for _, state := range s.functions {
+		states = append(states, state)
+	}
+	s.lock.Unlock()
+
+	decisions := []Decision{}
+	for _, state := range states {
+		state.lock.Lock()
+		if state.pending {
+			decision, err := s.scale(state, nil, now)
+			if err != nil {
+				logger.Error("unable to scale", "function", state.name, "namespace", state.namespace, "error", err)
+			}
+			decisions = append(decisions, decision)
+		}
+		idle := !state.pending &&
+			isStale(state.recommendations, state.window, now) &&
+			now.Sub(state.lastScale) >= state.cooldown
+		state.lock.Unlock()
+
+		if idle {
+			s.lock.Lock()
+			delete(s.functions, functionLabel(state.name, state.namespace))
+			s.lock.Unlock()
+		}
+	}
+
+	return decisions
+}
+
+// Scale queries the function, records the replicas recommended for it by
+// recommend, then sets the replicas allowed by its policy. The inputs of
+// the recommendation are kept with the decision in the History.
+func (s *Stabilizer) Scale(name, namespace string, inputs map[string]string, recommend func(ServiceQueryResponse) uint64, now time.Time) (Decision, error) {
+	key := functionLabel(name, namespace)
+
+	s.lock.Lock()
+	state, ok := s.functions[key]
+	if !ok {
+		state = &stabilizerState{name: name, namespace: namespace}
+		s.functions[key] = state
+	}
+	s.lock.Unlock()
+
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	state.inputs = inputs
+	return s.scale(state, recommend, now)
+}
+
+// scale must be called with the state's lock held
+func (s *Stabilizer) scale(state *stabilizerState, recommend func(ServiceQueryResponse) uint64, now time.Time) (Decision, error) {
+	queryResponse, err := s.config.ServiceQuery.GetReplicas(state.name, state.namespace)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	policy := queryResponse.Policy.WithDefaults(s.config.DefaultPolicy)
+
+	state.window = policy.ScaleUpWindow
+	if policy.ScaleDownWindow > state.window {
+		state.window = policy.ScaleDownWindow
+	}
+
+	if recommend != nil {
+		// The first recommendation after a quiet period is compared with
+		// the current replicas, rather than applied at once, so that a
+		// single alert does not bypass the windows when load returns
+		if state.window > 0 && isStale(state.recommendations, state.window, now) {
+			state.recommendations = append(state.recommendations, recommendation{replicas: queryResponse.Replicas, at: now})
+		}
+		state.recommendations = append(state.recommendations, recommendation{replicas: recommend(queryResponse), at: now})
+	}
+	state.recommendations = pruneRecommendations(state.recommendations, state.window, now)
+
+	state.cooldown = policy.ScaleUpCooldown
+	if policy.ScaleDownCooldown > state.cooldown {
+		state.cooldown = policy.ScaleDownCooldown
+	}
+
+	decision := decide(state, queryResponse.Replicas, policy, now)
+	state.pending = decision.Pending
+
+	if decision.TargetReplicas == decision.CurrentReplicas {
+		// Held decisions re-evaluated by Reconcile are not recorded again
+		if recommend != nil {
+			s.record(state, decision, nil, now)
+		}
+		return decision, nil
+	}
+
+	if err := s.config.ServiceQuery.SetReplicas(state.name, state.namespace, decision.TargetReplicas); err != nil {
+		// The decision is retried by Reconcile
+		state.pending = true
+		s.record(state, decision, err, now)
+		return decision, err
+	}
+	s.record(state, decision, nil, now)
+
+	if decision.TargetReplicas > decision.CurrentReplicas {
+		state.lastScaleUp = now
+	}
+	state.lastScale = now
+
+	return decision, nil
+}
+
+func (s *Stabilizer) record(state *stabilizerState, decision Decision, err error, now time.Time) {
+	if s.config.History == nil {
+		return
+	}
+
+	inputs := map[string]string{
+		"recommendedReplicas": strconv.FormatUint(decision.RecommendedReplicas, 10),
+	}
+	for k, v := range state.inputs {
+		inputs[k] = v
+	}
+	if len(decision.Reason) > 0 {
+		inputs["reason"] = decision.Reason
+	}
+	if decision.Pending {
+		inputs["pending"] = "true"
+	}
+
+	event := ScalingEvent{
+		Time:        now,
+		Function:    state.name,
+		Namespace:   state.namespace,
+		Trigger:     TriggerAlert,
+		Inputs:      inputs,
+		OldReplicas: decision.CurrentReplicas,
+		NewReplicas: decision.TargetReplicas,
+	}
+	event.Error = errorString(err)
+	s.config.History.Record(event)
+}
+
+// pruneRecommendations removes recommendations older than window, the
+// latest recommendation is always kept.
+func pruneRecommendations(recommendations []recommendation, window time.Duration, now time.Time) []recommendation {
+	kept := []recommendation{}
+	for i, r := range recommendations {
+		if i == len(recommendations)-1 || now.Sub(r.at) < window {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// isStale is true when no recommendation is within window, so that the
+// state of the function is no longer needed
+func isStale(recommendations []recommendation, window time.Duration, now time.Time) bool {
+	if len(recommendations) == 0 {
+		return true
+	}
+	return now.Sub(recommendations[len(recommendations)-1].at) >= window
+}
+
+func decide(state *stabilizerState, current uint64, policy ScalingPolicy, now time.Time) Decision {
+	decision := Decision{Queried: true, CurrentReplicas: current, TargetReplicas: current}
+	if len(state.recommendations) == 0 {
+		return decision
+	}
+
+	latest := state.recommendations[len(state.recommendations)-1].replicas
+	decision.RecommendedReplicas = latest
+
+	switch {
+	case latest > current:
+		target := latest
+		for _, r := range state.recommendations {
+			if now.Sub(r.at) < policy.ScaleUpWindow && r.replicas < target {
+				target = r.replicas
+			}
+		}
+
+		switch {
+		case target <= current:
+			decision.Reason = ReasonWindow
+		case !state.lastScaleUp.IsZero() && now.Sub(state.lastScaleUp) < policy.ScaleUpCooldown:
+			decision.Reason = ReasonCooldown
+		default:
+			if policy.MaxStepUp > 0 && target-current > policy.MaxStepUp {
+				target = current + policy.MaxStepUp
+				decision.Reason = ReasonMaxStep
+			}
+			decision.TargetReplicas = target
+		}
+
+	case latest < current:
+		target := latest
+		for _, r := range state.recommendations {
+			if now.Sub(r.at) < policy.ScaleDownWindow && r.replicas > target {
+				target = r.replicas
+			}
+		}
+
+		switch {
+		case target >= current:
+			decision.Reason = ReasonWindow
+		case !state.lastScale.IsZero() && now.Sub(state.lastScale) < policy.ScaleDownCooldown:
+			decision.Reason = ReasonCooldown
+		default:
+			if policy.MaxStepDown > 0 && current-target > policy.MaxStepDown {
+				target = current - policy.MaxStepDown
+				decision.Reason = ReasonMaxStep
+			}
+			decision.TargetReplicas = target
+		}
+	}
+
+	decision.Pending = decision.TargetReplicas != latest
+
+	return decision
+}
@@ -0,0 +1,206 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package scaling
+
+import (
+	"testing"
+	"time"
+)
+
+func recommendReplicas(replicas uint64) func(ServiceQueryResponse) uint64 {
+	return func(ServiceQueryResponse) uint64 {
+		return replicas
+	}
+}
+
+func Test_Stabilizer_NoPolicyScalesImmediately(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1, MinReplicas: 1, MaxReplicas: 10}}
+	stabilizer := NewStabilizer(StabilizerConfig{ServiceQuery: query})
+	now := time.Now()
+
+	decision, err := stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(3), now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if decision.TargetReplicas != 3 || decision.Pending {
+		t.Fatalf("want 3 replicas straight away, got: %+v", decision)
+	}
+
+	decision, _ = stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(1), now.Add(time.Second))
+	if decision.TargetReplicas != 1 || decision.Pending {
+		t.Fatalf("want 1 replica straight away, got: %+v", decision)
+	}
+}
+
+func Test_Stabilizer_ScaleDownWindowHoldsFlappingAlert(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1, MinReplicas: 1, MaxReplicas: 10}}
+	stabilizer := NewStabilizer(StabilizerConfig{
+		ServiceQuery:  query,
+		DefaultPolicy: ScalingPolicy{ScaleDownWindow: time.Minute * 5},
+	})
+	now := time.Now()
+
+	stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(3), now)
+
+	// Resolved a minute later
+	decision, _ := stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(1), now.Add(time.Minute))
+	if decision.TargetReplicas != 3 || !decision.Pending || decision.Reason != ReasonWindow {
+		t.Fatalf("want the scale down held by the window, got: %+v", decision)
+	}
+
+	// Still within the window
+	stabilizer.Reconcile(now.Add(time.Minute * 4))
+	if query.response.Replicas != 3 {
+		t.Fatalf("Replicas, want: 3, got: %d", query.response.Replicas)
+	}
+
+	// The window has passed since the firing recommendation
+	decisions := stabilizer.Reconcile(now.Add(time.Minute * 6))
+	if len(decisions) != 1 || decisions[0].TargetReplicas != 1 {
+		t.Fatalf("want the held back scale down completed, got: %+v", decisions)
+	}
+	if query.response.Replicas != 1 {
+		t.Fatalf("Replicas, want: 1, got: %d", query.response.Replicas)
+	}
+}
+
+func Test_Stabilizer_ScaleUpWindowRequiresSustainedRecommendation(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 2, MinReplicas: 1, MaxReplicas: 10}}
+	stabilizer := NewStabilizer(StabilizerConfig{
+		ServiceQuery:  query,
+		DefaultPolicy: ScalingPolicy{ScaleUpWindow: time.Minute},
+	})
+	now := time.Now()
+
+	stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(2), now)
+
+	decision, _ := stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(4), now.Add(time.Second*30))
+	if decision.TargetReplicas != 2 || decision.Reason != ReasonWindow {
+		t.Fatalf("want the scale up held by the window, got: %+v", decision)
+	}
+
+	stabilizer.Reconcile(now.Add(time.Second * 61))
+	if query.response.Replicas != 4 {
+		t.Fatalf("Replicas, want: 4, got: %d", query.response.Replicas)
+	}
+}
+
+func Test_Stabilizer_ScaleUpWindowHoldsFirstAlert(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 2, MinReplicas: 1, MaxReplicas: 10}}
+	stabilizer := NewStabilizer(StabilizerConfig{
+		ServiceQuery:  query,
+		DefaultPolicy: ScalingPolicy{ScaleUpWindow: time.Minute},
+	})
+	now := time.Now()
+
+	decision, _ := stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(4), now)
+	if decision.TargetReplicas != 2 || decision.Reason != ReasonWindow || !decision.Pending {
+		t.Fatalf("want the first alert held by the window, got: %+v", decision)
+	}
+
+	stabilizer.Reconcile(now.Add(time.Second * 30))
+	if query.response.Replicas != 2 {
+		t.Fatalf("Replicas within the window, want: 2, got: %d", query.response.Replicas)
+	}
+
+	stabilizer.Reconcile(now.Add(time.Second * 61))
+	if query.response.Replicas != 4 {
+		t.Fatalf("Replicas, want: 4, got: %d", query.response.Replicas)
+	}
+}
+
+func Test_Stabilizer_ScaleUpWindowHoldsAlertAfterQuietPeriod(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 2, MinReplicas: 1, MaxReplicas: 10}}
+	stabilizer := NewStabilizer(StabilizerConfig{
+		ServiceQuery:  query,
+		DefaultPolicy: ScalingPolicy{ScaleUpWindow: time.Minute},
+	})
+	now := time.Now()
+
+	stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(2), now)
+
+	decision, _ := stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(4), now.Add(time.Minute*5))
+	if decision.TargetReplicas != 2 || decision.Reason != ReasonWindow {
+		t.Fatalf("want the alert after a quiet period held by the window, got: %+v", decision)
+	}
+}
+
+func Test_Stabilizer_ScaleUpCooldown(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1, MinReplicas: 1, MaxReplicas: 10}}
+	stabilizer := NewStabilizer(StabilizerConfig{
+		ServiceQuery:  query,
+		DefaultPolicy: ScalingPolicy{ScaleUpCooldown: time.Minute},
+	})
+	now := time.Now()
+
+	stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(2), now)
+
+	decision, _ := stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(3), now.Add(time.Second*10))
+	if decision.TargetReplicas != 2 || decision.Reason != ReasonCooldown {
+		t.Fatalf("want the scale up held by the cooldown, got: %+v", decision)
+	}
+
+	stabilizer.Reconcile(now.Add(time.Minute * 2))
+	if query.response.Replicas != 3 {
+		t.Fatalf("Replicas, want: 3, got: %d", query.response.Replicas)
+	}
+}
+
+func Test_Stabilizer_MaxStepDownFromFunctionPolicy(t *testing.T) {
+	query := &fakeServiceQuery{response: ServiceQueryResponse{
+		Replicas: 10, MinReplicas: 1, MaxReplicas: 10,
+		Policy: ScalingPolicy{MaxStepDown: 4},
+	}}
+	stabilizer := NewStabilizer(StabilizerConfig{ServiceQuery: query})
+	now := time.Now()
+
+	decision, _ := stabilizer.Scale("echo", "openfaas-fn", nil, recommendReplicas(1), now)
+	if decision.TargetReplicas != 6 || decision.Reason != ReasonMaxStep || !decision.Pending {
+		t.Fatalf("want a step down to 6, got: %+v", decision)
+	}
+
+	stabilizer.Reconcile(now.Add(time.Second * 10))
+	stabilizer.Reconcile(now.Add(time.Second * 20))
+
+	if want := []uint64{6, 2, 1}; len(query.setCalls) != 3 || query.setCalls[1] != want[1] || query.setCalls[2] != want[2] {
+		t.Fatalf("setCalls, want: %v, got: %v", want, query.setCalls)
+	}
+
+	// Settled, so nothing is left to do
+	if decisions := stabilizer.Reconcile(now.Add(time.Second * 30)); len(decisions) != 0 {
+		t.Fatalf("want no pending decisions, got: %+v", decisions)
+	}
+}
+
+func Test_ScalingPolicy_WithDefaults(t *testing.T) {
+	policy := ScalingPolicy{MaxStepUp: 2}.WithDefaults(ScalingPolicy{MaxStepUp: 5, ScaleDownWindow: time.Minute})
+
+	if policy.MaxStepUp != 2 {
+		t.Fatalf("MaxStepUp, want: 2, got: %d", policy.MaxStepUp)
+	}
+	if policy.ScaleDownWindow != time.Minute {
+		t.Fatalf("ScaleDownWindow, want: %s, got: %s", time.Minute, policy.ScaleDownWindow)
+	}
+}
+
+func Test_Stabilizer_RecordsAlertDecisions(t *testing.T) {
+	history, _ := NewScalingHistory(10, "")
+	query := &fakeServiceQuery{response: ServiceQueryResponse{Replicas: 1, MaxReplicas: 10}}
+	stabilizer := NewStabilizer(StabilizerConfig{ServiceQuery: query, History: history})
+
+	stabilizer.Scale("echo", "openfaas-fn", map[string]string{"status": "firing"}, recommendReplicas(3), time.Now())
+
+	events := history.Events("echo", "openfaas-fn")
+	if len(events) != 1 {
+		t.Fatalf("events, want: 1, got: %d", len(events))
+	}
+
+	event := events[0]
+	if event.Trigger != TriggerAlert || event.OldReplicas != 1 || event.NewReplicas != 3 {
+		t.Fatalf("want alert 1 => 3, got: %s %d => %d", event.Trigger, event.OldReplicas, event.NewReplicas)
+	}
+	if event.Inputs["status"] != "firing" || event.Inputs["recommendedReplicas"] != "3" {
+		t.Fatalf("want the alert's inputs, got: %v", event.Inputs)
+	}
+}
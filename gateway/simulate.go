@@ -0,0 +1,164 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/logging"
+	"github.com/openfaas/faas/gateway/scaling"
+	"github.com/openfaas/faas/gateway/simulator"
+)
+
+// simulate runs the "gateway simulate" command, which replays a timeline
+// of invocations against the gateway's scaling and returns the exit code.
+func simulate(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+
+	timelinePath := flags.String("timeline", "", "CSV or JSON file of the RPS of each function over time, - for stdin")
+	format := flags.String("format", "", "Format of the timeline, csv or json. Default: from the file extension")
+	output := flags.String("output", "text", "Output as text, csv for the replicas at each step, or json")
+	verbose := flags.Bool("verbose", false, "Print the log of scaling decisions to stderr")
+
+	config := simulator.Config{}
+	flags.DurationVar(&config.Step, "step", time.Second, "Time between each evaluation of the simulation")
+	flags.DurationVar(&config.Tail, "tail", time.Minute*5, "How long to continue after the last point of the timeline")
+	flags.Uint64Var(&config.MinReplicas, "min", scaling.DefaultMinReplicas, "Min replicas of each function")
+	flags.Uint64Var(&config.MaxReplicas, "max", scaling.DefaultMaxReplicas, "Max replicas of each function")
+	flags.Uint64Var(&config.ScalingFactor, "factor", scaling.DefaultScalingFactor, "Scaling factor of each function")
+	flags.BoolVar(&config.ScaleToZero, "scale-to-zero", false, "Scale functions to zero when idle, functions start from zero")
+	flags.DurationVar(&config.IdleDuration, "idle-duration", time.Minute*15, "How long a function is idle before it is scaled to zero")
+	flags.DurationVar(&config.ColdStart, "cold-start", time.Second*2, "How long new replicas take to become available")
+	flags.Float64Var(&config.AlertThreshold, "alert-threshold", 5, "RPS per replica at which the alert fires")
+	flags.DurationVar(&config.AlertFor, "alert-for", time.Second*5, "How long the threshold is exceeded before the alert fires")
+	flags.DurationVar(&config.AlertRepeat, "alert-repeat", time.Second*30, "Interval between notifications of a firing alert")
+	flags.Float64Var(&config.Capacity, "capacity", 0, "RPS a replica can serve, higher load is a violation. 0 disables")
+	flags.DurationVar(&config.MaxColdStart, "max-cold-start", 0, "Longest acceptable time without available replicas. 0 disables")
+	flags.DurationVar(&config.Policy.ScaleUpWindow, "scale-up-window", 0, "Same as alert_scale_up_window")
+	flags.DurationVar(&config.Policy.ScaleDownWindow, "scale-down-window", 0, "Same as alert_scale_down_window")
+	flags.DurationVar(&config.Policy.ScaleUpCooldown, "scale-up-cooldown", 0, "Same as alert_scale_up_cooldown")
+	flags.DurationVar(&config.Policy.ScaleDownCooldown, "scale-down-cooldown", 0, "Same as alert_scale_down_cooldown")
+	flags.Uint64Var(&config.Policy.MaxStepUp, "max-step-up", 0, "Same as alert_max_step_up")
+	flags.Uint64Var(&config.Policy.MaxStepDown, "max-step-down", 0, "Same as alert_max_step_down")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if len(*timelinePath) == 0 {
+		fmt.Fprintln(os.Stderr, "simulate: -timeline is required")
+		flags.Usage()
+		return 2
+	}
+
+	// The scalers log each decision, which is only wanted with -verbose
+	if !*verbose {
+		logging.Configure(io.Discard, logging.FormatText, slog.LevelInfo)
+	}
+
+	timeline, err := readTimeline(*timelinePath, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: %s\n", err)
+		return 1
+	}
+
+	result, err := simulator.Simulate(config, timeline)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: %s\n", err)
+		return 1
+	}
+
+	switch *output {
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(result)
+	case "csv":
+		err = writeSamples(stdout, result.Samples)
+	case "text":
+		err = writeSummary(stdout, result)
+	default:
+		err = fmt.Errorf("unknown output: %s", *output)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: %s\n", err)
+		return 1
+	}
+
+	if len(result.Violations) > 0 {
+		return 3
+	}
+	return 0
+}
+
+func readTimeline(path, format string) ([]simulator.Point, error) {
+	if len(format) == 0 {
+		format = simulator.FormatCSV
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			format = simulator.FormatJSON
+		}
+	}
+
+	if path == "-" {
+		return simulator.ReadTimeline(os.Stdin, format)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return simulator.ReadTimeline(file, format)
+}
+
+func writeSamples(w io.Writer, samples []simulator.Sample) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"time", "function", "rps", "replicas", "available_replicas", "alert"})
+
+	for _, s := range samples {
+		writer.Write([]string{
+			strconv.FormatFloat(time.Duration(s.Time).Seconds(), 'f', -1, 64),
+			s.Function,
+			strconv.FormatFloat(s.RPS, 'f', -1, 64),
+			strconv.FormatUint(s.Replicas, 10),
+			strconv.FormatUint(s.AvailableReplicas, 10),
+			s.Alert,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeSummary(w io.Writer, result simulator.Result) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "FUNCTION\tMAX REPLICAS\tREPLICA SECONDS\tSCALE UPS\tSCALE DOWNS\tCOLD STARTS\tCOLD TIME\tCOLD REQUESTS\tVIOLATIONS")
+	for _, s := range result.Summaries {
+		fmt.Fprintf(writer, "%s\t%d\t%.0f\t%d\t%d\t%d\t%s\t%.0f\t%d\n",
+			s.Function, s.MaxReplicas, s.ReplicaSeconds, s.ScaleUps, s.ScaleDowns, s.ColdStarts, s.ColdTime, s.ColdRequests, s.Violations)
+	}
+
+	if len(result.Violations) > 0 {
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "FUNCTION\tVIOLATION\tSTART\tEND")
+		for _, v := range result.Violations {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", v.Function, v.Kind, v.Start, v.End)
+		}
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package simulator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// simulatedQuery is a ServiceQuery for the functions of a simulation, new
+// replicas become available after the ColdStart of the Config
+type simulatedQuery struct {
+	config    Config
+	now       time.Time
+	functions map[string]*simulatedFunction
+}
+
+type simulatedFunction struct {
+	replicas  uint64
+	available uint64
+
+	// starting are the replica counts waiting to become available
+	starting []startingReplicas
+
+	scaleUps   int
+	scaleDowns int
+}
+
+type startingReplicas struct {
+	replicas uint64
+	readyAt  time.Time
+}
+
+func newSimulatedQuery(config Config) *simulatedQuery {
+	return &simulatedQuery{
+		config:    config,
+		functions: make(map[string]*simulatedFunction),
+	}
+}
+
+func (q *simulatedQuery) add(name string, replicas uint64) {
+	q.functions[name] = &simulatedFunction{replicas: replicas, available: replicas}
+}
+
+func (q *simulatedQuery) GetReplicas(service, namespace string) (scaling.ServiceQueryResponse, error) {
+	fn, ok := q.functions[service]
+	if !ok {
+		return scaling.ServiceQueryResponse{}, fmt.Errorf("%w: %s", scaling.ErrFunctionNotFound, service)
+	}
+
+	return scaling.ServiceQueryResponse{
+		Replicas:          fn.replicas,
+		MaxReplicas:       q.config.MaxReplicas,
+		MinReplicas:       q.config.MinReplicas,
+		ScalingFactor:     q.config.ScalingFactor,
+		AvailableReplicas: fn.available,
+		ScaleToZero:       q.config.ScaleToZero,
+	}, nil
+}
+
+func (q *simulatedQuery) SetReplicas(service, namespace string, count uint64) error {
+	fn, ok := q.functions[service]
+	if !ok {
+		return fmt.Errorf("%w: %s", scaling.ErrFunctionNotFound, service)
+	}
+
+	switch {
+	case count > fn.replicas:
+		fn.scaleUps++
+		fn.starting = append(fn.starting, startingReplicas{replicas: count, readyAt: q.now.Add(q.config.ColdStart)})
+	case count < fn.replicas:
+		fn.scaleDowns++
+
+		starting := []startingReplicas{}
+		for _, s := range fn.starting {
+			if s.replicas <= count {
+				starting = append(starting, s)
+			}
+		}
+		fn.starting = starting
+		if fn.available > count {
+			fn.available = count
+		}
+	}
+	fn.replicas = count
+
+	q.advance(fn)
+	return nil
+}
+
+// advance makes the replicas which have started by now available
+func (q *simulatedQuery) advance(fn *simulatedFunction) {
+	starting := []startingReplicas{}
+	for _, s := range fn.starting {
+		if q.now.Before(s.readyAt) {
+			starting = append(starting, s)
+			continue
+		}
+		if s.replicas > fn.available {
+			fn.available = s.replicas
+		}
+	}
+	fn.starting = starting
+}
+
+// setTime moves the simulation to now
+func (q *simulatedQuery) setTime(now time.Time) {
+	q.now = now
+	for _, fn := range q.functions {
+		q.advance(fn)
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+// Package simulator replays a timeline of invocations against the gateway's
+// scaling, so that scaling settings can be tuned without production traffic.
+package simulator
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/openfaas/faas/gateway/handlers"
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+// Kinds of Violation
+const (
+	// ViolationOverloaded is load above the Capacity of the available replicas
+	ViolationOverloaded = "overloaded"
+
+	// ViolationColdStart is a function without available replicas for
+	// longer than the MaxColdStart
+	ViolationColdStart = "cold_start"
+)
+
+// Config is the scaling of every function in a simulation
+type Config struct {
+	// Step is the time between each evaluation of the simulation
+	Step time.Duration
+
+	// Tail is how long the simulation runs for after the last point of the
+	// timeline, so that scaling down can be seen
+	Tail time.Duration
+
+	MinReplicas   uint64
+	MaxReplicas   uint64
+	ScalingFactor uint64
+
+	// Policy limits how quickly alerts change the replicas
+	Policy scaling.ScalingPolicy
+
+	// ScaleToZero scales functions to zero after IdleDuration without
+	// invocations. Functions start from zero replicas when it is set,
+	// otherwise from MinReplicas.
+	ScaleToZero  bool
+	IdleDuration time.Duration
+
+	// ColdStart is how long new replicas take to become available
+	ColdStart time.Duration
+
+	// AlertThreshold is the RPS per replica at which the alert fires
+	AlertThreshold float64
+
+	// AlertFor is how long the threshold is exceeded for before the alert
+	// fires
+	AlertFor time.Duration
+
+	// AlertRepeat is the interval between notifications of a firing alert
+	AlertRepeat time.Duration
+
+	// Capacity is the RPS a replica can serve, 0 disables ViolationOverloaded
+	Capacity float64
+
+	// MaxColdStart is the longest acceptable time without available
+	// replicas, 0 disables ViolationColdStart
+	MaxColdStart time.Duration
+}
+
+// Sample is the state of a function at a step of the simulation
+type Sample struct {
+	Time              Offset  `json:"time"`
+	Function          string  `json:"function"`
+	RPS               float64 `json:"rps"`
+	Replicas          uint64  `json:"replicas"`
+	AvailableReplicas uint64  `json:"availableReplicas"`
+	Alert             string  `json:"alert,omitempty"`
+}
+
+// Violation is a period of time in which a function did not meet the
+// Capacity or MaxColdStart of the Config
+type Violation struct {
+	Function string `json:"function"`
+	Kind     string `json:"kind"`
+	Start    Offset `json:"start"`
+	End      Offset `json:"end"`
+}
+
+// Summary of a function over the whole simulation
+type Summary struct {
+	Function string `json:"function"`
+
+	// ColdTime is the time with invocations but no available replicas
+	ColdTime Offset `json:"coldTime"`
+
+	// ColdStarts is the number of scale ups from zero
+	ColdStarts int `json:"coldStarts"`
+
+	// ColdRequests is the number of requests which arrived during ColdTime
+	ColdRequests float64 `json:"coldRequests"`
+
+	MaxReplicas uint64 `json:"maxReplicas"`
+
+	// ReplicaSeconds is the cost of the replicas over the simulation
+	ReplicaSeconds float64 `json:"replicaSeconds"`
+
+	ScaleUps   int `json:"scaleUps"`
+	ScaleDowns int `json:"scaleDowns"`
+
+	Violations int `json:"violations"`
+}
+
+// Result of a simulation
+type Result struct {
+	Samples    []Sample    `json:"samples"`
+	Summaries  []Summary   `json:"summaries"`
+	Violations []Violation `json:"violations"`
+}
+
+// function is the state of a function during a simulation
+type function struct {
+	name string
+	rps  float64
+
+	// points are the indexes of the function's points in the timeline,
+	// next is the first which has not been reached
+	points []int
+	next   int
+
+	// alertSince is when the threshold was first exceeded, or zero
+	alertSince time.Time
+	firing     bool
+	lastNotify time.Time
+
+	// cold is a period without available replicas, and overloaded a
+	// period above Capacity, in progress
+	cold       *Violation
+	overloaded *Violation
+
+	summary Summary
+}
+
+// Simulate replays the timeline against the alert scaling of the gateway,
+// its ScalingPolicy, scale to zero and scale from zero, with a simulated
+// provider.
+func Simulate(config Config, timeline []Point) (Result, error) {
+	if config.Step <= 0 {
+		return Result{}, fmt.Errorf("step must be greater than zero")
+	}
+	if config.MaxReplicas < config.MinReplicas {
+		return Result{}, fmt.Errorf("max replicas %d is less than min replicas %d", config.MaxReplicas, config.MinReplicas)
+	}
+
+	result := Result{Samples: []Sample{}, Violations: []Violation{}, Summaries: []Summary{}}
+	if len(timeline) == 0 {
+		return result, nil
+	}
+
+	query := newSimulatedQuery(config)
+	tracker := scaling.NewInvocationTracker("")
+	stabilizer := scaling.NewStabilizer(scaling.StabilizerConfig{
+		ServiceQuery:  query,
+		DefaultPolicy: config.Policy,
+	})
+	idler := scaling.NewIdler(scaling.IdlerConfig{
+		ServiceQuery:        query,
+		DefaultIdleDuration: config.IdleDuration,
+	}, tracker)
+
+	initialReplicas := config.MinReplicas
+	if config.ScaleToZero {
+		initialReplicas = 0
+	}
+
+	functions := []*function{}
+	byName := map[string]*function{}
+	for i, point := range timeline {
+		fn, ok := byName[point.Function]
+		if !ok {
+			fn = &function{name: point.Function, summary: Summary{Function: point.Function}}
+			byName[point.Function] = fn
+			functions = append(functions, fn)
+			query.add(fn.name, initialReplicas)
+		}
+		fn.points = append(fn.points, i)
+	}
+
+	// The idler only considers invocations after it was created
+	start := time.Now()
+	end := time.Duration(timeline[len(timeline)-1].Time) + config.Tail
+
+	for offset := time.Duration(0); offset <= end; offset += config.Step {
+		now := start.Add(offset)
+		query.setTime(now)
+
+		for _, fn := range functions {
+			for fn.next < len(fn.points) && time.Duration(timeline[fn.points[fn.next]].Time) <= offset {
+				fn.rps = timeline[fn.points[fn.next]].RPS
+				fn.next++
+			}
+
+			if fn.rps > 0 {
+				tracker.Touch(fn.name, "", now)
+				scaleFromZero(query, fn)
+			}
+
+			evaluateAlert(config, stabilizer, query, fn, now)
+		}
+
+		stabilizer.Reconcile(now)
+		if config.ScaleToZero {
+			idler.Reconcile(now)
+		}
+		query.setTime(now)
+
+		for _, fn := range functions {
+			sample := observe(config, query, fn, Offset(offset), &result)
+			result.Samples = append(result.Samples, sample)
+		}
+	}
+
+	for _, fn := range functions {
+		closeViolations(config, fn, Offset(end+config.Step), &result)
+
+		state := query.functions[fn.name]
+		fn.summary.ScaleUps = state.scaleUps
+		fn.summary.ScaleDowns = state.scaleDowns
+		result.Summaries = append(result.Summaries, fn.summary)
+	}
+
+	return result, nil
+}
+
+// scaleFromZero sets the min replicas of a function invoked at zero
+// replicas, as the gateway does when scale from zero is enabled
+func scaleFromZero(query *simulatedQuery, fn *function) {
+	if query.functions[fn.name].replicas > 0 {
+		return
+	}
+
+	replicas := query.config.MinReplicas
+	if replicas == 0 {
+		replicas = 1
+	}
+	query.SetReplicas(fn.name, "", replicas)
+	fn.summary.ColdStarts++
+}
+
+// evaluateAlert fires the alert when the RPS per replica has exceeded the
+// AlertThreshold for AlertFor, and notifies the stabilizer as Alertmanager
+// would notify the alert handler
+func evaluateAlert(config Config, stabilizer *scaling.Stabilizer, query *simulatedQuery, fn *function, now time.Time) {
+	replicas := query.functions[fn.name].replicas
+	if replicas == 0 {
+		replicas = 1
+	}
+	exceeded := config.AlertThreshold > 0 && fn.rps/float64(replicas) >= config.AlertThreshold
+
+	switch {
+	case exceeded:
+		if fn.alertSince.IsZero() {
+			fn.alertSince = now
+		}
+		if !fn.firing && now.Sub(fn.alertSince) >= config.AlertFor {
+			fn.firing = true
+			notify(stabilizer, fn, "firing", now)
+		} else if fn.firing && config.AlertRepeat > 0 && now.Sub(fn.lastNotify) >= config.AlertRepeat {
+			notify(stabilizer, fn, "firing", now)
+		}
+	default:
+		fn.alertSince = time.Time{}
+		if fn.firing {
+			fn.firing = false
+			notify(stabilizer, fn, "resolved", now)
+		}
+	}
+}
+
+func notify(stabilizer *scaling.Stabilizer, fn *function, status string, now time.Time) {
+	fn.lastNotify = now
+
+	inputs := map[string]string{
+		"status": status,
+		"rps":    strconv.FormatFloat(fn.rps, 'f', -1, 64),
+	}
+	stabilizer.Scale(fn.name, "", inputs, func(queryResponse scaling.ServiceQueryResponse) uint64 {
+		return handlers.CalculateReplicas(status, queryResponse.Replicas, queryResponse.MaxReplicas, queryResponse.MinReplicas, queryResponse.ScalingFactor)
+	}, now)
+}
+
+// observe samples the function and updates its summary and violations
+func observe(config Config, query *simulatedQuery, fn *function, offset Offset, result *Result) Sample {
+	state := query.functions[fn.name]
+	step := config.Step.Seconds()
+
+	sample := Sample{
+		Time:              offset,
+		Function:          fn.name,
+		RPS:               fn.rps,
+		Replicas:          state.replicas,
+		AvailableReplicas: state.available,
+	}
+	if fn.firing {
+		sample.Alert = "firing"
+	}
+
+	fn.summary.ReplicaSeconds += float64(state.replicas) * step
+	if state.replicas > fn.summary.MaxReplicas {
+		fn.summary.MaxReplicas = state.replicas
+	}
+
+	cold := fn.rps > 0 && state.available == 0
+	if cold {
+		fn.summary.ColdTime += Offset(config.Step)
+		fn.summary.ColdRequests += fn.rps * step
+		if fn.cold == nil {
+			fn.cold = &Violation{Function: fn.name, Kind: ViolationColdStart, Start: offset}
+		}
+	} else if fn.cold != nil {
+		closeColdStart(config, fn, offset, result)
+	}
+
+	overloaded := config.Capacity > 0 && state.available > 0 && fn.rps > float64(state.available)*config.Capacity
+	if overloaded && fn.overloaded == nil {
+		fn.overloaded = &Violation{Function: fn.name, Kind: ViolationOverloaded, Start: offset}
+	} else if !overloaded && fn.overloaded != nil {
+		closeOverloaded(fn, offset, result)
+	}
+
+	return sample
+}
+
+func closeColdStart(config Config, fn *function, offset Offset, result *Result) {
+	fn.cold.End = offset
+	if config.MaxColdStart > 0 && time.Duration(fn.cold.End-fn.cold.Start) > config.MaxColdStart {
+		result.Violations = append(result.Violations, *fn.cold)
+		fn.summary.Violations++
+	}
+	fn.cold = nil
+}
+
+func closeOverloaded(fn *function, offset Offset, result *Result) {
+	fn.overloaded.End = offset
+	result.Violations = append(result.Violations, *fn.overloaded)
+	fn.overloaded = nil
+	fn.summary.Violations++
+}
+
+// closeViolations ends the violations still in progress at the end of the
+// simulation
+func closeViolations(config Config, fn *function, offset Offset, result *Result) {
+	if fn.cold != nil {
+		closeColdStart(config, fn, offset, result)
+	}
+	if fn.overloaded != nil {
+		closeOverloaded(fn, offset, result)
+	}
+}
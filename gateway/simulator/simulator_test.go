@@ -0,0 +1,185 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package simulator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas/gateway/scaling"
+)
+
+func testConfig() Config {
+	return Config{
+		Step:           time.Second,
+		Tail:           time.Minute,
+		MinReplicas:    1,
+		MaxReplicas:    10,
+		ScalingFactor:  20,
+		AlertThreshold: 5,
+		AlertFor:       time.Second * 5,
+		AlertRepeat:    time.Second * 30,
+	}
+}
+
+func maxReplicas(samples []Sample, function string) uint64 {
+	var max uint64
+	for _, s := range samples {
+		if s.Function == function && s.Replicas > max {
+			max = s.Replicas
+		}
+	}
+	return max
+}
+
+func Test_ReadTimeline_CSV(t *testing.T) {
+	input := `time,function,rps
+1m,echo,10
+0,echo,1.5
+30,figlet,2`
+
+	points, err := ReadTimeline(strings.NewReader(input), FormatCSV)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(points) != 3 {
+		t.Fatalf("points, want: 3, got: %d", len(points))
+	}
+	if points[0].RPS != 1.5 || points[1].Function != "figlet" || time.Duration(points[2].Time) != time.Minute {
+		t.Fatalf("want points in order of time, got: %v", points)
+	}
+}
+
+func Test_ReadTimeline_JSON(t *testing.T) {
+	input := `[{"time": "90s", "function": "echo", "rps": 3}, {"time": 10, "function": "echo", "rps": 1}]`
+
+	points, err := ReadTimeline(strings.NewReader(input), FormatJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if time.Duration(points[0].Time) != time.Second*10 || time.Duration(points[1].Time) != time.Second*90 {
+		t.Fatalf("want times of 10s and 90s, got: %s and %s", points[0].Time, points[1].Time)
+	}
+}
+
+func Test_ReadTimeline_Invalid(t *testing.T) {
+	inputs := []string{
+		"time,function\n0,echo",
+		"time,function,rps\n-1,echo,1",
+		"time,function,rps\n0,echo,fast",
+		"time,function,rps\n0,,1",
+	}
+
+	for _, input := range inputs {
+		if _, err := ReadTimeline(strings.NewReader(input), FormatCSV); err == nil {
+			t.Errorf("want an error for: %q", input)
+		}
+	}
+}
+
+func Test_Simulate_AlertScalesUpAndDown(t *testing.T) {
+	timeline := []Point{
+		{Time: 0, Function: "echo", RPS: 1},
+		{Time: Offset(time.Second * 10), Function: "echo", RPS: 50},
+		{Time: Offset(time.Minute * 3), Function: "echo", RPS: 1},
+	}
+
+	result, err := Simulate(testConfig(), timeline)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := maxReplicas(result.Samples, "echo"); got != 10 {
+		t.Fatalf("max replicas, want: 10, got: %d", got)
+	}
+
+	last := result.Samples[len(result.Samples)-1]
+	if last.Replicas != 1 {
+		t.Fatalf("want the alert to resolve to 1 replica, got: %d", last.Replicas)
+	}
+
+	summary := result.Summaries[0]
+	if summary.ScaleDowns != 1 || summary.ColdStarts != 0 {
+		t.Fatalf("want one scale down without cold starts, got: %+v", summary)
+	}
+}
+
+func Test_Simulate_PolicyLimitsSteps(t *testing.T) {
+	config := testConfig()
+	config.Policy = scaling.ScalingPolicy{MaxStepUp: 1, ScaleUpCooldown: time.Second * 20}
+
+	timeline := []Point{{Time: 0, Function: "echo", RPS: 50}}
+	config.Tail = time.Second * 40
+
+	result, err := Simulate(config, timeline)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// One replica is added at 5s, and the rest of the recommendation at 25s
+	// once the cooldown has passed
+	if got := maxReplicas(result.Samples, "echo"); got != 3 {
+		t.Fatalf("max replicas, want: 3, got: %d", got)
+	}
+}
+
+func Test_Simulate_ColdStartViolation(t *testing.T) {
+	config := testConfig()
+	config.ScaleToZero = true
+	config.IdleDuration = time.Minute
+	config.ColdStart = time.Second * 3
+	config.MaxColdStart = time.Second * 2
+
+	timeline := []Point{
+		{Time: Offset(time.Second * 10), Function: "echo", RPS: 1},
+		{Time: Offset(time.Second * 20), Function: "echo", RPS: 0},
+	}
+
+	result, err := Simulate(config, timeline)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	summary := result.Summaries[0]
+	if summary.ColdStarts != 1 || time.Duration(summary.ColdTime) != time.Second*3 || summary.ColdRequests != 3 {
+		t.Fatalf("want one cold start of 3s, got: %+v", summary)
+	}
+
+	if len(result.Violations) != 1 || result.Violations[0].Kind != ViolationColdStart {
+		t.Fatalf("want a cold start violation, got: %v", result.Violations)
+	}
+
+	last := result.Samples[len(result.Samples)-1]
+	if last.Replicas != 0 {
+		t.Fatalf("want echo to be scaled to zero once idle, got: %d replicas", last.Replicas)
+	}
+}
+
+func Test_Simulate_OverloadedViolation(t *testing.T) {
+	config := testConfig()
+	config.Capacity = 10
+	config.MaxReplicas = 2
+
+	timeline := []Point{
+		{Time: 0, Function: "echo", RPS: 30},
+		{Time: Offset(time.Minute), Function: "echo", RPS: 0},
+	}
+
+	result, err := Simulate(config, timeline)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(result.Violations) != 1 {
+		t.Fatalf("violations, want: 1, got: %v", result.Violations)
+	}
+
+	violation := result.Violations[0]
+	if violation.Kind != ViolationOverloaded || violation.Start != 0 || time.Duration(violation.End) != time.Minute {
+		t.Fatalf("want echo overloaded from 0s to 1m, got: %+v", violation)
+	}
+}
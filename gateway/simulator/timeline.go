@@ -0,0 +1,158 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package simulator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timeline formats read by ReadTimeline
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Point is the invocation rate of a function from Time until the next
+// Point for the same function
+type Point struct {
+	Time     Offset  `json:"time"`
+	Function string  `json:"function"`
+	RPS      float64 `json:"rps"`
+}
+
+// Offset is a time from the start of a simulation. It is read from a
+// number of seconds or a duration such as "1m30s", and written as a
+// duration.
+type Offset time.Duration
+
+// UnmarshalJSON reads the offset as either a number or a string
+func (o *Offset) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return err
+		}
+		value = number.String()
+	}
+
+	offset, err := parseOffset(value)
+	if err != nil {
+		return err
+	}
+	*o = Offset(offset)
+	return nil
+}
+
+// MarshalJSON writes the offset as a duration
+func (o Offset) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+func (o Offset) String() string {
+	return time.Duration(o).String()
+}
+
+func parseOffset(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("negative time: %s", value)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	offset, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s", value)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative time: %s", value)
+	}
+	return offset, nil
+}
+
+// ReadTimeline reads a timeline in FormatCSV, with a header of
+// "time,function,rps", or FormatJSON, as a list of Points. The points are
+// returned in order of time.
+func ReadTimeline(reader io.Reader, format string) ([]Point, error) {
+	var points []Point
+
+	switch format {
+	case FormatJSON:
+		if err := json.NewDecoder(reader).Decode(&points); err != nil {
+			return nil, fmt.Errorf("unable to read timeline: %w", err)
+		}
+	case FormatCSV:
+		var err error
+		if points, err = readCSV(reader); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown timeline format: %s", format)
+	}
+
+	for i, point := range points {
+		if len(point.Function) == 0 {
+			return nil, fmt.Errorf("point %d has no function", i+1)
+		}
+		if point.RPS < 0 {
+			return nil, fmt.Errorf("point %d has a negative rps", i+1)
+		}
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Time < points[j].Time
+	})
+
+	return points, nil
+}
+
+func readCSV(reader io.Reader) ([]Point, error) {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read timeline: %w", err)
+	}
+	if len(records) == 0 {
+		return []Point{}, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"time", "function", "rps"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("timeline has no %s column", name)
+		}
+	}
+
+	points := make([]Point, 0, len(records)-1)
+	for i, record := range records[1:] {
+		offset, err := parseOffset(record[columns["time"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+
+		rps, err := strconv.ParseFloat(strings.TrimSpace(record[columns["rps"]]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rps: %s", i+2, record[columns["rps"]])
+		}
+
+		points = append(points, Point{
+			Time:     Offset(offset),
+			Function: strings.TrimSpace(record[columns["function"]]),
+			RPS:      rps,
+		})
+	}
+
+	return points, nil
+}
@@ -0,0 +1,53 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package stats
+
+import (
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+)
+
+// InvocationCounts returns the invocations recorded for each function since
+// the gateway started, so that the Store can be used as a
+// metrics.FunctionMetricsQuery
+func (s *Store) InvocationCounts(functions []string) (map[string]float64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	res := make(map[string]float64, len(functions))
+	for _, key := range functions {
+		if function, ok := s.functions[key]; ok {
+			res[key] = float64(function.total)
+		}
+	}
+	return res, nil
+}
+
+// WindowMetrics returns the rates and latencies of each recorded function
+// over window
+func (s *Store) WindowMetrics(functions []string, window time.Duration) (map[string]metrics.FunctionMetrics, error) {
+	now := s.now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	res := make(map[string]metrics.FunctionMetrics, len(functions))
+	for _, key := range functions {
+		function, ok := s.functions[key]
+		if !ok {
+			continue
+		}
+
+		w := function.window(now, window)
+		res[key] = metrics.FunctionMetrics{
+			RequestRate: w.RequestRate,
+			ErrorRate:   w.ErrorRate,
+			LatencyP50:  w.LatencyP50,
+			LatencyP95:  w.LatencyP95,
+			LatencyP99:  w.LatencyP99,
+		}
+	}
+	return res, nil
+}
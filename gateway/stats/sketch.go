@@ -0,0 +1,94 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// sketchAccuracy is the relative error of a quantile read from a sketch
+const sketchAccuracy = 0.01
+
+// sketchMinValue is the shortest duration in seconds with its own bucket,
+// shorter durations are counted as zero
+const sketchMinValue = 1e-6
+
+var (
+	sketchGamma    = (1 + sketchAccuracy) / (1 - sketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// sketch estimates the quantiles of the durations added to it with
+// logarithmic buckets, so that a quantile is within sketchAccuracy of the
+// true value and sketches can be merged.
+type sketch struct {
+	buckets map[int]uint64
+	zero    uint64
+	count   uint64
+}
+
+// add records a duration in seconds
+func (s *sketch) add(seconds float64) {
+	s.count++
+	if seconds <= sketchMinValue {
+		s.zero++
+		return
+	}
+
+	if s.buckets == nil {
+		s.buckets = make(map[int]uint64)
+	}
+	s.buckets[int(math.Ceil(math.Log(seconds)/sketchLogGamma))]++
+}
+
+// merge adds the durations of o to s
+func (s *sketch) merge(o *sketch) {
+	if o.count == 0 {
+		return
+	}
+	if s.buckets == nil {
+		s.buckets = make(map[int]uint64, len(o.buckets))
+	}
+
+	s.count += o.count
+	s.zero += o.zero
+	for index, count := range o.buckets {
+		s.buckets[index] += count
+	}
+}
+
+// quantile returns the estimated q-quantile in seconds, false is returned
+// when the sketch is empty
+func (s *sketch) quantile(q float64) (float64, bool) {
+	if s.count == 0 {
+		return 0, false
+	}
+
+	// The nearest rank, so that a high quantile of a few durations is
+	// the longest of them
+	rank := uint64(math.Ceil(q*float64(s.count))) - 1
+	if q <= 0 {
+		rank = 0
+	}
+	if rank < s.zero {
+		return 0, true
+	}
+
+	indexes := make([]int, 0, len(s.buckets))
+	for index := range s.buckets {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	seen := s.zero
+	for _, index := range indexes {
+		seen += s.buckets[index]
+		if seen > rank {
+			return 2 * math.Pow(sketchGamma, float64(index)) / (sketchGamma + 1), true
+		}
+	}
+
+	return 2 * math.Pow(sketchGamma, float64(indexes[len(indexes)-1])) / (sketchGamma + 1), true
+}
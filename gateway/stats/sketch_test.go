@@ -0,0 +1,55 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_sketch_QuantilesWithinAccuracy(t *testing.T) {
+	s := sketch{}
+	for i := 1; i <= 1000; i++ {
+		s.add(float64(i) / 1000)
+	}
+
+	for _, tc := range []struct {
+		quantile float64
+		want     float64
+	}{
+		{0.5, 0.5},
+		{0.95, 0.95},
+		{0.99, 0.99},
+	} {
+		got, ok := s.quantile(tc.quantile)
+		if !ok {
+			t.Fatalf("want a value for %v", tc.quantile)
+		}
+		if math.Abs(got-tc.want)/tc.want > sketchAccuracy+0.001 {
+			t.Fatalf("quantile %v, want: %v, got: %v", tc.quantile, tc.want, got)
+		}
+	}
+}
+
+func Test_sketch_MergeAndZero(t *testing.T) {
+	a, b := sketch{}, sketch{}
+	a.add(0)
+	a.add(0)
+	b.add(2)
+
+	a.merge(&b)
+	if a.count != 3 {
+		t.Fatalf("count, want: 3, got: %d", a.count)
+	}
+	if got, _ := a.quantile(0.5); got != 0 {
+		t.Fatalf("median, want: 0, got: %v", got)
+	}
+	if got, _ := a.quantile(1); math.Abs(got-2)/2 > sketchAccuracy {
+		t.Fatalf("max, want: 2, got: %v", got)
+	}
+
+	if _, ok := (&sketch{}).quantile(0.5); ok {
+		t.Fatalf("want no value for an empty sketch")
+	}
+}
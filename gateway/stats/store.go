@@ -0,0 +1,325 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+// Package stats keeps rolling invocation statistics in memory, for gateways
+// which run without Prometheus.
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/logging"
+	"github.com/openfaas/faas/gateway/pkg/middleware"
+	"github.com/openfaas/faas/gateway/types"
+)
+
+// slotCount is the number of one minute slots kept for each function,
+// enough for the longest window
+const slotCount = 24 * 60
+
+var logger = logging.For(logging.ComponentExporter)
+
+// Windows are the ranges reported by /system/stats
+var Windows = []time.Duration{time.Minute, time.Minute * 5, time.Hour, time.Hour * 24}
+
+// WindowStats are the invocations of a function over a window
+type WindowStats struct {
+	Window string `json:"window"`
+
+	Invocations uint64 `json:"invocations"`
+	Errors      uint64 `json:"errors"`
+
+	// RequestRate is the invocations per second
+	RequestRate float64 `json:"requestRate"`
+
+	// ErrorRate is the fraction of invocations which returned a 5xx status
+	ErrorRate float64 `json:"errorRate"`
+
+	// Latency quantiles in seconds, omitted when there were no invocations
+	LatencyP50 *float64 `json:"latencyP50Seconds,omitempty"`
+	LatencyP95 *float64 `json:"latencyP95Seconds,omitempty"`
+	LatencyP99 *float64 `json:"latencyP99Seconds,omitempty"`
+}
+
+// FunctionStats are the statistics of a function over a single window
+type FunctionStats struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	WindowStats
+}
+
+// slot holds the invocations completed within one minute
+type slot struct {
+	minute  int64
+	count   uint64
+	errors  uint64
+	latency sketch
+}
+
+type functionStats struct {
+	name      string
+	namespace string
+
+	// total is the number of invocations since the gateway started
+	total uint64
+
+	last  time.Time
+	slots [slotCount]*slot
+}
+
+// FunctionChecker reports whether a function is deployed, serviceName is
+// in the form name.namespace
+type FunctionChecker interface {
+	FunctionExists(serviceName string) bool
+}
+
+// Store records the invocations of each function from the gateway's own
+// proxy events, with one minute resolution for up to 24 hours. It
+// implements the same Notify method as the handlers.HTTPNotifier.
+type Store struct {
+	// Functions folds invocations of functions which are not deployed into
+	// metrics.UnknownFunctionName, so that any path under /function/ does
+	// not add an entry. Every function is recorded when nil.
+	Functions FunctionChecker
+
+	defaultNamespace string
+	maxFunctions     int
+
+	lock      sync.Mutex
+	functions map[string]*functionStats
+	dropped   bool
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewStore creates a Store, functions without a namespace in their URL are
+// recorded in defaultNamespace. maxFunctions bounds the number of functions
+// recorded, 0 is unlimited.
+func NewStore(defaultNamespace string, maxFunctions int) *Store {
+	return &Store{
+		defaultNamespace: defaultNamespace,
+		maxFunctions:     maxFunctions,
+		functions:        make(map[string]*functionStats),
+		now:              time.Now,
+	}
+}
+
+// Notify records a "completed" event for the function in originalURL
+func (s *Store) Notify(n types.HTTPNotification) {
+	if n.Event != "completed" {
+		return
+	}
+
+	serviceName := middleware.GetServiceName(n.OriginalURL)
+	if len(serviceName) == 0 {
+		return
+	}
+	name, namespace := middleware.GetNamespace(s.defaultNamespace, serviceName)
+	if s.Functions != nil && !s.Functions.FunctionExists(functionLabel(name, namespace)) {
+		// The namespace of an unknown function is not bounded either
+		name, namespace = metrics.UnknownFunctionName, ""
+	}
+
+	s.record(name, namespace, n.StatusCode, n.Duration, s.now())
+}
+
+func (s *Store) record(name, namespace string, statusCode int, duration time.Duration, at time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := functionLabel(name, namespace)
+	function, ok := s.functions[key]
+	if !ok {
+		if s.maxFunctions > 0 && len(s.functions) >= s.maxFunctions {
+			s.prune(at)
+		}
+		if s.maxFunctions > 0 && len(s.functions) >= s.maxFunctions {
+			if !s.dropped {
+				logger.Warn("not recording invocation statistics, too many functions are already recorded",
+					"function", key, "max_functions", s.maxFunctions)
+				s.dropped = true
+			}
+			return
+		}
+
+		function = &functionStats{name: name, namespace: namespace}
+		s.functions[key] = function
+	}
+
+	minute := at.Unix() / 60
+	index := minute % slotCount
+	current := function.slots[index]
+	if current == nil || current.minute != minute {
+		current = &slot{minute: minute}
+		function.slots[index] = current
+	}
+
+	current.count++
+	if statusCode >= 500 {
+		current.errors++
+	}
+	current.latency.add(duration.Seconds())
+
+	function.total++
+	function.last = at
+}
+
+// prune must be called with the lock held, it removes the functions which
+// have not been invoked within the longest window
+func (s *Store) prune(now time.Time) {
+	for key, function := range s.functions {
+		if now.Sub(function.last) > time.Minute*slotCount {
+			delete(s.functions, key)
+		}
+	}
+	s.dropped = false
+}
+
+// aggregate must be called with the lock held, it merges the slots within
+// window and returns the time they cover
+func (f *functionStats) aggregate(now time.Time, window time.Duration) (slot, time.Duration) {
+	minutes := int64(window / time.Minute)
+	if window%time.Minute != 0 {
+		minutes++
+	}
+	if minutes < 1 {
+		minutes = 1
+	}
+	if minutes > slotCount {
+		minutes = slotCount
+	}
+
+	res := slot{}
+	current := now.Unix() / 60
+	for minute := current - minutes + 1; minute <= current; minute++ {
+		s := f.slots[minute%slotCount]
+		if s == nil || s.minute != minute {
+			continue
+		}
+		res.count += s.count
+		res.errors += s.errors
+		res.latency.merge(&s.latency)
+	}
+
+	// The current minute is only partly over
+	elapsed := time.Duration(minutes-1)*time.Minute + now.Sub(time.Unix(current*60, 0))
+	if elapsed < time.Second {
+		elapsed = time.Second
+	}
+	return res, elapsed
+}
+
+// window must be called with the lock held
+func (f *functionStats) window(now time.Time, window time.Duration) WindowStats {
+	total, elapsed := f.aggregate(now, window)
+
+	res := WindowStats{
+		Window:      FormatWindow(window),
+		Invocations: total.count,
+		Errors:      total.errors,
+		RequestRate: float64(total.count) / elapsed.Seconds(),
+	}
+	if total.count > 0 {
+		res.ErrorRate = float64(total.errors) / float64(total.count)
+	}
+	if v, ok := total.latency.quantile(0.5); ok {
+		res.LatencyP50 = &v
+	}
+	if v, ok := total.latency.quantile(0.95); ok {
+		res.LatencyP95 = &v
+	}
+	if v, ok := total.latency.quantile(0.99); ok {
+		res.LatencyP99 = &v
+	}
+
+	return res
+}
+
+// Window returns the statistics of every recorded function over window
+func (s *Store) Window(window time.Duration) []FunctionStats {
+	now := s.now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	res := make([]FunctionStats, 0, len(s.functions))
+	for _, function := range s.functions {
+		res = append(res, FunctionStats{
+			Name:        function.name,
+			Namespace:   function.namespace,
+			WindowStats: function.window(now, window),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return functionLabel(res[i].Name, res[i].Namespace) < functionLabel(res[j].Name, res[j].Namespace)
+	})
+	return res
+}
+
+// Top returns up to limit functions invoked within window, ordered by their
+// request rate, by their errors and by their p99 latency
+func (s *Store) Top(window time.Duration, limit int) (byRate, byErrors, byLatency []FunctionStats) {
+	all := s.Window(window)
+
+	byRate = []FunctionStats{}
+	byErrors = []FunctionStats{}
+	byLatency = []FunctionStats{}
+	for _, function := range all {
+		if function.Invocations == 0 {
+			continue
+		}
+		byRate = append(byRate, function)
+		byLatency = append(byLatency, function)
+		if function.Errors > 0 {
+			byErrors = append(byErrors, function)
+		}
+	}
+
+	sort.SliceStable(byRate, func(i, j int) bool {
+		return byRate[i].RequestRate > byRate[j].RequestRate
+	})
+	sort.SliceStable(byErrors, func(i, j int) bool {
+		if byErrors[i].Errors == byErrors[j].Errors {
+			return byErrors[i].ErrorRate > byErrors[j].ErrorRate
+		}
+		return byErrors[i].Errors > byErrors[j].Errors
+	})
+	sort.SliceStable(byLatency, func(i, j int) bool {
+		return *byLatency[i].LatencyP99 > *byLatency[j].LatencyP99
+	})
+
+	return truncate(byRate, limit), truncate(byErrors, limit), truncate(byLatency, limit)
+}
+
+func truncate(functions []FunctionStats, limit int) []FunctionStats {
+	if limit > 0 && len(functions) > limit {
+		return functions[:limit]
+	}
+	return functions
+}
+
+// functionLabel returns the function_name recorded by the gateway
+func functionLabel(name, namespace string) string {
+	if len(namespace) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", name, namespace)
+}
+
+// formatWindow formats a window as "5m" or "24h"
+func FormatWindow(window time.Duration) string {
+	switch {
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", int(window/time.Hour))
+	case window%time.Minute == 0:
+		return fmt.Sprintf("%dm", int(window/time.Minute))
+	}
+	return window.String()
+}
@@ -0,0 +1,184 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package stats
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	providerTypes "github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/types"
+)
+
+func newTestStore(now *time.Time) *Store {
+	s := NewStore("openfaas-fn", 0)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func invoke(s *Store, url string, code int, duration time.Duration) {
+	s.Notify(types.HTTPNotification{OriginalURL: url, StatusCode: code, Event: "started"})
+	s.Notify(types.HTTPNotification{OriginalURL: url, StatusCode: code, Event: "completed", Duration: duration})
+}
+
+func Test_Store_Windows(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	s := newTestStore(&now)
+
+	// Two hours ago, within 24h only
+	now = now.Add(-time.Hour * 2)
+	invoke(s, "/function/echo", 200, time.Millisecond*100)
+
+	// Three minutes ago, within 5m
+	now = now.Add(time.Hour*2 - time.Minute*3)
+	invoke(s, "/function/echo", 500, time.Millisecond*100)
+
+	now = now.Add(time.Minute * 3)
+	for i := 0; i < 8; i++ {
+		invoke(s, "/function/echo", 200, time.Millisecond*10)
+	}
+	invoke(s, "/function/figlet.dev", 200, time.Second)
+
+	want := map[time.Duration]uint64{time.Minute: 8, time.Minute * 5: 9, time.Hour: 9, time.Hour * 24: 10}
+	for window, invocations := range want {
+		res := s.Window(window)
+		if len(res) != 2 || res[0].Name != "echo" || res[0].Namespace != "openfaas-fn" {
+			t.Fatalf("unexpected functions: %+v", res)
+		}
+		if res[0].Invocations != invocations {
+			t.Fatalf("window %s, want: %d invocations, got: %d", window, invocations, res[0].Invocations)
+		}
+	}
+
+	five := s.Window(time.Minute * 5)[0]
+	if five.Errors != 1 || five.ErrorRate != 1.0/9 {
+		t.Fatalf("unexpected errors: %+v", five.WindowStats)
+	}
+	// 4 complete minutes and 30s of the current minute
+	if want := 9.0 / 270; five.RequestRate != want {
+		t.Fatalf("request rate, want: %v, got: %v", want, five.RequestRate)
+	}
+	if five.LatencyP50 == nil || *five.LatencyP50 < 0.0099 || *five.LatencyP50 > 0.0101 {
+		t.Fatalf("unexpected p50: %v", five.LatencyP50)
+	}
+	if five.Window != "5m" {
+		t.Fatalf("window, want: 5m, got: %s", five.Window)
+	}
+
+	// A day later the slots have expired
+	now = now.Add(time.Hour * 25)
+	if res := s.Window(time.Hour * 24)[0]; res.Invocations != 0 || res.LatencyP99 != nil {
+		t.Fatalf("want no invocations, got: %+v", res.WindowStats)
+	}
+}
+
+func Test_Store_Top(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	for i := 0; i < 5; i++ {
+		invoke(s, "/function/busy", 200, time.Millisecond)
+	}
+	invoke(s, "/function/slow", 200, time.Second*5)
+	invoke(s, "/function/broken", 502, time.Millisecond*10)
+	invoke(s, "/function/broken", 200, time.Millisecond*10)
+
+	byRate, byErrors, byLatency := s.Top(time.Minute*5, 2)
+	if len(byRate) != 2 || byRate[0].Name != "busy" || byRate[1].Name != "broken" {
+		t.Fatalf("unexpected byRate: %+v", byRate)
+	}
+	if len(byErrors) != 1 || byErrors[0].Name != "broken" {
+		t.Fatalf("unexpected byErrors: %+v", byErrors)
+	}
+	if len(byLatency) != 2 || byLatency[0].Name != "slow" {
+		t.Fatalf("unexpected byLatency: %+v", byLatency)
+	}
+}
+
+func Test_Store_MaxFunctions(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+	s.maxFunctions = 1
+
+	invoke(s, "/function/echo", 200, time.Millisecond)
+	invoke(s, "/function/figlet", 200, time.Millisecond)
+	if res := s.Window(time.Hour); len(res) != 1 || res[0].Name != "echo" {
+		t.Fatalf("want only echo to be recorded, got: %+v", res)
+	}
+
+	// echo is pruned once it has not been invoked for a day
+	now = now.Add(time.Hour * 25)
+	invoke(s, "/function/figlet", 200, time.Millisecond)
+	if res := s.Window(time.Hour); len(res) != 1 || res[0].Name != "figlet" {
+		t.Fatalf("want figlet to replace echo, got: %+v", res)
+	}
+}
+
+func Test_Store_MetricsForListFunctions(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	s := newTestStore(&now)
+
+	for i := 0; i < 3; i++ {
+		invoke(s, "/function/echo", 200, time.Millisecond*20)
+	}
+	invoke(s, "/function/echo", 503, time.Millisecond*20)
+	invoke(s, "/function/echo.dev", 200, time.Millisecond*20)
+
+	list := func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]providerTypes.FunctionStatus{{Name: "echo", Namespace: "openfaas-fn"}})
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/system/functions?window=1m", nil)
+	metrics.AddMetricsHandler(list, s, "openfaas-fn").ServeHTTP(rr, req)
+
+	if rr.Header().Get(metrics.MetricsStatusHeader) != "" {
+		t.Fatalf("want every query to be answered, got: %s", rr.Body.String())
+	}
+
+	res := []struct {
+		InvocationCount float64                  `json:"invocationCount"`
+		Metrics         *metrics.FunctionMetrics `json:"metrics"`
+	}{}
+	json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res) != 1 || res[0].InvocationCount != 4 || res[0].Metrics == nil {
+		t.Fatalf("unexpected functions: %s", rr.Body.String())
+	}
+
+	m := res[0].Metrics
+	if m.RequestRate != 4.0/30 || m.ErrorRate != 0.25 {
+		t.Fatalf("unexpected rates: %+v", m)
+	}
+	if m.LatencyP99 == nil || *m.LatencyP99 < 0.0198 || *m.LatencyP99 > 0.0202 {
+		t.Fatalf("unexpected p99: %v", m.LatencyP99)
+	}
+}
+
+type knownFunctions map[string]bool
+
+func (k knownFunctions) FunctionExists(serviceName string) bool {
+	return k[serviceName]
+}
+
+func Test_Store_FoldsUnknownFunctions(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+	s.Functions = knownFunctions{"echo.openfaas-fn": true}
+
+	invoke(s, "/function/echo", 200, time.Millisecond)
+	invoke(s, "/function/missing", 200, time.Millisecond)
+	invoke(s, "/function/missing.dev", 200, time.Millisecond)
+
+	res := s.Window(time.Hour)
+	if len(res) != 2 || res[0].Name != metrics.UnknownFunctionName || res[0].Namespace != "" || res[0].Invocations != 2 {
+		t.Fatalf("want unknown functions folded into %s, got: %+v", metrics.UnknownFunctionName, res)
+	}
+	if res[1].Name != "echo" || res[1].Invocations != 1 {
+		t.Fatalf("want echo to be recorded, got: %+v", res[1])
+	}
+}
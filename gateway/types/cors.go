@@ -0,0 +1,83 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"strings"
+	"time"
+)
+
+// CORSPolicy describes which cross-origin requests are allowed for a
+// function or for the system API.
+type CORSPolicy struct {
+	// AllowedOrigins is a list of origins, or "*" for any origin
+	AllowedOrigins []string
+
+	// AllowedMethods is returned to the browser in response to a preflight
+	AllowedMethods []string
+
+	// AllowedHeaders is returned to the browser in response to a preflight
+	AllowedHeaders []string
+
+	// ExposedHeaders can be read by the browser from a response
+	ExposedHeaders []string
+
+	// AllowCredentials allows cookies and HTTP authentication to be sent
+	AllowCredentials bool
+
+	// MaxAge is how long the browser may cache the result of a preflight
+	MaxAge time.Duration
+}
+
+// AllowsAnyOrigin returns true when the allowed origins include "*"
+func (p CORSPolicy) AllowsAnyOrigin() bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowOrigin returns the value for Access-Control-Allow-Origin for the
+// given origin, or false when the origin is not allowed.
+func (p CORSPolicy) AllowOrigin(origin string) (string, bool) {
+	if len(origin) == 0 {
+		return "", false
+	}
+
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+
+	return "", false
+}
+
+// AllowMethod returns true when the method can be used for a cross-origin request
+func (p CORSPolicy) AllowMethod(method string) bool {
+	for _, allowed := range p.AllowedMethods {
+		if allowed == "*" || strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCSV splits a comma-separated value into a list of trimmed,
+// non-empty items.
+func ParseCSV(val string) []string {
+	items := []string{}
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
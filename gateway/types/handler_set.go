@@ -44,4 +44,16 @@ type HandlerSet struct {
 	NamespaceListerHandler http.HandlerFunc
 
 	NamespaceMutatorHandler http.HandlerFunc
+
+	// ScalingEvents lists the recent scaling decisions for functions
+	ScalingEvents http.HandlerFunc
+
+	// Peer shares the scaling cache with other gateway replicas
+	Peer http.HandlerFunc
+
+	// Stats lists the top functions from the invocation statistics
+	Stats http.HandlerFunc
+
+	// LogLevel reads and changes the level of the gateway's logs
+	LogLevel http.HandlerFunc
 }
@@ -0,0 +1,38 @@
+// Copyright (c) OpenFaaS Author(s). All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import "time"
+
+// HTTPNotification describes a request handled by the gateway, it is passed
+// to each HTTPNotifier
+type HTTPNotification struct {
+	Method string
+
+	// URL is the path forwarded upstream
+	URL string
+
+	// OriginalURL is the path requested of the gateway, i.e. /function/echo
+	OriginalURL string
+
+	StatusCode int
+
+	// Event is "started" before the request is forwarded and "completed"
+	// once the response has been written
+	Event string
+
+	// Duration is only set for the "completed" event
+	Duration time.Duration
+
+	// RequestBytes and ResponseBytes are the sizes of the request and
+	// response bodies, they are only set for the "completed" event
+	RequestBytes  int64
+	ResponseBytes int64
+
+	// CallID is the X-Call-Id of the request
+	CallID string
+
+	// TraceID is the ID of the request's trace, when the trace is sampled
+	TraceID string
+}
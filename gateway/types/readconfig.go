@@ -5,10 +5,15 @@ package types
 
 import (
 	"fmt"
+	"log/slog"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"time"
+
+	"github.com/openfaas/faas/gateway/metrics"
+	"github.com/openfaas/faas/gateway/pkg/logging"
 )
 
 // OsEnv implements interface to wrap os.Getenv
@@ -25,6 +30,25 @@ type HasEnv interface {
 	Getenv(key string) string
 }
 
+// Backends which the metrics in the list of functions are queried from
+const (
+	// MetricsQueryBackendPrometheus queries Prometheus
+	MetricsQueryBackendPrometheus = "prometheus"
+
+	// MetricsQueryBackendMemory queries the gateway's own invocation
+	// statistics, for installations without Prometheus
+	MetricsQueryBackendMemory = "memory"
+)
+
+// Discovery of the other gateway replicas which share the scaling cache
+const (
+	// PeerDiscoveryStatic reads the addresses of peers from a list
+	PeerDiscoveryStatic = "static"
+
+	// PeerDiscoveryDNS resolves the addresses of peers from a host name
+	PeerDiscoveryDNS = "dns"
+)
+
 // ReadConfig constitutes config from env variables
 type ReadConfig struct {
 }
@@ -60,6 +84,23 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 
 	defaultDuration := time.Second * 60
 
+	cfg.LogFormat = logging.FormatText
+	if logFormat := hasEnv.Getenv("log_format"); len(logFormat) > 0 {
+		if logFormat != logging.FormatText && logFormat != logging.FormatJSON {
+			return nil, fmt.Errorf("invalid value for log_format: %s, want %s or %s", logFormat, logging.FormatText, logging.FormatJSON)
+		}
+		cfg.LogFormat = logFormat
+	}
+
+	cfg.LogLevel = slog.LevelInfo
+	if logLevel := hasEnv.Getenv("log_level"); len(logLevel) > 0 {
+		val, err := logging.ParseLevel(logLevel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for log_level: %s", logLevel)
+		}
+		cfg.LogLevel = val
+	}
+
 	cfg.ReadTimeout = parseIntOrDurationValue(hasEnv.Getenv("read_timeout"), defaultDuration)
 	cfg.WriteTimeout = parseIntOrDurationValue(hasEnv.Getenv("write_timeout"), defaultDuration)
 	cfg.UpstreamTimeout = parseIntOrDurationValue(hasEnv.Getenv("upstream_timeout"), defaultDuration)
@@ -113,6 +154,14 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 		cfg.NATSChannel = &v
 	}
 
+	if maxBodyBytes := hasEnv.Getenv("async_max_body_bytes"); len(maxBodyBytes) > 0 {
+		val, err := strconv.ParseInt(maxBodyBytes, 10, 64)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for async_max_body_bytes: %s", maxBodyBytes)
+		}
+		cfg.AsyncMaxBodyBytes = val
+	}
+
 	prometheusPort := hasEnv.Getenv("faas_prometheus_port")
 	if len(prometheusPort) > 0 {
 		prometheusPortVal, err := strconv.Atoi(prometheusPort)
@@ -128,6 +177,22 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 		cfg.PrometheusHost = prometheusHost
 	}
 
+	// faas_prometheus_url takes precedence over the host and port
+	cfg.PrometheusURL = fmt.Sprintf("http://%s:%d", cfg.PrometheusHost, cfg.PrometheusPort)
+	if prometheusURL := hasEnv.Getenv("faas_prometheus_url"); len(prometheusURL) > 0 {
+		u, err := url.Parse(prometheusURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, fmt.Errorf("invalid value for faas_prometheus_url: %s", prometheusURL)
+		}
+		cfg.PrometheusURL = prometheusURL
+	}
+	cfg.PrometheusTimeout = parseIntOrDurationValue(hasEnv.Getenv("faas_prometheus_timeout"), time.Second*5)
+	cfg.PrometheusBearerTokenFile = hasEnv.Getenv("faas_prometheus_bearer_token_file")
+	cfg.PrometheusUsername = hasEnv.Getenv("faas_prometheus_username")
+	cfg.PrometheusPasswordFile = hasEnv.Getenv("faas_prometheus_password_file")
+	cfg.PrometheusCAFile = hasEnv.Getenv("faas_prometheus_ca_file")
+	cfg.PrometheusTLSInsecure = parseBoolValue(hasEnv.Getenv("faas_prometheus_tls_insecure"))
+
 	cfg.UseBasicAuth = parseBoolValue(hasEnv.Getenv("basic_auth"))
 
 	secretPath := hasEnv.Getenv("secret_mount_path")
@@ -136,6 +201,198 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 	}
 	cfg.SecretMountPath = secretPath
 	cfg.ScaleFromZero = parseBoolValue(hasEnv.Getenv("scale_from_zero"))
+	cfg.ColdStartTimeout = parseIntOrDurationValue(hasEnv.Getenv("cold_start_timeout"), 0)
+
+	if peerDiscovery := hasEnv.Getenv("peer_discovery"); len(peerDiscovery) > 0 {
+		if peerDiscovery != PeerDiscoveryStatic && peerDiscovery != PeerDiscoveryDNS {
+			return nil, fmt.Errorf("invalid value for peer_discovery: %s, must be %q or %q", peerDiscovery, PeerDiscoveryStatic, PeerDiscoveryDNS)
+		}
+		cfg.PeerDiscovery = peerDiscovery
+
+		cfg.Peers = ParseCSV(hasEnv.Getenv("peers"))
+		if len(cfg.Peers) == 0 {
+			return nil, fmt.Errorf("peers is required for peer_discovery")
+		}
+		if peerDiscovery == PeerDiscoveryDNS && len(cfg.Peers) != 1 {
+			return nil, fmt.Errorf("invalid value for peers: %s, must be a single host:port for dns", hasEnv.Getenv("peers"))
+		}
+
+		cfg.PeerAddress = hasEnv.Getenv("peer_address")
+		if len(cfg.PeerAddress) == 0 {
+			return nil, fmt.Errorf("peer_address is required for peer_discovery")
+		}
+
+		// Peers write into the scaling cache, so they must always
+		// authenticate, even when basic_auth is off
+		cfg.PeerTokenFile = hasEnv.Getenv("peer_token_file")
+		if len(cfg.PeerTokenFile) == 0 {
+			return nil, fmt.Errorf("peer_token_file is required for peer_discovery")
+		}
+	}
+	cfg.PeerGossipInterval = parseIntOrDurationValue(hasEnv.Getenv("peer_gossip_interval"), time.Millisecond*50)
+
+	cfg.ColdStartMaxHeld = 1000
+	if coldStartMaxHeld := hasEnv.Getenv("cold_start_max_held"); len(coldStartMaxHeld) > 0 {
+		val, err := strconv.Atoi(coldStartMaxHeld)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for cold_start_max_held: %s", coldStartMaxHeld)
+		}
+		cfg.ColdStartMaxHeld = val
+	}
+
+	if maxReplicas := hasEnv.Getenv("max_replicas"); len(maxReplicas) > 0 {
+		val, err := strconv.ParseUint(maxReplicas, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for max_replicas: %s", maxReplicas)
+		}
+		cfg.MaxReplicas = val
+	}
+
+	cfg.AlertScaleUpWindow = parseIntOrDurationValue(hasEnv.Getenv("alert_scale_up_window"), 0)
+	cfg.AlertScaleDownWindow = parseIntOrDurationValue(hasEnv.Getenv("alert_scale_down_window"), 0)
+	cfg.AlertScaleUpCooldown = parseIntOrDurationValue(hasEnv.Getenv("alert_scale_up_cooldown"), 0)
+	cfg.AlertScaleDownCooldown = parseIntOrDurationValue(hasEnv.Getenv("alert_scale_down_cooldown"), 0)
+
+	if maxStepUp := hasEnv.Getenv("alert_max_step_up"); len(maxStepUp) > 0 {
+		val, err := strconv.ParseUint(maxStepUp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for alert_max_step_up: %s", maxStepUp)
+		}
+		cfg.AlertMaxStepUp = val
+	}
+
+	if maxStepDown := hasEnv.Getenv("alert_max_step_down"); len(maxStepDown) > 0 {
+		val, err := strconv.ParseUint(maxStepDown, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for alert_max_step_down: %s", maxStepDown)
+		}
+		cfg.AlertMaxStepDown = val
+	}
+
+	cfg.AlertDedupeWindow = parseIntOrDurationValue(hasEnv.Getenv("alert_dedupe_window"), time.Second*5)
+
+	cfg.ScaleToZero = parseBoolValue(hasEnv.Getenv("scale_to_zero"))
+	cfg.ScaleToZeroInterval = parseIntOrDurationValue(hasEnv.Getenv("scale_to_zero_interval"), time.Second*30)
+	cfg.ScaleToZeroIdleDuration = parseIntOrDurationValue(hasEnv.Getenv("scale_to_zero_idle_duration"), time.Minute*15)
+
+	cfg.Autoscaler = parseBoolValue(hasEnv.Getenv("autoscaler"))
+	cfg.AutoscalerInterval = parseIntOrDurationValue(hasEnv.Getenv("autoscaler_interval"), time.Second*15)
+	cfg.AutoscalerScaleDownWindow = parseIntOrDurationValue(hasEnv.Getenv("autoscaler_scale_down_window"), time.Minute*5)
+
+	cfg.Prewarm = parseBoolValue(hasEnv.Getenv("prewarm"))
+	cfg.PrewarmInterval = parseIntOrDurationValue(hasEnv.Getenv("prewarm_interval"), time.Minute)
+	cfg.PrewarmLead = parseIntOrDurationValue(hasEnv.Getenv("prewarm_lead"), time.Minute*5)
+	cfg.PrewarmStateFile = hasEnv.Getenv("prewarm_state_file")
+
+	cfg.PrewarmThreshold = 1
+	if threshold := hasEnv.Getenv("prewarm_threshold"); len(threshold) > 0 {
+		val, err := strconv.ParseFloat(threshold, 64)
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("invalid value for prewarm_threshold: %s", threshold)
+		}
+		cfg.PrewarmThreshold = val
+	}
+
+	cfg.PrewarmMinWeeks = 2
+	if minWeeks := hasEnv.Getenv("prewarm_min_weeks"); len(minWeeks) > 0 {
+		val, err := strconv.Atoi(minWeeks)
+		if err != nil || val < 1 {
+			return nil, fmt.Errorf("invalid value for prewarm_min_weeks: %s", minWeeks)
+		}
+		cfg.PrewarmMinWeeks = val
+	}
+
+	cfg.ScaleSchedule = parseBoolValue(hasEnv.Getenv("scale_schedule"))
+	cfg.ScaleScheduleInterval = parseIntOrDurationValue(hasEnv.Getenv("scale_schedule_interval"), time.Minute)
+	cfg.ScaleSchedulePolicy = hasEnv.Getenv("scale_schedule_policy")
+
+	cfg.ScaleScheduleTimezone = "UTC"
+	if timezone := hasEnv.Getenv("scale_schedule_timezone"); len(timezone) > 0 {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid value for scale_schedule_timezone: %s", timezone)
+		}
+		cfg.ScaleScheduleTimezone = timezone
+	}
+
+	cfg.ScalingHistorySize = 1000
+	if historySize := hasEnv.Getenv("scaling_history_size"); len(historySize) > 0 {
+		val, err := strconv.Atoi(historySize)
+		if err != nil || val < 1 {
+			return nil, fmt.Errorf("invalid value for scaling_history_size: %s", historySize)
+		}
+		cfg.ScalingHistorySize = val
+	}
+	cfg.ScalingHistoryFile = hasEnv.Getenv("scaling_history_file")
+
+	cfg.FunctionCacheMaxEntries = 5000
+	if maxEntries := hasEnv.Getenv("function_cache_max_entries"); len(maxEntries) > 0 {
+		val, err := strconv.Atoi(maxEntries)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for function_cache_max_entries: %s", maxEntries)
+		}
+		cfg.FunctionCacheMaxEntries = val
+	}
+	cfg.FunctionCacheNotFoundTTL = parseIntOrDurationValue(hasEnv.Getenv("function_cache_not_found_ttl"), time.Second*2)
+
+	cfg.TracingEndpoint = hasEnv.Getenv("tracing_otlp_endpoint")
+	cfg.TracingServiceName = "gateway"
+	if serviceName := hasEnv.Getenv("tracing_service_name"); len(serviceName) > 0 {
+		cfg.TracingServiceName = serviceName
+	}
+	cfg.TracingSampleRatio = 1
+	if sampleRatio := hasEnv.Getenv("tracing_sample_ratio"); len(sampleRatio) > 0 {
+		val, err := strconv.ParseFloat(sampleRatio, 64)
+		if err != nil || val < 0 || val > 1 {
+			return nil, fmt.Errorf("invalid value for tracing_sample_ratio: %s", sampleRatio)
+		}
+		cfg.TracingSampleRatio = val
+	}
+
+	if buckets := hasEnv.Getenv("function_histogram_buckets"); len(buckets) > 0 {
+		val, err := metrics.ParseBuckets(buckets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for function_histogram_buckets: %s", err)
+		}
+		cfg.FunctionHistogramBuckets = val
+	}
+	cfg.MetricsIncludeUsage = parseBoolValue(hasEnv.Getenv("metrics_include_usage"))
+	cfg.MetricsStaleSeriesGrace = parseIntOrDurationValue(hasEnv.Getenv("metrics_stale_series_grace"), time.Minute*5)
+
+	cfg.MetricsQueryBackend = MetricsQueryBackendPrometheus
+	if backend := hasEnv.Getenv("metrics_query_backend"); len(backend) > 0 {
+		if backend != MetricsQueryBackendPrometheus && backend != MetricsQueryBackendMemory {
+			return nil, fmt.Errorf("invalid value for metrics_query_backend: %s, must be %q or %q", backend, MetricsQueryBackendPrometheus, MetricsQueryBackendMemory)
+		}
+		cfg.MetricsQueryBackend = backend
+	}
+
+	// The memory backend answers from the invocation statistics
+	cfg.InvocationStats = parseBoolValue(hasEnv.Getenv("invocation_stats")) || cfg.MetricsQueryBackend == MetricsQueryBackendMemory
+	cfg.InvocationStatsMaxFunctions = 1000
+	if maxFunctions := hasEnv.Getenv("invocation_stats_max_functions"); len(maxFunctions) > 0 {
+		val, err := strconv.Atoi(maxFunctions)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for invocation_stats_max_functions: %s", maxFunctions)
+		}
+		cfg.InvocationStatsMaxFunctions = val
+	}
+
+	cfg.FunctionHistogramNative = parseBoolValue(hasEnv.Getenv("function_histogram_native"))
+	cfg.FunctionHistogramNativeFactor = 1.1
+	if factor := hasEnv.Getenv("function_histogram_native_bucket_factor"); len(factor) > 0 {
+		val, err := strconv.ParseFloat(factor, 64)
+		if err != nil || val <= 1 {
+			return nil, fmt.Errorf("invalid value for function_histogram_native_bucket_factor: %s", factor)
+		}
+		cfg.FunctionHistogramNativeFactor = val
+	}
+	if maxFunctions := hasEnv.Getenv("function_histogram_max_functions"); len(maxFunctions) > 0 {
+		val, err := strconv.Atoi(maxFunctions)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("invalid value for function_histogram_max_functions: %s", maxFunctions)
+		}
+		cfg.FunctionHistogramMaxFunctions = val
+	}
 
 	cfg.MaxIdleConns = 1024
 	cfg.MaxIdleConnsPerHost = 1024
@@ -165,12 +422,44 @@ func (ReadConfig) Read(hasEnv HasEnv) (*GatewayConfig, error) {
 
 	cfg.Namespace = hasEnv.Getenv("function_namespace")
 
+	if systemCORSOrigins := ParseCSV(hasEnv.Getenv("system_cors_allow_origins")); len(systemCORSOrigins) > 0 {
+		policy := CORSPolicy{
+			AllowedOrigins: systemCORSOrigins,
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+			AllowedHeaders: []string{"Content-Type", "Authorization"},
+			MaxAge:         parseIntOrDurationValue(hasEnv.Getenv("system_cors_max_age"), time.Minute*10),
+		}
+
+		if methods := ParseCSV(hasEnv.Getenv("system_cors_allow_methods")); len(methods) > 0 {
+			policy.AllowedMethods = methods
+		}
+		if headers := ParseCSV(hasEnv.Getenv("system_cors_allow_headers")); len(headers) > 0 {
+			policy.AllowedHeaders = headers
+		}
+		policy.ExposedHeaders = ParseCSV(hasEnv.Getenv("system_cors_expose_headers"))
+		policy.AllowCredentials = parseBoolValue(hasEnv.Getenv("system_cors_allow_credentials"))
+
+		// Any site could make credentialed requests to the system API
+		if policy.AllowCredentials && policy.AllowsAnyOrigin() {
+			return nil, fmt.Errorf("invalid value for system_cors_allow_credentials: credentials cannot be allowed when system_cors_allow_origins is *")
+		}
+
+		cfg.SystemCORS = &policy
+	}
+
 	return &cfg, nil
 }
 
 // GatewayConfig provides config for the API Gateway server process
 type GatewayConfig struct {
 
+	// LogFormat is text or json
+	LogFormat string
+
+	// LogLevel is the level logged at startup, it can be changed at runtime
+	// through /system/log-level
+	LogLevel slog.Level
+
 	// HTTP timeout for reading a request from clients.
 	ReadTimeout time.Duration
 
@@ -198,12 +487,37 @@ type GatewayConfig struct {
 	// NATSChannel is the name of the NATS Streaming channel used for asynchronous function invocations.
 	NATSChannel *string
 
+	// AsyncMaxBodyBytes is the largest body accepted onto the queue, 0 means
+	// no limit
+	AsyncMaxBodyBytes int64
+
 	// Host to connect to Prometheus.
 	PrometheusHost string
 
 	// Port to connect to Prometheus.
 	PrometheusPort int
 
+	// PrometheusURL is the URL of Prometheus including any path prefix,
+	// built from PrometheusHost and PrometheusPort when not set
+	PrometheusURL string
+
+	// PrometheusTimeout bounds each query to Prometheus
+	PrometheusTimeout time.Duration
+
+	// PrometheusBearerTokenFile holds a token sent with each query
+	PrometheusBearerTokenFile string
+
+	// PrometheusUsername and PrometheusPasswordFile set basic auth for
+	// each query
+	PrometheusUsername     string
+	PrometheusPasswordFile string
+
+	// PrometheusCAFile holds the certificates Prometheus is verified with
+	PrometheusCAFile string
+
+	// PrometheusTLSInsecure skips the verification of Prometheus' certificate
+	PrometheusTLSInsecure bool
+
 	// If set, reads secrets from file-system for enabling basic auth.
 	UseBasicAuth bool
 
@@ -213,6 +527,178 @@ type GatewayConfig struct {
 	// Enable the gateway to scale any service from 0 replicas to its configured "min replicas"
 	ScaleFromZero bool
 
+	// ColdStartTimeout is how long requests are held while a function scales up
+	// from zero, unless overridden by the function's label
+	ColdStartTimeout time.Duration
+
+	// PeerDiscovery enables sharing of the scaling cache and scale ups in
+	// progress with other gateway replicas, PeerDiscoveryStatic or
+	// PeerDiscoveryDNS, disabled when empty
+	PeerDiscovery string
+
+	// Peers are the addresses of the gateway replicas for PeerDiscoveryStatic,
+	// or a single host:port to resolve for PeerDiscoveryDNS
+	Peers []string
+
+	// PeerAddress is the address of this gateway as seen by its peers
+	PeerAddress string
+
+	// PeerTokenFile holds the token shared by the gateway replicas, which
+	// is required on every request to /system/peer/
+	PeerTokenFile string
+
+	// PeerGossipInterval is how often cache entries are sent to peers
+	PeerGossipInterval time.Duration
+
+	// ColdStartMaxHeld is the maximum number of requests held per function while
+	// it scales up from zero, 0 means no limit
+	ColdStartMaxHeld int
+
+	// MaxReplicas is a ceiling on the replicas of any function, regardless of
+	// its labels, 0 means no ceiling
+	MaxReplicas uint64
+
+	// AlertScaleUpWindow is the default time a higher replica count must be
+	// recommended by alerts before a function is scaled up
+	AlertScaleUpWindow time.Duration
+
+	// AlertScaleDownWindow is the default time a lower replica count must be
+	// recommended by alerts before a function is scaled down
+	AlertScaleDownWindow time.Duration
+
+	// AlertScaleUpCooldown is the default minimum time between scale ups
+	AlertScaleUpCooldown time.Duration
+
+	// AlertScaleDownCooldown is the default minimum time after any change of
+	// replicas before a scale down
+	AlertScaleDownCooldown time.Duration
+
+	// AlertMaxStepUp is the default for the most replicas added at once, 0
+	// means no limit
+	AlertMaxStepUp uint64
+
+	// AlertMaxStepDown is the default for the most replicas removed at once,
+	// 0 means no limit
+	AlertMaxStepDown uint64
+
+	// AlertDedupeWindow is how long a notification of an alert with the same
+	// fingerprint is ignored for after it was handled, 0 disables it
+	AlertDedupeWindow time.Duration
+
+	// ScaleToZero enables the idler, which scales functions labelled with
+	// com.openfaas.scale.zero=true to zero replicas when idle
+	ScaleToZero bool
+
+	// ScaleToZeroInterval is the interval between checks for idle functions
+	ScaleToZeroInterval time.Duration
+
+	// ScaleToZeroIdleDuration is the default idle time before a function is scaled to zero
+	ScaleToZeroIdleDuration time.Duration
+
+	// Autoscaler enables the built-in autoscaler, which scales functions on the
+	// load observed by the gateway instead of alerts from AlertManager
+	Autoscaler bool
+
+	// AutoscalerInterval is the interval between evaluations of the autoscaler
+	AutoscalerInterval time.Duration
+
+	// AutoscalerScaleDownWindow is how long the autoscaler waits before removing replicas
+	AutoscalerScaleDownWindow time.Duration
+
+	// Prewarm enables the pre-warmer, which scales functions up from zero
+	// ahead of the traffic forecast from their invocations in past weeks
+	Prewarm bool
+
+	// PrewarmInterval is the interval between evaluations of the forecasts
+	PrewarmInterval time.Duration
+
+	// PrewarmLead is how far ahead of the forecast traffic functions are scaled up
+	PrewarmLead time.Duration
+
+	// PrewarmThreshold is the number of invocations forecast in an hour for
+	// which a function is scaled up
+	PrewarmThreshold float64
+
+	// PrewarmMinWeeks is the number of weeks of history needed for a forecast
+	PrewarmMinWeeks int
+
+	// PrewarmStateFile keeps the learned history across restarts
+	PrewarmStateFile string
+
+	// ScaleSchedule enables the scheduler, which applies the minimum replicas
+	// of each function's active schedule profile
+	ScaleSchedule bool
+
+	// ScaleScheduleInterval is the interval between checks of the schedules
+	ScaleScheduleInterval time.Duration
+
+	// ScaleSchedulePolicy is the path to a JSON file with the schedules of
+	// functions which do not set their own
+	ScaleSchedulePolicy string
+
+	// ScaleScheduleTimezone is used for schedules which do not set a time zone
+	ScaleScheduleTimezone string
+
+	// ScalingHistorySize is the number of scaling decisions kept for
+	// /system/scaling/events
+	ScalingHistorySize int
+
+	// ScalingHistoryFile keeps the scaling decisions across restarts
+	ScalingHistoryFile string
+
+	// FunctionCacheMaxEntries bounds the number of functions in each
+	// function cache, 0 means no bound
+	FunctionCacheMaxEntries int
+
+	// FunctionCacheNotFoundTTL is how long a function which the provider
+	// does not have is cached for, 0 disables negative caching
+	FunctionCacheNotFoundTTL time.Duration
+
+	// TracingEndpoint is the OTLP/HTTP endpoint of a collector which spans
+	// are sent to i.e. http://127.0.0.1:4318, tracing is disabled when empty
+	TracingEndpoint string
+
+	// TracingServiceName is the service.name of the gateway's spans
+	TracingServiceName string
+
+	// TracingSampleRatio is the ratio of new traces which are recorded
+	TracingSampleRatio float64
+
+	// MetricsIncludeUsage requests the CPU and memory usage of each function
+	// from the provider for the exporter's gauges
+	MetricsIncludeUsage bool
+
+	// MetricsStaleSeriesGrace is how long a function can be missing from
+	// the provider before its series are deleted, 0 keeps them
+	MetricsStaleSeriesGrace time.Duration
+
+	// MetricsQueryBackend is where the metrics in the list of functions are
+	// queried from, MetricsQueryBackendPrometheus or MetricsQueryBackendMemory
+	MetricsQueryBackend string
+
+	// InvocationStats records rolling invocation statistics in memory and
+	// serves them from /system/stats
+	InvocationStats bool
+
+	// InvocationStatsMaxFunctions bounds the functions with statistics, 0
+	// is unlimited
+	InvocationStatsMaxFunctions int
+
+	// FunctionHistogramBuckets are the default buckets of gateway_functions_seconds,
+	// the Prometheus default buckets are used when empty
+	FunctionHistogramBuckets []float64
+
+	// FunctionHistogramNative records gateway_functions_seconds as a native
+	// histogram as well as with classic buckets
+	FunctionHistogramNative bool
+
+	// FunctionHistogramNativeFactor is the growth factor between native buckets
+	FunctionHistogramNativeFactor float64
+
+	// FunctionHistogramMaxFunctions bounds the functions with their own series
+	// in gateway_functions_seconds, the rest are recorded as "_overflow", 0 is unlimited
+	FunctionHistogramMaxFunctions int
+
 	// MaxIdleConns with a default value of 1024, can be used for tuning HTTP proxy performance
 	MaxIdleConns int
 
@@ -227,6 +713,9 @@ type GatewayConfig struct {
 
 	// Namespace for endpoints
 	Namespace string
+
+	// SystemCORS is the CORS policy for the /system/ API, disabled when nil
+	SystemCORS *CORSPolicy
 }
 
 // UseNATS Use NATSor not
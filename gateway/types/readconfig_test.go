@@ -5,8 +5,11 @@ package types
 
 import (
 	"fmt"
+	"log/slog"
 	"testing"
 	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/logging"
 )
 
 type EnvBucket struct {
@@ -436,3 +439,412 @@ func TestRead_LogsProviderURL(t *testing.T) {
 		}
 	})
 }
+
+func TestRead_SystemCORS(t *testing.T) {
+	defaults := NewEnvBucket()
+
+	t.Run("disabled by default", func(t *testing.T) {
+		readConfig := ReadConfig{}
+		config, _ := readConfig.Read(defaults)
+		if config.SystemCORS != nil {
+			t.Fatalf("config.SystemCORS, want: nil, got: %v", config.SystemCORS)
+		}
+	})
+
+	t.Run("enabled with origins", func(t *testing.T) {
+		defaults.Setenv("system_cors_allow_origins", "https://a.example.com,https://b.example.com")
+		defaults.Setenv("system_cors_allow_credentials", "true")
+		defaults.Setenv("system_cors_max_age", "30")
+
+		readConfig := ReadConfig{}
+		config, _ := readConfig.Read(defaults)
+		if config.SystemCORS == nil {
+			t.Fatalf("config.SystemCORS, want a policy, got: nil")
+		}
+		if len(config.SystemCORS.AllowedOrigins) != 2 {
+			t.Fatalf("config.SystemCORS.AllowedOrigins, want: 2, got: %d", len(config.SystemCORS.AllowedOrigins))
+		}
+		if !config.SystemCORS.AllowCredentials {
+			t.Fatalf("config.SystemCORS.AllowCredentials, want: true")
+		}
+		if config.SystemCORS.MaxAge != time.Second*30 {
+			t.Fatalf("config.SystemCORS.MaxAge, want: %s, got: %s", time.Second*30, config.SystemCORS.MaxAge)
+		}
+	})
+
+	t.Run("credentials rejected for any origin", func(t *testing.T) {
+		defaults.Setenv("system_cors_allow_origins", "*")
+		defaults.Setenv("system_cors_allow_credentials", "true")
+
+		readConfig := ReadConfig{}
+		if _, err := readConfig.Read(defaults); err == nil {
+			t.Fatalf("want an error for credentials with any origin")
+		}
+	})
+}
+
+func TestRead_ScaleSchedule(t *testing.T) {
+	defaults := NewEnvBucket()
+
+	readConfig := ReadConfig{}
+	config, _ := readConfig.Read(defaults)
+	if config.ScaleSchedule {
+		t.Fatalf("config.ScaleSchedule, want: false")
+	}
+	if config.ScaleScheduleTimezone != "UTC" {
+		t.Fatalf("config.ScaleScheduleTimezone, want: UTC, got: %s", config.ScaleScheduleTimezone)
+	}
+	if config.ScaleScheduleInterval != time.Minute {
+		t.Fatalf("config.ScaleScheduleInterval, want: %s, got: %s", time.Minute, config.ScaleScheduleInterval)
+	}
+
+	defaults.Setenv("scale_schedule", "true")
+	defaults.Setenv("scale_schedule_timezone", "Asia/Tokyo")
+	config, _ = readConfig.Read(defaults)
+	if !config.ScaleSchedule || config.ScaleScheduleTimezone != "Asia/Tokyo" {
+		t.Fatalf("want scheduled scaling in Asia/Tokyo, got: %v, %s", config.ScaleSchedule, config.ScaleScheduleTimezone)
+	}
+
+	defaults.Setenv("scale_schedule_timezone", "Nowhere/Special")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for an invalid scale_schedule_timezone")
+	}
+}
+
+func TestRead_Prewarm(t *testing.T) {
+	defaults := NewEnvBucket()
+
+	readConfig := ReadConfig{}
+	config, _ := readConfig.Read(defaults)
+	if config.Prewarm {
+		t.Fatalf("config.Prewarm, want: false")
+	}
+	if config.PrewarmLead != time.Minute*5 || config.PrewarmThreshold != 1 || config.PrewarmMinWeeks != 2 {
+		t.Fatalf("want default lead, threshold and min weeks, got: %s, %.1f, %d", config.PrewarmLead, config.PrewarmThreshold, config.PrewarmMinWeeks)
+	}
+
+	defaults.Setenv("prewarm", "true")
+	defaults.Setenv("prewarm_threshold", "2.5")
+	config, _ = readConfig.Read(defaults)
+	if !config.Prewarm || config.PrewarmThreshold != 2.5 {
+		t.Fatalf("want pre-warming with a threshold of 2.5, got: %v, %.1f", config.Prewarm, config.PrewarmThreshold)
+	}
+
+	defaults.Setenv("prewarm_threshold", "0")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for a prewarm_threshold of 0")
+	}
+}
+
+func TestRead_AlertScalingPolicy(t *testing.T) {
+	defaults := NewEnvBucket()
+	defaults.Setenv("alert_scale_down_window", "5m")
+	defaults.Setenv("alert_max_step_down", "2")
+
+	readConfig := ReadConfig{}
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if config.AlertScaleDownWindow != time.Minute*5 {
+		t.Fatalf("config.AlertScaleDownWindow, want: %s, got: %s", time.Minute*5, config.AlertScaleDownWindow)
+	}
+	if config.AlertMaxStepDown != 2 || config.AlertMaxStepUp != 0 {
+		t.Fatalf("want max step down of 2 and no max step up, got: %d, %d", config.AlertMaxStepDown, config.AlertMaxStepUp)
+	}
+
+	defaults.Setenv("alert_max_step_up", "-1")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for an invalid alert_max_step_up")
+	}
+}
+
+func TestRead_ScalingHistory(t *testing.T) {
+	defaults := NewEnvBucket()
+
+	readConfig := ReadConfig{}
+	config, _ := readConfig.Read(defaults)
+	if config.ScalingHistorySize != 1000 || len(config.ScalingHistoryFile) > 0 {
+		t.Fatalf("want a default size of 1000 without a file, got: %d, %q", config.ScalingHistorySize, config.ScalingHistoryFile)
+	}
+
+	defaults.Setenv("scaling_history_size", "50")
+	defaults.Setenv("scaling_history_file", "/tmp/scaling.jsonl")
+	config, _ = readConfig.Read(defaults)
+	if config.ScalingHistorySize != 50 || config.ScalingHistoryFile != "/tmp/scaling.jsonl" {
+		t.Fatalf("want size 50 and a file, got: %d, %q", config.ScalingHistorySize, config.ScalingHistoryFile)
+	}
+
+	defaults.Setenv("scaling_history_size", "0")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for a scaling_history_size of 0")
+	}
+}
+
+func TestRead_AlertDedupeWindow(t *testing.T) {
+	defaults := NewEnvBucket()
+
+	readConfig := ReadConfig{}
+	config, _ := readConfig.Read(defaults)
+	if config.AlertDedupeWindow != time.Second*5 {
+		t.Fatalf("config.AlertDedupeWindow, want: %s, got: %s", time.Second*5, config.AlertDedupeWindow)
+	}
+
+	defaults.Setenv("alert_dedupe_window", "0")
+	config, _ = readConfig.Read(defaults)
+	if config.AlertDedupeWindow != 0 {
+		t.Fatalf("config.AlertDedupeWindow, want: 0, got: %s", config.AlertDedupeWindow)
+	}
+}
+
+func TestRead_PeerDiscovery(t *testing.T) {
+	defaults := NewEnvBucket()
+
+	readConfig := ReadConfig{}
+	config, _ := readConfig.Read(defaults)
+	if len(config.PeerDiscovery) > 0 {
+		t.Fatalf("config.PeerDiscovery, want: disabled, got: %s", config.PeerDiscovery)
+	}
+
+	defaults.Setenv("peer_discovery", "static")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error without peers")
+	}
+
+	defaults.Setenv("peers", "10.0.0.1:8080, 10.0.0.2:8080")
+	defaults.Setenv("peer_address", "10.0.0.1:8080")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error without peer_token_file")
+	}
+
+	defaults.Setenv("peer_token_file", "/var/secrets/peer-token")
+	config, err := readConfig.Read(defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(config.Peers) != 2 || config.Peers[1] != "10.0.0.2:8080" {
+		t.Fatalf("config.Peers, want two peers, got: %v", config.Peers)
+	}
+	if config.PeerTokenFile != "/var/secrets/peer-token" {
+		t.Fatalf("config.PeerTokenFile, want: /var/secrets/peer-token, got: %s", config.PeerTokenFile)
+	}
+
+	defaults.Setenv("peer_discovery", "dns")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for more than one host for dns")
+	}
+
+	defaults.Setenv("peer_discovery", "gossip")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for an unknown peer_discovery")
+	}
+}
+
+func TestRead_FunctionCache(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.FunctionCacheMaxEntries != 5000 || config.FunctionCacheNotFoundTTL != time.Second*2 {
+		t.Fatalf("want defaults of 5000 entries and 2s, got: %d, %s", config.FunctionCacheMaxEntries, config.FunctionCacheNotFoundTTL)
+	}
+
+	defaults.Setenv("function_cache_max_entries", "100")
+	defaults.Setenv("function_cache_not_found_ttl", "5s")
+	config, _ = readConfig.Read(defaults)
+	if config.FunctionCacheMaxEntries != 100 || config.FunctionCacheNotFoundTTL != time.Second*5 {
+		t.Fatalf("want 100 entries and 5s, got: %d, %s", config.FunctionCacheMaxEntries, config.FunctionCacheNotFoundTTL)
+	}
+
+	defaults.Setenv("function_cache_not_found_ttl", "0")
+	config, _ = readConfig.Read(defaults)
+	if config.FunctionCacheNotFoundTTL != 0 {
+		t.Fatalf("want negative caching disabled, got: %s", config.FunctionCacheNotFoundTTL)
+	}
+
+	defaults.Setenv("function_cache_max_entries", "-1")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for a negative function_cache_max_entries")
+	}
+}
+
+func TestRead_Tracing(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if len(config.TracingEndpoint) > 0 || config.TracingServiceName != "gateway" || config.TracingSampleRatio != 1 {
+		t.Fatalf("want tracing disabled with defaults, got: %q, %q, %v", config.TracingEndpoint, config.TracingServiceName, config.TracingSampleRatio)
+	}
+
+	defaults.Setenv("tracing_otlp_endpoint", "http://127.0.0.1:4318")
+	defaults.Setenv("tracing_service_name", "gateway-eu")
+	defaults.Setenv("tracing_sample_ratio", "0.25")
+	config, _ = readConfig.Read(defaults)
+	if config.TracingEndpoint != "http://127.0.0.1:4318" || config.TracingServiceName != "gateway-eu" || config.TracingSampleRatio != 0.25 {
+		t.Fatalf("unexpected tracing config: %q, %q, %v", config.TracingEndpoint, config.TracingServiceName, config.TracingSampleRatio)
+	}
+
+	defaults.Setenv("tracing_sample_ratio", "2")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for a tracing_sample_ratio above 1")
+	}
+}
+
+func TestRead_FunctionHistogram(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if len(config.FunctionHistogramBuckets) > 0 || config.FunctionHistogramNative || config.FunctionHistogramMaxFunctions != 0 {
+		t.Fatalf("want default buckets without native histograms, got: %v, %v, %d", config.FunctionHistogramBuckets, config.FunctionHistogramNative, config.FunctionHistogramMaxFunctions)
+	}
+
+	defaults.Setenv("function_histogram_buckets", "0.005, 0.05, 0.5, 5, 60")
+	defaults.Setenv("function_histogram_native", "true")
+	defaults.Setenv("function_histogram_native_bucket_factor", "1.05")
+	defaults.Setenv("function_histogram_max_functions", "500")
+	config, _ = readConfig.Read(defaults)
+	if len(config.FunctionHistogramBuckets) != 5 || config.FunctionHistogramBuckets[4] != 60 {
+		t.Fatalf("unexpected buckets: %v", config.FunctionHistogramBuckets)
+	}
+	if !config.FunctionHistogramNative || config.FunctionHistogramNativeFactor != 1.05 || config.FunctionHistogramMaxFunctions != 500 {
+		t.Fatalf("unexpected histogram config: %v, %v, %d", config.FunctionHistogramNative, config.FunctionHistogramNativeFactor, config.FunctionHistogramMaxFunctions)
+	}
+
+	defaults.Setenv("function_histogram_buckets", "1,0.5")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for buckets out of order")
+	}
+}
+
+func TestRead_MetricsIncludeUsage(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.MetricsIncludeUsage {
+		t.Fatalf("want usage to be excluded by default")
+	}
+
+	defaults.Setenv("metrics_include_usage", "true")
+	config, _ = readConfig.Read(defaults)
+	if !config.MetricsIncludeUsage {
+		t.Fatalf("want usage to be included")
+	}
+}
+
+func TestRead_MetricsStaleSeriesGrace(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.MetricsStaleSeriesGrace != time.Minute*5 {
+		t.Fatalf("want a grace of 5m by default, got: %s", config.MetricsStaleSeriesGrace)
+	}
+
+	defaults.Setenv("metrics_stale_series_grace", "30s")
+	config, _ = readConfig.Read(defaults)
+	if config.MetricsStaleSeriesGrace != time.Second*30 {
+		t.Fatalf("want a grace of 30s, got: %s", config.MetricsStaleSeriesGrace)
+	}
+}
+
+func TestRead_InvocationStats(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.InvocationStats || config.MetricsQueryBackend != MetricsQueryBackendPrometheus || config.InvocationStatsMaxFunctions != 1000 {
+		t.Fatalf("unexpected defaults: %v, %s, %d", config.InvocationStats, config.MetricsQueryBackend, config.InvocationStatsMaxFunctions)
+	}
+
+	defaults.Setenv("metrics_query_backend", "memory")
+	defaults.Setenv("invocation_stats_max_functions", "50")
+	config, _ = readConfig.Read(defaults)
+	if !config.InvocationStats || config.InvocationStatsMaxFunctions != 50 {
+		t.Fatalf("want the memory backend to enable invocation stats, got: %v, %d", config.InvocationStats, config.InvocationStatsMaxFunctions)
+	}
+
+	defaults.Setenv("metrics_query_backend", "influxdb")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for an unknown backend")
+	}
+}
+
+func TestRead_PrometheusClient(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	defaults.Setenv("faas_prometheus_host", "prom")
+	config, _ := readConfig.Read(defaults)
+	if config.PrometheusURL != "http://prom:9090" || config.PrometheusTimeout != time.Second*5 {
+		t.Fatalf("unexpected defaults: %s, %s", config.PrometheusURL, config.PrometheusTimeout)
+	}
+
+	defaults.Setenv("faas_prometheus_url", "https://monitoring.example.com/prometheus")
+	defaults.Setenv("faas_prometheus_timeout", "10s")
+	defaults.Setenv("faas_prometheus_bearer_token_file", "/var/run/secrets/token")
+	defaults.Setenv("faas_prometheus_tls_insecure", "true")
+	config, _ = readConfig.Read(defaults)
+	if config.PrometheusURL != "https://monitoring.example.com/prometheus" || config.PrometheusTimeout != time.Second*10 {
+		t.Fatalf("unexpected URL or timeout: %s, %s", config.PrometheusURL, config.PrometheusTimeout)
+	}
+	if config.PrometheusBearerTokenFile != "/var/run/secrets/token" || !config.PrometheusTLSInsecure {
+		t.Fatalf("unexpected auth or TLS: %s, %v", config.PrometheusBearerTokenFile, config.PrometheusTLSInsecure)
+	}
+
+	defaults.Setenv("faas_prometheus_url", "monitoring.example.com")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for a URL without a scheme")
+	}
+}
+
+func TestRead_AsyncMaxBodyBytes(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.AsyncMaxBodyBytes != 0 {
+		t.Fatalf("want no limit by default, got: %d", config.AsyncMaxBodyBytes)
+	}
+
+	defaults.Setenv("async_max_body_bytes", "1048576")
+	config, _ = readConfig.Read(defaults)
+	if config.AsyncMaxBodyBytes != 1048576 {
+		t.Fatalf("want: %d, got: %d", 1048576, config.AsyncMaxBodyBytes)
+	}
+
+	defaults.Setenv("async_max_body_bytes", "-1")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for a negative limit")
+	}
+}
+
+func TestRead_Logging(t *testing.T) {
+	defaults := NewEnvBucket()
+	readConfig := ReadConfig{}
+
+	config, _ := readConfig.Read(defaults)
+	if config.LogFormat != logging.FormatText || config.LogLevel != slog.LevelInfo {
+		t.Fatalf("unexpected defaults: %s, %s", config.LogFormat, config.LogLevel)
+	}
+
+	defaults.Setenv("log_format", "json")
+	defaults.Setenv("log_level", "DEBUG")
+	config, _ = readConfig.Read(defaults)
+	if config.LogFormat != logging.FormatJSON || config.LogLevel != slog.LevelDebug {
+		t.Fatalf("want json at debug, got: %s at %s", config.LogFormat, config.LogLevel)
+	}
+
+	defaults.Setenv("log_level", "verbose")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for an unknown level")
+	}
+
+	defaults.Setenv("log_level", "info")
+	defaults.Setenv("log_format", "logfmt")
+	if _, err := readConfig.Read(defaults); err == nil {
+		t.Fatalf("want an error for an unknown format")
+	}
+}
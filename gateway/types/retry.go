@@ -1,10 +1,13 @@
 package types
 
 import (
-	"log"
 	"time"
+
+	"github.com/openfaas/faas/gateway/pkg/logging"
 )
 
+var retryLogger = logging.For(logging.ComponentGateway)
+
 type routine func(attempt int) error
 
 func Retry(r routine, label string, attempts int, interval time.Duration) error {
@@ -14,7 +17,7 @@ func Retry(r routine, label string, attempts int, interval time.Duration) error
 		res := r(i)
 		if res != nil {
 			err = res
-			log.Printf("[%s]: %d/%d, error: %s\n", label, i, attempts, res)
+			retryLogger.Warn("attempt failed", "label", label, "attempt", i, "attempts", attempts, "error", res)
 		} else {
 			err = nil
 			break